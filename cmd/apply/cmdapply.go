@@ -5,58 +5,143 @@ package apply
 
 import (
 	"context"
+	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/klog/klogr"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/i18n"
 	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
 )
 
 // NewCmdApply creates the `apply` command
-func NewCmdApply(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
-	applier := apply.NewApplier(f, ioStreams)
-	printer := &apply.BasicPrinter{
-		IOStreams: ioStreams,
-	}
+func NewCmdApply(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	watcher := apply.NewContinuousApplier(f, ioStreams)
+	applier := watcher.Applier
+	applier.Logger = klogr.New()
+	printerOptions := apply.NewPrinterOptions()
+	confirmer := apply.NewConfirmer(ioStreams)
+	applier.PreRunGate = confirmer.Gate
+	prune := true
+	watch := false
 
 	cmd := &cobra.Command{
 		Use:                   "apply (FILENAME... | DIRECTORY)",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Apply a configuration to a resource by filename or stdin"),
 		Run: func(cmd *cobra.Command, args []string) {
-			paths := args
-			cmdutil.CheckErr(applier.Initialize(cmd, paths))
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
+			// The deprecated --no-prune flag sets NoPrune directly.
+			if !prune {
+				applier.NoPrune = true
+			}
 
-			// Create a context with the provided timout from the cobra parameter.
-			ctx, cancel := context.WithTimeout(context.Background(), applier.StatusOptions.Timeout)
+			// An interrupt stops the run once the resources being
+			// applied are, and the inventory records what was applied.
+			ctx, cancel := apply.WithInterrupt(context.Background(), ioStreams.ErrOut)
 			defer cancel()
+
+			paths := args
+			// The deferred calls don't run when the command exits with
+			// an error, so the manifests are removed before it does.
+			if watch {
+				err = watcher.Initialize(cmd, paths)
+				if err != nil {
+					_ = watcher.Close()
+					cmdutil.CheckErr(err)
+				}
+				defer watcher.Close()
+				watcher.Run(ctx, func(ch <-chan event.Event) {
+					printer, err := printerOptions.ToPrinter(ioStreams)
+					if err != nil {
+						_ = watcher.Close()
+						cmdutil.CheckErr(err)
+					}
+					printer.Print(printErrors(ch, ioStreams.ErrOut))
+				})
+				return
+			}
+			err = applier.Initialize(cmd, paths)
+			if err != nil {
+				_ = applier.Close()
+				cmdutil.CheckErr(err)
+			}
+
 			// Run the applier. It will return a channel where we can receive updates
-			// to keep track of progress and any issues.
+			// to keep track of progress and any issues. The wait for the resources
+			// to reconcile is limited by the timeout from the StatusOptions.
 			ch := applier.Run(ctx)
 
 			// The printer will print updates from the channel. It will block
-			// until the channel is closed.
-			printer.Print(ch)
+			// until the channel is closed, or until the first error, which
+			// is only reported once the manifests are removed.
+			var runErr error
+			printer.Print(firstError(ch, &runErr))
+			_ = applier.Close()
+			apply.CheckErr(ioStreams.ErrOut, runErr)
 		},
 	}
 
 	cmd.Flags().BoolVar(&applier.NoPrune, "no-prune", applier.NoPrune, "If true, do not prune previously applied objects.")
+	_ = cmd.Flags().MarkDeprecated("no-prune", "use --prune=false instead")
+	cmd.Flags().BoolVar(&prune, "prune", prune, "If false, do not prune previously applied objects. The inventory is still updated, so they are pruned by the next run with pruning enabled.")
+	cmd.Flags().BoolVar(&watch, "watch-apply", watch, "If true, keep applying the configuration whenever the "+
+		"manifest files change, or after every --watch-interval. Failed runs are reported, and the next run is "+
+		"started anyway. The manifests are rendered and downloaded again for every run, while stdin is only "+
+		"read once.")
+	cmd.Flags().DurationVar(&watcher.Interval, "watch-interval", watcher.Interval, "With --watch-apply, "+
+		"the time between the starts of two runs. If 0, a run only starts when the manifest files change.")
+	cmd.Flags().BoolVar(&applier.Diff, "diff", applier.Diff, "If true, print the diff between the live and local version of each resource before it is applied.")
 	cmdutil.CheckErr(applier.SetFlags(cmd))
+	printerOptions.AddFlags(cmd)
+	confirmer.AddFlags(cmd)
 
 	// The following flags are added, but hidden because other code
 	// depend on them when parsing flags. These flags are hidden and unused.
-	var unusedBool bool
-	cmd.Flags().BoolVar(&unusedBool, "dry-run", unusedBool, "NOT USED")
-	_ = cmd.Flags().MarkHidden("dry-run")
 	cmdutil.AddValidateFlags(cmd)
 	_ = cmd.Flags().MarkHidden("validate")
-	// Server-side flags are hidden for now.
 	cmdutil.AddServerSideApplyFlags(cmd)
-	_ = cmd.Flags().MarkHidden("server-side")
-	_ = cmd.Flags().MarkHidden("force-conflicts")
-	_ = cmd.Flags().MarkHidden("field-manager")
 
 	return cmd
 }
+
+// printErrors prints the error events of a run instead of passing
+// them on, since the printers exit on errors, and the next run
+// should still start.
+func printErrors(ch <-chan event.Event, w io.Writer) <-chan event.Event {
+	out := make(chan event.Event)
+	go func() {
+		defer close(out)
+		for e := range ch {
+			if e.Type == event.ErrorType {
+				fmt.Fprintf(w, "error: %v\n", e.ErrorEvent.Err)
+				continue
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+// firstError passes the events on until the first error event, and
+// keeps its error instead of passing it on, since the printers exit
+// on errors before the command can clean up.
+func firstError(ch <-chan event.Event, err *error) <-chan event.Event {
+	out := make(chan event.Event)
+	go func() {
+		defer close(out)
+		for e := range ch {
+			if e.Type == event.ErrorType {
+				*err = e.ErrorEvent.Err
+				return
+			}
+			out <- e
+		}
+	}()
+	return out
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package commands provides the kapply commands for embedding in other
+// CLIs. Every constructor takes the factory used to talk to the cluster
+// and the streams to read from and print to, so the commands can be
+// added under the root command of another CLI with its own kubeconfig
+// flags and output handling.
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/cmd/apply"
+	"sigs.k8s.io/cli-utils/cmd/destroy"
+	"sigs.k8s.io/cli-utils/cmd/diff"
+	"sigs.k8s.io/cli-utils/cmd/drift"
+	"sigs.k8s.io/cli-utils/cmd/initcmd"
+	"sigs.k8s.io/cli-utils/cmd/preview"
+	"sigs.k8s.io/cli-utils/cmd/rollback"
+	"sigs.k8s.io/cli-utils/cmd/status"
+	"sigs.k8s.io/cli-utils/cmd/undo"
+	"sigs.k8s.io/cli-utils/cmd/validate"
+)
+
+// NewApplyCommand returns the command that applies a package
+// and prunes the resources that have been removed from it.
+func NewApplyCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return apply.NewCmdApply(f, ioStreams)
+}
+
+// NewPreviewCommand returns the command that shows what apply or
+// destroy would do, without changing anything in the cluster.
+func NewPreviewCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return preview.NewCmdPreview(f, ioStreams)
+}
+
+// NewDiffCommand returns the command that prints the difference
+// between a package and the live state of the cluster.
+func NewDiffCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return diff.NewCmdDiff(f, ioStreams)
+}
+
+// NewDriftCommand returns the command that reports the resources
+// whose live state differs from a package.
+func NewDriftCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return drift.NewCmdDrift(f, ioStreams)
+}
+
+// NewDestroyCommand returns the command that deletes all the
+// resources of a package.
+func NewDestroyCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return destroy.NewCmdDestroy(f, ioStreams)
+}
+
+// NewRollbackCommand returns the command that applies a previous
+// revision of a package again.
+func NewRollbackCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return rollback.NewCmdRollback(f, ioStreams)
+}
+
+// NewStatusCommand returns the command that shows the status
+// of the resources of a package.
+func NewStatusCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return status.NewCmdStatus(f, ioStreams)
+}
+
+// NewInitCommand returns the command that adds the grouping
+// object template to a package.
+func NewInitCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return initcmd.NewCmdInit(f, ioStreams)
+}
+
+// NewUndoCommand returns the command that undoes the
+// changes of the last apply of a package.
+func NewUndoCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return undo.NewCmdUndo(f, ioStreams)
+}
+
+// NewValidateCommand returns the command that checks a
+// package for problems without applying it.
+func NewValidateCommand(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return validate.NewCmdValidate(f, ioStreams)
+}
+
+// NewFactory returns a factory that talks to the cluster selected by
+// the config flags, including the kubeconfig, the context and the
+// user and groups to impersonate with --as and --as-group.
+func NewFactory(configFlags *genericclioptions.ConfigFlags) util.Factory {
+	return util.NewFactory(util.NewMatchVersionFlags(configFlags))
+}
+
+// AddCommandsWithConfigFlags adds the config flags as persistent flags
+// of the parent command, so they can be set on every subcommand, and
+// adds all the commands with a factory built from them. The factory
+// is returned for use by other commands of the embedding CLI.
+func AddCommandsWithConfigFlags(parent *cobra.Command, configFlags *genericclioptions.ConfigFlags,
+	ioStreams genericclioptions.IOStreams) util.Factory {
+	configFlags.AddFlags(parent.PersistentFlags())
+	matchVersionFlags := util.NewMatchVersionFlags(configFlags)
+	matchVersionFlags.AddFlags(parent.PersistentFlags())
+	f := util.NewFactory(matchVersionFlags)
+	AddCommands(parent, f, ioStreams)
+	return f
+}
+
+// AddCommands adds all the commands to the parent command, which is
+// usually the root command of the embedding CLI.
+func AddCommands(parent *cobra.Command, f util.Factory, ioStreams genericclioptions.IOStreams) {
+	parent.AddCommand(
+		NewInitCommand(f, ioStreams),
+		NewApplyCommand(f, ioStreams),
+		NewDiffCommand(f, ioStreams),
+		NewDriftCommand(f, ioStreams),
+		NewDestroyCommand(f, ioStreams),
+		NewPreviewCommand(f, ioStreams),
+		NewRollbackCommand(f, ioStreams),
+		NewStatusCommand(f, ioStreams),
+		NewUndoCommand(f, ioStreams),
+		NewValidateCommand(f, ioStreams),
+	)
+}
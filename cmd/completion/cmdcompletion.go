@@ -0,0 +1,46 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package completion
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// NewCmdCompletion creates the `completion` command, which prints the
+// shell completion script for the root command.
+func NewCmdCompletion(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion SHELL",
+		Short: "Output shell completion code for the specified shell (bash, zsh, fish or powershell)",
+		Long: `Output shell completion code for the specified shell (bash, zsh, fish or powershell).
+The values of flags like --inventory-id and --namespace are completed by querying the cluster.`,
+		Example: `  # Load the completion code for bash into the current shell
+  source <(kapply completion bash)`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(runCompletion(cmd.Root(), ioStreams, args[0]))
+		},
+	}
+	return cmd
+}
+
+func runCompletion(root *cobra.Command, ioStreams genericclioptions.IOStreams, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletion(ioStreams.Out)
+	case "zsh":
+		return root.GenZshCompletion(ioStreams.Out)
+	case "fish":
+		return root.GenFishCompletion(ioStreams.Out, true)
+	case "powershell":
+		return root.GenPowerShellCompletion(ioStreams.Out)
+	default:
+		return fmt.Errorf("unsupported shell type %q", shell)
+	}
+}
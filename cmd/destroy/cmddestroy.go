@@ -6,24 +6,28 @@ package destroy
 import (
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/klog/klogr"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/i18n"
 	"sigs.k8s.io/cli-utils/pkg/apply"
 )
 
 // NewCmdDestroy creates the `destroy` command
-func NewCmdDestroy(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+func NewCmdDestroy(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
 	destroyer := apply.NewDestroyer(f, ioStreams)
-	printer := &apply.BasicPrinter{
-		IOStreams: ioStreams,
-	}
+	destroyer.Logger = klogr.New()
+	printerOptions := apply.NewPrinterOptions()
+	confirmer := apply.NewConfirmer(ioStreams)
+	destroyer.PreRunGate = confirmer.Gate
 
 	cmd := &cobra.Command{
 		Use:                   "destroy (FILENAME... | DIRECTORY)",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Destroy all the resources related to configuration"),
 		Run: func(cmd *cobra.Command, args []string) {
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
 			paths := args
 			cmdutil.CheckErr(destroyer.Initialize(cmd, paths))
 
@@ -38,11 +42,11 @@ func NewCmdDestroy(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra
 	}
 
 	cmdutil.CheckErr(destroyer.SetFlags(cmd))
+	printerOptions.AddFlags(cmd)
+	confirmer.AddFlags(cmd)
 
 	// The following flags are added, but hidden because other code
 	// dependencies when parsing flags. These flags are hidden and unused.
-	var unusedBool bool
-	cmd.Flags().BoolVar(&unusedBool, "dry-run", unusedBool, "NOT USED")
 	cmdutil.AddValidateFlags(cmd)
 	_ = cmd.Flags().MarkHidden("validate")
 	// Server-side flags are hidden for now.
@@ -4,12 +4,65 @@
 package diff
 
 import (
+	"context"
+
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/kubectl/pkg/cmd/diff"
+	"k8s.io/klog/klogr"
 	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/common"
 )
 
+// NewCmdDiff creates the `diff` command. It applies the configuration
+// with a dry-run and prints the difference to the live state, including
+// the resources that will be pruned.
 func NewCmdDiff(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
-	return diff.NewCmdDiff(f, ioStreams)
+	differ := apply.NewDiffer(f, ioStreams)
+	differ.Applier.Logger = klogr.New()
+	printerOptions := apply.NewPrinterOptions()
+	prune := true
+	serverDryRun := true
+
+	cmd := &cobra.Command{
+		Use:                   "diff (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Diff the configuration against the live state of the cluster"),
+		Run: func(cmd *cobra.Command, args []string) {
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
+			differ.NoPrune = !prune
+			differ.DryRunStrategy = common.DryRunClient
+			if serverDryRun {
+				differ.DryRunStrategy = common.DryRunServer
+			}
+			cmdutil.CheckErr(differ.Initialize(cmd, args))
+			defer differ.Applier.Close()
+
+			// The printer will print the diffs from the channel. It will
+			// block until the channel is closed.
+			printer.Print(differ.Run(context.Background()))
+		},
+	}
+
+	cmd.Flags().BoolVar(&prune, "prune", prune, "If false, do not show the previously applied objects that will be pruned.")
+	cmd.Flags().BoolVar(&serverDryRun, "server-dry-run", serverDryRun, "If true, the resources are applied on the server "+
+		"in dry-run mode and the result is compared to the live state. If false, the local configuration is compared "+
+		"to the live state.")
+	cmdutil.CheckErr(differ.SetFlags(cmd))
+	// This command always does a dry-run, which is chosen
+	// with --server-dry-run instead.
+	_ = cmd.Flags().MarkHidden("dry-run")
+	printerOptions.AddFlags(cmd)
+
+	// The following flags are added, but hidden because other code
+	// depend on them when parsing flags. These flags are hidden and unused.
+	cmdutil.AddValidateFlags(cmd)
+	_ = cmd.Flags().MarkHidden("validate")
+	cmdutil.AddServerSideApplyFlags(cmd)
+
+	return cmd
 }
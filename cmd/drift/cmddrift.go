@@ -0,0 +1,75 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+)
+
+// NewCmdDrift creates the `drift` command. It compares the live state
+// of the cluster with the package without changing anything, prints the
+// resources that are modified, missing or extraneous, and exits with the
+// drift exit code if there are any.
+func NewCmdDrift(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	detector := apply.NewDriftDetector(f, ioStreams)
+	showDiff := true
+
+	cmd := &cobra.Command{
+		Use:                   "drift (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Report the resources whose live state differs from the configuration"),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(detector.Initialize(cmd, args))
+			drifts, err := detector.Detect(context.Background())
+			apply.CheckErr(ioStreams.ErrOut, err)
+			if len(drifts) == 0 {
+				fmt.Fprintln(ioStreams.Out, "no drift found")
+				return
+			}
+			for _, d := range drifts {
+				fmt.Fprintln(ioStreams.Out, d.String())
+				if showDiff && d.Diff != "" {
+					fmt.Fprint(ioStreams.Out, d.Diff)
+				}
+			}
+			apply.CheckErr(ioStreams.ErrOut, &apply.DriftError{Drifts: drifts})
+		},
+	}
+
+	cmd.Flags().BoolVar(&showDiff, "show-diff", showDiff, "If true, the difference between the live state and "+
+		"the configuration is printed for the modified resources.")
+	cmdutil.CheckErr(detector.SetFlags(cmd))
+	for name, usage := range map[string]string{"filename": "to apply", "kustomize": "and apply"} {
+		flag := cmd.Flags().Lookup(name)
+		flag.Usage = strings.Replace(flag.Usage, usage, strings.Replace(usage, "apply", "compare", 1), 1)
+	}
+	// The following flags are added, but hidden because other code
+	// depend on them when parsing flags. These flags are hidden and unused.
+	cmdutil.AddValidateFlags(cmd)
+	_ = cmd.Flags().MarkHidden("validate")
+	cmdutil.AddServerSideApplyFlags(cmd)
+
+	// Nothing is applied, so the flags that only
+	// affect the apply are hidden.
+	for _, name := range []string{"allow-protected-deletion", "audit", "audit-package-version",
+		"audit-pipeline-id", "audit-user", "check-permissions", "dry-run", "field-manager", "force-conflicts",
+		"history-limit", "inventory-policy", "no-wait", "on-duplicate", "protected-namespaces",
+		"prune-propagation-policy", "prune-timeout", "reconcile-timeout", "selector", "server-side",
+		"status-poll-interval", "wait", "wait-for-kinds"} {
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.Flags().MarkHidden(name)
+		}
+	}
+
+	return cmd
+}
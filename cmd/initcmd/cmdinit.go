@@ -0,0 +1,41 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package initcmd
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/config"
+)
+
+// NewCmdInit creates the `init` command, which writes the grouping
+// object template to a package directory.
+func NewCmdInit(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	initOptions := config.NewInitOptions(ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   "init DIRECTORY",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Create the grouping object template for a package directory"),
+		Args:                  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// The namespace is only set in the template if it was
+			// explicitly provided.
+			namespace, enforceNamespace, err := f.ToRawKubeConfigLoader().Namespace()
+			cmdutil.CheckErr(err)
+			if enforceNamespace {
+				initOptions.Namespace = namespace
+			}
+			cmdutil.CheckErr(initOptions.Complete(args))
+			cmdutil.CheckErr(initOptions.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&initOptions.InventoryID, "inventory-id", initOptions.InventoryID,
+		"Identifier for the group of applied resources. Generated if not provided.")
+	return cmd
+}
@@ -10,11 +10,10 @@ import (
 
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/kubectl/pkg/cmd/util"
-	"sigs.k8s.io/cli-utils/cmd/apply"
-	"sigs.k8s.io/cli-utils/cmd/destroy"
-	"sigs.k8s.io/cli-utils/cmd/diff"
-	"sigs.k8s.io/cli-utils/cmd/preview"
+	"k8s.io/klog"
+	"sigs.k8s.io/cli-utils/cmd/commands"
+	"sigs.k8s.io/cli-utils/cmd/completion"
+	"sigs.k8s.io/cli-utils/pkg/apply"
 
 	// This is here rather than in the libraries because of
 	// https://github.com/kubernetes-sigs/kustomize/issues/2060
@@ -29,13 +28,9 @@ var cmd = &cobra.Command{
 
 func main() {
 	// configure kubectl dependencies and flags
-	flags := cmd.Flags()
 	kubeConfigFlags := genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag()
-	kubeConfigFlags.AddFlags(flags)
-	matchVersionKubeConfigFlags := util.NewMatchVersionFlags(kubeConfigFlags)
-	matchVersionKubeConfigFlags.AddFlags(cmd.PersistentFlags())
+	klog.InitFlags(nil)
 	cmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
-	f := util.NewFactory(matchVersionKubeConfigFlags)
 
 	ioStreams := genericclioptions.IOStreams{
 		In:     os.Stdin,
@@ -44,14 +39,12 @@ func main() {
 	}
 
 	names := []string{"apply", "preview", "diff", "destroy"}
-	applyCmd := apply.NewCmdApply(f, ioStreams)
-	updateHelp(names, applyCmd)
-	previewCmd := preview.NewCmdPreview(f, ioStreams)
-	updateHelp(names, previewCmd)
-	diffCmd := diff.NewCmdDiff(f, ioStreams)
-	updateHelp(names, diffCmd)
-	destroyCmd := destroy.NewCmdDestroy(f, ioStreams)
-	cmd.AddCommand(applyCmd, diffCmd, destroyCmd, previewCmd)
+	f := commands.AddCommandsWithConfigFlags(cmd, kubeConfigFlags, ioStreams)
+	cmd.AddCommand(completion.NewCmdCompletion(ioStreams))
+	_ = cmd.RegisterFlagCompletionFunc("namespace", apply.NamespaceCompletionFunc(f))
+	for _, c := range cmd.Commands() {
+		updateHelp(names, c)
+	}
 
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
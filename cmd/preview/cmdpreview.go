@@ -8,46 +8,70 @@ import (
 
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/klogr"
 	"k8s.io/kubectl/pkg/cmd/util"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/i18n"
 	"sigs.k8s.io/cli-utils/pkg/apply"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/common"
 )
 
-// NewCmdApply creates the `apply` command
+// NewCmdPreview creates the `preview` command. It runs a dry-run of
+// the apply, including prune, or of the destroy if --destroy is set.
 func NewCmdPreview(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
 	applier := apply.NewApplier(f, ioStreams)
+	applier.Logger = klogr.New()
 	destroyer := apply.NewDestroyer(f, ioStreams)
+	destroyer.Logger = klogr.New()
 
-	printer := &apply.BasicPrinter{
-		IOStreams: ioStreams,
-	}
+	printerOptions := apply.NewPrinterOptions()
+	prune := true
+	var previewDestroy, serverDryRun bool
 
 	cmd := &cobra.Command{
 		Use:                   "preview (-f FILENAME | -k DIRECTORY)",
 		DisableFlagsInUseLine: true,
-		Short:                 i18n.T("Preview the apply of a configuration"),
+		Short:                 i18n.T("Preview the apply or destroy of a configuration"),
 		Args:                  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
+			// The deprecated --no-prune flag sets NoPrune directly.
+			if !prune {
+				applier.NoPrune = true
+			}
+
+			drs := common.DryRunClient
+			if serverDryRun {
+				drs = common.DryRunServer
+			}
+
 			var ch <-chan event.Event
-			cmdutil.CheckErr(destroyer.Initialize(cmd, args))
-			// if destroy flag is set in preview, transmit it to destroyer DryRun flag
-			// and pivot execution to destroy with dry-run
-			if !destroyer.DryRun {
-				// Set DryRun option true before Initialize. DryRun is propagated to
+			// if destroy flag is set in preview, pivot execution to
+			// destroy with dry-run
+			if !previewDestroy {
+				// Set the DryRunStrategy before Initialize. It is propagated to
 				// ApplyOptions and PruneOptions in Initialize.
-				applier.DryRun = true
+				applier.DryRunStrategy = drs
 				cmdutil.CheckErr(applier.Initialize(cmd, args))
-
-				// Create a context with the provided timout from the cobra parameter.
-				ctx, cancel := context.WithTimeout(context.Background(), applier.StatusOptions.Timeout)
-				defer cancel()
+				defer applier.Close()
 
 				// Run the applier. It will return a channel where we can receive updates
-				// to keep track of progress and any issues.
-				ch = applier.Run(ctx)
+				// to keep track of progress and any issues. The wait for the resources
+				// to reconcile is limited by the timeout from the StatusOptions.
+				ch = applier.Run(context.Background())
 			} else {
+				destroyer.DryRunStrategy = drs
+				// Show the whole teardown plan up front, unless the
+				// output is meant to be parsed or kept short.
+				if printerOptions.Output != apply.JSONOutput && !printerOptions.Quiet && !printerOptions.Summary {
+					destroyer.PlanFn = func(plan apply.Plan) {
+						apply.PrintDeletePlan(ioStreams.Out, plan)
+					}
+				}
+				cmdutil.CheckErr(destroyer.Initialize(cmd, args))
 				ch = destroyer.Run()
 			}
 
@@ -58,21 +82,24 @@ func NewCmdPreview(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra
 	}
 
 	cmd.Flags().BoolVar(&applier.NoPrune, "no-prune", applier.NoPrune, "If true, do not prune previously applied objects.")
+	_ = cmd.Flags().MarkDeprecated("no-prune", "use --prune=false instead")
+	cmd.Flags().BoolVar(&prune, "prune", prune, "If false, do not prune previously applied objects. The inventory is still updated, so they are pruned by the next run with pruning enabled.")
+	cmd.Flags().BoolVar(&applier.Diff, "diff", applier.Diff, "If true, print the diff between the live and local version of each resource before it is applied.")
 	cmdutil.CheckErr(applier.SetFlags(cmd))
+	// This command always does a dry-run, which is chosen
+	// with --server-dry-run instead.
+	_ = cmd.Flags().MarkHidden("dry-run")
+	printerOptions.AddFlags(cmd)
 
 	// The following flags are added, but hidden because other code
 	// dependend on them when parsing flags. These flags are hidden and unused.
-	var unusedBool bool
-	cmd.Flags().BoolVar(&unusedBool, "dry-run", unusedBool, "NOT USED")
-	cmd.Flags().BoolVar(&destroyer.DryRun, "destroy", destroyer.DryRun, "If true, preview of destroy operations will be displayed.")
-	_ = cmd.Flags().MarkHidden("dry-run")
+	cmd.Flags().BoolVar(&previewDestroy, "destroy", previewDestroy, "If true, preview what destroy would delete. "+
+		"All the resources in the inventory are listed in the order they would be deleted.")
+	cmd.Flags().BoolVar(&serverDryRun, "server-dry-run", serverDryRun, "If true, the changes are sent to the server "+
+		"in dry-run mode, so they are validated and go through admission without being persisted.")
 	cmdutil.AddValidateFlags(cmd)
 	_ = cmd.Flags().MarkHidden("validate")
-	// Server-side flags are hidden for now.
 	cmdutil.AddServerSideApplyFlags(cmd)
-	_ = cmd.Flags().MarkHidden("server-side")
-	_ = cmd.Flags().MarkHidden("force-conflicts")
-	_ = cmd.Flags().MarkHidden("field-manager")
 
 	return cmd
 }
@@ -0,0 +1,80 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/klogr"
+	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+)
+
+// NewCmdRollback creates the `rollback` command. It applies a previous
+// revision of the package, recorded by an apply with --history-limit,
+// and prunes the resources added since.
+func NewCmdRollback(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	rollbacker := apply.NewRollbacker(f, ioStreams)
+	rollbacker.Applier.Logger = klogr.New()
+	printerOptions := apply.NewPrinterOptions()
+	confirmer := apply.NewConfirmer(ioStreams)
+	rollbacker.Applier.PreRunGate = confirmer.Gate
+	list := false
+
+	cmd := &cobra.Command{
+		Use:                   "rollback (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Apply a previous revision of a configuration again"),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(rollbacker.Initialize(cmd, args))
+			defer rollbacker.Applier.Close()
+
+			if list {
+				revisions, err := rollbacker.Revisions()
+				apply.CheckErr(ioStreams.ErrOut, err)
+				w := tabwriter.NewWriter(ioStreams.Out, 0, 0, 3, ' ', 0)
+				fmt.Fprintln(w, "REVISION\tCREATED\tRESOURCES")
+				for _, rev := range revisions {
+					fmt.Fprintf(w, "%d\t%s\t%d\n", rev.Number, rev.Created.UTC().Format(time.RFC3339),
+						len(rev.Objects))
+				}
+				_ = w.Flush()
+				return
+			}
+
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
+			ctx, cancel := apply.WithInterrupt(context.Background(), ioStreams.ErrOut)
+			defer cancel()
+
+			// The printer will print updates from the channel. It will block
+			// until the channel is closed.
+			printer.Print(rollbacker.Run(ctx))
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", list, "If true, list the recorded revisions instead of rolling back.")
+	cmdutil.CheckErr(rollbacker.SetFlags(cmd))
+	printerOptions.AddFlags(cmd)
+	confirmer.AddFlags(cmd)
+	flag := cmd.Flags().Lookup("filename")
+	flag.Usage = strings.Replace(flag.Usage, "to apply", "to roll back", 1)
+
+	// The following flags are added, but hidden because other code
+	// depend on them when parsing flags. These flags are hidden and unused.
+	cmdutil.AddValidateFlags(cmd)
+	_ = cmd.Flags().MarkHidden("validate")
+	cmdutil.AddServerSideApplyFlags(cmd)
+
+	return cmd
+}
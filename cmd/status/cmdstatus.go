@@ -0,0 +1,172 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/scheme"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	pollUntilCurrent = "current"
+	pollUntilForever = "forever"
+)
+
+// NewCmdStatus creates the `status` command
+func NewCmdStatus(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	r := &StatusRunner{
+		factory:        f,
+		ioStreams:      ioStreams,
+		printerOptions: apply.NewPrinterOptions(),
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "status (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Show the status of the resources in a configuration"),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(r.Run(args))
+		},
+	}
+
+	cmd.Flags().DurationVar(&r.period, "status-poll-interval", 2*time.Second,
+		"How often the status of the resources is polled. A longer interval reduces the load on the API server.")
+	cmd.Flags().DurationVar(&r.period, "poll-period", 2*time.Second,
+		"Polling period for resource statuses.")
+	_ = cmd.Flags().MarkDeprecated("poll-period", "use --status-poll-interval instead")
+	cmd.Flags().StringVar(&r.pollUntil, "poll-until", pollUntilCurrent,
+		"When to stop polling. Must be one of 'current' or 'forever'.")
+	cmd.Flags().DurationVar(&r.timeout, "timeout", 0,
+		"How long to wait before exiting. A value of 0 means no timeout.")
+	r.printerOptions.AddFlags(cmd)
+
+	return cmd
+}
+
+// StatusRunner captures the parameters for the status command and
+// contains the logic for running it.
+type StatusRunner struct {
+	factory   cmdutil.Factory
+	ioStreams genericclioptions.IOStreams
+
+	period         time.Duration
+	pollUntil      string
+	timeout        time.Duration
+	printerOptions *apply.PrinterOptions
+}
+
+// Run reads the resources from the provided paths, polls the cluster
+// for their status and prints the events in the selected output format.
+func (r *StatusRunner) Run(paths []string) error {
+	if r.pollUntil != pollUntilCurrent && r.pollUntil != pollUntilForever {
+		return fmt.Errorf("pollUntil must be either %q or %q", pollUntilCurrent, pollUntilForever)
+	}
+	if r.period <= 0 {
+		return fmt.Errorf("the status poll interval must be greater than 0")
+	}
+	printer, err := r.printerOptions.ToPrinter(r.ioStreams)
+	if err != nil {
+		return err
+	}
+
+	infos, err := r.readInfos(paths)
+	if err != nil {
+		return err
+	}
+	identifiers := infosToIdentifiers(infos)
+
+	config, err := r.factory.ToRESTConfig()
+	if err != nil {
+		return errors.WrapPrefix(err, "error getting RESTConfig", 1)
+	}
+	mapper, err := r.factory.ToRESTMapper()
+	if err != nil {
+		return errors.WrapPrefix(err, "error getting RESTMapper", 1)
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme.Scheme, Mapper: mapper})
+	if err != nil {
+		return errors.WrapPrefix(err, "error creating client", 1)
+	}
+
+	ctx := context.Background()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	statusObserver := observe.NewStatusObserver(c, mapper)
+	ch := statusObserver.Observe(ctx, identifiers, r.period, r.pollUntil == pollUntilForever)
+	printer.Print(toStatusEvents(ch))
+	return nil
+}
+
+// toStatusEvents wraps the events from the observer in status events,
+// so they can be printed by the same printers as the apply events.
+func toStatusEvents(ch <-chan pollevent.Event) <-chan event.Event {
+	statusCh := make(chan event.Event)
+	go func() {
+		defer close(statusCh)
+		started := time.Now()
+		for e := range ch {
+			statusCh <- event.Event{
+				Type:        event.StatusType,
+				Timestamp:   time.Now(),
+				Started:     started,
+				StatusEvent: e,
+			}
+		}
+	}()
+	return statusCh
+}
+
+// readInfos reads the manifests from the given paths, or from StdIn
+// if no paths are provided.
+func (r *StatusRunner) readInfos(paths []string) ([]*resource.Info, error) {
+	namespace, enforceNamespace, err := r.factory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	return r.factory.NewBuilder().
+		Unstructured().
+		ContinueOnError().
+		NamespaceParam(namespace).DefaultNamespace().
+		FilenameParam(enforceNamespace, &resource.FilenameOptions{
+			Filenames: paths,
+			Recursive: true,
+		}).
+		Flatten().
+		Do().
+		Infos()
+}
+
+func infosToIdentifiers(infos []*resource.Info) []wait.ResourceIdentifier {
+	var identifiers []wait.ResourceIdentifier
+	for _, info := range infos {
+		identifiers = append(identifiers, wait.ResourceIdentifier{
+			GroupKind: info.Object.GetObjectKind().GroupVersionKind().GroupKind(),
+			Name:      info.Name,
+			Namespace: info.Namespace,
+		})
+	}
+	return identifiers
+}
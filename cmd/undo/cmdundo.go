@@ -0,0 +1,78 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package undo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/klogr"
+	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+)
+
+// NewCmdUndo creates the `undo` command. It prints what undoing the
+// last apply changes, asks for confirmation, and then applies the
+// revision before the last one again.
+func NewCmdUndo(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	rollbacker := apply.NewRollbacker(f, ioStreams)
+	rollbacker.Applier.Logger = klogr.New()
+	printerOptions := apply.NewPrinterOptions()
+	confirmer := apply.NewConfirmer(ioStreams)
+	planOnly := false
+
+	cmd := &cobra.Command{
+		Use:                   "undo (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Undo the changes of the last apply of a configuration"),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(rollbacker.Initialize(cmd, args))
+			defer rollbacker.Applier.Close()
+
+			plan, err := rollbacker.UndoPlan()
+			apply.CheckErr(ioStreams.ErrOut, err)
+			apply.PrintUndoPlan(ioStreams.Out, plan)
+			if planOnly || plan.Empty() {
+				return
+			}
+			apply.CheckErr(ioStreams.ErrOut, confirmer.Confirm())
+
+			printer, err := printerOptions.ToPrinter(ioStreams)
+			cmdutil.CheckErr(err)
+
+			ctx, cancel := apply.WithInterrupt(context.Background(), ioStreams.ErrOut)
+			defer cancel()
+
+			// The revision from the plan is applied, even if
+			// another one has been recorded since.
+			rollbacker.Revision = plan.Target
+			printer.Print(rollbacker.Run(ctx))
+		},
+	}
+
+	cmd.Flags().BoolVar(&planOnly, "plan", planOnly, "If true, only print what the undo changes.")
+	cmdutil.CheckErr(rollbacker.SetFlags(cmd))
+	printerOptions.AddFlags(cmd)
+	confirmer.AddFlags(cmd)
+	for _, name := range []string{"yes", "auto-approve"} {
+		flag := cmd.Flags().Lookup(name)
+		flag.Usage = strings.Replace(flag.Usage, "before deleting resources", "before undoing the changes", 1)
+	}
+	flag := cmd.Flags().Lookup("filename")
+	flag.Usage = strings.Replace(flag.Usage, "to apply", "whose last apply is undone", 1)
+	// The revision before the last one is always applied.
+	_ = cmd.Flags().MarkHidden("to-revision")
+
+	// The following flags are added, but hidden because other code
+	// depend on them when parsing flags. These flags are hidden and unused.
+	cmdutil.AddValidateFlags(cmd)
+	_ = cmd.Flags().MarkHidden("validate")
+	cmdutil.AddServerSideApplyFlags(cmd)
+
+	return cmd
+}
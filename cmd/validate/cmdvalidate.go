@@ -0,0 +1,59 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+)
+
+// NewCmdValidate creates the `validate` command. It checks the package
+// without applying anything, and exits with the validation exit code
+// if any problems are found.
+func NewCmdValidate(f util.Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	validator := apply.NewValidator(f, ioStreams)
+
+	cmd := &cobra.Command{
+		Use:                   "validate (FILENAME... | DIRECTORY)",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Check a configuration for problems without applying it"),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(validator.Initialize(cmd, args))
+			apply.CheckErr(ioStreams.ErrOut, validator.Validate())
+			fmt.Fprintln(ioStreams.Out, "no problems found")
+		},
+	}
+
+	cmdutil.CheckErr(validator.SetFlags(cmd))
+	for name, usage := range map[string]string{"filename": "to apply", "kustomize": "and apply"} {
+		flag := cmd.Flags().Lookup(name)
+		flag.Usage = strings.Replace(flag.Usage, usage, strings.Replace(usage, "apply", "validate", 1), 1)
+	}
+	// The following flags are added, but hidden because other code
+	// depend on them when parsing flags. These flags are hidden and unused.
+	cmdutil.AddValidateFlags(cmd)
+	_ = cmd.Flags().MarkHidden("validate")
+	cmdutil.AddServerSideApplyFlags(cmd)
+
+	// Nothing is applied, so the flags that only
+	// affect the apply are hidden.
+	for _, name := range []string{"allow-protected-deletion", "audit", "audit-package-version",
+		"audit-pipeline-id", "audit-user", "check-permissions", "dry-run", "field-manager", "force-conflicts",
+		"history-limit", "inventory-policy", "no-wait", "on-duplicate", "protected-namespaces",
+		"prune-propagation-policy", "prune-timeout", "reconcile-timeout", "selector", "sensitive-field",
+		"server-side", "status-poll-interval", "target-namespace", "wait"} {
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.Flags().MarkHidden(name)
+		}
+	}
+
+	return cmd
+}
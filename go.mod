@@ -3,18 +3,32 @@ module sigs.k8s.io/cli-utils
 go 1.13
 
 require (
+	github.com/Azure/go-autorest v14.2.0+incompatible // indirect
+	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-errors/errors v1.0.1
+	github.com/go-logr/logr v0.1.0
+	github.com/google/cel-go v0.12.6
 	github.com/pkg/errors v0.9.1
-	github.com/spf13/cobra v0.0.5
-	github.com/stretchr/testify v1.4.0
-	gopkg.in/yaml.v3 v3.0.0-20200121175148-a6ecf24a6d71
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/prometheus/client_golang v1.0.0
+	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 	gotest.tools v2.2.0+incompatible
+	helm.sh/helm/v3 v3.1.0
 	k8s.io/api v0.17.2
 	k8s.io/apimachinery v0.17.2
 	k8s.io/cli-runtime v0.17.2
 	k8s.io/client-go v0.17.2
-	k8s.io/kubectl v0.0.0-20191219154910-1528d4eea6dd
+	k8s.io/klog v1.0.0
+	k8s.io/kubectl v0.17.2
 	sigs.k8s.io/controller-runtime v0.4.0
+	sigs.k8s.io/kustomize/api v0.3.2
 	sigs.k8s.io/yaml v1.1.0
 )
+
+replace k8s.io/kubectl => k8s.io/kubectl v0.0.0-20191219154910-1528d4eea6dd
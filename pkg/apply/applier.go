@@ -5,21 +5,36 @@ package apply
 
 import (
 	"context"
-	"sort"
+	"fmt"
 	"time"
 
 	"github.com/go-errors/errors"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/kubectl/pkg/cmd/apply"
 	"k8s.io/kubectl/pkg/cmd/util"
-	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/metrics"
+	"sigs.k8s.io/cli-utils/pkg/apply/poller"
 	"sigs.k8s.io/cli-utils/pkg/apply/prune"
-	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observers"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // newApplier returns a new Applier. It will set up the ApplyOptions and
@@ -29,61 +44,362 @@ import (
 // handled by a separate printer with the KubectlPrinterAdapter bridging
 // between the two.
 func NewApplier(factory util.Factory, ioStreams genericclioptions.IOStreams) *Applier {
+	return NewApplierWithClients(factory, ioStreams, Clients{})
+}
+
+// NewApplierWithClients returns an Applier that uses the given clients
+// to talk to the cluster, instead of creating them from the factory.
+// The clients that are nil are still created from the factory.
+func NewApplierWithClients(factory util.Factory, ioStreams genericclioptions.IOStreams, clients Clients) *Applier {
 	return &Applier{
-		ApplyOptions:  apply.NewApplyOptions(ioStreams),
-		StatusOptions: NewStatusOptions(),
-		PruneOptions:  prune.NewPruneOptions(),
-		factory:       factory,
-		ioStreams:     ioStreams,
+		ApplyOptions:        apply.NewApplyOptions(ioStreams),
+		StatusOptions:       NewStatusOptions(),
+		PruneOptions:        prune.NewPruneOptionsWithClients(clients.DynamicClient, clients.Mapper),
+		SensitiveFields:     append([]object.SensitiveField{}, object.DefaultSensitiveFields...),
+		ProtectedNamespaces: append([]string{}, prune.DefaultProtectedNamespaces...),
+		Metrics:             metrics.NoopRecorder{},
+		baseFactory:         factory,
+		factory:             factory,
+		clients:             clients,
+		ioStreams:           ioStreams,
 	}
 }
 
-// resolver defines the interface the applier needs to observe status for resources.
-type resolver interface {
-	WaitForStatusOfObjects(ctx context.Context, objects []wait.KubernetesObject) <-chan wait.Event
+// statusPoller defines the interface the applier needs to observe status for resources.
+type statusPoller interface {
+	WaitForCurrent(ctx context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event
 }
 
 // Applier performs the step of applying a set of resources into a cluster,
 // conditionally waits for all of them to be fully reconciled and finally
 // performs prune to clean up any resources that has been deleted.
 type Applier struct {
-	factory   util.Factory
-	ioStreams genericclioptions.IOStreams
+	// baseFactory is the factory the Applier was created with. Initialize
+	// wraps it into factory, so calling it again doesn't stack the wrappers.
+	baseFactory util.Factory
+	factory     util.Factory
+	clients     Clients
+	ioStreams   genericclioptions.IOStreams
 
 	ApplyOptions  *apply.ApplyOptions
 	StatusOptions *StatusOptions
 	PruneOptions  *prune.PruneOptions
-	resolver      resolver
+	statusPoller  statusPoller
+	// deletionPoller is used to wait for the pruned resources
+	// to be removed if there is a PruneTimeout.
+	deletionPoller deletionPoller
 
+	// PruneTimeout makes the Applier wait up to the given duration for
+	// the pruned resources to be removed from the cluster, before the
+	// previous grouping objects are deleted. It is independent of the
+	// Timeout for the resources to reconcile. There is no wait if it
+	// is 0.
+	PruneTimeout time.Duration
+	// NoPrune disables pruning for this run. The inventory is still
+	// updated, and the previous grouping objects are kept, so the
+	// resources that were not pruned are pruned by the next run
+	// that has pruning enabled.
 	NoPrune bool
-	DryRun  bool
+	// DryRunStrategy determines if the changes are made in the
+	// cluster, or only evaluated on the client or the server. The
+	// inventory is never updated during a dry-run.
+	DryRunStrategy common.DryRunStrategy
+	// Diff enables printing the diff between the live and the
+	// local version of every resource before it is applied.
+	Diff bool
+	// SensitiveFields are the fields whose values are redacted in
+	// diffs and in the objects included in the events.
+	SensitiveFields []object.SensitiveField
+	// InventoryPolicy determines whether resources that already exist
+	// in the cluster can be taken over by the inventory, and which
+	// resources can be pruned. Resources that are also changed by
+	// other managers, like Helm or a controller, are reported as
+	// warnings, or as an error if the policy is strict.
+	InventoryPolicy prune.InventoryPolicy
+	// ProtectedNamespaces are the namespaces that are never pruned, and
+	// whose resources are never pruned, together with the other
+	// resources the cluster depends on, unless AllowProtectedDeletion
+	// is set. The resources are skipped, like the ones that don't
+	// belong to the inventory.
+	ProtectedNamespaces    []string
+	AllowProtectedDeletion bool
+	// RecoverUnrecorded prunes the resources of the inventory that a
+	// failed run applied without recording them in a grouping object.
+	// Finding them lists the resources of every namespaced kind of the
+	// inventory, so it is only needed after such a run.
+	RecoverUnrecorded bool
+	// Metrics is notified about the progress of every run.
+	Metrics metrics.Recorder
+	// Logger is used to log what the Applier is doing. Errors are
+	// also reported as events, so nothing needs to be logged for
+	// the output to be complete. Nothing is logged if it is nil.
+	Logger logr.Logger
+	// Tracer is used to create spans for the phases of every run,
+	// as children of the span in the context passed to Run. No
+	// spans are created if it is nil.
+	Tracer trace.Tracer
+	// RequireChecksum makes it an error to read manifests from URLs
+	// that don't pin the checksum of the content.
+	RequireChecksum bool
+	// InventoryID overrides the inventory id in the grouping object
+	// template, so the same package can be applied several times in
+	// one namespace. The id from the template is used if it is empty.
+	InventoryID string
+	// TargetNamespace moves all the namespaced resources of the
+	// package, and its inventory, to the namespace, so a package
+	// can be installed in any namespace without editing it. The
+	// namespaces of the manifests are used if it is empty.
+	TargetNamespace string
+	// FieldManager is the name recorded as the manager in the
+	// managedFields of the applied resources, so the changes made
+	// by different pipelines can be told apart. The default of the
+	// client is used if it is empty.
+	FieldManager string
+	// PreRunGate is called with the plan after the resources have
+	// been read, but before anything is changed in the cluster. The
+	// run is aborted if it returns an error. It is not called for
+	// dry-runs.
+	PreRunGate PreRunGate
+	// Selector is a label selector that limits the resources that
+	// are applied. The resources that don't match are reported with
+	// a Filtered event. They are still recorded in the inventory, so
+	// they are not pruned.
+	Selector string
+	// DuplicatePolicy determines what happens when several manifests
+	// in the package define the same resource.
+	DuplicatePolicy DuplicatePolicy
+	// Planner orders the resources before they are applied. The
+	// SortingPlanner is used if it is nil.
+	Planner Planner
+	// Actuator applies the resources. The KubectlActuator with the
+	// ApplyOptions of the run is used if it is nil.
+	Actuator Actuator
+	// InventoryClient records the reconcile status in the grouping
+	// object. The GroupingObjectInventoryClient is used if it is nil.
+	InventoryClient InventoryClient
+	// Pruner deletes the resources that are no longer in the
+	// package. The PruneOptions are used if it is nil.
+	Pruner Pruner
+	// RetriableErrors are matched against the errors of the apply,
+	// the wait and the prune, and the errors they match are reported
+	// as transient, so callers retrying on IsRetriable retry them too.
+	RetriableErrors []ErrorMatcher
+	// TaskQueue returns the tasks that are run once the resources have
+	// been read and planned. It is passed the ApplyTask, the WaitTask,
+	// the ConditionTask, the PruneTask and, if a history is kept, the
+	// HistoryTask, so custom tasks can be inserted between them. The
+	// default tasks are run if it is nil.
+	TaskQueue TaskQueueFunc
+	// ConditionParsers parse the conditions of additional types the
+	// resources can declare with the WaitForAnnotation, keyed by their
+	// type. They take precedence over the DefaultConditionParsers.
+	ConditionParsers map[string]ConditionParser
+	// ReadinessRules compute the status of the resources of their
+	// GroupKind with CEL expressions, so custom resources that don't
+	// follow the conventions of the status library can be waited on.
+	// The rules set on a resource with the ReadyWhenAnnotation and the
+	// FailedWhenAnnotation of the observers package take precedence.
+	ReadinessRules map[schema.GroupKind]observers.CELRule
+	// UnknownKindTimeout is how long to wait for the kinds of resources
+	// that the cluster doesn't serve yet, for example because their
+	// operator is installed by another system. The other resources are
+	// applied first. If it is zero, the run fails if a kind is unknown.
+	UnknownKindTimeout time.Duration
+	// RequireExplicitNamespace determines whether the namespace of
+	// namespaced resources that don't set one is only defaulted to the
+	// namespace passed with --namespace. If it is true and the flag is
+	// not set, the resources are reported when they are planned instead
+	// of being applied in the namespace of the current context.
+	RequireExplicitNamespace bool
+	// serverSideApply and forceConflicts are set by the
+	// WithServerSideApply option, and override the flags.
+	serverSideApply bool
+	forceConflicts  bool
+	// observersFactory is set by the WithStatusObservers option.
+	observersFactory observer.ObserversFactoryFunc
+	// discovery caches the discovery information for all the
+	// clients created from the factory.
+	discovery *cachedDiscoveryClientGetter
+	// selector is the parsed Selector. It is nil if
+	// all resources are applied.
+	selector labels.Selector
+	// sensitiveFieldFlags holds the additional sensitive fields
+	// provided on the command line.
+	sensitiveFieldFlags []string
+	// inventoryPolicyFlag holds the inventory policy provided
+	// on the command line.
+	inventoryPolicyFlag string
+	// propagationPolicyFlag holds the prune propagation policy
+	// provided on the command line.
+	propagationPolicyFlag string
+	// duplicatePolicyFlag holds the duplicate policy provided
+	// on the command line.
+	duplicatePolicyFlag string
+	// Helm is the Helm chart that is rendered and read
+	// together with the manifests.
+	Helm HelmOptions
+	// Substitution contains the values of the variables
+	// substituted in the manifests.
+	Substitution SubstitutionOptions
+	// Audit determines whether the applied resources are
+	// annotated with who applied them and when.
+	Audit AuditOptions
+	// HistoryLimit is the number of revisions of the package that are
+	// kept, so the Rollbacker can apply them again. If it is zero,
+	// no revisions are recorded.
+	HistoryLimit int
+	// CheckPermissions makes the run ask the API server whether the
+	// user is allowed to apply and prune the resources before anything
+	// is changed, and fail with a PermissionError listing every action
+	// that is denied. It is turned on by Initialize for dry-runs with
+	// impersonation, so a preview or a diff with --as tells whether
+	// the impersonated user can apply the package.
+	CheckPermissions bool
+	// remote holds the manifests downloaded from URLs, or rendered
+	// or normalized by Initialize, until the Applier is closed.
+	remote *remoteManifests
 }
 
 // Initialize sets up the Applier for actually doing an apply against
 // a cluster. This involves validating command line inputs and configuring
 // clients for communicating with the cluster.
 func (a *Applier) Initialize(cmd *cobra.Command, paths []string) error {
-	fileNameFlags := processPaths(paths)
-	a.ApplyOptions.DeleteFlags.FileNameFlags = &fileNameFlags
-	err := a.ApplyOptions.Complete(a.factory, cmd)
+	a.Audit.initialize(a.factory.ToRawKubeConfigLoader())
+	if a.DryRunStrategy.ClientOrServerDryRun() {
+		if config, err := a.factory.ToRESTConfig(); err == nil && impersonating(config) != "" {
+			a.CheckPermissions = true
+		}
+	}
+	if a.Helm.Chart != "" && a.Helm.Namespace == "" {
+		namespace, _, err := a.factory.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return errors.WrapPrefix(err, "error getting the namespace", 1)
+		}
+		a.Helm.Namespace = namespace
+	}
+	if err := a.resolveManifests(paths, a.ApplyOptions.DeleteFlags.FileNameFlags); err != nil {
+		return err
+	}
+	if fieldManager := fieldManagerFromFlags(cmd); fieldManager != "" {
+		a.FieldManager = fieldManager
+	}
+	var getter genericclioptions.RESTClientGetter = a.baseFactory
+	if a.FieldManager != "" {
+		getter = &fieldManagerClientGetter{
+			RESTClientGetter: a.baseFactory,
+			fieldManager:     a.FieldManager,
+		}
+	}
+	a.discovery = newCachedDiscoveryClientGetter(getter)
+	a.factory = util.NewFactory(a.discovery)
+	err := completeApplyOptions(a.ApplyOptions, a.factory, cmd)
 	if err != nil {
 		return errors.WrapPrefix(err, "error setting up ApplyOptions", 1)
 	}
+	if a.FieldManager != "" {
+		a.ApplyOptions.FieldManager = a.FieldManager
+	}
+	// WithServerSideApply sets the defaults, which the
+	// flags given on the command line override.
+	if a.serverSideApply {
+		if !flagChanged(cmd, "server-side") {
+			a.ApplyOptions.ServerSideApply = true
+		}
+		if !flagChanged(cmd, "force-conflicts") {
+			a.ApplyOptions.ForceConflicts = a.forceConflicts
+		}
+	}
+	setApplyOptionsClients(a.ApplyOptions, a.clients)
 	a.ApplyOptions.PreProcessorFn = prune.PrependGroupingObject(a.ApplyOptions)
 	err = a.PruneOptions.Initialize(a.factory, a.ApplyOptions.Namespace)
 	if err != nil {
 		return errors.WrapPrefix(err, "error setting up PruneOptions", 1)
 	}
+	if a.RequireExplicitNamespace && !a.ApplyOptions.EnforceNamespace {
+		// Without a default, the builder leaves the namespace empty.
+		a.ApplyOptions.Namespace = ""
+	}
 
 	// Propagate dry-run flags.
-	a.ApplyOptions.DryRun = a.DryRun
-	a.PruneOptions.DryRun = a.DryRun
+	a.ApplyOptions.DryRun = a.DryRunStrategy.ClientDryRun()
+	a.ApplyOptions.ServerDryRun = a.DryRunStrategy.ServerDryRun()
+	// A server-side apply ignores the client dry-run, so
+	// it would change the cluster.
+	if a.ApplyOptions.ServerSideApply && a.DryRunStrategy.ClientDryRun() {
+		return errors.New("server-side apply can only be used with a server dry-run")
+	}
+	a.PruneOptions.DryRunStrategy = a.DryRunStrategy
 
-	resolver, err := a.newResolver(a.StatusOptions.period)
+	for _, f := range a.sensitiveFieldFlags {
+		field, err := object.ParseSensitiveField(f)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing sensitive fields", 1)
+		}
+		a.SensitiveFields = append(a.SensitiveFields, field)
+	}
+	a.PruneOptions.SensitiveFields = a.SensitiveFields
+	if a.inventoryPolicyFlag != "" {
+		a.InventoryPolicy, err = prune.ParseInventoryPolicy(a.inventoryPolicyFlag)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing inventory policy", 1)
+		}
+	}
+	a.PruneOptions.InventoryPolicy = a.InventoryPolicy
+	a.PruneOptions.ProtectedNamespaces = a.ProtectedNamespaces
+	a.PruneOptions.AllowProtectedDeletion = a.AllowProtectedDeletion
+	a.PruneOptions.RecoverUnrecorded = a.RecoverUnrecorded
+	if a.propagationPolicyFlag != "" {
+		a.PruneOptions.PropagationPolicy, err = prune.ParsePropagationPolicy(a.propagationPolicyFlag)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing prune propagation policy", 1)
+		}
+	}
+	if a.duplicatePolicyFlag != "" {
+		a.DuplicatePolicy, err = ParseDuplicatePolicy(a.duplicatePolicyFlag)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing duplicate policy", 1)
+		}
+	}
+	if a.Selector != "" {
+		a.selector, err = labels.Parse(a.Selector)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing selector", 1)
+		}
+	}
+	a.PruneOptions.Logger = a.logger().WithName("prune")
+
+	if err := a.StatusOptions.complete(); err != nil {
+		return errors.WrapPrefix(err, "error setting up StatusOptions", 1)
+	}
+	// The fields set by the options may have been changed
+	// since the Applier was created, or by the flags.
+	if err := a.validateOptions(); err != nil {
+		return err
+	}
+	for gk, rule := range a.ReadinessRules {
+		if err := rule.Validate(); err != nil {
+			return errors.WrapPrefix(err, fmt.Sprintf("error parsing readiness rule for %s", gk), 1)
+		}
+	}
+	// The rules of the annotations apply to the resources of the
+	// custom observers too, like they do to the built-in ones.
+	observersFactory := a.observersFactory
+	if observersFactory == nil {
+		observersFactory = observe.BuiltinObserversFactoryFunc
+	}
+	observersFactory = observe.NewCELObserversFactoryFunc(a.ReadinessRules, observersFactory)
+	statusPoller, err := newStatusPoller(a.clients, a.factory, a.StatusOptions.period,
+		poller.WithObserversFactory(observersFactory))
 	if err != nil {
-		return errors.WrapPrefix(err, "error creating resolver", 1)
+		return errors.WrapPrefix(err, "error creating status poller", 1)
+	}
+	a.statusPoller = statusPoller
+	if a.PruneTimeout > 0 {
+		a.deletionPoller = statusPoller
+		a.PruneOptions.WaitForDeletion = func(objs []*object.ObjMetadata, ch chan<- event.Event) error {
+			return waitForDeletion(a.deletionPoller, a.PruneTimeout, objs, ch)
+		}
 	}
-	a.resolver = resolver
 	return nil
 }
 
@@ -91,139 +407,649 @@ func (a *Applier) Initialize(cmd *cobra.Command, paths []string) error {
 // status. This is a temporary solution as we should separate the configuration
 // of cobra flags from the Applier.
 func (a *Applier) SetFlags(cmd *cobra.Command) error {
+	// The --wait flag controls the wait for the resources to
+	// reconcile, so the one for deletions is left out.
+	a.ApplyOptions.DeleteFlags.Wait = nil
 	a.ApplyOptions.DeleteFlags.AddFlags(cmd)
-	for _, flag := range []string{"kustomize", "filename", "recursive"} {
-		err := cmd.Flags().MarkHidden(flag)
-		if err != nil {
-			return err
-		}
-	}
+	// The kustomization is built in-process, so it can
+	// be combined with the other manifests.
+	cmd.Flags().Lookup("kustomize").Usage = "Build the kustomization directory and apply the result. " +
+		"Can be combined with -f."
+	cmd.Flags().Lookup("filename").Usage = "Filename, directory, pattern or URL of the manifests to apply. Patterns like " +
+		"'manifests/**/*.yaml' match files in lexical order. Directories in git " +
+		"repositories can be given like kustomize remote bases, for example https://github.com/org/repo//dir?ref=v1, " +
+		"and packages in OCI registries as oci://REGISTRY/REPOSITORY@sha256:<hex>. Manifests can be YAML or JSON, " +
+		"including JSON arrays of objects, and - reads them from stdin."
 	a.ApplyOptions.RecordFlags.AddFlags(cmd)
 	_ = cmd.Flags().MarkHidden("record")
 	_ = cmd.Flags().MarkHidden("cascade")
 	_ = cmd.Flags().MarkHidden("force")
 	_ = cmd.Flags().MarkHidden("grace-period")
 	_ = cmd.Flags().MarkHidden("timeout")
-	_ = cmd.Flags().MarkHidden("wait")
 	a.StatusOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVar(&a.sensitiveFieldFlags, "sensitive-field", a.sensitiveFieldFlags,
+		"Additional field whose value is redacted in the output, in the format KIND[.GROUP]:PATH, "+
+			"for example ConfigMap:data.password. The data of Secrets is always redacted, and so is the "+
+			"last-applied-configuration annotation of the kinds with redacted fields.")
+	cmd.Flags().StringVar(&a.InventoryID, "inventory-id", a.InventoryID,
+		"Override the inventory id of the grouping object template. This allows the same package to be "+
+			"applied several times in one namespace.")
+	_ = cmd.RegisterFlagCompletionFunc("inventory-id", InventoryIDCompletionFunc(a.factory))
+	cmd.Flags().StringVar(&a.TargetNamespace, "target-namespace", a.TargetNamespace,
+		"If set, all the namespaced resources of the package, including the grouping object, are applied to "+
+			"this namespace, whatever the namespace of their manifest.")
+	cmd.Flags().BoolVar(&a.RequireChecksum, "require-checksum", a.RequireChecksum,
+		"If true, manifests can only be read from URLs that pin the checksum of the content, "+
+			"for example https://example.com/release.yaml#sha256=<hex>.")
+	cmd.Flags().StringVar(&a.inventoryPolicyFlag, "inventory-policy", a.InventoryPolicy.String(),
+		"Whether resources that already exist can be taken over. Must be one of strict, which only allows "+
+			"resources that belong to the inventory and are not changed by other managers, adopt-if-no-inventory, which also allows resources that "+
+			"don't belong to any inventory, or force-adopt, which allows all resources.")
+	cmd.Flags().DurationVar(&a.PruneTimeout, "prune-timeout", a.PruneTimeout,
+		"Wait up to the given duration for the pruned resources to be removed from the cluster. If they "+
+			"aren't, the previous inventory is kept and the command fails. A value of 0 means there is no wait.")
+	cmd.Flags().StringVar(&a.propagationPolicyFlag, "prune-propagation-policy", a.propagationPolicyFlag,
+		"How the dependents of pruned resources are deleted. Must be one of background, foreground, which "+
+			"deletes the dependents before the resource, or orphan, which keeps the dependents. If not set, "+
+			"the default of the server is used.")
+	cmd.Flags().StringSliceVar(&a.ProtectedNamespaces, "protected-namespaces", a.ProtectedNamespaces,
+		"Namespaces that are never pruned, and whose resources are never pruned. The resources the cluster "+
+			"depends on, like the default RBAC roles, are never pruned either.")
+	cmd.Flags().BoolVar(&a.AllowProtectedDeletion, "allow-protected-deletion", a.AllowProtectedDeletion,
+		"If true, the protected namespaces and resources can be pruned.")
+	cmd.Flags().BoolVar(&a.RecoverUnrecorded, "recover-unrecorded", a.RecoverUnrecorded,
+		"If true, also prune the resources of the inventory that a failed run applied without recording them. "+
+			"This lists the resources of every namespaced kind of the inventory in its namespace, so it is only "+
+			"needed after a run that failed while applying. Cluster-scoped resources are not found.")
+	cmd.Flags().StringVarP(&a.Selector, "selector", "l", a.Selector,
+		"Selector (label query) to filter on, supports '=', '==', and '!='. Only the matching resources are "+
+			"applied. The other resources are kept in the inventory, so they are not pruned.")
+	cmd.Flags().StringVar(&a.duplicatePolicyFlag, "on-duplicate", a.DuplicatePolicy.String(),
+		"What to do when several manifests define the same resource. Must be one of fail, which fails before "+
+			"anything is applied, or last-wins, which applies the manifest read last and prints a warning.")
+	cmd.Flags().DurationVar(&a.UnknownKindTimeout, "wait-for-kinds", a.UnknownKindTimeout,
+		"How long to wait for the kinds of resources that the cluster doesn't serve yet, for example when "+
+			"their CRDs are installed by another system. The other resources are applied first. Zero means "+
+			"the apply fails if a kind is unknown.")
+	cmd.Flags().BoolVar(&a.RequireExplicitNamespace, "require-explicit-namespace", a.RequireExplicitNamespace,
+		"If true, namespaced resources must set their namespace in the manifest, or it must be passed with "+
+			"--namespace. The namespace of the current context is not used as a default.")
+	addHelmFlags(cmd, &a.Helm)
+	addSubstitutionFlags(cmd, &a.Substitution)
+	addAuditFlags(cmd, &a.Audit)
+	cmd.Flags().BoolVar(&a.CheckPermissions, "check-permissions", a.CheckPermissions,
+		"If true, check that the user is allowed to apply and prune all the resources before anything is "+
+			"changed, and list every action that is denied. Always true for dry-runs with --as or --as-group.")
+	cmd.Flags().IntVar(&a.HistoryLimit, "history-limit", a.HistoryLimit,
+		"The number of revisions of the package to keep in the cluster, so the rollback command can apply "+
+			"a previous one again. If 0, no revisions are recorded.")
+	addDryRunFlag(cmd, &a.DryRunStrategy)
 	a.ApplyOptions.Overwrite = true
 	return nil
 }
 
-// newResolver sets up a new Resolver for computing status. The configuration
-// needed for the resolver is taken from the Factory.
-func (a *Applier) newResolver(pollInterval time.Duration) (*wait.Resolver, error) {
-	config, err := a.factory.ToRESTConfig()
-	if err != nil {
-		return nil, errors.WrapPrefix(err, "error getting RESTConfig", 1)
-	}
+// Run performs the Apply step. This happens asynchronously with updates
+// on progress and any errors are reported back on the event channel.
+// Cancelling the operation can be done with the passed in context. The
+// wait is also limited by the Timeout in the StatusOptions.
+// Once the context is cancelled, the batch of resources being applied is
+// finished, but no more resources are applied and nothing is pruned. The
+// inventory then records only the resources that were applied.
+//
+// Every run works on its own copy of the ApplyOptions, so an Applier
+// can be used for several runs at the same time.
+func (a *Applier) Run(ctx context.Context) <-chan event.Event {
+	r := a.newRun()
+	return r.run(ctx, r.readObjects)
+}
 
-	mapper, err := a.factory.ToRESTMapper()
-	if err != nil {
-		return nil, errors.WrapPrefix(err, "error getting RESTMapper", 1)
+// resolveManifests downloads, renders and normalizes the manifests
+// given by the paths and the flags into a new temporary directory, and
+// sets the FileNameFlags of the ApplyOptions to read them. The manifests
+// resolved before are removed, unless there is an error.
+func (a *Applier) resolveManifests(paths []string, flags *genericclioptions.FileNameFlags) error {
+	remote := newRemoteManifests(a.RequireChecksum)
+	if a.Substitution.enabled() {
+		variables, err := a.Substitution.variables()
+		if err != nil {
+			return errors.WrapPrefix(err, "error reading variables", 1)
+		}
+		remote.variables = variables
 	}
-
-	c, err := client.New(config, client.Options{Scheme: scheme.Scheme, Mapper: mapper})
+	fileNameFlags, err := remote.resolvePaths(paths, flags, a.Helm)
 	if err != nil {
-		return nil, errors.WrapPrefix(err, "error creating client", 1)
+		return errors.WrapPrefix(err, "error resolving manifests", 1)
 	}
+	a.remote.cleanup()
+	a.remote = remote
+	a.ApplyOptions.DeleteFlags.FileNameFlags = &fileNameFlags
+	// The DeleteOptions are only set once the ApplyOptions are
+	// completed, and are copied since earlier runs can share them.
+	if a.ApplyOptions.DeleteOptions != nil {
+		o := *a.ApplyOptions.DeleteOptions
+		o.FilenameOptions = fileNameFlags.ToOptions()
+		a.ApplyOptions.DeleteOptions = &o
+	}
+	return nil
+}
 
-	return wait.NewResolver(c, mapper, pollInterval), nil
+// Close removes the manifests that Initialize downloaded, rendered or
+// normalized into a temporary directory. The Applier can be run as
+// many times as needed until it is closed.
+func (a *Applier) Close() error {
+	a.remote.cleanup()
+	return nil
 }
 
-// Run performs the Apply step. This happens asynchronously with updates
-// on progress and any errors are reported back on the event channel.
-// Cancelling the operation or setting timeout on how long to wait
-// for it complete can be done with the passed in context.
-// Note: There sn't currently any way to interrupt the operation
-// before all the given resources have been applied to the cluster. Any
-// cancellation or timeout will only affect how long we wait for the
-// resources to become current.
-func (a *Applier) Run(ctx context.Context) <-chan event.Event {
+// RunObjects is like Run, but applies the given objects instead of
+// the manifests passed to Initialize. The objects must include the
+// grouping object. They are copied, so they are not changed by the
+// run. This allows a single Applier to apply many packages, for
+// example in a controller, even at the same time.
+func (a *Applier) RunObjects(ctx context.Context, objs []*unstructured.Unstructured) <-chan event.Event {
+	r := a.newRun()
+	return r.run(ctx, func(ctx context.Context) ([]*resource.Info, error) {
+		return r.objectsToInfos(ctx, objs)
+	})
+}
+
+// newRun returns a copy of the Applier for a single run. kubectl keeps
+// the objects and the resources it has visited in the ApplyOptions, so
+// they are copied with a new builder, which also keeps the files it
+// has read.
+func (a *Applier) newRun() *Applier {
+	r := *a
+	if a.ApplyOptions != nil {
+		o := *a.ApplyOptions
+		if a.factory != nil {
+			o.Builder = a.factory.NewBuilder()
+		}
+		o.VisitedUids = sets.NewString()
+		o.VisitedNamespaces = sets.NewString()
+		if o.PreProcessorFn != nil {
+			o.PreProcessorFn = prune.PrependGroupingObject(&o)
+		}
+		r.ApplyOptions = &o
+	}
+	return &r
+}
+
+// run performs a run with the objects returned by read.
+func (a *Applier) run(ctx context.Context,
+	read func(ctx context.Context) ([]*resource.Info, error)) <-chan event.Event {
 	ch := make(chan event.Event)
 
 	go func() {
 		defer close(ch)
+		ctx = withApplyOptions(ctx, a.ApplyOptions)
+		ctx, runSpan := startSpan(ctx, a.Tracer, spanApplierRun)
+		defer runSpan.End()
+
+		if err := a.validateOptions(); err != nil {
+			sendError(ch, a.logger(), "invalid options", err, ExitValidationError)
+			return
+		}
+
+		// This provides us with a slice of all the objects that will be
+		// applied to the cluster.
+		_, span := startSpan(ctx, a.Tracer, spanRead)
+		infos, err := read(ctx)
+		endSpan(span, err)
+		if err != nil {
+			sendError(ch, a.logger(), "error reading resources", err, ExitValidationError)
+			return
+		}
+		infos, duplicates, err := removeDuplicates(infos, a.DuplicatePolicy)
+		if err != nil {
+			sendError(ch, a.logger(), "error reading resources", err, ExitValidationError)
+			return
+		}
+		for _, d := range duplicates {
+			a.logger().Info("using the last manifest for duplicate resource", "object", d.Object.String(),
+				"sources", d.Sources)
+			fmt.Fprintf(a.ApplyOptions.ErrOut, "warning: %s, using the last one\n", d.String())
+		}
+		if err := prune.DetectGroupingObject(infos); err != nil {
+			sendError(ch, a.logger(), "error finding grouping object", err, ExitValidationError)
+			return
+		}
+		if a.InventoryID != "" {
+			if err := prune.SetInventoryID(infos, a.InventoryID); err != nil {
+				sendError(ch, a.logger(), "error setting inventory id", err, ExitValidationError)
+				return
+			}
+		}
+		if a.TargetNamespace != "" {
+			if err := setNamespace(infos, a.TargetNamespace); err != nil {
+				sendError(ch, a.logger(), "error setting namespace", err, ExitValidationError)
+				return
+			}
+		}
+		// The revision records the manifests before the values
+		// from the cluster are filled in.
+		var history []*unstructured.Unstructured
+		if a.recordsHistory() {
+			history, err = snapshotObjects(infos)
+			if err != nil {
+				sendError(ch, a.logger(), "error reading resources", err, ExitValidationError)
+				return
+			}
+		}
+		if err := a.injectValues(infos); err != nil {
+			sendError(ch, a.logger(), "error injecting values", err, ExitValidationError)
+			return
+		}
+		// The conditions are only checked once the resources are
+		// applied, but a typo should fail the run before that.
+		if _, err := waitConditions(infos, a.conditionParsers()); err != nil {
+			sendError(ch, a.logger(), "error parsing wait conditions", err, ExitValidationError)
+			return
+		}
+		if err := validateReadinessRules(infos); err != nil {
+			sendError(ch, a.logger(), "error parsing readiness rules", err, ExitValidationError)
+			return
+		}
+		if err := a.Audit.annotate(infos, time.Now()); err != nil {
+			sendError(ch, a.logger(), "error adding audit annotations", err, ExitValidationError)
+			return
+		}
 		adapter := &KubectlPrinterAdapter{
-			ch: ch,
+			ch:              ch,
+			sensitiveFields: a.SensitiveFields,
+			tracer:          a.Tracer,
 		}
 		// The adapter is used to intercept what is meant to be printing
 		// in the ApplyOptions, and instead turn those into events.
 		a.ApplyOptions.ToPrinter = adapter.toPrinterFunc()
-		// This provides us with a slice of all the objects that will be
-		// applied to the cluster.
-		infos, _ := a.ApplyOptions.GetObjects()
 
 		// sort the info objects starting from independent to dependent objects, and set them back
 		// ordering precedence can be found in gvk.go
-		sort.Sort(ResourceInfos(infos))
-		a.ApplyOptions.SetObjects(infos)
-
-		err := a.ApplyOptions.Run()
+		_, span = startSpan(ctx, a.Tracer, spanPlan)
+		infos, err = a.planner().Plan(ctx, infos)
 		if err != nil {
-			// If we see an error here we just report it on the channel and then
-			// give up. Eventually we might be able to determine which errors
-			// are fatal and which might allow us to continue.
-			ch <- event.Event{
-				Type: event.ErrorType,
-				ErrorEvent: event.ErrorEvent{
-					Err: errors.WrapPrefix(err, "error applying resources", 1),
-				},
+			endSpan(span, err)
+			sendError(ch, a.logger(), "error planning resources", err, ExitValidationError)
+			return
+		}
+		// Report all the names, labels and annotations the API server
+		// would reject, and the namespaces that contradict the scope of
+		// the kinds, rather than failing on the first one mid-apply.
+		problems := validateMetadata(infos, a.ApplyOptions.ServerSideApply)
+		problems = append(problems, validateScopes(infos)...)
+		if len(problems) > 0 {
+			err = withExitCode(&ValidationError{Problems: problems}, ExitValidationError)
+			endSpan(span, err)
+			a.logger().Error(err, "invalid resources")
+			sendErrorEvent(ch, err)
+			return
+		}
+		// Every resource in a namespace that is being deleted would
+		// be rejected, so the run fails once with the reason instead.
+		if err := a.checkTerminatingNamespaces(infos); err != nil {
+			endSpan(span, err)
+			a.logger().Error(err, "namespaces are being deleted")
+			sendErrorEvent(ch, withExitCode(err, ExitApplyError))
+			return
+		}
+		inventoryID, err := prune.AddOwningInventory(infos)
+		if err != nil {
+			endSpan(span, err)
+			sendError(ch, a.logger(), "error reading inventory", err, ExitValidationError)
+			return
+		}
+		if a.selector != nil {
+			infos, err = a.filterBySelector(infos, ch)
+			if err != nil {
+				endSpan(span, err)
+				sendError(ch, a.logger(), "error filtering resources", err, ExitValidationError)
+				return
 			}
+		}
+		infos, deferred, err := a.deferResources(infos)
+		if err == nil {
+			// The inventory is added to the grouping object once, so
+			// the pre-run gate sees the grouping object that is applied.
+			err = a.addInventory(infos)
+		}
+		if err != nil {
+			endSpan(span, err)
+			sendError(ch, a.logger(), "error reading inventory", err, ExitValidationError)
 			return
 		}
-		// If we get there, then all resources have been successfully applied.
-		ch <- event.Event{
-			Type: event.ApplyType,
-			ApplyEvent: event.ApplyEvent{
-				Type: event.ApplyEventCompleted,
-			},
+		if err := a.checkInventorySize(infos); err != nil {
+			endSpan(span, err)
+			a.logger().Error(err, "error checking inventory size")
+			sendErrorEvent(ch, withExitCode(err, ExitValidationError))
+			return
 		}
-
-		if a.StatusOptions.wait {
-			statusChannel := a.resolver.WaitForStatusOfObjects(ctx, infosToObjects(infos))
-			// As long as the statusChannel remains open, we take every statusEvent,
-			// wrap it in an Event and send it on the channel.
-			// TODO: What should we do if waiting for status times out? We currently proceed with
-			// prune, but that doesn't seem right.
-			for statusEvent := range statusChannel {
-				ch <- event.Event{
-					Type:        event.StatusType,
-					StatusEvent: statusEvent,
-				}
+		err = a.checkInventoryPolicy(infos, inventoryID)
+		if err != nil {
+			endSpan(span, err)
+			a.logger().Error(err, "error checking inventory policy")
+			sendErrorEvent(ch, withExitCode(err, ExitApplyError))
+			return
+		}
+		if err := a.checkManagers(infos); err != nil {
+			endSpan(span, err)
+			a.logger().Error(err, "error checking managers")
+			sendErrorEvent(ch, withExitCode(err, ExitApplyError))
+			return
+		}
+		if a.CheckPermissions {
+			if err := a.checkPermissions(infos); err != nil {
+				endSpan(span, err)
+				a.logger().Error(err, "error checking permissions")
+				sendErrorEvent(ch, withExitCode(err, ExitApplyError))
+				return
 			}
 		}
+		if a.Diff {
+			err = a.sendDiffs(infos, ch)
+		}
+		endSpan(span, err)
+		if err != nil {
+			a.logger().Error(err, "error computing diff")
+			sendErrorEvent(ch, errors.WrapPrefix(err, "error computing diff", 1))
+			return
+		}
 
-		if !a.NoPrune {
-			err = a.PruneOptions.Prune(infos, ch)
+		if a.PreRunGate != nil && !a.DryRunStrategy.ClientOrServerDryRun() {
+			err = a.runPreRunGate(infos)
 			if err != nil {
-				// If we see an error here we just report it on the channel and then
-				// give up. Eventually we might be able to determine which errors
-				// are fatal and which might allow us to continue.
-				ch <- event.Event{
-					Type: event.ErrorType,
-					ErrorEvent: event.ErrorEvent{
-						Err: errors.WrapPrefix(err, "error pruning resources", 1),
-					},
-				}
+				a.logger().Error(err, "run was not allowed to continue")
+				sendErrorEvent(ch, err)
 				return
 			}
+		}
+
+		tc := &TaskContext{Infos: infos, ch: ch}
+		tasks := []Task{
+			&ApplyTask{applier: a, adapter: adapter, deferred: deferred},
+			&WaitTask{applier: a},
+			&ConditionTask{applier: a},
+			&PruneTask{applier: a},
+		}
+		if a.recordsHistory() {
+			tasks = append(tasks, &HistoryTask{applier: a, objects: history})
+		}
+		err = a.runTasks(ctx, tc, tasks)
+		if err != nil {
+			sendErrorEvent(ch, withRetriableErrors(err, a.RetriableErrors))
+			return
+		}
+
+		// We don't stop if the resources don't reconcile before the
+		// timeout, but it still needs to be reported as a failure.
+		if tc.WaitTimedOut {
+			runSpan.SetStatus(codes.Error, "timed out")
+			sendErrorEvent(ch, withExitCode(
+				fmt.Errorf("%w waiting for resources to reach the Current status", ErrTimeout), ExitReconcileTimeout))
+		}
+	}()
+	return recordMetrics(a.Metrics, ch)
+}
+
+// sendError logs the error with the message, and sends it on the
+// channel prefixed with the message and with the exit code. The
+// errors that are reported as they are use sendErrorEvent instead.
+func sendError(ch chan<- event.Event, logger logr.Logger, msg string, err error, code int) {
+	logger.Error(err, msg)
+	sendErrorEvent(ch, withExitCode(errors.WrapPrefix(err, msg, 2), code))
+}
+
+// sendErrorEvent sends the error on the channel.
+func sendErrorEvent(ch chan<- event.Event, err error) {
+	ch <- event.Event{
+		Type:      event.ErrorType,
+		Timestamp: time.Now(),
+		ErrorEvent: event.ErrorEvent{
+			Err: err,
+		},
+	}
+}
+
+// recordsHistory returns true if the run records a revision.
+func (a *Applier) recordsHistory() bool {
+	return a.HistoryLimit > 0 && !a.DryRunStrategy.ClientOrServerDryRun()
+}
+
+// filterBySelector returns the resources that match the selector, and
+// sends a Filtered event for each of the other resources. The inventory
+// is computed from all the resources before they are filtered, so the
+// resources that are left out are not pruned.
+func (a *Applier) filterBySelector(infos []*resource.Info, ch chan<- event.Event) ([]*resource.Info, error) {
+	matched, filtered, err := filterBySelector(infos, a.selector)
+	if err != nil {
+		return nil, err
+	}
+	// The inventory must not be computed from only the
+	// matching resources when they are applied.
+	if err := a.addInventory(infos); err != nil {
+		return nil, err
+	}
+	prune.SortGroupingObject(matched)
+	a.logger().V(1).Info("filtered resources by selector", "selector", a.Selector,
+		"matched", len(matched), "filtered", len(filtered))
+	for _, info := range filtered {
+		ch <- event.Event{
+			Type:      event.ApplyType,
+			Timestamp: time.Now(),
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Filtered,
+				Object:     object.Redact(info.Object, a.SensitiveFields),
+				Identifier: infoToObjMetadata(info),
+			},
+		}
+	}
+	return matched, nil
+}
+
+// runPreRunGate calls the PreRunGate with the resources that will be
+// applied and pruned. The inventory has already been added to the
+// grouping object, so the prune set is computed from it directly.
+func (a *Applier) runPreRunGate(infos []*resource.Info) error {
+	plan := Plan{
+		Apply: infosToObjMetadata(infos),
+	}
+	if !a.NoPrune {
+		pruneSet, err := a.pruner().PruneSet(infos)
+		if err != nil {
+			return errors.WrapPrefix(err, "error computing prune set", 1)
+		}
+		plan.Delete = pruneSet
+	}
+	return a.PreRunGate(plan)
+}
+
+// addInventory adds the inventory of the resources to the grouping
+// object and moves it first, unless that has already been done in
+// this run. Afterwards the ApplyOptions no longer do it, so the
+// grouping object is only changed once.
+func (a *Applier) addInventory(infos []*resource.Info) error {
+	if a.ApplyOptions.PreProcessorFn == nil {
+		return nil
+	}
+	if err := prune.AddInventoryToGroupingObj(infos); err != nil {
+		return err
+	}
+	prune.SortGroupingObject(infos)
+	a.ApplyOptions.PreProcessorFn = nil
+	return nil
+}
+
+// inventorySizeWarning is the share of the size limit above which a
+// warning is printed, so the package can be split before it fails.
+const inventorySizeWarning = 0.8
+
+// checkInventorySize returns an InventoryTooLargeError if the grouping
+// object is larger than the API server accepts, and prints a warning if
+// it is close to the limit.
+func (a *Applier) checkInventorySize(infos []*resource.Info) error {
+	groupingInfo, found := prune.FindGroupingObject(infos)
+	if !found {
+		return nil
+	}
+	groupingObj, ok := groupingInfo.Object.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	serverSide := a.ApplyOptions.ServerSideApply
+	size, limit, err := prune.GroupingObjectSize(groupingObj, serverSide)
+	if err != nil {
+		return err
+	}
+	inventory, _, err := unstructured.NestedStringMap(groupingObj.Object, "data")
+	if err != nil {
+		return err
+	}
+	items := len(inventory)
+	a.logger().V(1).Info("calculated inventory size", "size", size, "limit", limit, "items", items)
+	if size > limit {
+		return &prune.InventoryTooLargeError{Name: groupingInfo.Name, Items: items, Size: size, Limit: limit,
+			ServerSide: serverSide}
+	}
+	if float64(size) > inventorySizeWarning*float64(limit) {
+		fmt.Fprintf(a.ApplyOptions.ErrOut, "warning: the inventory of %d resources takes %d of the %d bytes "+
+			"allowed for grouping object %s; split the package before it reaches the limit\n", items, size, limit,
+			groupingInfo.Name)
+	}
+	return nil
+}
+
+// validateReadinessRules returns an error if the readiness rules set
+// with annotations on the resources can't be compiled, so they don't
+// only fail once the resources have been applied.
+func validateReadinessRules(infos []*resource.Info) error {
+	for _, info := range infos {
+		obj, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		rule, found := observers.CELRuleFor(obj, nil)
+		if !found {
+			continue
+		}
+		if err := rule.Validate(); err != nil {
+			id := infoToObjMetadata(info)
+			return fmt.Errorf("%s: %v", id.String(), err)
+		}
+	}
+	return nil
+}
+
+// checkInventoryPolicy verifies that the inventory policy allows the
+// inventory to take over the resources that already exist in the
+// cluster. All the resources that can't be taken over are included
+// in the returned error.
+func (a *Applier) checkInventoryPolicy(infos []*resource.Info, inventoryID string) error {
+	if a.InventoryPolicy == prune.InventoryPolicyForceAdopt {
+		return nil
+	}
+	var errs []error
+	for _, info := range infos {
+		if prune.IsGroupingObject(info.Object) {
+			continue
+		}
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		live, err := helper.Get(info.Namespace, info.Name, false)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := prune.CanApply(inventoryID, live, a.InventoryPolicy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// sendDiffs sends a diff event for every resource that will be changed
+// by the apply.
+func (a *Applier) sendDiffs(infos []*resource.Info, ch chan<- event.Event) error {
+	for _, info := range infos {
+		// The grouping object gets a new name for every apply,
+		// so there is nothing useful to diff against.
+		if prune.IsGroupingObject(info.Object) {
+			continue
+		}
+		diff, err := diffInfo(info, a.SensitiveFields)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			continue
+		}
+		ch <- event.Event{
+			Type:      event.DiffType,
+			Timestamp: time.Now(),
+			DiffEvent: event.DiffEvent{
+				Identifier: infoToObjMetadata(info),
+				Diff:       diff,
+			},
+		}
+	}
+	return nil
+}
+
+// PollStatus waits for an explicit list of resources to become Current.
+// The resources don't need to be part of the inventory, so this can be
+// used to wait for pre-existing resources that a set of manifests depend
+// on. Status updates are reported on the returned channel using the same
+// events as Run, and the channel is closed when the wait is over. The
+// Applier must have been initialized before calling PollStatus.
+func (a *Applier) PollStatus(ctx context.Context, objs []*object.ObjMetadata) <-chan event.Event {
+	ch := make(chan event.Event)
+
+	go func() {
+		defer close(ch)
+		for statusEvent := range a.statusPoller.WaitForCurrent(ctx, objs) {
 			ch <- event.Event{
-				Type: event.PruneType,
-				PruneEvent: event.PruneEvent{
-					Type: event.PruneEventCompleted,
-				},
+				Type:        event.StatusType,
+				Timestamp:   time.Now(),
+				StatusEvent: statusEvent,
 			}
 		}
 	}()
 	return ch
 }
 
-func infosToObjects(infos []*resource.Info) []wait.KubernetesObject {
-	var objects []wait.KubernetesObject
+// countReconciled returns the number of resources that are either
+// Current or NotFound, which is what the applier waits for.
+func countReconciled(statuses map[string]status.Status) int {
+	count := 0
+	for _, s := range statuses {
+		if s == status.CurrentStatus || s == status.NotFoundStatus {
+			count++
+		}
+	}
+	return count
+}
+
+func infosToObjMetadata(infos []*resource.Info) []*object.ObjMetadata {
+	var objs []*object.ObjMetadata
 	for _, info := range infos {
-		u := info.Object.(*unstructured.Unstructured)
-		objects = append(objects, u)
+		objMeta := infoToObjMetadata(info)
+		objs = append(objs, &objMeta)
+	}
+	return objs
+}
+
+func infoToObjMetadata(info *resource.Info) object.ObjMetadata {
+	return object.ObjMetadata{
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		GroupKind: info.Object.GetObjectKind().GroupVersionKind().GroupKind(),
+	}
+}
+
+// logger returns the Logger, or a logger that discards
+// everything if none has been set.
+func (a *Applier) logger() logr.Logger {
+	if a.Logger == nil {
+		return log.NullLogger{}
 	}
-	return objects
+	return a.Logger
 }
@@ -0,0 +1,333 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observers"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestPollStatus(t *testing.T) {
+	objs := []*object.ObjMetadata{
+		{
+			Namespace: "default",
+			Name:      "db",
+			GroupKind: schema.GroupKind{Group: "apps", Kind: "StatefulSet"},
+		},
+	}
+	fakePoller := &fakeStatusPoller{
+		events: []pollevent.Event{
+			{
+				EventType:       pollevent.ResourceUpdateEvent,
+				AggregateStatus: status.CurrentStatus,
+			},
+			{
+				EventType:       pollevent.CompletedEvent,
+				AggregateStatus: status.CurrentStatus,
+			},
+		},
+	}
+	applier := &Applier{
+		statusPoller: fakePoller,
+	}
+
+	var events []event.Event
+	for e := range applier.PollStatus(context.Background(), objs) {
+		events = append(events, e)
+	}
+
+	assert.Equal(t, objs, fakePoller.objs)
+	assert.Len(t, events, 2)
+	for i, e := range events {
+		assert.Equal(t, event.StatusType, e.Type)
+		assert.Equal(t, fakePoller.events[i], e.StatusEvent)
+	}
+}
+
+// fakeStatusPoller records the resources it was asked to wait
+// for and returns a predefined list of events.
+type fakeStatusPoller struct {
+	objs   []*object.ObjMetadata
+	events []pollevent.Event
+}
+
+func (f *fakeStatusPoller) WaitForCurrent(_ context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event {
+	f.objs = objs
+	ch := make(chan pollevent.Event, len(f.events))
+	for _, e := range f.events {
+		ch <- e
+	}
+	close(ch)
+	return ch
+}
+
+func (f *fakeStatusPoller) WaitForDeleted(ctx context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event {
+	return f.WaitForCurrent(ctx, objs)
+}
+
+func TestCheckInventorySize(t *testing.T) {
+	testCases := map[string]struct {
+		items         int
+		serverSide    bool
+		expectWarning bool
+		expectErr     bool
+	}{
+		"small inventory": {
+			items: 100,
+		},
+		"close to the limit": {
+			items:         5000,
+			expectWarning: true,
+		},
+		"over the limit": {
+			items:     7000,
+			expectErr: true,
+		},
+		"server-side apply": {
+			items:      7000,
+			serverSide: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			infos := []*resource.Info{groupingInfo("test")}
+			for i := 0; i < tc.items; i++ {
+				infos = append(infos, validatorInfo("v1", "ConfigMap", "default", fmt.Sprintf("cm-%05d", i), ""))
+			}
+			require.NoError(t, prune.AddInventoryToGroupingObj(infos))
+			ioStreams, _, _, errOut := genericclioptions.NewTestIOStreams()
+			applier := NewApplier(nil, ioStreams)
+			applier.ApplyOptions.ServerSideApply = tc.serverSide
+
+			err := applier.checkInventorySize(infos)
+			if tc.expectErr {
+				var tooLarge *prune.InventoryTooLargeError
+				require.True(t, errors.As(err, &tooLarge))
+				assert.Equal(t, tc.items, tooLarge.Items)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectWarning {
+				assert.Contains(t, errOut.String(), "warning: the inventory of 5000 resources")
+			} else {
+				assert.Empty(t, errOut.String())
+			}
+		})
+	}
+}
+
+func TestRunReadinessRules(t *testing.T) {
+	// The rule for the ConfigMaps applies to the inventory too.
+	notReady := map[schema.GroupKind]observers.CELRule{
+		{Kind: "ConfigMap"}: {Ready: "object.metadata.name.startsWith('inventory') || has(object.data.done)"},
+	}
+	// The custom observers report every resource as Current.
+	currentObservers := func(reader observer.ClusterReader, mapper meta.RESTMapper) (
+		map[schema.GroupKind]observer.ResourceObserver, observer.ResourceObserver) {
+		o := observers.NewGenericObserver(reader, mapper)
+		o.SetComputeStatusFunc(func(u *unstructured.Unstructured) (*status.Result, error) {
+			return &status.Result{Status: status.CurrentStatus}, nil
+		})
+		return nil, o
+	}
+	testCases := map[string]struct {
+		annotations map[string]string
+		rules       map[schema.GroupKind]observers.CELRule
+		observers   observer.ObserversFactoryFunc
+		expectCode  int
+	}{
+		"ready annotation": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready == 'true'"},
+		},
+		"not ready annotation": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready == 'false'"},
+			expectCode:  ExitReconcileTimeout,
+		},
+		"not ready rule": {
+			rules:      notReady,
+			expectCode: ExitReconcileTimeout,
+		},
+		"annotation overriding the rule": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready == 'true'"},
+			rules:       notReady,
+		},
+		"not ready annotation with custom observers": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready == 'false'"},
+			observers:   currentObservers,
+			expectCode:  ExitReconcileTimeout,
+		},
+		"ready annotation with custom observers": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready == 'true'"},
+			observers:   currentObservers,
+		},
+		"invalid annotation": {
+			annotations: map[string]string{observers.ReadyWhenAnnotation: "object.data.ready =="},
+			expectCode:  ExitValidationError,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			opts := []ApplierOption{
+				WithTimeout(500 * time.Millisecond), WithPollInterval(10 * time.Millisecond),
+				WithReadinessRules(tc.rules),
+			}
+			if tc.observers != nil {
+				opts = append(opts, WithStatusObservers(tc.observers))
+			}
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				opts...)
+			require.NoError(t, err)
+			applier.StatusOptions.Wait = true
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			a := configMap("a", nil)
+			a.Object["data"] = map[string]interface{}{"ready": "true"}
+			a.SetAnnotations(tc.annotations)
+			var errs []error
+			for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{inventory, a}) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+
+			if tc.expectCode == 0 {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tc.expectCode, ExitCode(errs[0]))
+		})
+	}
+}
+
+func TestInitializeInvalidReadinessRule(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithReadinessRules(map[schema.GroupKind]observers.CELRule{
+			{Group: "example.com", Kind: "Cron"}: {Ready: "size(object.status.phase)"},
+		}))
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	err = applier.Initialize(cmd, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Cron.example.com")
+}
+
+func TestRunTwiceNormalizedManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "applier-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// A JSON array is normalized into a temporary file by Initialize.
+	manifest := filepath.Join(dir, "package.json")
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf(`[
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "inventory", "labels": {%q: "test"}}},
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}
+]`, prune.GroupingLabel)), 0600))
+
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	applier.StatusOptions.NoWait = true
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", manifest))
+	require.NoError(t, applier.Initialize(cmd, nil))
+	normalizedDir := applier.remote.dir
+	require.NotEmpty(t, normalizedDir)
+
+	for i := 0; i < 2; i++ {
+		for e := range applier.Run(context.Background()) {
+			if e.Type == event.ErrorType {
+				t.Errorf("run %d: unexpected error: %v", i, e.ErrorEvent.Err)
+			}
+		}
+		assert.NotNil(t, cluster.Get(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			fakecluster.DefaultNamespace, "a"))
+	}
+
+	require.NoError(t, applier.Close())
+	_, err = os.Stat(normalizedDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunRedactsAppliedSecrets(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(), WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	secret := &unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetName("credentials")
+	require.NoError(t, unstructured.SetNestedStringMap(secret.Object, map[string]string{"password": "c2VjcmV0"}, "data"))
+	objs := []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		secret,
+	}
+	// The Secret is created by the first run, and updated by the second.
+	for i := 0; i < 2; i++ {
+		var applied []*unstructured.Unstructured
+		for e := range applier.RunObjects(context.Background(), objs) {
+			require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+			if e.Type == event.ApplyType && e.ApplyEvent.Type == event.ApplyEventResourceUpdate &&
+				e.ApplyEvent.Identifier.GroupKind.Kind == "Secret" {
+				applied = append(applied, e.ApplyEvent.Object.(*unstructured.Unstructured))
+			}
+		}
+		require.Len(t, applied, 1)
+		data, _, err := unstructured.NestedStringMap(applied[0].Object, "data")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"password": object.RedactedValue}, data)
+		assert.Equal(t, object.RedactedValue,
+			applied[0].GetAnnotations()["kubectl.kubernetes.io/last-applied-configuration"])
+	}
+}
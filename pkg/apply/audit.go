@@ -0,0 +1,120 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"os/user"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// The annotations recording who applied a resource, and when. They
+// are left out of diffs, since they change with every run.
+const (
+	AppliedByAnnotation      = "cli-utils.sigs.k8s.io/applied-by"
+	AppliedAtAnnotation      = "cli-utils.sigs.k8s.io/applied-at"
+	PipelineIDAnnotation     = "cli-utils.sigs.k8s.io/pipeline-id"
+	PackageVersionAnnotation = "cli-utils.sigs.k8s.io/package-version"
+)
+
+// auditAnnotations are all the annotations that can be set by an audit.
+var auditAnnotations = []string{
+	AppliedByAnnotation,
+	AppliedAtAnnotation,
+	PipelineIDAnnotation,
+	PackageVersionAnnotation,
+}
+
+// AuditOptions determine whether the applied resources are annotated
+// with who applied them and when, so the origin of a change can be
+// found after the fact. Since the time of the run is recorded, every
+// resource is updated by every run.
+type AuditOptions struct {
+	// Enabled turns on the annotations. It is implied by
+	// a PipelineID or a PackageVersion.
+	Enabled bool
+	// User is recorded as who applied the resources. If it is empty,
+	// the user of the current context of the kubeconfig is recorded,
+	// or the user running the process if the context has no user.
+	User string
+	// PipelineID identifies the pipeline or job that applied
+	// the resources. It is not recorded if it is empty.
+	PipelineID string
+	// PackageVersion is the version of the package, like a release
+	// or a commit. It is not recorded if it is empty.
+	PackageVersion string
+}
+
+// addAuditFlags adds the flags for the audit annotations.
+func addAuditFlags(cmd *cobra.Command, o *AuditOptions) {
+	cmd.Flags().BoolVar(&o.Enabled, "audit", o.Enabled,
+		"If true, annotate the applied resources with who applied them and when. Every resource is updated by "+
+			"every run, since the time changes.")
+	cmd.Flags().StringVar(&o.User, "audit-user", o.User,
+		"With --audit, who is recorded as having applied the resources. Defaults to the user of the current "+
+			"context of the kubeconfig.")
+	cmd.Flags().StringVar(&o.PipelineID, "audit-pipeline-id", o.PipelineID,
+		"Record the ID of the pipeline or job applying the resources. Implies --audit.")
+	cmd.Flags().StringVar(&o.PackageVersion, "audit-package-version", o.PackageVersion,
+		"Record the version of the package being applied, like a release or a commit. Implies --audit.")
+}
+
+// initialize enables the annotations if any value has been set, and
+// defaults the user from the kubeconfig.
+func (o *AuditOptions) initialize(loader clientcmd.ClientConfig) {
+	if o.PipelineID != "" || o.PackageVersion != "" {
+		o.Enabled = true
+	}
+	if !o.Enabled || o.User != "" {
+		return
+	}
+	if config, err := loader.RawConfig(); err == nil {
+		if kubeContext, found := config.Contexts[config.CurrentContext]; found {
+			o.User = kubeContext.AuthInfo
+		}
+	}
+	if o.User == "" {
+		if u, err := user.Current(); err == nil {
+			o.User = u.Username
+		}
+	}
+}
+
+// annotate sets the audit annotations on the resources, with the
+// given time as the time they were applied.
+func (o AuditOptions) annotate(infos []*resource.Info, now time.Time) error {
+	if !o.Enabled {
+		return nil
+	}
+	values := map[string]string{
+		AppliedByAnnotation:      o.User,
+		AppliedAtAnnotation:      now.UTC().Format(time.RFC3339),
+		PipelineIDAnnotation:     o.PipelineID,
+		PackageVersionAnnotation: o.PackageVersion,
+	}
+	for _, info := range infos {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return err
+		}
+		annotations := accessor.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		for key, value := range values {
+			// Values left out are removed from the resources if a
+			// previous run set them, since the annotations are part
+			// of the last applied configuration.
+			if value != "" {
+				annotations[key] = value
+			}
+		}
+		accessor.SetAnnotations(annotations)
+	}
+	return nil
+}
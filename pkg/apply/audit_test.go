@@ -0,0 +1,101 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func TestAuditOptionsAnnotate(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	testCases := map[string]struct {
+		options     AuditOptions
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		"disabled": {
+			options:     AuditOptions{User: "admin"},
+			annotations: map[string]string{"owner": "team-a"},
+			expected:    map[string]string{"owner": "team-a"},
+		},
+		"user and time": {
+			options: AuditOptions{Enabled: true, User: "admin"},
+			expected: map[string]string{
+				AppliedByAnnotation: "admin",
+				AppliedAtAnnotation: "2020-01-02T02:04:05Z",
+			},
+		},
+		"all values with existing annotations": {
+			options: AuditOptions{
+				Enabled:        true,
+				User:           "ci",
+				PipelineID:     "build-42",
+				PackageVersion: "v1.2.3",
+			},
+			annotations: map[string]string{"owner": "team-a"},
+			expected: map[string]string{
+				"owner":                  "team-a",
+				AppliedByAnnotation:      "ci",
+				AppliedAtAnnotation:      "2020-01-02T02:04:05Z",
+				PipelineIDAnnotation:     "build-42",
+				PackageVersionAnnotation: "v1.2.3",
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			u := configMap("cm", nil)
+			u.SetAnnotations(tc.annotations)
+			require.NoError(t, tc.options.annotate([]*resource.Info{{Object: u}}, now))
+			assert.Equal(t, tc.expected, u.GetAnnotations())
+		})
+	}
+}
+
+func TestRunWithAudit(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, cmd.Flags().Set("audit-pipeline-id", "build-42"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		configMap("cm", nil),
+	}) {
+		require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+	}
+
+	cm := cluster.Get(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, fakecluster.DefaultNamespace, "cm")
+	require.NotNil(t, cm)
+	annotations := cm.GetAnnotations()
+	// The user of the current context of the kubeconfig.
+	assert.Equal(t, "fakecluster", annotations[AppliedByAnnotation])
+	assert.Equal(t, "build-42", annotations[PipelineIDAnnotation])
+	assert.NotEmpty(t, annotations[AppliedAtAnnotation])
+	assert.NotContains(t, annotations, PackageVersionAnnotation)
+}
@@ -6,14 +6,14 @@ package apply
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
-	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
 )
 
 // BasicPrinter is a simple implementation that just prints the events
@@ -21,6 +21,9 @@ import (
 // We need to support different printers for different output formats.
 type BasicPrinter struct {
 	IOStreams genericclioptions.IOStreams
+	// NoColor turns off colors in the output. Colors are only
+	// used if the output is a terminal.
+	NoColor bool
 }
 
 // Print outputs the events from the provided channel in a simple
@@ -28,10 +31,18 @@ type BasicPrinter struct {
 // this should probably be an interface.
 // This function will block until the channel is closed.
 func (b *BasicPrinter) Print(ch <-chan event.Event) {
+	withColor := !b.NoColor && useColor(b.IOStreams.Out)
+	// c returns the color if colors are enabled.
+	c := func(col color) color {
+		if withColor {
+			return col
+		}
+		return noColor
+	}
 	for e := range ch {
 		switch e.Type {
 		case event.ErrorType:
-			cmdutil.CheckErr(e.ErrorEvent.Err)
+			CheckErr(b.IOStreams.ErrOut, e.ErrorEvent.Err)
 		case event.ApplyType:
 			ae := e.ApplyEvent
 			if ae.Type == event.ApplyEventCompleted {
@@ -41,20 +52,23 @@ func (b *BasicPrinter) Print(ch <-chan event.Event) {
 				gvk := obj.GetObjectKind().GroupVersionKind()
 				name := getName(obj)
 				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name),
-					strings.ToLower(ae.Operation.String()))
+					colorize(c(operationColor(ae.Operation)), operationToString(ae.Operation)))
 			}
 		case event.StatusType:
 			statusEvent := e.StatusEvent
-			switch statusEvent.Type {
-			case wait.ResourceUpdate:
-				id := statusEvent.EventResource.ResourceIdentifier
+			switch statusEvent.EventType {
+			case pollevent.ResourceUpdateEvent:
+				id := statusEvent.Resource.Identifier
 				gk := id.GroupKind
+				s := statusEvent.Resource.Status
 				fmt.Fprintf(b.IOStreams.Out, "%s is %s: %s\n", resourceIDToString(gk, id.Name),
-					statusEvent.EventResource.Status.String(), statusEvent.EventResource.Message)
-			case wait.Completed:
+					colorize(c(statusColor(s)), s.String()), statusEvent.Resource.Message)
+			case pollevent.CompletedEvent:
 				fmt.Fprint(b.IOStreams.Out, "all resources has reached the Current status\n")
-			case wait.Aborted:
-				fmt.Fprintf(b.IOStreams.Out, "resources failed to the reached Current status\n")
+			case pollevent.AbortedEvent:
+				fmt.Fprintf(b.IOStreams.Out, "%s\n", colorize(c(red), "resources failed to the reached Current status"))
+			case pollevent.ErrorEvent:
+				fmt.Fprintf(b.IOStreams.Out, "error waiting for status: %v\n", statusEvent.Error)
 			}
 		case event.PruneType:
 			pe := e.PruneEvent
@@ -64,7 +78,11 @@ func (b *BasicPrinter) Print(ch <-chan event.Event) {
 				obj := e.PruneEvent.Object
 				gvk := obj.GetObjectKind().GroupVersionKind()
 				name := getName(obj)
-				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name), "pruned")
+				if pe.Operation == event.PruneSkipped {
+					fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name), colorize(c(yellow), "prune skipped"))
+					break
+				}
+				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name), colorize(c(red), "pruned"))
 			}
 		case event.DeleteType:
 			de := e.DeleteEvent
@@ -74,12 +92,44 @@ func (b *BasicPrinter) Print(ch <-chan event.Event) {
 				obj := de.Object
 				gvk := obj.GetObjectKind().GroupVersionKind()
 				name := getName(obj)
-				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name), "deleted")
+				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(gvk.GroupKind(), name), colorize(c(red), "deleted"))
+			}
+		case event.DiffType:
+			if e.DiffEvent.Prune {
+				id := e.DiffEvent.Identifier
+				fmt.Fprintf(b.IOStreams.Out, "%s %s\n", resourceIDToString(id.GroupKind, id.Name),
+					colorize(c(red), "will be pruned"))
+				break
 			}
+			fmt.Fprint(b.IOStreams.Out, e.DiffEvent.Diff)
+		case event.ProgressType:
+			fmt.Fprintf(b.IOStreams.Out, "%s\n", progressToString(e.ProgressEvent))
 		}
 	}
 }
 
+// progressToString returns a single line describing the progress
+// of a phase, including the estimated time remaining if known.
+func progressToString(pe event.ProgressEvent) string {
+	var progress string
+	switch pe.Phase {
+	case event.ApplyPhase:
+		progress = fmt.Sprintf("progress: applied %d/%d", pe.Completed, pe.Total)
+	case event.WaitPhase:
+		progress = fmt.Sprintf("progress: waiting on %d of %d resources", pe.Total-pe.Completed, pe.Total)
+	case event.PrunePhase:
+		progress = fmt.Sprintf("progress: pruned %d/%d", pe.Completed, pe.Total)
+	case event.DeletePhase:
+		progress = fmt.Sprintf("progress: deleted %d/%d", pe.Completed, pe.Total)
+	case event.ConditionPhase:
+		progress = fmt.Sprintf("progress: waiting on %d of %d conditions", pe.Total-pe.Completed, pe.Total)
+	}
+	if eta := pe.ETA.Round(time.Second); eta > 0 {
+		progress = fmt.Sprintf("%s (ETA %s)", progress, eta)
+	}
+	return progress
+}
+
 func getName(obj runtime.Object) string {
 	if acc, err := meta.Accessor(obj); err == nil {
 		if n := acc.GetName(); len(n) > 0 {
@@ -93,3 +143,13 @@ func getName(obj runtime.Object) string {
 func resourceIDToString(gk schema.GroupKind, name string) string {
 	return fmt.Sprintf("%s/%s", strings.ToLower(gk.String()), name)
 }
+
+// operationToString returns the apply operation as it is shown in
+// the output. Server-side applies are spelled out, so it is clear
+// which mode every resource was applied with.
+func operationToString(op event.ApplyEventOperation) string {
+	if op == event.ServersideApplied {
+		return "server-side applied"
+	}
+	return strings.ToLower(op.String())
+}
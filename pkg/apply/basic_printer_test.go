@@ -0,0 +1,25 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+func TestOperationToString(t *testing.T) {
+	testCases := map[event.ApplyEventOperation]string{
+		event.ServersideApplied: "server-side applied",
+		event.Created:           "created",
+		event.Configured:        "configured",
+		event.Unchanged:         "unchanged",
+		event.Filtered:          "filtered",
+	}
+
+	for op, expected := range testCases {
+		assert.Equal(t, expected, operationToString(op))
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+// benchmarkSizes are the numbers of resources and kinds
+// of the packages the benchmarks are run with.
+var benchmarkSizes = []struct {
+	objects int
+	kinds   int
+}{
+	{objects: 100, kinds: 5},
+	{objects: 1000, kinds: 20},
+}
+
+func BenchmarkPlan(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("objects=%d,kinds=%d", size.objects, size.kinds), func(b *testing.B) {
+			p := fakecluster.GeneratePackage(size.objects, size.kinds)
+			read := make([]*resource.Info, 0, len(p.Objects))
+			// Reversed, so there is something to sort.
+			for i := len(p.Objects) - 1; i >= 0; i-- {
+				read = append(read, &resource.Info{Name: p.Objects[i].GetName(), Object: p.Objects[i]})
+			}
+			infos := make([]*resource.Info, len(read))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(infos, read)
+				if _, err := (SortingPlanner{}).Plan(context.Background(), infos); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRunObjects measures a complete run against a fake cluster,
+// including the apply, the inventory and the prune. Every iteration
+// applies the package again, so after the first one the resources are
+// updated and the previous grouping object is pruned.
+func BenchmarkRunObjects(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("objects=%d,kinds=%d", size.objects, size.kinds), func(b *testing.B) {
+			cluster, err := fakecluster.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			p := fakecluster.GeneratePackage(size.objects, size.kinds)
+			p.AddKinds(cluster)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithNoWait())
+			if err != nil {
+				b.Fatal(err)
+			}
+			cmd := &cobra.Command{}
+			if err := applier.SetFlags(cmd); err != nil {
+				b.Fatal(err)
+			}
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			if err := cmd.Flags().Set("filename", "-"); err != nil {
+				b.Fatal(err)
+			}
+			if err := applier.Initialize(cmd, nil); err != nil {
+				b.Fatal(err)
+			}
+			objs := append([]*unstructured.Unstructured{
+				configMap("inventory", map[string]string{prune.GroupingLabel: "bench"}),
+			}, p.Objects...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for e := range applier.RunObjects(context.Background(), objs) {
+					if e.Type == event.ErrorType {
+						b.Fatal(e.ErrorEvent.Err)
+					}
+				}
+			}
+		})
+	}
+}
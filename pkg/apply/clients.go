@@ -0,0 +1,156 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-errors/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/cli-utils/pkg/apply/poller"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StatusPoller waits for resources to reach a status. It is
+// implemented by poller.StatusPoller.
+type StatusPoller interface {
+	WaitForCurrent(ctx context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event
+	WaitForDeleted(ctx context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event
+}
+
+// Clients are the clients the Applier and the Destroyer use to talk
+// to the cluster. The ones that are nil are created from the factory,
+// so controllers can pass the clients they already have, and tests
+// can pass fakes. The manifests are always read, and the resources
+// applied, with the clients from the factory.
+type Clients struct {
+	// DynamicClient is used to prune and to create CRDs.
+	DynamicClient dynamic.Interface
+	// Clientset is used to read the values injected into
+	// the resources, and to check namespaces.
+	Clientset kubernetes.Interface
+	// Mapper maps the kinds of the resources. If it has a Reset
+	// method, it is called before waiting for new kinds.
+	Mapper meta.RESTMapper
+	// StatusPoller is used to wait for the resources to reconcile
+	// and to be deleted. The poll interval from the flags is
+	// ignored if it is set.
+	StatusPoller StatusPoller
+}
+
+// setApplyOptionsClients replaces the clients the ApplyOptions
+// created from the factory with the ones from the Clients.
+func setApplyOptionsClients(o *apply.ApplyOptions, clients Clients) {
+	if clients.DynamicClient != nil {
+		o.DynamicClient = clients.DynamicClient
+	}
+	if clients.Mapper != nil {
+		o.Mapper = clients.Mapper
+	}
+}
+
+// resettableMapper is a mapper that caches the discovery
+// information, like the DeferredDiscoveryRESTMapper.
+type resettableMapper interface {
+	Reset()
+}
+
+// restMapper returns the mapper from the Clients, or the one from the
+// factory. The mapper is reset first if fresh is true, so it sees kinds
+// that were added since it read the discovery information. Mappers that
+// can't be reset are created for every call by the factory, so they
+// always read the discovery information.
+func restMapper(clients Clients, factory util.Factory, fresh bool) (meta.RESTMapper, error) {
+	mapper := clients.Mapper
+	if mapper == nil {
+		var err error
+		mapper, err = factory.ToRESTMapper()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r, ok := mapper.(resettableMapper); ok && fresh {
+		r.Reset()
+	}
+	return mapper, nil
+}
+
+// refreshOnceMapper resets the mapper it wraps the first time a kind is
+// not found, and tries again, since the kind might have been registered
+// moments ago, after the mapper read the discovery information. It only
+// does so once, so the discovery information is not read again for
+// every resource of a kind that really doesn't exist.
+type refreshOnceMapper struct {
+	meta.RESTMapper
+	refreshed bool
+}
+
+func (m *refreshOnceMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	mapping, err := m.RESTMapper.RESTMapping(gk, versions...)
+	if !meta.IsNoMatchError(err) || m.refreshed {
+		return mapping, err
+	}
+	m.refreshed = true
+	r, ok := m.RESTMapper.(resettableMapper)
+	if !ok {
+		return mapping, err
+	}
+	r.Reset()
+	return m.RESTMapper.RESTMapping(gk, versions...)
+}
+
+// dynamicClient returns the dynamic client from the Clients,
+// or a new one from the factory.
+func dynamicClient(clients Clients, factory util.Factory) (dynamic.Interface, error) {
+	if clients.DynamicClient != nil {
+		return clients.DynamicClient, nil
+	}
+	return factory.DynamicClient()
+}
+
+// clientset returns the clientset from the Clients,
+// or a new one from the factory.
+func clientset(clients Clients, factory util.Factory) (kubernetes.Interface, error) {
+	if clients.Clientset != nil {
+		return clients.Clientset, nil
+	}
+	return factory.KubernetesClientSet()
+}
+
+// newStatusPoller returns the StatusPoller from the Clients, or sets up
+// a new StatusPoller for computing status. The configuration needed for
+// the poller is taken from the Factory. The options are passed on to
+// the new StatusPoller.
+func newStatusPoller(clients Clients, factory util.Factory, pollInterval time.Duration,
+	opts ...poller.Option) (StatusPoller, error) {
+	if clients.StatusPoller != nil {
+		return clients.StatusPoller, nil
+	}
+	config, err := factory.ToRESTConfig()
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error getting RESTConfig", 1)
+	}
+
+	mapper, err := restMapper(clients, factory, false)
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error getting RESTMapper", 1)
+	}
+
+	c, err := client.New(config, client.Options{Scheme: scheme.Scheme, Mapper: mapper})
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error creating client", 1)
+	}
+
+	opts = append([]poller.Option{poller.WithPollInterval(pollInterval)}, opts...)
+	return poller.NewStatusPoller(c, mapper, opts...), nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// recordingDynamicClient records the resources it is used for.
+type recordingDynamicClient struct {
+	dynamic.Interface
+	resources []schema.GroupVersionResource
+}
+
+func (c *recordingDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	c.resources = append(c.resources, resource)
+	return c.Interface.Resource(resource)
+}
+
+func TestApplierWithClients(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "clients-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	write("grouping.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: inventory\n  labels:\n"+
+		"    cli-utils.sigs.k8s.io/inventory-id: test\n")
+	write("cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")
+
+	dynamicClient := &recordingDynamicClient{Interface: cluster.DynamicClient()}
+	statusPoller := &fakeStatusPoller{
+		events: []pollevent.Event{
+			{EventType: pollevent.CompletedEvent, AggregateStatus: status.CurrentStatus},
+		},
+	}
+	applier := NewApplierWithClients(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(), Clients{
+		DynamicClient: dynamicClient,
+		StatusPoller:  statusPoller,
+	})
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	require.NoError(t, cmd.Flags().Set("wait", "true"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	for e := range applier.Run(context.Background()) {
+		if e.Type == event.ErrorType {
+			t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+		}
+	}
+	// The status poller waited for the resources, and the
+	// previous grouping objects were looked up for the prune.
+	assert.Len(t, statusPoller.objs, 2)
+	assert.Contains(t, dynamicClient.resources, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+}
+
+// resettingMapper learns the kind once it has been reset.
+type resettingMapper struct {
+	*meta.DefaultRESTMapper
+	kind   schema.GroupVersionKind
+	resets int
+}
+
+func (m *resettingMapper) Reset() {
+	m.resets++
+	m.Add(m.kind, meta.RESTScopeNamespace)
+}
+
+func TestRefreshOnceMapper(t *testing.T) {
+	widget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	mapper := &resettingMapper{
+		DefaultRESTMapper: meta.NewDefaultRESTMapper(nil),
+		kind:              widget,
+	}
+	refreshing := &refreshOnceMapper{RESTMapper: mapper}
+
+	_, err := refreshing.RESTMapping(widget.GroupKind(), widget.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mapper.resets)
+
+	// Kinds that are still unknown don't cause another reset.
+	_, err = refreshing.RESTMapping(schema.GroupKind{Group: "example.com", Kind: "Gadget"}, "v1")
+	assert.True(t, meta.IsNoMatchError(err))
+	assert.Equal(t, 1, mapper.resets)
+}
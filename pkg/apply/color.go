@@ -0,0 +1,69 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/kubectl/pkg/util/term"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// color is an ANSI escape code for a foreground color.
+type color int
+
+const (
+	noColor color = 0
+	red     color = 31
+	green   color = 32
+	yellow  color = 33
+)
+
+// colorize wraps the text in the escape codes for the color, unless
+// the color is noColor.
+func colorize(c color, text string) string {
+	if c == noColor {
+		return text
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", c, text)
+}
+
+// useColor returns whether the output written to w should be
+// colorized. Colors are only used when writing to a terminal and
+// can be turned off with the NO_COLOR environment variable.
+func useColor(w io.Writer) bool {
+	if _, found := os.LookupEnv("NO_COLOR"); found {
+		return false
+	}
+	return term.IsTerminal(w)
+}
+
+// statusColor returns the color used for the given status.
+func statusColor(s status.Status) color {
+	switch s {
+	case status.CurrentStatus:
+		return green
+	case status.InProgressStatus, status.TerminatingStatus:
+		return yellow
+	case status.FailedStatus:
+		return red
+	default:
+		return noColor
+	}
+}
+
+// operationColor returns the color used for the given apply operation.
+func operationColor(op event.ApplyEventOperation) color {
+	switch op {
+	case event.Created, event.ServersideApplied:
+		return green
+	case event.Configured:
+		return yellow
+	default:
+		return noColor
+	}
+}
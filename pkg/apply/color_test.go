@@ -0,0 +1,34 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func TestColorize(t *testing.T) {
+	assert.Equal(t, "Current", colorize(noColor, "Current"))
+	assert.Equal(t, "\x1b[32mCurrent\x1b[0m", colorize(green, "Current"))
+}
+
+func TestStatusColor(t *testing.T) {
+	testCases := map[status.Status]color{
+		status.CurrentStatus:    green,
+		status.InProgressStatus: yellow,
+		status.FailedStatus:     red,
+		status.UnknownStatus:    noColor,
+	}
+	for s, expected := range testCases {
+		assert.Equal(t, expected, statusColor(s), s.String())
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	// Colors should never be used when the output is not a terminal.
+	assert.False(t, useColor(&bytes.Buffer{}))
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// CompletionFunc is the signature cobra uses for the dynamic
+// completion of flag values.
+type CompletionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// InventoryIDCompletionFunc returns a function that completes the
+// inventory ids of the grouping objects in the namespace of the
+// factory. The cluster is queried on every completion, and nothing
+// is suggested if it can't be reached.
+func InventoryIDCompletionFunc(f util.Factory) CompletionFunc {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		client, err := f.KubernetesClientSet()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		namespace, _, err := f.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ids, err := listInventoryIDs(client, namespace)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterPrefix(ids, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// NamespaceCompletionFunc returns a function that completes the
+// namespaces of the cluster.
+func NamespaceCompletionFunc(f util.Factory) CompletionFunc {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		client, err := f.KubernetesClientSet()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		namespaces, err := listNamespaces(client)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterPrefix(namespaces, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// listInventoryIDs returns the sorted, unique inventory ids of the
+// grouping objects in the namespace.
+func listInventoryIDs(client kubernetes.Interface, namespace string) ([]string, error) {
+	list, err := client.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{
+		LabelSelector: prune.GroupingLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, cm := range list.Items {
+		id := cm.Labels[prune.GroupingLabel]
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// listNamespaces returns the sorted names of the namespaces.
+func listNamespaces(client kubernetes.Interface) ([]string, error) {
+	list, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, ns := range list.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// filterPrefix returns the values that start with the prefix.
+func filterPrefix(values []string, prefix string) []string {
+	var filtered []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+func groupingConfigMap(namespace, name, id string) *v1.ConfigMap {
+	labels := map[string]string{}
+	if id != "" {
+		labels[prune.GroupingLabel] = id
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+	}
+}
+
+func TestListInventoryIDs(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		groupingConfigMap("default", "inventory-b-1234", "b"),
+		groupingConfigMap("default", "inventory-a-1234", "a"),
+		groupingConfigMap("default", "inventory-a-5678", "a"),
+		groupingConfigMap("default", "not-an-inventory", ""),
+		groupingConfigMap("other", "inventory-c-1234", "c"),
+	)
+
+	ids, err := listInventoryIDs(client, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids)
+}
+
+func TestListNamespaces(t *testing.T) {
+	objs := []runtime.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	}
+	client := fake.NewSimpleClientset(objs...)
+
+	names, err := listNamespaces(client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default", "kube-system"}, names)
+}
+
+func TestFilterPrefix(t *testing.T) {
+	values := []string{"app", "apply", "web"}
+	assert.Equal(t, []string{"app", "apply"}, filterPrefix(values, "ap"))
+	assert.Equal(t, values, filterPrefix(values, ""))
+	assert.Empty(t, filterPrefix(values, "x"))
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func configMap(name string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetName(name)
+	u.SetLabels(labels)
+	return u
+}
+
+func TestRunObjectsConcurrently(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	packages := 5
+	var wg sync.WaitGroup
+	errs := make([][]error, packages)
+	for i := 0; i < packages; i++ {
+		objs := []*unstructured.Unstructured{
+			configMap(fmt.Sprintf("inventory-%d", i), map[string]string{
+				prune.GroupingLabel: fmt.Sprintf("package-%d", i),
+			}),
+			configMap(fmt.Sprintf("cm-%d", i), nil),
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for e := range applier.RunObjects(context.Background(), objs) {
+				if e.Type == event.ErrorType {
+					errs[i] = append(errs[i], e.ErrorEvent.Err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	for i := 0; i < packages; i++ {
+		assert.Empty(t, errs[i])
+		cm := cluster.Get(gvk, fakecluster.DefaultNamespace, fmt.Sprintf("cm-%d", i))
+		if assert.NotNil(t, cm) {
+			// Every resource belongs to the inventory of its own package.
+			assert.Equal(t, fmt.Sprintf("package-%d", i), cm.GetAnnotations()[prune.OwningInventoryAnnotation])
+		}
+	}
+}
+
+func TestRunObjectsDoesNotChangeObjects(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	objs := []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		configMap("cm", nil),
+	}
+	expected := []*unstructured.Unstructured{objs[0].DeepCopy(), objs[1].DeepCopy()}
+	for i := 0; i < 2; i++ {
+		for e := range applier.RunObjects(context.Background(), objs) {
+			if e.Type == event.ErrorType {
+				t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+			}
+		}
+	}
+	assert.Equal(t, expected, objs)
+}
+
+func TestRunObjectsInNamespaces(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	// Both packages use the same inventory id, in their own namespace.
+	run := func(namespace string, names ...string) {
+		inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+		inventory.SetNamespace(namespace)
+		objs := []*unstructured.Unstructured{inventory}
+		for _, name := range names {
+			cm := configMap(name, nil)
+			cm.SetNamespace(namespace)
+			objs = append(objs, cm)
+		}
+		for e := range applier.RunObjects(context.Background(), objs) {
+			require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+		}
+	}
+	run("team-a", "config", "removed")
+	run("team-b", "config", "removed")
+	run("team-a", "config")
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	assert.NotNil(t, cluster.Get(gvk, "team-a", "config"))
+	assert.Nil(t, cluster.Get(gvk, "team-a", "removed"))
+	assert.NotNil(t, cluster.Get(gvk, "team-b", "config"))
+	assert.NotNil(t, cluster.Get(gvk, "team-b", "removed"))
+	namespaces := map[string]int{}
+	for _, obj := range cluster.Objects() {
+		if prune.IsGroupingObject(obj) {
+			namespaces[obj.GetNamespace()]++
+		}
+	}
+	assert.Equal(t, map[string]int{"team-a": 1, "team-b": 1}, namespaces)
+}
+
+func TestObjectsToInfosDefaultsNamespaces(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	cm := configMap("cm", nil)
+	other := configMap("other", nil)
+	other.SetNamespace("team-a")
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("team-a")
+	infos, err := applier.objectsToInfos(context.Background(), []*unstructured.Unstructured{cm, other, ns})
+	require.NoError(t, err)
+	namespaces := map[string]string{}
+	for _, info := range infos {
+		assert.Equal(t, info.Namespace, info.Object.(*unstructured.Unstructured).GetNamespace())
+		namespaces[info.Object.GetObjectKind().GroupVersionKind().Kind+"/"+info.Name] = info.Namespace
+	}
+	assert.Equal(t, map[string]string{
+		"ConfigMap/cm":     fakecluster.DefaultNamespace,
+		"ConfigMap/other":  "team-a",
+		"Namespace/team-a": "",
+	}, namespaces)
+}
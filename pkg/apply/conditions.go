@@ -0,0 +1,421 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// WaitForAnnotation declares conditions a resource must meet, in
+// addition to being reconciled, before a run that waits is done. The
+// value is a semicolon-separated list of conditions in the format
+// TYPE:ARGUMENT, for example "field:data.ready=true; log:migrated".
+// The built-in types are field and log, and more can be added with the
+// ConditionParsers of the Applier.
+const WaitForAnnotation = "cli-utils.sigs.k8s.io/wait-for"
+
+// WaitCondition is a condition declared by a resource
+// with the WaitForAnnotation.
+type WaitCondition interface {
+	// Met returns true if the live resource meets the condition. An
+	// error aborts the wait, unless it is transient like the API server
+	// being unavailable, so it should only be returned if the condition
+	// can never be met.
+	Met(ctx context.Context, cc ConditionContext) (bool, error)
+	// String describes the condition, like it is declared.
+	String() string
+}
+
+// KindValidator is implemented by the conditions that can only be
+// declared by some kinds of resources. The kinds are checked before
+// anything is applied.
+type KindValidator interface {
+	// ValidateKind returns an error if a resource of the
+	// kind can't declare the condition.
+	ValidateKind(gk schema.GroupKind) error
+}
+
+// ConditionContext is passed to the conditions when they are checked.
+type ConditionContext struct {
+	// Object is the live resource.
+	Object *unstructured.Unstructured
+	// Client is used by the conditions that
+	// read other resources, like logs.
+	Client kubernetes.Interface
+}
+
+// ConditionParser returns the condition of its
+// type described by the argument.
+type ConditionParser func(arg string) (WaitCondition, error)
+
+// DefaultConditionParsers are the parsers of the built-in condition types.
+var DefaultConditionParsers = map[string]ConditionParser{
+	"field": ParseFieldCondition,
+	"log":   ParseLogCondition,
+}
+
+// ParseConditions parses the value of the WaitForAnnotation with
+// the parsers, which are keyed by the type of the conditions.
+func ParseConditions(value string, parsers map[string]ConditionParser) ([]WaitCondition, error) {
+	var conditions []WaitCondition
+	for _, s := range strings.Split(value, ";") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("condition %q must be in the format TYPE:ARGUMENT", s)
+		}
+		parse, found := parsers[parts[0]]
+		if !found {
+			return nil, fmt.Errorf("condition %q has unknown type %q", s, parts[0])
+		}
+		c, err := parse(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %v", s, err)
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// FieldCondition is met once a field of the resource is set, or has
+// the given value. It is declared as field:PATH or field:PATH=VALUE,
+// where PATH is the dot-separated path to the field.
+type FieldCondition struct {
+	// Path is the path to the field. Only maps can be traversed.
+	Path []string
+	// Value is compared with the value of the field, formatted as a
+	// string, if HasValue is set. Otherwise the field must be set.
+	Value    string
+	HasValue bool
+}
+
+// ParseFieldCondition parses the argument of a field condition.
+func ParseFieldCondition(arg string) (WaitCondition, error) {
+	c := &FieldCondition{}
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) == 2 {
+		c.Value = parts[1]
+		c.HasValue = true
+	}
+	c.Path = strings.Split(parts[0], ".")
+	for _, p := range c.Path {
+		if p == "" {
+			return nil, fmt.Errorf("the path %q has an empty element", parts[0])
+		}
+	}
+	return c, nil
+}
+
+// Met returns true if the field is set, and has the value if any.
+func (c *FieldCondition) Met(_ context.Context, cc ConditionContext) (bool, error) {
+	v, found, err := unstructured.NestedFieldNoCopy(cc.Object.Object, c.Path...)
+	if err != nil || !found || v == nil {
+		return false, nil
+	}
+	return !c.HasValue || fmt.Sprint(v) == c.Value, nil
+}
+
+func (c *FieldCondition) String() string {
+	s := "field:" + strings.Join(c.Path, ".")
+	if c.HasValue {
+		s += "=" + c.Value
+	}
+	return s
+}
+
+// LogCondition is met once the log of a Pod, or of any of the Pods of
+// a Job, contains the text. It is declared as log:TEXT. Only the lines
+// logged since the last check are read, so the text is not found if it
+// spans the lines of two checks.
+type LogCondition struct {
+	Text string
+
+	// since is the timestamp of the last line read from the log of
+	// every container, keyed by the namespace, Pod and container.
+	since map[string]metav1.Time
+}
+
+// ParseLogCondition parses the argument of a log condition.
+func ParseLogCondition(arg string) (WaitCondition, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("the text must not be empty")
+	}
+	return &LogCondition{Text: arg}, nil
+}
+
+var (
+	podGroupKind = schema.GroupKind{Kind: "Pod"}
+	jobGroupKind = schema.GroupKind{Group: "batch", Kind: "Job"}
+)
+
+// ValidateKind returns an error unless the kind is Pod or Job.
+func (c *LogCondition) ValidateKind(gk schema.GroupKind) error {
+	if gk != podGroupKind && gk != jobGroupKind {
+		return fmt.Errorf("log conditions can only be declared by Pods and Jobs, not by %s", gk)
+	}
+	return nil
+}
+
+// Met returns true if the log of any container contains the text.
+// The logs of containers that haven't started yet can't be read, so
+// they are left out.
+func (c *LogCondition) Met(_ context.Context, cc ConditionContext) (bool, error) {
+	gk := cc.Object.GroupVersionKind().GroupKind()
+	if err := c.ValidateKind(gk); err != nil {
+		return false, err
+	}
+	var pods []corev1.Pod
+	switch gk {
+	case podGroupKind:
+		pod, err := cc.Client.CoreV1().Pods(cc.Object.GetNamespace()).Get(cc.Object.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		pods = append(pods, *pod)
+	case jobGroupKind:
+		list, err := cc.Client.CoreV1().Pods(cc.Object.GetNamespace()).List(metav1.ListOptions{
+			LabelSelector: "job-name=" + cc.Object.GetName(),
+		})
+		if err != nil {
+			return false, err
+		}
+		pods = list.Items
+	}
+	if c.since == nil {
+		c.since = make(map[string]metav1.Time)
+	}
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			key := pod.Namespace + "/" + pod.Name + "/" + container.Name
+			opts := &corev1.PodLogOptions{
+				Container:  container.Name,
+				Timestamps: true,
+			}
+			if since, found := c.since[key]; found {
+				opts.SinceTime = &since
+			}
+			raw, err := cc.Client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Do().Raw()
+			if err != nil {
+				continue
+			}
+			log, last := stripLogTimestamps(raw)
+			if !last.IsZero() {
+				c.since[key] = last
+			}
+			if strings.Contains(log, c.Text) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// stripLogTimestamps removes the timestamps the lines of the log are
+// prefixed with, and returns the log with the timestamp of its last
+// line. The timestamps are the ones of the node, so they are used to
+// read the log from where the last read stopped, whatever the time of
+// the client. The lines without a timestamp are left unchanged.
+func stripLogTimestamps(raw []byte) (string, metav1.Time) {
+	var last metav1.Time
+	lines := strings.Split(string(raw), "\n")
+	for i, line := range lines {
+		parts := strings.SplitN(line, " ", 2)
+		t, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			continue
+		}
+		last = metav1.NewTime(t)
+		lines[i] = ""
+		if len(parts) == 2 {
+			lines[i] = parts[1]
+		}
+	}
+	return strings.Join(lines, "\n"), last
+}
+
+func (c *LogCondition) String() string {
+	return "log:" + c.Text
+}
+
+// resourceCondition is a condition declared by a resource.
+type resourceCondition struct {
+	info      *resource.Info
+	id        object.ObjMetadata
+	condition WaitCondition
+}
+
+func (rc resourceCondition) String() string {
+	return fmt.Sprintf("%s %s", rc.id.String(), rc.condition.String())
+}
+
+// conditionParsers returns the built-in parsers,
+// together with the ones of the Applier.
+func (a *Applier) conditionParsers() map[string]ConditionParser {
+	parsers := make(map[string]ConditionParser, len(DefaultConditionParsers)+len(a.ConditionParsers))
+	for t, p := range DefaultConditionParsers {
+		parsers[t] = p
+	}
+	for t, p := range a.ConditionParsers {
+		parsers[t] = p
+	}
+	return parsers
+}
+
+// waitConditions returns the conditions declared by the resources.
+func waitConditions(infos []*resource.Info, parsers map[string]ConditionParser) ([]resourceCondition, error) {
+	var conditions []resourceCondition
+	for _, info := range infos {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		value, found := accessor.GetAnnotations()[WaitForAnnotation]
+		if !found {
+			continue
+		}
+		id := infoToObjMetadata(info)
+		parsed, err := ParseConditions(value, parsers)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", id.String(), err)
+		}
+		for _, c := range parsed {
+			if v, ok := c.(KindValidator); ok {
+				if err := v.ValidateKind(id.GroupKind); err != nil {
+					return nil, fmt.Errorf("%s: condition %q: %v", id.String(), c.String(), err)
+				}
+			}
+			conditions = append(conditions, resourceCondition{info: info, id: id, condition: c})
+		}
+	}
+	return conditions, nil
+}
+
+// ConditionTask waits for the resources to meet the conditions they
+// declare with the WaitForAnnotation. Like the WaitTask, it only waits
+// if the Applier waits, and it shares its timeout.
+type ConditionTask struct {
+	applier *Applier
+}
+
+// Name returns ConditionTaskName.
+func (t *ConditionTask) Name() string {
+	return ConditionTaskName
+}
+
+// Run polls the resources until all the conditions are met.
+func (t *ConditionTask) Run(ctx context.Context, tc *TaskContext) error {
+	a := t.applier
+	if !a.StatusOptions.Wait || a.DryRunStrategy.ClientOrServerDryRun() {
+		return nil
+	}
+	conditions, err := waitConditions(tc.Infos, a.conditionParsers())
+	if err != nil {
+		return withExitCode(errors.WrapPrefix(err, "error parsing wait conditions", 1), ExitValidationError)
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return errors.WrapPrefix(err, "error creating client", 1)
+	}
+	a.logger().V(1).Info("waiting for conditions", "count", len(conditions))
+	waitCtx := ctx
+	if a.StatusOptions.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, a.StatusOptions.Timeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(a.StatusOptions.period)
+	defer ticker.Stop()
+	started := time.Now()
+	total := len(conditions)
+	for {
+		var unmet []resourceCondition
+		for _, rc := range conditions {
+			met, err := conditionMet(waitCtx, client, rc)
+			if err != nil && IsTransientError(err) {
+				// The condition is checked again by the next poll.
+				a.logger().V(1).Info("error checking condition, retrying", "condition", rc.String(),
+					"error", err.Error())
+				met, err = false, nil
+			}
+			if err != nil {
+				a.logger().Error(err, "error checking condition", "condition", rc.String())
+				return withExitCode(errors.WrapPrefix(err, fmt.Sprintf("error checking %s", rc.String()), 1),
+					ExitApplyError)
+			}
+			if met {
+				a.logger().V(1).Info("condition met", "condition", rc.String())
+				continue
+			}
+			unmet = append(unmet, rc)
+		}
+		if len(unmet) < len(conditions) {
+			tc.SendEvent(event.NewProgressEvent(event.ConditionPhase, total-len(unmet), total, started))
+		}
+		conditions = unmet
+		if len(conditions) == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			var names []string
+			for _, rc := range conditions {
+				names = append(names, rc.String())
+			}
+			sort.Strings(names)
+			if ctx.Err() != nil {
+				return withExitCode(fmt.Errorf("run %w while waiting for conditions", ErrCancelled), ExitCancelled)
+			}
+			return withExitCode(fmt.Errorf("%w waiting for conditions: %s", ErrTimeout, strings.Join(names, ", ")),
+				ExitReconcileTimeout)
+		}
+	}
+}
+
+// conditionMet reads the live resource, and checks the condition.
+// Resources that don't exist yet, or are not served yet, don't
+// meet any condition.
+func conditionMet(ctx context.Context, client kubernetes.Interface, rc resourceCondition) (bool, error) {
+	if rc.info.Mapping == nil {
+		return false, nil
+	}
+	helper := resource.NewHelper(rc.info.Client, rc.info.Mapping)
+	live, err := helper.Get(rc.info.Namespace, rc.info.Name, false)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	content, err := toMap(live)
+	if err != nil {
+		return false, err
+	}
+	return rc.condition.Met(ctx, ConditionContext{Object: &unstructured.Unstructured{Object: content}, Client: client})
+}
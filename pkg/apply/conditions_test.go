@@ -0,0 +1,321 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+type phaseCondition struct {
+	phase string
+}
+
+func (c *phaseCondition) Met(_ context.Context, cc ConditionContext) (bool, error) {
+	phase, _, _ := unstructured.NestedString(cc.Object.Object, "status", "phase")
+	return phase == c.phase, nil
+}
+
+func (c *phaseCondition) String() string {
+	return "phase:" + c.phase
+}
+
+// flakyCondition returns the error until it has been checked
+// the given number of times, and is then met.
+type flakyCondition struct {
+	err      error
+	failures int
+	checks   int
+}
+
+func (c *flakyCondition) Met(_ context.Context, _ ConditionContext) (bool, error) {
+	c.checks++
+	if c.checks <= c.failures {
+		return false, c.err
+	}
+	return true, nil
+}
+
+func (c *flakyCondition) String() string {
+	return fmt.Sprintf("flaky:%d", c.failures)
+}
+
+func TestParseConditions(t *testing.T) {
+	parsers := map[string]ConditionParser{
+		"field": ParseFieldCondition,
+		"log":   ParseLogCondition,
+		"phase": func(arg string) (WaitCondition, error) {
+			return &phaseCondition{phase: arg}, nil
+		},
+	}
+	testCases := map[string]struct {
+		value     string
+		expected  []string
+		expectErr bool
+	}{
+		"empty": {},
+		"several conditions": {
+			value:    "field:data.ready=true; log:migration done ;phase:Running;",
+			expected: []string{"field:data.ready=true", "log:migration done", "phase:Running"},
+		},
+		"field is set": {
+			value:    "field:status.loadBalancer.ingress",
+			expected: []string{"field:status.loadBalancer.ingress"},
+		},
+		"value with separators": {
+			value:    "field:data.url=http://example.com?a=b",
+			expected: []string{"field:data.url=http://example.com?a=b"},
+		},
+		"missing type": {
+			value:     "data.ready",
+			expectErr: true,
+		},
+		"unknown type": {
+			value:     "event:Scheduled",
+			expectErr: true,
+		},
+		"empty path element": {
+			value:     "field:data..ready",
+			expectErr: true,
+		},
+		"empty log text": {
+			value:     "log: ",
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			conditions, err := ParseConditions(tc.value, parsers)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, c := range conditions {
+				names = append(names, c.String())
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestFieldCondition(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{"key": "value", "empty": ""},
+		"status": map[string]interface{}{
+			"replicas": int64(3),
+			"ready":    true,
+		},
+	}}
+	testCases := map[string]struct {
+		arg      string
+		expected bool
+	}{
+		"set":                  {arg: "data.key", expected: true},
+		"set to empty":         {arg: "data.empty", expected: true},
+		"not set":              {arg: "data.other"},
+		"value":                {arg: "data.key=value", expected: true},
+		"other value":          {arg: "data.key=other"},
+		"empty value":          {arg: "data.empty=", expected: true},
+		"number":               {arg: "status.replicas=3", expected: true},
+		"boolean":              {arg: "status.ready=true", expected: true},
+		"not a map":            {arg: "data.key.nested"},
+		"parent of the fields": {arg: "status", expected: true},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			c, err := ParseFieldCondition(tc.arg)
+			require.NoError(t, err)
+			met, err := c.Met(context.Background(), ConditionContext{Object: obj})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, met)
+		})
+	}
+}
+
+func TestLogCondition(t *testing.T) {
+	testCases := map[string]struct {
+		obj       *unstructured.Unstructured
+		expectErr bool
+	}{
+		"pod that doesn't exist": {
+			obj: validatorInfo("v1", "Pod", "default", "migrate", "").Object.(*unstructured.Unstructured),
+		},
+		"job without pods": {
+			obj: validatorInfo("batch/v1", "Job", "default", "migrate", "").Object.(*unstructured.Unstructured),
+		},
+		"other kind": {
+			obj:       configMap("migrate", nil),
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			// Pods of another Job are not read.
+			client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:      "other",
+				Namespace: "default",
+				Labels:    map[string]string{"job-name": "other"},
+			}})
+			c, err := ParseLogCondition("done")
+			require.NoError(t, err)
+			met, err := c.Met(context.Background(), ConditionContext{Object: tc.obj, Client: client})
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.False(t, met)
+		})
+	}
+}
+
+func TestStripLogTimestamps(t *testing.T) {
+	testCases := map[string]struct {
+		raw          string
+		expectedLog  string
+		expectedLast string
+	}{
+		"empty": {},
+		"lines with timestamps": {
+			raw: "2020-01-02T03:04:05.123456789Z starting\n" +
+				"2020-01-02T03:04:06.5Z migration done\n",
+			expectedLog:  "starting\nmigration done\n",
+			expectedLast: "2020-01-02T03:04:06.5Z",
+		},
+		"line without a timestamp": {
+			raw:          "2020-01-02T03:04:05Z starting\ncontinued",
+			expectedLog:  "starting\ncontinued",
+			expectedLast: "2020-01-02T03:04:05Z",
+		},
+		"empty line": {
+			raw:          "2020-01-02T03:04:05Z\n",
+			expectedLog:  "\n",
+			expectedLast: "2020-01-02T03:04:05Z",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			log, last := stripLogTimestamps([]byte(tc.raw))
+			assert.Equal(t, tc.expectedLog, log)
+			if tc.expectedLast == "" {
+				assert.True(t, last.IsZero())
+				return
+			}
+			expected, err := time.Parse(time.RFC3339Nano, tc.expectedLast)
+			require.NoError(t, err)
+			assert.True(t, expected.Equal(last.Time), "last: %v", last)
+		})
+	}
+}
+
+func TestRunWaitConditions(t *testing.T) {
+	parsers := map[string]ConditionParser{
+		"unavailable": func(arg string) (WaitCondition, error) {
+			failures, err := strconv.Atoi(arg)
+			return &flakyCondition{err: apierrors.NewServiceUnavailable("try again"), failures: failures}, err
+		},
+		"forbidden": func(arg string) (WaitCondition, error) {
+			return &flakyCondition{err: apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, arg,
+				fmt.Errorf("denied")), failures: 1}, nil
+		},
+	}
+	testCases := map[string]struct {
+		conditions  string
+		expectPhase bool
+		expectCode  int
+	}{
+		"met conditions": {
+			conditions:  "field:data.key=value; field:metadata.name=a",
+			expectPhase: true,
+		},
+		"unmet condition": {
+			conditions: "field:data.key=other",
+			expectCode: ExitReconcileTimeout,
+		},
+		"invalid condition": {
+			conditions: "field:",
+			expectCode: ExitValidationError,
+		},
+		"log condition on a ConfigMap": {
+			conditions: "log:done",
+			expectCode: ExitValidationError,
+		},
+		"transient errors": {
+			conditions:  "unavailable:2",
+			expectPhase: true,
+		},
+		"permanent error": {
+			conditions: "forbidden:a",
+			expectCode: ExitApplyError,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithTimeout(500*time.Millisecond), WithPollInterval(10*time.Millisecond))
+			require.NoError(t, err)
+			applier.StatusOptions.Wait = true
+			applier.ConditionParsers = parsers
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			a := configMap("a", nil)
+			a.Object["data"] = map[string]interface{}{"key": "value"}
+			a.SetAnnotations(map[string]string{WaitForAnnotation: tc.conditions})
+			var errs []error
+			conditionPhase := false
+			for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{inventory, a}) {
+				switch e.Type {
+				case event.ErrorType:
+					errs = append(errs, e.ErrorEvent.Err)
+				case event.ProgressType:
+					conditionPhase = conditionPhase || e.ProgressEvent.Phase == event.ConditionPhase
+				}
+			}
+
+			assert.Equal(t, tc.expectPhase, conditionPhase)
+			if tc.expectCode == 0 {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tc.expectCode, ExitCode(errs[0]))
+			if tc.expectCode == ExitValidationError {
+				assert.Nil(t, cluster.Get(a.GroupVersionKind(), fakecluster.DefaultNamespace, "a"))
+			}
+		})
+	}
+}
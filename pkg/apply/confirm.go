@@ -0,0 +1,128 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/util/term"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ErrNotConfirmed is returned by the gate of the Confirmer if
+// the user didn't confirm the run.
+var ErrNotConfirmed = fmt.Errorf("aborted, the changes were not confirmed")
+
+// isTerminal is used to decide whether to ask for confirmation. It
+// is a variable so it can be replaced in tests.
+var isTerminal = term.IsTerminal
+
+// Plan describes the changes a run is about to make, so they can be
+// confirmed before anything is changed in the cluster.
+type Plan struct {
+	// Apply contains the resources that will be applied.
+	Apply []*object.ObjMetadata
+	// Delete contains the resources that will be deleted, either
+	// because they are pruned or because they are destroyed.
+	Delete []*object.ObjMetadata
+}
+
+// PreRunGate is called with the plan before a run makes any changes
+// to the cluster. The run is aborted if it returns an error.
+type PreRunGate func(plan Plan) error
+
+// Confirmer asks the user to confirm runs that delete resources. It
+// only asks if the input is a terminal, so it never blocks automation.
+type Confirmer struct {
+	IOStreams genericclioptions.IOStreams
+	// AutoApprove skips the confirmation.
+	AutoApprove bool
+}
+
+// NewConfirmer returns a new Confirmer.
+func NewConfirmer(ioStreams genericclioptions.IOStreams) *Confirmer {
+	return &Confirmer{
+		IOStreams: ioStreams,
+	}
+}
+
+// AddFlags adds the --yes and --auto-approve flags, which both
+// skip the confirmation.
+func (c *Confirmer) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&c.AutoApprove, "yes", "y", c.AutoApprove,
+		"If true, don't ask for confirmation before deleting resources.")
+	cmd.Flags().BoolVar(&c.AutoApprove, "auto-approve", c.AutoApprove,
+		"If true, don't ask for confirmation before deleting resources. Same as --yes.")
+}
+
+// Gate is a PreRunGate that prints the plan and waits for the user to
+// confirm it if any resources will be deleted.
+func (c *Confirmer) Gate(plan Plan) error {
+	if c.AutoApprove || len(plan.Delete) == 0 || !isTerminal(c.IOStreams.In) {
+		return nil
+	}
+	// The prompt is written to ErrOut, so it doesn't get mixed
+	// up with output that is meant to be parsed.
+	w := c.IOStreams.ErrOut
+	if len(plan.Apply) > 0 {
+		fmt.Fprintf(w, "%d resource(s) will be applied.\n", len(plan.Apply))
+	}
+	fmt.Fprintf(w, "The following %d resource(s) will be deleted:\n", len(plan.Delete))
+	printIDs(w, plan.Delete)
+	return c.ask()
+}
+
+// Confirm waits for the user to confirm changes that have already
+// been printed. Like Gate, it only asks if the input is a terminal.
+func (c *Confirmer) Confirm() error {
+	if c.AutoApprove || !isTerminal(c.IOStreams.In) {
+		return nil
+	}
+	return c.ask()
+}
+
+// ask prompts the user and returns ErrNotConfirmed
+// unless the answer is yes.
+func (c *Confirmer) ask() error {
+	fmt.Fprint(c.IOStreams.ErrOut, "Do you want to continue? [y/N]: ")
+	answer, err := bufio.NewReader(c.IOStreams.In).ReadString('\n')
+	if err != nil && answer == "" {
+		return ErrNotConfirmed
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return ErrNotConfirmed
+	}
+}
+
+// PrintDeletePlan prints the resources that will be deleted in the
+// order they are deleted, so a teardown can be reviewed before it is
+// done for real.
+func PrintDeletePlan(w io.Writer, plan Plan) {
+	if len(plan.Delete) == 0 {
+		fmt.Fprintln(w, "No resources will be deleted.")
+		return
+	}
+	fmt.Fprintf(w, "The following %d resource(s) will be deleted, in this order:\n", len(plan.Delete))
+	printIDs(w, plan.Delete)
+}
+
+// printIDs prints the numbered list of resources.
+func printIDs(w io.Writer, ids []*object.ObjMetadata) {
+	for i, id := range ids {
+		resourceID := resourceIDToString(id.GroupKind, id.Name)
+		if id.Namespace != "" {
+			fmt.Fprintf(w, "  %d. %s (namespace %s)\n", i+1, resourceID, id.Namespace)
+			continue
+		}
+		fmt.Fprintf(w, "  %d. %s\n", i+1, resourceID)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestConfirmerGate(t *testing.T) {
+	deployment := &object.ObjMetadata{
+		Namespace: "default",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+
+	testCases := map[string]struct {
+		plan           Plan
+		input          string
+		terminal       bool
+		autoApprove    bool
+		expectedErr    error
+		expectedPrompt bool
+	}{
+		"confirmed": {
+			plan:           Plan{Delete: []*object.ObjMetadata{deployment}},
+			input:          "y\n",
+			terminal:       true,
+			expectedPrompt: true,
+		},
+		"declined": {
+			plan:           Plan{Delete: []*object.ObjMetadata{deployment}},
+			input:          "n\n",
+			terminal:       true,
+			expectedErr:    ErrNotConfirmed,
+			expectedPrompt: true,
+		},
+		"no answer": {
+			plan:           Plan{Delete: []*object.ObjMetadata{deployment}},
+			terminal:       true,
+			expectedErr:    ErrNotConfirmed,
+			expectedPrompt: true,
+		},
+		"nothing to delete": {
+			plan:     Plan{Apply: []*object.ObjMetadata{deployment}},
+			terminal: true,
+		},
+		"auto approve": {
+			plan:        Plan{Delete: []*object.ObjMetadata{deployment}},
+			terminal:    true,
+			autoApprove: true,
+		},
+		"not a terminal": {
+			plan: Plan{Delete: []*object.ObjMetadata{deployment}},
+		},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.terminal }
+			ioStreams, in, _, errOut := genericclioptions.NewTestIOStreams()
+			in.WriteString(tc.input)
+			c := NewConfirmer(ioStreams)
+			c.AutoApprove = tc.autoApprove
+
+			err := c.Gate(tc.plan)
+			assert.Equal(t, tc.expectedErr, err)
+			prompt := errOut.String()
+			assert.Equal(t, tc.expectedPrompt, strings.Contains(prompt, "Do you want to continue?"))
+			if tc.expectedPrompt {
+				assert.Contains(t, prompt, "deployment.apps/foo")
+			}
+		})
+	}
+}
+
+func TestPrintDeletePlan(t *testing.T) {
+	namespace := &object.ObjMetadata{
+		Name:      "bar",
+		GroupKind: schema.GroupKind{Kind: "Namespace"},
+	}
+	deployment := &object.ObjMetadata{
+		Namespace: "bar",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+
+	testCases := map[string]struct {
+		plan     Plan
+		expected string
+	}{
+		"nothing to delete": {
+			plan:     Plan{},
+			expected: "No resources will be deleted.\n",
+		},
+		"resources in deletion order": {
+			plan: Plan{Delete: []*object.ObjMetadata{deployment, namespace}},
+			expected: "The following 2 resource(s) will be deleted, in this order:\n" +
+				"  1. deployment.apps/foo (namespace bar)\n" +
+				"  2. namespace/bar\n",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintDeletePlan(&buf, tc.plan)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestConfirmerConfirm(t *testing.T) {
+	testCases := map[string]struct {
+		input          string
+		terminal       bool
+		autoApprove    bool
+		expectedErr    error
+		expectedPrompt bool
+	}{
+		"confirmed": {
+			input:          "yes\n",
+			terminal:       true,
+			expectedPrompt: true,
+		},
+		"declined": {
+			input:          "\n",
+			terminal:       true,
+			expectedErr:    ErrNotConfirmed,
+			expectedPrompt: true,
+		},
+		"auto approve": {
+			terminal:    true,
+			autoApprove: true,
+		},
+		"not a terminal": {},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.terminal }
+			ioStreams, in, _, errOut := genericclioptions.NewTestIOStreams()
+			in.WriteString(tc.input)
+			c := NewConfirmer(ioStreams)
+			c.AutoApprove = tc.autoApprove
+
+			assert.Equal(t, tc.expectedErr, c.Confirm())
+			assert.Equal(t, tc.expectedPrompt, strings.Contains(errOut.String(), "Do you want to continue?"))
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// defaultWatchPollInterval is how often the ContinuousApplier
+// checks the manifests for changes if no PollInterval is set.
+const defaultWatchPollInterval = 2 * time.Second
+
+// NewContinuousApplier returns a new ContinuousApplier.
+func NewContinuousApplier(factory util.Factory, ioStreams genericclioptions.IOStreams) *ContinuousApplier {
+	return &ContinuousApplier{
+		Applier:      NewApplier(factory, ioStreams),
+		PollInterval: defaultWatchPollInterval,
+	}
+}
+
+// ContinuousApplier applies a package again and again, like a small
+// GitOps agent for development clusters. Every run applies, waits and
+// prunes like a run of the Applier, and the runs never overlap. A run
+// starts when the Interval has passed since the previous run started,
+// or when the local manifest files have changed. The manifests are
+// resolved again for every run, so kustomizations, Helm charts and
+// variables are rendered again and remote manifests are downloaded
+// again. The manifests from stdin are read once, and applied by
+// every run.
+type ContinuousApplier struct {
+	// Applier performs the runs.
+	Applier *Applier
+	// Interval is the time between the starts of two runs. If it is
+	// zero, a run only starts when the manifests change.
+	Interval time.Duration
+	// PollInterval is how often the manifests are checked for
+	// changes. They are not checked if it is zero.
+	PollInterval time.Duration
+
+	// paths and fileNameFlags give the manifests as passed to
+	// Initialize, before they are resolved for a run.
+	paths         []string
+	fileNameFlags genericclioptions.FileNameFlags
+	// stdin holds the copy of the manifests read from stdin.
+	stdin *remoteManifests
+	// watchPaths are the local files and directories of the
+	// manifests, as given on the command line.
+	watchPaths []string
+}
+
+// Initialize sets up the Applier, and records the local manifest
+// files and directories given by the paths and the flags, so they
+// can be checked for changes.
+func (c *ContinuousApplier) Initialize(cmd *cobra.Command, paths []string) error {
+	var flags genericclioptions.FileNameFlags
+	if f := c.Applier.ApplyOptions.DeleteFlags.FileNameFlags; f != nil {
+		flags = *f
+	}
+	// Without a chart and manifests, the manifests are read from stdin.
+	if c.Applier.Helm.Chart == "" {
+		flags = processPaths(paths, &flags)
+		paths = nil
+		if err := c.copyStdin(*flags.Filenames); err != nil {
+			return err
+		}
+	}
+	c.paths = paths
+	c.fileNameFlags = flags
+
+	candidates := append([]string{}, paths...)
+	if flags.Filenames != nil {
+		candidates = append(candidates, *flags.Filenames...)
+	}
+	if flags.Kustomize != nil && *flags.Kustomize != "" {
+		candidates = append(candidates, *flags.Kustomize)
+	}
+	candidates = append(candidates, c.Applier.Helm.Chart)
+	candidates = append(candidates, c.Applier.Helm.ValuesFiles...)
+	candidates = append(candidates, c.Applier.Substitution.ValuesFiles...)
+	c.watchPaths = nil
+	for _, path := range candidates {
+		// URLs, git and OCI references and the copy of stdin are not
+		// watched, since they are not local files that can change.
+		if path == "" || c.stdin.contains(path) {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			c.watchPaths = append(c.watchPaths, path)
+		}
+	}
+	c.Applier.ApplyOptions.DeleteFlags.FileNameFlags = &flags
+	if err := c.Applier.Initialize(cmd, paths); err != nil {
+		return err
+	}
+	if c.Interval <= 0 && (c.PollInterval <= 0 || len(c.watchPaths) == 0) {
+		return errors.New("an interval is needed if there are no local manifests to watch for changes")
+	}
+	return nil
+}
+
+// copyStdin replaces the filename - with a copy of the manifests
+// read from stdin, since stdin can only be read once.
+func (c *ContinuousApplier) copyStdin(filenames []string) error {
+	for i, filename := range filenames {
+		if filename != "-" {
+			continue
+		}
+		if c.stdin == nil {
+			c.stdin = newRemoteManifests(false)
+		}
+		path, err := c.stdin.readStdin(i)
+		if err != nil {
+			return errors.WrapPrefix(err, "error reading manifests", 1)
+		}
+		filenames[i] = path
+	}
+	return nil
+}
+
+// Close removes the manifests resolved for the last run and
+// the copy of stdin.
+func (c *ContinuousApplier) Close() error {
+	c.stdin.cleanup()
+	return c.Applier.Close()
+}
+
+// SetFlags configures the command line flags needed by the ContinuousApplier.
+func (c *ContinuousApplier) SetFlags(cmd *cobra.Command) error {
+	return c.Applier.SetFlags(cmd)
+}
+
+// Run performs runs until the context is done, and passes the events
+// of every run to the handle function. The next run starts after
+// handle has returned, so it must read the channel until it is closed.
+// A failed run is reported on its channel like for the Applier, and
+// doesn't stop the loop.
+func (c *ContinuousApplier) Run(ctx context.Context, handle func(ch <-chan event.Event)) {
+	var tick, poll <-chan time.Time
+	if c.Interval > 0 {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	if c.PollInterval > 0 && len(c.watchPaths) > 0 {
+		ticker := time.NewTicker(c.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	// The manifests of the first run were resolved by Initialize.
+	for resolved := true; ; resolved = false {
+		fingerprint := c.fingerprint()
+		handle(c.run(ctx, resolved))
+		if !c.waitForTrigger(ctx, tick, poll, fingerprint) {
+			return
+		}
+	}
+}
+
+// run starts a run, after resolving the manifests again unless they
+// have just been resolved. A failure to resolve them is reported on
+// the channel, and the run is skipped.
+func (c *ContinuousApplier) run(ctx context.Context, resolved bool) <-chan event.Event {
+	if !resolved {
+		if err := c.Applier.resolveManifests(c.paths, &c.fileNameFlags); err != nil {
+			return errorEvent(withExitCode(err, ExitValidationError))
+		}
+	}
+	c.Applier.logger().V(1).Info("starting run")
+	return c.Applier.Run(ctx)
+}
+
+// waitForTrigger blocks until the next run should start, and returns
+// false if the context is done first. The fingerprint is the one of the
+// manifests read by the previous run, so changes made during the run
+// trigger the next one.
+func (c *ContinuousApplier) waitForTrigger(ctx context.Context, tick, poll <-chan time.Time,
+	fingerprint string) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-tick:
+			return true
+		case <-poll:
+			if c.fingerprint() != fingerprint {
+				c.Applier.logger().Info("manifests changed")
+				return true
+			}
+		}
+	}
+}
+
+// fingerprint returns a hash of the names, sizes and modification
+// times of the files in the watched paths.
+func (c *ContinuousApplier) fingerprint() string {
+	h := sha256.New()
+	for _, root := range c.watchPaths {
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// A removed file changes the fingerprint as well.
+				fmt.Fprintf(h, "%s\x00error\x00", path)
+				return nil
+			}
+			if !info.IsDir() {
+				fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+			}
+			return nil
+		})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
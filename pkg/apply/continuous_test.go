@@ -0,0 +1,150 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+const continuousManifest = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    %s: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+`
+
+func newTestContinuousApplier(t *testing.T, cluster *fakecluster.Cluster, filename string) (*ContinuousApplier,
+	error) {
+	c := NewContinuousApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	c.Applier.StatusOptions.NoWait = true
+	c.PollInterval = 10 * time.Millisecond
+	cmd := &cobra.Command{}
+	require.NoError(t, c.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", filename))
+	return c, c.Initialize(cmd, nil)
+}
+
+func TestContinuousApplierAppliesChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "continuous-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "package.yaml")
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf(continuousManifest, prune.GroupingLabel,
+		"first")), 0600))
+
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	c, err := newTestContinuousApplier(t, cluster, dir)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	runs := 0
+	c.Run(ctx, func(ch <-chan event.Event) {
+		for e := range ch {
+			if e.Type == event.ErrorType {
+				t.Errorf("unexpected error: %v", e.ErrorEvent.Err)
+			}
+		}
+		runs++
+		switch runs {
+		case 1:
+			assert.NotNil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "first"))
+			// The change starts the next run.
+			require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf(continuousManifest,
+				prune.GroupingLabel, "second-name")), 0600))
+		case 2:
+			cancel()
+		}
+	})
+	assert.Equal(t, 2, runs)
+	assert.Nil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "first"))
+	assert.NotNil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "second-name"))
+}
+
+func TestContinuousApplierNeedsTrigger(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	// Manifests from stdin can't be watched for changes.
+	_, err = newTestContinuousApplier(t, cluster, "-")
+	assert.Error(t, err)
+}
+
+func TestContinuousApplierResolvesManifestsForEveryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "continuous-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// The JSON array is normalized and the variables are substituted
+	// by every run, so changing the values starts a run that applies
+	// the new name.
+	manifest := filepath.Join(dir, "package.json")
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf(`[
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "inventory", "labels": {%q: "test"}}},
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "${NAME}"}}
+]`, prune.GroupingLabel)), 0600))
+	values := filepath.Join(dir, "values.yaml")
+	require.NoError(t, ioutil.WriteFile(values, []byte("NAME: first\n"), 0600))
+
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	c := NewContinuousApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	c.Applier.StatusOptions.NoWait = true
+	c.PollInterval = 10 * time.Millisecond
+	cmd := &cobra.Command{}
+	require.NoError(t, c.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("var-file", values))
+	require.NoError(t, c.Initialize(cmd, []string{manifest}))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	runs := 0
+	c.Run(ctx, func(ch <-chan event.Event) {
+		for e := range ch {
+			if e.Type == event.ErrorType {
+				t.Errorf("unexpected error: %v", e.ErrorEvent.Err)
+			}
+		}
+		runs++
+		switch runs {
+		case 1:
+			assert.NotNil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "first"))
+			require.NoError(t, ioutil.WriteFile(values, []byte("NAME: second-name\n"), 0600))
+		case 2:
+			cancel()
+		}
+	})
+	assert.Equal(t, 2, runs)
+	assert.Nil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "first"))
+	assert.NotNil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "second-name"))
+}
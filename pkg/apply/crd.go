@@ -0,0 +1,266 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-errors/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/kubectl/pkg/util"
+)
+
+// crdGroupKind is the GroupKind of CustomResourceDefinitions.
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// crdEstablishTimeout is how long to wait for the CustomResourceDefinitions
+// to be established before their custom resources are mapped.
+const crdEstablishTimeout = time.Minute
+
+// crdPollInterval is how often the CustomResourceDefinitions
+// are checked while waiting for them.
+const crdPollInterval = 500 * time.Millisecond
+
+// readObjects returns the resources of the package. The builder can only
+// read custom resources whose kinds are known to the cluster, so if the
+// package also contains the CustomResourceDefinitions for them, and
+// reading fails, the CustomResourceDefinitions are created first. Once
+// they are established, the resources that were read locally are mapped
+// with the new kinds, rather than reading every manifest again. The
+// CustomResourceDefinitions are applied again
+// with the other resources, so they are reported like every other
+// resource. If reading still fails and UnknownKindTimeout is set, the
+// resources of kinds that are not served yet are returned without a
+// mapping, so they can be applied once the kinds appear. If reading
+// fails, it is first tried again with fresh discovery information, since
+// the kinds might have been added since it was cached. The builder
+// doesn't keep the type of the error, so this is done for all errors.
+func (a *Applier) readObjects(ctx context.Context) ([]*resource.Info, error) {
+	infos, err := a.ApplyOptions.GetObjects()
+	if err == nil {
+		return infos, nil
+	}
+	if a.discovery != nil {
+		a.discovery.Invalidate()
+		// The builder keeps the files it has read.
+		a.ApplyOptions.Builder = a.factory.NewBuilder()
+		infos, err = a.ApplyOptions.GetObjects()
+		if err == nil {
+			return infos, nil
+		}
+	}
+	localInfos, localErr := a.factory.NewBuilder().
+		Local().
+		Unstructured().
+		ContinueOnError().
+		FilenameParam(false, &a.ApplyOptions.DeleteOptions.FilenameOptions).
+		Flatten().
+		Do().
+		Infos()
+	if localErr != nil {
+		return nil, err
+	}
+	// Nothing can be created in a dry-run.
+	if !a.DryRunStrategy.ClientOrServerDryRun() {
+		created, crdErr := a.createMissingCRDs(ctx, localInfos)
+		if crdErr != nil {
+			return nil, crdErr
+		}
+		if created {
+			if mapErr := a.mapInfos(localInfos); mapErr == nil {
+				return localInfos, nil
+			}
+		}
+	}
+	if a.UnknownKindTimeout > 0 {
+		return a.deferUnknownKinds(localInfos, err)
+	}
+	return nil, err
+}
+
+// objectsToInfos returns the resources for the objects passed to
+// RunObjects, like readObjects does for the manifests. The objects are
+// copied, and mapInfos defaults the namespace of namespaced resources,
+// since the scope of a kind is only known once it is mapped. Prune
+// looks for the past grouping objects in the namespace of the grouping
+// object of each run, so packages in different namespaces can share
+// the Applier.
+func (a *Applier) objectsToInfos(ctx context.Context, objs []*unstructured.Unstructured) ([]*resource.Info, error) {
+	infos := make([]*resource.Info, 0, len(objs))
+	for _, obj := range objs {
+		obj = obj.DeepCopy()
+		infos = append(infos, &resource.Info{
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Object:    obj,
+		})
+	}
+	// Nothing can be created in a dry-run.
+	if !a.DryRunStrategy.ClientOrServerDryRun() {
+		if _, err := a.createMissingCRDs(ctx, infos); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.mapInfos(infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// mapInfos sets the mapping and the client of resources that were not
+// read by the builder. The objects are kept as they are, so they are not
+// converted again. If UnknownKindTimeout is set, the resources of kinds
+// that are not served yet are left without a mapping.
+func (a *Applier) mapInfos(infos []*resource.Info) error {
+	mapper, err := restMapper(a.clients, a.factory, false)
+	if err != nil {
+		return err
+	}
+	refreshing := &refreshOnceMapper{RESTMapper: mapper}
+	for _, info := range infos {
+		gvk := info.Object.GetObjectKind().GroupVersionKind()
+		mapping, err := refreshing.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) && a.UnknownKindTimeout > 0 {
+			// The resource is applied once its kind appears.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.setMapping(info, mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createMissingCRDs creates the CustomResourceDefinitions for the kinds
+// of resources in the package that the cluster doesn't serve yet, and
+// waits until the kinds are served. It returns false if there are none.
+func (a *Applier) createMissingCRDs(ctx context.Context, localInfos []*resource.Info) (bool, error) {
+	mapper, err := restMapper(a.clients, a.factory, true)
+	if err != nil {
+		return false, nil
+	}
+	crds, kinds, err := findMissingCRDs(localInfos, mapper)
+	if err != nil || len(crds) == 0 {
+		return false, nil
+	}
+
+	client, err := dynamicClient(a.clients, a.factory)
+	if err != nil {
+		return false, errors.WrapPrefix(err, "error creating dynamic client", 1)
+	}
+	for _, crd := range crds {
+		a.logger().Info("creating CustomResourceDefinition before its custom resources", "name", crd.Name)
+		if err := createCRD(client, mapper, crd); err != nil {
+			return false, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, crdEstablishTimeout)
+	defer cancel()
+	err = wait.PollImmediateUntil(crdPollInterval, func() (bool, error) {
+		// A fresh mapper sees the kinds added since the last poll.
+		mapper, err := restMapper(a.clients, a.factory, true)
+		if err != nil {
+			return false, err
+		}
+		for _, gk := range kinds {
+			if _, err := mapper.RESTMapping(gk); err != nil {
+				if meta.IsNoMatchError(err) {
+					return false, nil
+				}
+				return false, err
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		return false, errors.WrapPrefix(err, "error waiting for CustomResourceDefinitions to be established", 1)
+	}
+	return true, nil
+}
+
+// findMissingCRDs returns the CustomResourceDefinitions in the package
+// that define kinds of other resources in the package that the mapper
+// doesn't know yet, and those kinds.
+func findMissingCRDs(infos []*resource.Info, mapper meta.RESTMapper) ([]*resource.Info, []schema.GroupKind, error) {
+	defined := definedKinds(infos)
+	if len(defined) == 0 {
+		return nil, nil, nil
+	}
+
+	var crds []*resource.Info
+	var kinds []schema.GroupKind
+	added := make(map[schema.GroupKind]bool)
+	for _, info := range infos {
+		gvk := info.Object.GetObjectKind().GroupVersionKind()
+		gk := gvk.GroupKind()
+		crd, found := defined[gk]
+		if !found || added[gk] {
+			continue
+		}
+		if _, err := mapper.RESTMapping(gk, gvk.Version); err == nil {
+			continue
+		} else if !meta.IsNoMatchError(err) {
+			return nil, nil, err
+		}
+		added[gk] = true
+		crds = append(crds, crd)
+		kinds = append(kinds, gk)
+	}
+	return crds, kinds, nil
+}
+
+// definedKinds returns the kinds defined by the
+// CustomResourceDefinitions in the package.
+func definedKinds(infos []*resource.Info) map[schema.GroupKind]*resource.Info {
+	defined := make(map[schema.GroupKind]*resource.Info)
+	for _, info := range infos {
+		u, ok := info.Object.(*unstructured.Unstructured)
+		if !ok || u.GroupVersionKind().GroupKind() != crdGroupKind {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+		defined[schema.GroupKind{Group: group, Kind: kind}] = info
+	}
+	return defined
+}
+
+// createCRD creates the CustomResourceDefinition unless it already
+// exists, with the annotation that records the applied configuration,
+// so the later apply patches it like any other resource.
+func createCRD(client dynamic.Interface, mapper meta.RESTMapper, crd *resource.Info) error {
+	gvk := crd.Object.GetObjectKind().GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return errors.WrapPrefix(err, "error mapping CustomResourceDefinition", 1)
+	}
+	resourceClient := client.Resource(mapping.Resource)
+	_, err = resourceClient.Get(crd.Name, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.WrapPrefix(err, fmt.Sprintf("error getting CustomResourceDefinition %s", crd.Name), 1)
+	}
+	obj := crd.Object.(*unstructured.Unstructured).DeepCopy()
+	if err := util.CreateApplyAnnotation(obj, unstructured.UnstructuredJSONScheme); err != nil {
+		return errors.WrapPrefix(err, "error setting the applied configuration", 1)
+	}
+	if _, err := resourceClient.Create(obj, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.WrapPrefix(err, fmt.Sprintf("error creating CustomResourceDefinition %s", crd.Name), 1)
+	}
+	return nil
+}
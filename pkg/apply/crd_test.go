@@ -0,0 +1,188 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+var crdGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1beta1",
+	Resource: "customresourcedefinitions",
+}
+
+func crdInfo(name, group, kind string) *resource.Info {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1beta1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		"spec": map[string]interface{}{
+			"group": group,
+			"names": map[string]interface{}{
+				"kind": kind,
+			},
+		},
+	}}
+	return &resource.Info{Name: name, Object: obj}
+}
+
+func customResourceInfo(apiVersion, kind, name string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	return &resource.Info{Name: name, Object: obj}
+}
+
+func crdTestMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1",
+		Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Known"}, meta.RESTScopeNamespace)
+	return mapper
+}
+
+func TestFindMissingCRDs(t *testing.T) {
+	testCases := map[string]struct {
+		infos         []*resource.Info
+		expectedCRDs  []string
+		expectedKinds []schema.GroupKind
+	}{
+		"no CustomResourceDefinitions": {
+			infos: []*resource.Info{
+				customResourceInfo("v1", "ConfigMap", "cm"),
+			},
+		},
+		"kind is already known": {
+			infos: []*resource.Info{
+				crdInfo("knowns.example.com", "example.com", "Known"),
+				customResourceInfo("example.com/v1", "Known", "a"),
+			},
+		},
+		"no custom resources": {
+			infos: []*resource.Info{
+				crdInfo("widgets.example.com", "example.com", "Widget"),
+			},
+		},
+		"custom resources of a new kind": {
+			infos: []*resource.Info{
+				crdInfo("widgets.example.com", "example.com", "Widget"),
+				crdInfo("gadgets.example.com", "example.com", "Gadget"),
+				crdInfo("knowns.example.com", "example.com", "Known"),
+				customResourceInfo("example.com/v1", "Widget", "a"),
+				customResourceInfo("example.com/v1", "Widget", "b"),
+				customResourceInfo("example.com/v1", "Known", "c"),
+				customResourceInfo("v1", "ConfigMap", "cm"),
+			},
+			expectedCRDs:  []string{"widgets.example.com"},
+			expectedKinds: []schema.GroupKind{{Group: "example.com", Kind: "Widget"}},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			crds, kinds, err := findMissingCRDs(tc.infos, crdTestMapper())
+			if !assert.NoError(t, err) {
+				return
+			}
+			var names []string
+			for _, crd := range crds {
+				names = append(names, crd.Name)
+			}
+			assert.Equal(t, tc.expectedCRDs, names)
+			assert.Equal(t, tc.expectedKinds, kinds)
+		})
+	}
+}
+
+func TestCreateCRD(t *testing.T) {
+	existing := crdInfo("gadgets.example.com", "example.com", "Gadget").Object.(*unstructured.Unstructured)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+	mapper := crdTestMapper()
+
+	for _, info := range []*resource.Info{
+		crdInfo("widgets.example.com", "example.com", "Widget"),
+		crdInfo("gadgets.example.com", "example.com", "Gadget"),
+	} {
+		if !assert.NoError(t, createCRD(client, mapper, info)) {
+			return
+		}
+	}
+
+	created, err := client.Resource(crdGVR).Get("widgets.example.com", metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, created.GetAnnotations(), "kubectl.kubernetes.io/last-applied-configuration")
+	unchanged, err := client.Resource(crdGVR).Get("gadgets.example.com", metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, unchanged.GetAnnotations(), "kubectl.kubernetes.io/last-applied-configuration")
+	// The info is not changed, so it is applied as read.
+	original, err := util.GetOriginalConfiguration(crdInfo("widgets.example.com", "example.com", "Widget").Object)
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+}
+
+func TestReadObjectsWithCRD(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "crd-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	write("crd.yaml", "apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\n"+
+		"metadata:\n  name: widgets.example.com\nspec:\n  group: example.com\n  version: v1\n"+
+		"  names:\n    kind: Widget\n    plural: widgets\n")
+	write("widget.yaml", "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n")
+
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	infos, err := applier.readObjects(context.Background())
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	for _, info := range infos {
+		// The resources read before the CRD was created are
+		// mapped, so the mapping and the client are set.
+		assert.NotNil(t, info.Mapping, info.Name)
+		assert.NotNil(t, info.Client, info.Name)
+	}
+	// The namespace of the custom resource is defaulted.
+	assert.Equal(t, "w", infos[1].Name)
+	assert.Equal(t, fakecluster.DefaultNamespace, infos[1].Namespace)
+	assert.NotNil(t, cluster.Get(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1",
+		Kind: "CustomResourceDefinition"}, "", "widgets.example.com"))
+}
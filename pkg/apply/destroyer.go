@@ -4,13 +4,26 @@
 package apply
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/go-errors/errors"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/kubectl/pkg/cmd/apply"
 	"k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/metrics"
+	"sigs.k8s.io/cli-utils/pkg/apply/poller"
 	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // NewDestroyer returns a new destroyer. It will set up the ApplyOptions and
@@ -20,43 +33,184 @@ import (
 // handled by a separate printer with the KubectlPrinterAdapter bridging
 // between the two.
 func NewDestroyer(factory util.Factory, ioStreams genericclioptions.IOStreams) *Destroyer {
+	return NewDestroyerWithClients(factory, ioStreams, Clients{})
+}
+
+// NewDestroyerWithClients returns a Destroyer that uses the given
+// clients to talk to the cluster, instead of creating them from the
+// factory. The clients that are nil are still created from the factory.
+func NewDestroyerWithClients(factory util.Factory, ioStreams genericclioptions.IOStreams, clients Clients) *Destroyer {
 	return &Destroyer{
-		ApplyOptions: apply.NewApplyOptions(ioStreams),
-		PruneOptions: prune.NewPruneOptions(),
-		factory:      factory,
-		ioStreams:    ioStreams,
+		ApplyOptions:        apply.NewApplyOptions(ioStreams),
+		PruneOptions:        prune.NewPruneOptionsWithClients(clients.DynamicClient, clients.Mapper),
+		clients:             clients,
+		WaitTimeout:         time.Minute,
+		PollInterval:        poller.DefaultPollInterval,
+		gracePeriod:         -1,
+		ProtectedNamespaces: append([]string{}, prune.DefaultProtectedNamespaces...),
+		Metrics:             metrics.NoopRecorder{},
+		factory:             factory,
+		ioStreams:           ioStreams,
 	}
 }
 
+// deletionPoller defines the interface the destroyer needs to wait
+// for resources to be removed from the cluster.
+type deletionPoller interface {
+	WaitForDeleted(ctx context.Context, objs []*object.ObjMetadata) <-chan pollevent.Event
+}
+
 // Destroyer performs the step of grabbing all the previous inventory objects and
 // prune them. This also deletes all the previous inventory objects
 type Destroyer struct {
 	factory      util.Factory
+	clients      Clients
 	ioStreams    genericclioptions.IOStreams
 	ApplyOptions *apply.ApplyOptions
 	PruneOptions *prune.PruneOptions
 
-	DryRun bool
+	// DryRunStrategy determines if the resources are deleted, or
+	// if the deletes are only evaluated on the client or the server.
+	DryRunStrategy common.DryRunStrategy
+	// WaitForDeletion makes the Destroyer wait for the deleted
+	// resources to be removed from the cluster before the grouping
+	// object is deleted. If they are not removed within the
+	// WaitTimeout, the grouping object is kept.
+	WaitForDeletion bool
+	WaitTimeout     time.Duration
+	// PollInterval is how often the resources are polled
+	// while waiting for them to be deleted.
+	PollInterval time.Duration
+	statusPoller deletionPoller
+	// Pruner deletes the resources. The PruneOptions are
+	// used if it is nil.
+	Pruner Pruner
+	// ProtectedNamespaces are the namespaces that are never deleted, and
+	// whose resources are never deleted, together with the other
+	// resources the cluster depends on, unless AllowProtectedDeletion
+	// is set.
+	ProtectedNamespaces    []string
+	AllowProtectedDeletion bool
+	// RetriableErrors are matched against the errors of the deletes,
+	// and the errors they match are reported as transient.
+	RetriableErrors []ErrorMatcher
+	// Metrics is notified about the progress of every run.
+	Metrics metrics.Recorder
+	// Logger is used to log what the Destroyer is doing. Nothing
+	// is logged if it is nil.
+	Logger logr.Logger
+	// Tracer is used to create spans for the phases of every run.
+	// No spans are created if it is nil.
+	Tracer trace.Tracer
+	// RequireChecksum makes it an error to read manifests from URLs
+	// that don't pin the checksum of the content.
+	RequireChecksum bool
+	// PreRunGate is called with the plan after the resources have
+	// been read, but before anything is deleted. The run is aborted
+	// if it returns an error. It is not called for dry-runs.
+	PreRunGate PreRunGate
+	// PlanFn is called with the plan after the resources have been
+	// read, but before anything is deleted. Unlike the PreRunGate,
+	// it is also called for dry-runs, so a preview can show the
+	// order in which the resources would be deleted.
+	PlanFn func(plan Plan)
+	// InventoryID overrides the inventory id in the grouping object
+	// template. It must be the id the package was applied with. The
+	// id from the template is used if it is empty.
+	InventoryID string
+	// TargetNamespace moves the namespaced resources of the package
+	// to the namespace. It must be the namespace the package was
+	// applied to. The namespaces of the manifests are used if it is
+	// empty.
+	TargetNamespace string
+	// Helm is the Helm chart that is rendered and read
+	// together with the manifests.
+	Helm HelmOptions
+	// Substitution contains the values of the variables
+	// substituted in the manifests.
+	Substitution SubstitutionOptions
+	// remote holds the manifests downloaded from URLs until
+	// they have been read.
+	remote *remoteManifests
+	// cascade holds the propagation policy provided on the
+	// command line.
+	cascade string
+	// gracePeriod holds the grace period in seconds provided on
+	// the command line. It is ignored if negative.
+	gracePeriod int
+	// pruneTimeout is set from the --prune-timeout flag. It enables
+	// the wait for deletion and overrides the WaitTimeout.
+	pruneTimeout time.Duration
 }
 
 // Initialize sets up the Destroyer for actually doing an destroy against
 // a cluster. This involves validating command line inputs and configuring
 // clients for communicating with the cluster.
 func (d *Destroyer) Initialize(cmd *cobra.Command, paths []string) error {
-	fileNameFlags := processPaths(paths)
+	d.remote = newRemoteManifests(d.RequireChecksum)
+	if d.Substitution.enabled() {
+		variables, err := d.Substitution.variables()
+		if err != nil {
+			return errors.WrapPrefix(err, "error reading variables", 1)
+		}
+		d.remote.variables = variables
+	}
+	if d.Helm.Chart != "" && d.Helm.Namespace == "" {
+		namespace, _, err := d.factory.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return errors.WrapPrefix(err, "error getting the namespace", 1)
+		}
+		d.Helm.Namespace = namespace
+	}
+	fileNameFlags, err := d.remote.resolvePaths(paths, d.ApplyOptions.DeleteFlags.FileNameFlags, d.Helm)
+	if err != nil {
+		return errors.WrapPrefix(err, "error resolving manifests", 1)
+	}
 	d.ApplyOptions.DeleteFlags.FileNameFlags = &fileNameFlags
-	err := d.ApplyOptions.Complete(d.factory, cmd)
+	err = completeApplyOptions(d.ApplyOptions, d.factory, cmd)
 	if err != nil {
 		return errors.WrapPrefix(err, "error setting up ApplyOptions", 1)
 	}
+	setApplyOptionsClients(d.ApplyOptions, d.clients)
 	err = d.PruneOptions.Initialize(d.factory, d.ApplyOptions.Namespace)
 	if err != nil {
 		return errors.WrapPrefix(err, "error setting up PruneOptions", 1)
 	}
 
 	// Propagate dry-run flags.
-	d.ApplyOptions.DryRun = d.DryRun
-	d.PruneOptions.DryRun = d.DryRun
+	d.ApplyOptions.DryRun = d.DryRunStrategy.ClientDryRun()
+	d.ApplyOptions.ServerDryRun = d.DryRunStrategy.ServerDryRun()
+	d.PruneOptions.DryRunStrategy = d.DryRunStrategy
+	d.PruneOptions.SensitiveFields = object.DefaultSensitiveFields
+	d.PruneOptions.Logger = d.logger().WithName("prune")
+	d.PruneOptions.ProtectedNamespaces = d.ProtectedNamespaces
+	d.PruneOptions.AllowProtectedDeletion = d.AllowProtectedDeletion
+	if d.cascade != "" {
+		d.PruneOptions.PropagationPolicy, err = prune.ParsePropagationPolicy(d.cascade)
+		if err != nil {
+			return errors.WrapPrefix(err, "error parsing cascade", 1)
+		}
+	}
+	if d.gracePeriod >= 0 {
+		gracePeriod := int64(d.gracePeriod)
+		d.PruneOptions.GracePeriodSeconds = &gracePeriod
+	}
+
+	if d.pruneTimeout > 0 {
+		d.WaitForDeletion = true
+		d.WaitTimeout = d.pruneTimeout
+	}
+	if d.WaitForDeletion {
+		if d.PollInterval <= 0 {
+			return errors.New("the status poll interval must be greater than 0")
+		}
+		statusPoller, err := newStatusPoller(d.clients, d.factory, d.PollInterval)
+		if err != nil {
+			return errors.WrapPrefix(err, "error creating status poller", 1)
+		}
+		d.statusPoller = statusPoller
+		d.PruneOptions.WaitForDeletion = d.waitForDeletion
+	}
 
 	if err != nil {
 		return errors.WrapPrefix(err, "error creating resolver", 1)
@@ -71,19 +225,58 @@ func (d *Destroyer) Run() <-chan event.Event {
 
 	go func() {
 		defer close(ch)
-		infos, _ := d.ApplyOptions.GetObjects()
+		defer d.remote.cleanup()
+		ctx, runSpan := startSpan(context.Background(), d.Tracer, spanDestroyerRun)
+		defer runSpan.End()
+
+		destroyStarted := time.Now()
+		_, span := startSpan(ctx, d.Tracer, spanRead)
+		infos, err := d.ApplyOptions.GetObjects()
+		endSpan(span, err)
+		if err != nil {
+			sendError(ch, d.logger(), "error reading resources", err, ExitValidationError)
+			return
+		}
+		if err := prune.DetectGroupingObject(infos); err != nil {
+			sendError(ch, d.logger(), "error finding grouping object", err, ExitValidationError)
+			return
+		}
+		if d.InventoryID != "" {
+			if err := prune.SetInventoryID(infos, d.InventoryID); err != nil {
+				sendError(ch, d.logger(), "error setting inventory id", err, ExitValidationError)
+				return
+			}
+		}
+		if d.TargetNamespace != "" {
+			if err := setNamespace(infos, d.TargetNamespace); err != nil {
+				sendError(ch, d.logger(), "error setting namespace", err, ExitValidationError)
+				return
+			}
+		}
 		// Clear the data/inventory section of the grouping object configmap,
 		// so the prune will calculate the prune set as all the objects,
 		// deleting everything. We can ignore the error, since the Prune
 		// will catch the same problems.
 		_ = prune.ClearGroupingObj(infos)
 
+		if d.PlanFn != nil || d.PreRunGate != nil {
+			err = d.runPreRunGate(infos)
+			if err != nil {
+				d.logger().Error(err, "run was not allowed to continue")
+				sendErrorEvent(ch, err)
+				return
+			}
+		}
+
 		// Start the event transformer goroutine so we can transform
 		// the Prune events emitted from the Prune function to Delete
 		// Events. That we use Prune to implement destroy is an
 		// implementation detail and the events should not be Prune events.
 		tempChannel, completedChannel := runPruneEventTransformer(ch)
-		err := d.PruneOptions.Prune(infos, tempChannel)
+		d.logger().V(1).Info("deleting resources", "dryRun", d.DryRunStrategy.String())
+		_, span = startSpan(ctx, d.Tracer, spanDelete)
+		err = d.pruner().Prune(infos, tempChannel)
+		endSpan(span, err)
 		// Close the tempChannel to signal to the event transformer that
 		// it should terminate.
 		close(tempChannel)
@@ -91,53 +284,148 @@ func (d *Destroyer) Run() <-chan event.Event {
 		// events and shut down before we continue.
 		<-completedChannel
 		if err != nil {
+			d.logger().Error(err, "error deleting resources")
 			// If we see an error here we just report it on the channel and then
 			// give up. Eventually we might be able to determine which errors
 			// are fatal and which might allow us to continue.
-			ch <- event.Event{
-				Type: event.ErrorType,
-				ErrorEvent: event.ErrorEvent{
-					Err: errors.WrapPrefix(err, "error pruning resources", 1),
-				},
-			}
+			sendErrorEvent(ch, withRetriableErrors(withExitCode(errors.WrapPrefix(err, "error pruning resources", 1),
+				ExitPruneError), d.RetriableErrors))
 			return
 		}
 		ch <- event.Event{
-			Type: event.DeleteType,
+			Type:      event.DeleteType,
+			Timestamp: time.Now(),
+			Started:   destroyStarted,
 			DeleteEvent: event.DeleteEvent{
 				Type: event.DeleteEventCompleted,
 			},
 		}
 	}()
-	return ch
+	return recordMetrics(d.Metrics, ch)
 }
 
 // SetFlags configures the command line flags needed for destroy
 // This is a temporary solution as we should separate the configuration
 // of cobra flags from the Destroyer.
 func (d *Destroyer) SetFlags(cmd *cobra.Command) error {
+	// The cascade and grace period apply to the whole inventory,
+	// so the flags are added below instead of the kubectl ones.
+	d.ApplyOptions.DeleteFlags.Cascade = nil
+	d.ApplyOptions.DeleteFlags.GracePeriod = nil
 	d.ApplyOptions.DeleteFlags.AddFlags(cmd)
-	for _, flag := range []string{"kustomize", "filename", "recursive"} {
-		err := cmd.Flags().MarkHidden(flag)
-		if err != nil {
-			return err
-		}
-	}
+	// The kustomization is built in-process, so it can
+	// be combined with the other manifests.
+	cmd.Flags().Lookup("kustomize").Usage = "Build the kustomization directory and delete the result. " +
+		"Can be combined with -f."
+	cmd.Flags().Lookup("filename").Usage = "Filename, directory, pattern or URL of the manifests to delete. Patterns like " +
+		"'manifests/**/*.yaml' match files in lexical order. Directories in git " +
+		"repositories can be given like kustomize remote bases, for example https://github.com/org/repo//dir?ref=v1, " +
+		"and packages in OCI registries as oci://REGISTRY/REPOSITORY@sha256:<hex>. Manifests can be YAML or JSON, " +
+		"including JSON arrays of objects, and - reads them from stdin."
 	d.ApplyOptions.RecordFlags.AddFlags(cmd)
 	_ = cmd.Flags().MarkHidden("record")
-	_ = cmd.Flags().MarkHidden("cascade")
 	_ = cmd.Flags().MarkHidden("force")
-	_ = cmd.Flags().MarkHidden("grace-period")
 	_ = cmd.Flags().MarkHidden("timeout")
 	_ = cmd.Flags().MarkHidden("wait")
+	cmd.Flags().StringVar(&d.cascade, "cascade", d.cascade,
+		"How the dependents of the deleted resources are deleted. Must be one of background, foreground, "+
+			"which deletes the dependents before the resource, or orphan, which keeps the dependents. If not "+
+			"set, the default of the server is used.")
+	cmd.Flags().IntVar(&d.gracePeriod, "grace-period", d.gracePeriod,
+		"Period of time in seconds given to every resource to terminate gracefully. Ignored if negative.")
+	cmd.Flags().StringSliceVar(&d.ProtectedNamespaces, "protected-namespaces", d.ProtectedNamespaces,
+		"Namespaces that are never deleted, and whose resources are never deleted. The resources the cluster "+
+			"depends on, like the default RBAC roles, are never deleted either.")
+	cmd.Flags().BoolVar(&d.AllowProtectedDeletion, "allow-protected-deletion", d.AllowProtectedDeletion,
+		"If true, the protected namespaces and resources can be deleted.")
+	cmd.Flags().StringVar(&d.InventoryID, "inventory-id", d.InventoryID,
+		"Override the inventory id of the grouping object template. Must be the id the package was applied with.")
+	_ = cmd.RegisterFlagCompletionFunc("inventory-id", InventoryIDCompletionFunc(d.factory))
+	cmd.Flags().StringVar(&d.TargetNamespace, "target-namespace", d.TargetNamespace,
+		"If set, the namespaced resources of the package are deleted from this namespace. Must be the "+
+			"namespace the package was applied to.")
+	cmd.Flags().BoolVar(&d.RequireChecksum, "require-checksum", d.RequireChecksum,
+		"If true, manifests can only be read from URLs that pin the checksum of the content, "+
+			"for example https://example.com/release.yaml#sha256=<hex>.")
+	cmd.Flags().BoolVar(&d.WaitForDeletion, "wait-for-deletion", d.WaitForDeletion,
+		"Wait for all deleted resources to be removed from the cluster before the inventory is deleted.")
+	cmd.Flags().DurationVar(&d.WaitTimeout, "wait-timeout", d.WaitTimeout,
+		"Timeout threshold for waiting for all resources to be removed from the cluster.")
+	_ = cmd.Flags().MarkDeprecated("wait-timeout", "use --prune-timeout instead")
+	cmd.Flags().DurationVar(&d.PollInterval, "status-poll-interval", d.PollInterval,
+		"How often the resources are polled while waiting for them to be deleted.")
+	cmd.Flags().DurationVar(&d.pruneTimeout, "prune-timeout", d.pruneTimeout,
+		"Wait up to the given duration for all deleted resources to be removed from the cluster before the "+
+			"inventory is deleted. A value of 0 means the wait is controlled by --wait-for-deletion.")
+	addHelmFlags(cmd, &d.Helm)
+	addSubstitutionFlags(cmd, &d.Substitution)
+	addDryRunFlag(cmd, &d.DryRunStrategy)
 	d.ApplyOptions.Overwrite = true
 	return nil
 }
 
+// runPreRunGate calls the PlanFn and, unless this is a dry-run, the
+// PreRunGate with the resources that will be deleted, which are all
+// the resources in the previous inventories.
+func (d *Destroyer) runPreRunGate(infos []*resource.Info) error {
+	deleteSet, err := d.pruner().PruneSet(infos)
+	if err != nil {
+		return errors.WrapPrefix(err, "error computing the resources to delete", 1)
+	}
+	plan := Plan{Delete: deleteSet}
+	if d.PlanFn != nil {
+		d.PlanFn(plan)
+	}
+	if d.PreRunGate == nil || d.DryRunStrategy.ClientOrServerDryRun() {
+		return nil
+	}
+	return d.PreRunGate(plan)
+}
+
+// waitForDeletion waits until the resources have been removed from the
+// cluster, and sends the status updates on the channel. It returns an
+// error if the resources are not removed before the timeout.
+func (d *Destroyer) waitForDeletion(objs []*object.ObjMetadata, eventChannel chan<- event.Event) error {
+	return waitForDeletion(d.statusPoller, d.WaitTimeout, objs, eventChannel)
+}
+
+// waitForDeletion waits until the resources have been removed from the
+// cluster, and sends the status updates on the channel. It returns an
+// error if the resources are not removed before the timeout. There is
+// no timeout if it is 0.
+func waitForDeletion(p deletionPoller, timeout time.Duration, objs []*object.ObjMetadata,
+	eventChannel chan<- event.Event) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	waitStarted := time.Now()
+	aborted := false
+	for statusEvent := range p.WaitForDeleted(ctx, objs) {
+		if statusEvent.EventType == pollevent.AbortedEvent {
+			aborted = true
+		}
+		eventChannel <- event.Event{
+			Type:        event.StatusType,
+			Timestamp:   time.Now(),
+			Started:     waitStarted,
+			StatusEvent: statusEvent,
+		}
+	}
+	if aborted {
+		return withExitCode(fmt.Errorf("%w waiting for resources to be deleted", ErrTimeout), ExitReconcileTimeout)
+	}
+	return nil
+}
+
 // runPruneEventTransformer creates a channel for events and
 // starts a goroutine that will read from the channel until it
-// is closed. All events will be republished as Delete events
-// on the provided eventChannel. The function will also return
+// is closed. All prune events will be republished as Delete events
+// on the provided eventChannel, and progress for the prune phase is
+// reported as progress for the delete phase. All other events are
+// forwarded as-is. The function will also return
 // a channel that it will close once the goroutine is shutting
 // down.
 func runPruneEventTransformer(eventChannel chan event.Event) (chan event.Event, <-chan struct{}) {
@@ -146,14 +434,45 @@ func runPruneEventTransformer(eventChannel chan event.Event) (chan event.Event,
 	go func() {
 		defer close(completedChannel)
 		for msg := range tempEventChannel {
+			if msg.Type == event.ProgressType {
+				progressEvent := msg.ProgressEvent
+				if progressEvent.Phase == event.PrunePhase {
+					progressEvent.Phase = event.DeletePhase
+				}
+				eventChannel <- event.Event{
+					Type:          event.ProgressType,
+					Timestamp:     msg.Timestamp,
+					Started:       msg.Started,
+					ProgressEvent: progressEvent,
+				}
+				continue
+			}
+			// Resources that are left in the cluster are still
+			// reported as skipped prune events.
+			if msg.Type != event.PruneType || msg.PruneEvent.Operation == event.PruneSkipped {
+				eventChannel <- msg
+				continue
+			}
 			eventChannel <- event.Event{
-				Type: event.DeleteType,
+				Type:      event.DeleteType,
+				Timestamp: msg.Timestamp,
+				Started:   msg.Started,
 				DeleteEvent: event.DeleteEvent{
-					Type:   event.DeleteEventResourceUpdate,
-					Object: msg.PruneEvent.Object,
+					Type:       event.DeleteEventResourceUpdate,
+					Object:     msg.PruneEvent.Object,
+					Identifier: msg.PruneEvent.Identifier,
 				},
 			}
 		}
 	}()
 	return tempEventChannel, completedChannel
 }
+
+// logger returns the Logger, or a logger that discards
+// everything if none has been set.
+func (d *Destroyer) logger() logr.Logger {
+	if d.Logger == nil {
+		return log.NullLogger{}
+	}
+	return d.Logger
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestWaitForDeletion(t *testing.T) {
+	objs := []*object.ObjMetadata{
+		{
+			Namespace: "default",
+			Name:      "foo",
+			GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		},
+	}
+	testCases := map[string]struct {
+		events      []pollevent.Event
+		expectedErr bool
+	}{
+		"deleted": {
+			events: []pollevent.Event{
+				{EventType: pollevent.ResourceUpdateEvent, AggregateStatus: status.NotFoundStatus},
+				{EventType: pollevent.CompletedEvent, AggregateStatus: status.NotFoundStatus},
+			},
+		},
+		"timed out": {
+			events: []pollevent.Event{
+				{EventType: pollevent.ResourceUpdateEvent, AggregateStatus: status.TerminatingStatus},
+				{EventType: pollevent.AbortedEvent, AggregateStatus: status.TerminatingStatus},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			fakePoller := &fakeStatusPoller{events: tc.events}
+			destroyer := &Destroyer{statusPoller: fakePoller}
+
+			ch := make(chan event.Event, len(tc.events))
+			err := destroyer.waitForDeletion(objs, ch)
+			close(ch)
+
+			assert.Equal(t, objs, fakePoller.objs)
+			var received []pollevent.Event
+			for e := range ch {
+				assert.Equal(t, event.StatusType, e.Type)
+				received = append(received, e.StatusEvent)
+			}
+			assert.Equal(t, tc.events, received)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				assert.Equal(t, ExitReconcileTimeout, ExitCode(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPruneEventTransformer(t *testing.T) {
+	ch := make(chan event.Event, 3)
+	tempChannel, completed := runPruneEventTransformer(ch)
+	tempChannel <- event.Event{
+		Type: event.PruneType,
+		PruneEvent: event.PruneEvent{
+			Type: event.PruneEventResourceUpdate,
+		},
+	}
+	tempChannel <- event.NewProgressEvent(event.PrunePhase, 1, 1, time.Time{})
+	tempChannel <- event.Event{
+		Type: event.StatusType,
+		StatusEvent: pollevent.Event{
+			EventType: pollevent.CompletedEvent,
+		},
+	}
+	close(tempChannel)
+	<-completed
+	close(ch)
+
+	var types []event.Type
+	for e := range ch {
+		types = append(types, e.Type)
+		if e.Type == event.ProgressType {
+			assert.Equal(t, event.DeletePhase, e.ProgressEvent.Phase)
+		}
+	}
+	assert.Equal(t, []event.Type{event.DeleteType, event.ProgressType, event.StatusType}, types)
+}
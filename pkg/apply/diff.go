@@ -0,0 +1,189 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/yaml"
+)
+
+// ignoredMetadataFields are set by the apiserver and never part of
+// the local configuration, so they are left out of the diff.
+var ignoredMetadataFields = []string{
+	"creationTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// diffInfo returns a unified diff between the live version of the
+// resource and the local configuration. Only the fields that are set
+// in the local configuration are compared, since all other fields are
+// either defaulted by the apiserver or owned by someone else. If the
+// resource doesn't exist in the cluster, the diff shows the full
+// resource being added. The values of the sensitive fields are
+// redacted. An empty string means there are no changes.
+func diffInfo(info *resource.Info, sensitiveFields []object.SensitiveField) (string, error) {
+	live, err := getLive(info)
+	if err != nil {
+		return "", err
+	}
+	return diffLive(info, live, sensitiveFields)
+}
+
+// diffLive is like diffInfo, with the live version of the resource
+// returned by getLive.
+func diffLive(info *resource.Info, live map[string]interface{},
+	sensitiveFields []object.SensitiveField) (string, error) {
+	local, err := toMap(info.Object)
+	if err != nil {
+		return "", err
+	}
+	local = cleanForDiff(local)
+	if live != nil {
+		live = pruneToFields(live, local).(map[string]interface{})
+	}
+	gk := info.Object.GetObjectKind().GroupVersionKind().GroupKind()
+	object.RedactDiff(gk, live, local, sensitiveFields)
+
+	return diffObjects(fmt.Sprintf("%s/%s", info.Mapping.Resource.Resource, info.Name), live, local)
+}
+
+// diffDryRunResult returns a unified diff between the live version
+// of the resource and the result of applying it with a server-side
+// dry-run. Since the dry-run result is the complete object as it would
+// be stored, the diff also includes the fields set by defaulting and
+// admission. A nil live object means the resource doesn't exist in the
+// cluster. The values of the sensitive fields are redacted.
+func diffDryRunResult(id object.ObjMetadata, live map[string]interface{}, result runtime.Object,
+	sensitiveFields []object.SensitiveField) (string, error) {
+	merged, err := toMap(result)
+	if err != nil {
+		return "", err
+	}
+	merged = cleanForDiff(merged)
+	if live != nil {
+		live = runtime.DeepCopyJSON(live)
+	}
+	object.RedactDiff(id.GroupKind, live, merged, sensitiveFields)
+	return diffObjects(resourceIDToString(id.GroupKind, id.Name), live, merged)
+}
+
+// getLive returns the cleaned up content of the live version of the
+// resource, or nil if it doesn't exist in the cluster.
+func getLive(info *resource.Info) (map[string]interface{}, error) {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	liveObj, err := helper.Get(info.Namespace, info.Name, false)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	live, err := toMap(liveObj)
+	if err != nil {
+		return nil, err
+	}
+	return cleanForDiff(live), nil
+}
+
+// toMap returns a copy of the content of the object as a map.
+func toMap(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return runtime.DeepCopyJSON(u.UnstructuredContent()), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// diffObjects returns the unified diff between the YAML representation
+// of the two objects. A nil from object means it doesn't exist.
+func diffObjects(name string, from, to map[string]interface{}) (string, error) {
+	if reflect.DeepEqual(from, to) {
+		return "", nil
+	}
+	var fromLines []string
+	if from != nil {
+		fromYaml, err := yaml.Marshal(from)
+		if err != nil {
+			return "", err
+		}
+		fromLines = splitLines(string(fromYaml))
+	}
+	toYaml, err := yaml.Marshal(to)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        fromLines,
+		B:        splitLines(string(toYaml)),
+		FromFile: "live/" + name,
+		ToFile:   "local/" + name,
+		Context:  3,
+	})
+}
+
+// splitLines splits the text into lines, keeping the newlines.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// cleanForDiff removes the status and the metadata fields that are
+// managed by the apiserver. The last-applied-configuration annotation
+// is removed as well, since it repeats the rest of the object and
+// would reveal the values of sensitive fields, and so are the audit
+// annotations, which change with every run.
+func cleanForDiff(obj map[string]interface{}) map[string]interface{} {
+	delete(obj, "status")
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, f := range ignoredMetadataFields {
+			delete(metadata, f)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, corev1.LastAppliedConfigAnnotation)
+			for _, a := range auditAnnotations {
+				delete(annotations, a)
+			}
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	return obj
+}
+
+// pruneToFields returns a copy of live with only the map keys that
+// are also present in local. Lists and scalar values are kept as-is,
+// since there is no general way to match up list elements.
+func pruneToFields(live, local interface{}) interface{} {
+	liveMap, ok := live.(map[string]interface{})
+	if !ok {
+		return live
+	}
+	localMap, ok := local.(map[string]interface{})
+	if !ok {
+		return live
+	}
+	pruned := make(map[string]interface{})
+	for key, localValue := range localMap {
+		if liveValue, found := liveMap[key]; found {
+			pruned[key] = pruneToFields(liveValue, localValue)
+		}
+	}
+	return pruned
+}
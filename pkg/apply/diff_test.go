@@ -0,0 +1,201 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestDiffObjects(t *testing.T) {
+	local := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+		"data": map[string]interface{}{
+			"key": "new",
+		},
+	}
+
+	testCases := map[string]struct {
+		live         map[string]interface{}
+		expectedDiff string
+	}{
+		"resource does not exist": {
+			live: nil,
+			expectedDiff: `--- live/configmaps/foo
++++ local/configmaps/foo
+@@ -0,0 +1,6 @@
++apiVersion: v1
++data:
++  key: new
++kind: ConfigMap
++metadata:
++  name: foo
+`,
+		},
+		"resource changed": {
+			live: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name": "foo",
+				},
+				"data": map[string]interface{}{
+					"key": "old",
+				},
+			},
+			expectedDiff: `--- live/configmaps/foo
++++ local/configmaps/foo
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: old
++  key: new
+ kind: ConfigMap
+ metadata:
+   name: foo
+`,
+		},
+		"resource unchanged": {
+			live:         local,
+			expectedDiff: "",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			diff, err := diffObjects("configmaps/foo", tc.live, local)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDiff, diff)
+		})
+	}
+}
+
+func TestPruneToFields(t *testing.T) {
+	live := cleanForDiff(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "foo",
+			"uid":             "1234",
+			"resourceVersion": "42",
+		},
+		"spec": map[string]interface{}{
+			"replicas":             int64(3),
+			"revisionHistoryLimit": int64(10),
+		},
+		"status": map[string]interface{}{
+			"replicas": int64(3),
+		},
+	})
+	local := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+		},
+	}
+
+	assert.Equal(t, local, pruneToFields(live, local))
+}
+
+func TestCleanForDiffRemovesLastApplied(t *testing.T) {
+	obj := cleanForDiff(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+	}, obj)
+}
+
+func TestCleanForDiffRemovesAuditAnnotations(t *testing.T) {
+	obj := cleanForDiff(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+			"annotations": map[string]interface{}{
+				AppliedByAnnotation: "admin",
+				AppliedAtAnnotation: "2020-01-01T00:00:00Z",
+				"owner":             "team-a",
+			},
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "foo",
+			"annotations": map[string]interface{}{
+				"owner": "team-a",
+			},
+		},
+	}, obj)
+}
+
+func TestDiffDryRunResult(t *testing.T) {
+	id := object.ObjMetadata{
+		Namespace: "default",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Kind: "Secret"},
+	}
+	live := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      "foo",
+			"namespace": "default",
+		},
+		"data": map[string]interface{}{
+			"password": "b2xk",
+		},
+	}
+	result := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":            "foo",
+				"namespace":       "default",
+				"resourceVersion": "42",
+			},
+			"data": map[string]interface{}{
+				"password": "bmV3",
+			},
+			"type": "Opaque",
+		},
+	}
+
+	diff, err := diffDryRunResult(id, live, result, object.DefaultSensitiveFields)
+	assert.NoError(t, err)
+	assert.Equal(t, `--- live/secret/foo
++++ local/secret/foo
+@@ -1,7 +1,8 @@
+ apiVersion: v1
+ data:
+-  password: '*** (before)'
++  password: '*** (after)'
+ kind: Secret
+ metadata:
+   name: foo
+   namespace: default
++type: Opaque
+`, diff)
+	assert.Equal(t, "b2xk", live["data"].(map[string]interface{})["password"])
+}
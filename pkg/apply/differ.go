@@ -0,0 +1,191 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// NewDiffer returns a new Differ. Like the Applier, it sets up the
+// ApplyOptions and PruneOptions which capture the command line flags.
+func NewDiffer(factory util.Factory, ioStreams genericclioptions.IOStreams) *Differ {
+	return &Differ{
+		Applier:        NewApplier(factory, ioStreams),
+		DryRunStrategy: common.DryRunServer,
+	}
+}
+
+// Differ shows the changes an apply would make to the cluster, without
+// making them. With the default server-side dry-run, every resource is
+// applied in dry-run mode and the result is compared to the live state,
+// so the diff includes the changes made by defaulting and admission.
+// Resources that would be pruned are reported as well.
+type Differ struct {
+	// Applier performs the dry-run apply and prune.
+	Applier *Applier
+	// DryRunStrategy determines how the changes are computed. With
+	// DryRunClient, the local configuration is compared to the live
+	// state, which doesn't need dry-run support from the apiserver.
+	DryRunStrategy common.DryRunStrategy
+	// NoPrune disables reporting the resources that will be pruned.
+	NoPrune bool
+
+	// sensitiveFields are redacted in the diffs. The Applier doesn't
+	// redact the objects in the events, since they are needed to
+	// compute the diffs.
+	sensitiveFields []object.SensitiveField
+}
+
+// Initialize sets up the Differ for computing the diff against a
+// cluster. The DryRunStrategy must be set before Initialize is called.
+func (d *Differ) Initialize(cmd *cobra.Command, paths []string) error {
+	if !d.DryRunStrategy.ClientOrServerDryRun() {
+		return errors.Errorf("the differ requires a client or server dry-run")
+	}
+	a := d.Applier
+	a.DryRunStrategy = d.DryRunStrategy
+	a.NoPrune = d.NoPrune
+	a.Diff = d.DryRunStrategy.ClientDryRun()
+	if err := a.Initialize(cmd, paths); err != nil {
+		return err
+	}
+	d.sensitiveFields = a.SensitiveFields
+	if d.DryRunStrategy.ServerDryRun() {
+		a.SensitiveFields = nil
+	}
+	a.StatusOptions.Wait = false
+	a.StatusOptions.NoWait = false
+	return nil
+}
+
+// SetFlags configures the command line flags needed by the Differ.
+func (d *Differ) SetFlags(cmd *cobra.Command) error {
+	if err := d.Applier.SetFlags(cmd); err != nil {
+		return err
+	}
+	for _, flag := range []string{"wait", "no-wait", "wait-for-reconcile", "wait-polling-period", "wait-timeout", "reconcile-timeout"} {
+		_ = cmd.Flags().MarkHidden(flag)
+	}
+	return nil
+}
+
+// Run computes the diff for every resource and reports it as a diff
+// event on the returned channel. Resources without changes are left
+// out. Errors are reported as error events, and the channel is closed
+// when all resources have been handled.
+func (d *Differ) Run(ctx context.Context) <-chan event.Event {
+	ch := make(chan event.Event)
+
+	go func() {
+		defer close(ch)
+		serverDryRun := d.DryRunStrategy.ServerDryRun()
+		var live map[string]map[string]interface{}
+		if serverDryRun {
+			infos, err := d.Applier.ApplyOptions.GetObjects()
+			if err != nil {
+				ch <- event.Event{
+					Type:      event.ErrorType,
+					Timestamp: time.Now(),
+					ErrorEvent: event.ErrorEvent{
+						Err: withExitCode(errors.WrapPrefix(err, "error reading resources", 1), ExitValidationError),
+					},
+				}
+				return
+			}
+			live, err = getLiveObjects(infos)
+			if err != nil {
+				ch <- event.Event{
+					Type:      event.ErrorType,
+					Timestamp: time.Now(),
+					ErrorEvent: event.ErrorEvent{
+						Err: withExitCode(errors.WrapPrefix(err, "error reading live resources", 1), ExitApplyError),
+					},
+				}
+				return
+			}
+		}
+
+		for e := range d.Applier.Run(ctx) {
+			switch e.Type {
+			case event.ApplyType:
+				if !serverDryRun || e.ApplyEvent.Type != event.ApplyEventResourceUpdate ||
+					e.ApplyEvent.Operation == event.Filtered || prune.IsGroupingObject(e.ApplyEvent.Object) {
+					continue
+				}
+				id := e.ApplyEvent.Identifier
+				diff, err := diffDryRunResult(id, live[id.String()], e.ApplyEvent.Object, d.sensitiveFields)
+				if err != nil {
+					ch <- event.Event{
+						Type:      event.ErrorType,
+						Timestamp: time.Now(),
+						ErrorEvent: event.ErrorEvent{
+							Err: errors.WrapPrefix(err, "error computing diff", 1),
+						},
+					}
+					continue
+				}
+				if diff == "" {
+					continue
+				}
+				ch <- event.Event{
+					Type:      event.DiffType,
+					Timestamp: time.Now(),
+					DiffEvent: event.DiffEvent{
+						Identifier: id,
+						Diff:       diff,
+					},
+				}
+			case event.PruneType:
+				if e.PruneEvent.Type != event.PruneEventResourceUpdate ||
+					e.PruneEvent.Operation != event.Pruned ||
+					prune.IsGroupingObject(e.PruneEvent.Object) {
+					continue
+				}
+				ch <- event.Event{
+					Type:      event.DiffType,
+					Timestamp: time.Now(),
+					DiffEvent: event.DiffEvent{
+						Identifier: e.PruneEvent.Identifier,
+						Prune:      true,
+					},
+				}
+			case event.DiffType, event.ErrorType:
+				ch <- e
+			}
+		}
+	}()
+	return ch
+}
+
+// getLiveObjects fetches the live version of the resources, keyed by
+// the string form of their ObjMetadata. Resources that don't exist in
+// the cluster are left out.
+func getLiveObjects(infos []*resource.Info) (map[string]map[string]interface{}, error) {
+	live := make(map[string]map[string]interface{})
+	for _, info := range infos {
+		if prune.IsGroupingObject(info.Object) {
+			continue
+		}
+		obj, err := getLive(info)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			id := infoToObjMetadata(info)
+			live[id.String()] = obj
+		}
+	}
+	return live, nil
+}
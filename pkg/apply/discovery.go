@@ -0,0 +1,106 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// cachedDiscoveryClientGetter shares one discovery client and one
+// mapper between everything created from the factory, like the
+// builders, the ApplyOptions and the status poller. Otherwise every
+// one of them reads the discovery information again. The information
+// is kept until it is invalidated, which happens automatically when
+// new kinds are waited for.
+type cachedDiscoveryClientGetter struct {
+	genericclioptions.RESTClientGetter
+
+	mu              sync.Mutex
+	discoveryClient discovery.CachedDiscoveryInterface
+	mapper          *cachedMapper
+}
+
+func newCachedDiscoveryClientGetter(getter genericclioptions.RESTClientGetter) *cachedDiscoveryClientGetter {
+	return &cachedDiscoveryClientGetter{
+		RESTClientGetter: getter,
+	}
+}
+
+func (c *cachedDiscoveryClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.toDiscoveryClient()
+}
+
+func (c *cachedDiscoveryClientGetter) toDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	if c.discoveryClient == nil {
+		discoveryClient, err := c.RESTClientGetter.ToDiscoveryClient()
+		if err != nil {
+			return nil, err
+		}
+		c.discoveryClient = discoveryClient
+	}
+	return c.discoveryClient, nil
+}
+
+func (c *cachedDiscoveryClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mapper == nil {
+		discoveryClient, err := c.toDiscoveryClient()
+		if err != nil {
+			return nil, err
+		}
+		deferred := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+		c.mapper = &cachedMapper{
+			RESTMapper: restmapper.NewShortcutExpander(deferred, discoveryClient),
+			deferred:   deferred,
+		}
+	}
+	return c.mapper, nil
+}
+
+// Invalidate drops the cached discovery information, so it
+// is read again the next time a kind is mapped.
+func (c *cachedDiscoveryClientGetter) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mapper != nil {
+		// Resetting the mapper also invalidates the discovery client.
+		c.mapper.Reset()
+	} else if c.discoveryClient != nil {
+		c.discoveryClient.Invalidate()
+	}
+}
+
+// cachedMapper is the mapper of the cachedDiscoveryClientGetter. It can
+// be reset, unlike the shortcut expander it wraps.
+type cachedMapper struct {
+	meta.RESTMapper
+	deferred *restmapper.DeferredDiscoveryRESTMapper
+}
+
+func (m *cachedMapper) Reset() {
+	m.deferred.Reset()
+}
+
+// Invalidate drops the discovery information cached by the Applier, so
+// kinds that have been added or removed since it was read are seen by
+// the next mapping. The Applier already does this when it waits for new
+// kinds, and when the manifests can't be read with the cached information,
+// so it is only needed when the kinds of the cluster are known to have
+// changed, for example when a controller sees a CRD being deleted.
+func (a *Applier) Invalidate() {
+	if a.discovery != nil {
+		a.discovery.Invalidate()
+	}
+	if r, ok := a.clients.Mapper.(resettableMapper); ok {
+		r.Reset()
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+// countingClientGetter counts the discovery clients it creates.
+type countingClientGetter struct {
+	genericclioptions.RESTClientGetter
+	discoveryClients int
+}
+
+func (c *countingClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	c.discoveryClients++
+	return c.RESTClientGetter.ToDiscoveryClient()
+}
+
+var widgetKind = fakecluster.Kind{
+	GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"},
+	Namespaced:       true,
+}
+
+func TestCachedDiscoveryClientGetter(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	counting := &countingClientGetter{RESTClientGetter: cluster.RESTClientGetter()}
+	getter := newCachedDiscoveryClientGetter(counting)
+
+	mapper, err := getter.ToRESTMapper()
+	require.NoError(t, err)
+	_, err = mapper.RESTMapping(schema.GroupKind{Kind: "ConfigMap"}, "v1")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		m, err := getter.ToRESTMapper()
+		require.NoError(t, err)
+		assert.Equal(t, mapper, m)
+		_, err = getter.ToDiscoveryClient()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, counting.discoveryClients)
+
+	// Kinds added later are only seen once the cache is invalidated.
+	cluster.AddKind(widgetKind)
+	_, err = mapper.RESTMapping(widgetKind.GroupKind(), "v1")
+	assert.True(t, meta.IsNoMatchError(err))
+	getter.Invalidate()
+	_, err = mapper.RESTMapping(widgetKind.GroupKind(), "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, counting.discoveryClients)
+}
+
+func TestApplierSeesKindsAddedBetweenRuns(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "discovery-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	write("grouping.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: inventory\n  labels:\n"+
+		"    cli-utils.sigs.k8s.io/inventory-id: test\n")
+	write("cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")
+
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	require.NoError(t, applier.Initialize(cmd, nil))
+	run := func() {
+		for e := range applier.Run(context.Background()) {
+			if e.Type == event.ErrorType {
+				t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+			}
+		}
+	}
+	run()
+
+	// The kind is installed by someone else after the discovery
+	// information has been cached.
+	cluster.AddKind(widgetKind)
+	write("widget.yaml", "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n")
+	run()
+	assert.NotNil(t, cluster.Get(widgetKind.GroupVersionKind, fakecluster.DefaultNamespace, "w"))
+}
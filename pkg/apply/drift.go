@@ -0,0 +1,207 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// DriftType is the way a resource differs from the package.
+type DriftType string
+
+const (
+	// DriftModified means a field set in the manifest has
+	// a different value in the cluster.
+	DriftModified DriftType = "Modified"
+	// DriftMissing means the resource is in the package, but
+	// doesn't exist in the cluster.
+	DriftMissing DriftType = "Missing"
+	// DriftExtraneous means the resource belongs to the inventory
+	// and exists in the cluster, but is no longer in the package.
+	// The next apply prunes it.
+	DriftExtraneous DriftType = "Extraneous"
+)
+
+// Drift is a resource whose live state differs from the package.
+type Drift struct {
+	Identifier object.ObjMetadata
+	Type       DriftType
+	// Diff is the unified diff between the live resource and the
+	// manifest. It is only set for modified resources.
+	Diff string
+}
+
+// String returns the resource and the type, like
+// "deployment.apps/app modified".
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s", resourceIDToString(d.Identifier.GroupKind, d.Identifier.Name),
+		strings.ToLower(string(d.Type)))
+}
+
+// DriftError is returned by the drift command if resources differ
+// from the package, so it exits with ExitDriftDetected.
+type DriftError struct {
+	Drifts []Drift
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%d resource(s) differ from the package", len(e.Drifts))
+}
+
+// ExitCode returns ExitDriftDetected.
+func (e *DriftError) ExitCode() int {
+	return ExitDriftDetected
+}
+
+// NewDriftDetector returns a new DriftDetector.
+func NewDriftDetector(factory util.Factory, ioStreams genericclioptions.IOStreams) *DriftDetector {
+	return &DriftDetector{
+		Applier: NewApplier(factory, ioStreams),
+	}
+}
+
+// DriftDetector compares the live state of the cluster with a package,
+// without changing anything. Only the fields that are set in the
+// manifests are compared, since the other fields are defaulted by the
+// apiserver or owned by someone else. The resources that are recorded
+// in the inventory of the package, but not in the package, are the
+// ones the next apply prunes.
+type DriftDetector struct {
+	// Applier reads the manifests, so they are found and
+	// filled in the same way as for an apply.
+	Applier *Applier
+}
+
+// Initialize sets up the DriftDetector for reading the package
+// given by the paths and the flags.
+func (d *DriftDetector) Initialize(cmd *cobra.Command, paths []string) error {
+	d.Applier.DryRunStrategy = common.DryRunClient
+	return d.Applier.Initialize(cmd, paths)
+}
+
+// SetFlags configures the command line flags needed by the DriftDetector.
+func (d *DriftDetector) SetFlags(cmd *cobra.Command) error {
+	return d.Applier.SetFlags(cmd)
+}
+
+// Detect returns the resources that differ from the package, in the
+// order they are applied, followed by the extraneous resources in the
+// order they are pruned. The values of sensitive fields are redacted
+// in the diffs.
+func (d *DriftDetector) Detect(ctx context.Context) ([]Drift, error) {
+	a := d.Applier
+	defer a.remote.cleanup()
+
+	infos, err := a.ApplyOptions.GetObjects()
+	if err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error reading resources", 1), ExitValidationError)
+	}
+	if err := prune.DetectGroupingObject(infos); err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error finding grouping object", 1), ExitValidationError)
+	}
+	if a.InventoryID != "" {
+		if err := prune.SetInventoryID(infos, a.InventoryID); err != nil {
+			return nil, withExitCode(errors.WrapPrefix(err, "error setting inventory id", 1), ExitValidationError)
+		}
+	}
+	if a.TargetNamespace != "" {
+		if err := setNamespace(infos, a.TargetNamespace); err != nil {
+			return nil, withExitCode(errors.WrapPrefix(err, "error setting namespace", 1), ExitValidationError)
+		}
+	}
+	if err := a.injectValues(infos); err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error injecting values", 1), ExitValidationError)
+	}
+	infos, err = a.planner().Plan(ctx, infos)
+	if err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error planning resources", 1), ExitValidationError)
+	}
+
+	var drifts []Drift
+	for _, info := range infos {
+		if prune.IsGroupingObject(info.Object) {
+			continue
+		}
+		live, err := getLive(info)
+		if err != nil {
+			return nil, errors.WrapPrefix(err, "error reading live resources", 1)
+		}
+		id := infoToObjMetadata(info)
+		if live == nil {
+			drifts = append(drifts, Drift{Identifier: id, Type: DriftMissing})
+			continue
+		}
+		diff, err := diffLive(info, live, a.SensitiveFields)
+		if err != nil {
+			return nil, errors.WrapPrefix(err, "error computing diff", 1)
+		}
+		if diff != "" {
+			drifts = append(drifts, Drift{Identifier: id, Type: DriftModified, Diff: diff})
+		}
+	}
+
+	extraneous, err := d.extraneous(infos)
+	if err != nil {
+		return nil, err
+	}
+	return append(drifts, extraneous...), nil
+}
+
+// extraneous returns the resources in the inventory that are
+// no longer in the package, but still exist in the cluster.
+func (d *DriftDetector) extraneous(infos []*resource.Info) ([]Drift, error) {
+	a := d.Applier
+	if err := prune.AddInventoryToGroupingObj(infos); err != nil {
+		return nil, errors.WrapPrefix(err, "error computing inventory", 1)
+	}
+	pruneSet, err := a.pruner().PruneSet(infos)
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error reading inventory", 1)
+	}
+	if len(pruneSet) == 0 {
+		return nil, nil
+	}
+	mapper, err := restMapper(a.clients, a.factory, false)
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error getting RESTMapper", 1)
+	}
+	client, err := dynamicClient(a.clients, a.factory)
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error creating dynamic client", 1)
+	}
+	var drifts []Drift
+	for _, id := range pruneSet {
+		mapping, err := mapper.RESTMapping(id.GroupKind)
+		if meta.IsNoMatchError(err) {
+			// The kind is no longer served, so the resource is gone.
+			continue
+		}
+		if err != nil {
+			return nil, errors.WrapPrefix(err, "error getting the kinds served by the cluster", 1)
+		}
+		_, err = client.Resource(mapping.Resource).Namespace(id.Namespace).Get(id.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.WrapPrefix(err, "error reading live resources", 1)
+		}
+		drifts = append(drifts, Drift{Identifier: *id, Type: DriftExtraneous})
+	}
+	return drifts, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+const driftManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    %s: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: modified
+data:
+  key: local
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unchanged
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+`
+
+func TestDriftDetector(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+
+	// The package was applied with a resource that has been removed
+	// from it since, and a resource has been modified in the cluster.
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+	withData := func(obj *unstructured.Unstructured, value string) *unstructured.Unstructured {
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	objs := []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		withData(configMap("modified", nil), "local"),
+		withData(configMap("unchanged", nil), "value"),
+		configMap("extraneous", nil),
+	}
+	for e := range applier.RunObjects(context.Background(), objs) {
+		require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+	}
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	require.NoError(t, cluster.Update(withData(cluster.Get(gvk, fakecluster.DefaultNamespace, "modified"), "live")))
+
+	dir, err := ioutil.TempDir("", "drift-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "package.yaml"), []byte(fmt.Sprintf(driftManifests, prune.GroupingLabel)), 0600))
+
+	detector := NewDriftDetector(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd = &cobra.Command{}
+	require.NoError(t, detector.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	require.NoError(t, detector.Initialize(cmd, nil))
+	before := cluster.Objects()
+	drifts, err := detector.Detect(context.Background())
+	require.NoError(t, err)
+
+	var found []string
+	for _, d := range drifts {
+		found = append(found, d.String())
+		if d.Type == DriftModified {
+			assert.Contains(t, d.Diff, "-  key: live\n+  key: local\n")
+		}
+	}
+	assert.Equal(t, []string{
+		"configmap/missing missing",
+		"configmap/modified modified",
+		"configmap/extraneous extraneous",
+	}, found)
+	// Nothing is changed in the cluster.
+	assert.Equal(t, before, cluster.Objects())
+	assert.Equal(t, ExitDriftDetected, ExitCode(&DriftError{Drifts: drifts}))
+}
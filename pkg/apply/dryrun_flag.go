@@ -0,0 +1,63 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/common"
+)
+
+// dryRunFlag is the name of the flag that sets the DryRunStrategy.
+const dryRunFlag = "dry-run"
+
+// dryRunValue is a flag value that sets a DryRunStrategy
+// from one of none, client or server.
+type dryRunValue struct {
+	strategy *common.DryRunStrategy
+}
+
+func (d *dryRunValue) String() string {
+	return d.strategy.String()
+}
+
+func (d *dryRunValue) Set(s string) error {
+	strategy, err := common.ParseDryRunStrategy(s)
+	if err != nil {
+		return err
+	}
+	*d.strategy = strategy
+	return nil
+}
+
+func (d *dryRunValue) Type() string {
+	return "string"
+}
+
+// addDryRunFlag adds the --dry-run flag for the strategy. A --dry-run
+// without a value means client, like the boolean flag it replaces.
+func addDryRunFlag(cmd *cobra.Command, strategy *common.DryRunStrategy) {
+	cmd.Flags().Var(&dryRunValue{strategy: strategy}, dryRunFlag,
+		"Must be one of none, client or server. If client, the changes are only evaluated locally. If server, "+
+			"the changes are sent to the server without being persisted. Nothing, including the inventory, "+
+			"is changed in the cluster unless it is none.")
+	cmd.Flags().Lookup(dryRunFlag).NoOptDefVal = common.DryRunClient.String()
+}
+
+// completeApplyOptions calls Complete on the ApplyOptions. They read
+// the dry-run flag as a bool, so they are given a command where it is
+// replaced with an unset bool flag. The DryRunStrategy is propagated
+// to the ApplyOptions separately.
+func completeApplyOptions(o *apply.ApplyOptions, f util.Factory, cmd *cobra.Command) error {
+	completeCmd := &cobra.Command{}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != dryRunFlag {
+			completeCmd.Flags().AddFlag(flag)
+		}
+	})
+	completeCmd.Flags().Bool(dryRunFlag, false, "")
+	return o.Complete(f, completeCmd)
+}
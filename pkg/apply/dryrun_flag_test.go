@@ -0,0 +1,79 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/common"
+)
+
+func TestDryRunFlag(t *testing.T) {
+	testCases := map[string]struct {
+		args        []string
+		expected    common.DryRunStrategy
+		expectedErr bool
+	}{
+		"not set": {
+			expected: common.DryRunNone,
+		},
+		"no value means client": {
+			args:     []string{"--dry-run"},
+			expected: common.DryRunClient,
+		},
+		"server": {
+			args:     []string{"--dry-run=server"},
+			expected: common.DryRunServer,
+		},
+		"none": {
+			args:     []string{"--dry-run=none"},
+			expected: common.DryRunNone,
+		},
+		"invalid": {
+			args:        []string{"--dry-run=all"},
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var strategy common.DryRunStrategy
+			cmd := &cobra.Command{}
+			addDryRunFlag(cmd, &strategy)
+			err := cmd.Flags().Parse(tc.args)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, strategy)
+		})
+	}
+}
+
+func TestCompleteApplyOptionsWithDryRunStrategy(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	var strategy common.DryRunStrategy
+	o := apply.NewApplyOptions(genericclioptions.NewTestIOStreamsDiscard())
+	filenames := []string{"deployment.yaml"}
+	o.DeleteFlags.FileNameFlags = &genericclioptions.FileNameFlags{Filenames: &filenames}
+	cmd := &cobra.Command{}
+	o.RecordFlags.AddFlags(cmd)
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	addDryRunFlag(cmd, &strategy)
+	assert.NoError(t, cmd.Flags().Parse([]string{"--dry-run=server"}))
+
+	assert.NoError(t, completeApplyOptions(o, tf, cmd))
+	assert.Equal(t, common.DryRunServer, strategy)
+	assert.False(t, o.DryRun)
+}
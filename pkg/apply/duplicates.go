@@ -0,0 +1,127 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// DuplicatePolicy determines what happens when the package contains
+// several manifests for the same resource.
+type DuplicatePolicy int
+
+const (
+	// DuplicatePolicyFail fails the run before anything is applied.
+	// This is the default, since one of the manifests is usually a
+	// mistake.
+	DuplicatePolicyFail DuplicatePolicy = iota
+	// DuplicatePolicyLastWins applies the manifest that is read last
+	// and prints a warning for the other ones.
+	DuplicatePolicyLastWins
+)
+
+var duplicatePolicyNames = map[DuplicatePolicy]string{
+	DuplicatePolicyFail:     "fail",
+	DuplicatePolicyLastWins: "last-wins",
+}
+
+func (p DuplicatePolicy) String() string {
+	if name, found := duplicatePolicyNames[p]; found {
+		return name
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", int(p))
+}
+
+// ParseDuplicatePolicy returns the DuplicatePolicy with the
+// given name, which must be one of fail or last-wins.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	for p, n := range duplicatePolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return DuplicatePolicyFail, fmt.Errorf(
+		"invalid duplicate policy %q, must be one of fail or last-wins", name)
+}
+
+// Duplicate is a resource that is defined by several manifests
+// in the package.
+type Duplicate struct {
+	Object object.ObjMetadata
+	// Sources are the files the manifests were read from,
+	// in the order they were read.
+	Sources []string
+}
+
+func (d Duplicate) String() string {
+	return fmt.Sprintf("%s is defined %d times, in %s", d.Object.String(), len(d.Sources),
+		strings.Join(d.Sources, ", "))
+}
+
+// DuplicateError is returned if the package contains several
+// manifests for the same resource and the policy is fail.
+type DuplicateError struct {
+	Duplicates []Duplicate
+}
+
+func (e *DuplicateError) Error() string {
+	var lines []string
+	for _, d := range e.Duplicates {
+		lines = append(lines, d.String())
+	}
+	return "duplicate resources in the package: " + strings.Join(lines, "; ")
+}
+
+// findDuplicates returns the resources that are defined by more than
+// one of the infos, in the order they are first defined.
+func findDuplicates(infos []*resource.Info) []Duplicate {
+	indexes := make(map[object.ObjMetadata]int)
+	var all []Duplicate
+	for _, info := range infos {
+		id := infoToObjMetadata(info)
+		i, found := indexes[id]
+		if !found {
+			i = len(all)
+			indexes[id] = i
+			all = append(all, Duplicate{Object: id})
+		}
+		all[i].Sources = append(all[i].Sources, info.Source)
+	}
+	var duplicates []Duplicate
+	for _, d := range all {
+		if len(d.Sources) > 1 {
+			duplicates = append(duplicates, d)
+		}
+	}
+	return duplicates
+}
+
+// removeDuplicates applies the policy to the resources that are
+// defined more than once. It returns a DuplicateError for the fail
+// policy. For the last-wins policy it returns the infos without the
+// ones that are overridden by a later manifest, and the duplicates.
+func removeDuplicates(infos []*resource.Info, policy DuplicatePolicy) ([]*resource.Info, []Duplicate, error) {
+	duplicates := findDuplicates(infos)
+	if len(duplicates) == 0 {
+		return infos, nil, nil
+	}
+	if policy != DuplicatePolicyLastWins {
+		return nil, nil, &DuplicateError{Duplicates: duplicates}
+	}
+	last := make(map[object.ObjMetadata]int)
+	for i, info := range infos {
+		last[infoToObjMetadata(info)] = i
+	}
+	var kept []*resource.Info
+	for i, info := range infos {
+		if last[infoToObjMetadata(info)] == i {
+			kept = append(kept, info)
+		}
+	}
+	return kept, duplicates, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func duplicateInfo(kind, namespace, name, source string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return &resource.Info{
+		Name:      name,
+		Namespace: namespace,
+		Source:    source,
+		Object:    obj,
+	}
+}
+
+func TestRemoveDuplicates(t *testing.T) {
+	testCases := map[string]struct {
+		infos              []*resource.Info
+		policy             DuplicatePolicy
+		expectedSources    []string
+		expectedDuplicates []string
+		expectedErr        string
+	}{
+		"no duplicates": {
+			infos: []*resource.Info{
+				duplicateInfo("ConfigMap", "default", "a", "a.yaml"),
+				duplicateInfo("ConfigMap", "other", "a", "b.yaml"),
+				duplicateInfo("Secret", "default", "a", "c.yaml"),
+			},
+			policy:          DuplicatePolicyFail,
+			expectedSources: []string{"a.yaml", "b.yaml", "c.yaml"},
+		},
+		"duplicates fail": {
+			infos: []*resource.Info{
+				duplicateInfo("ConfigMap", "default", "a", "a.yaml"),
+				duplicateInfo("ConfigMap", "default", "b", "b.yaml"),
+				duplicateInfo("ConfigMap", "default", "a", "c.yaml"),
+			},
+			policy: DuplicatePolicyFail,
+			expectedErr: "duplicate resources in the package: " +
+				"default_a__ConfigMap is defined 2 times, in a.yaml, c.yaml",
+		},
+		"last wins": {
+			infos: []*resource.Info{
+				duplicateInfo("ConfigMap", "default", "a", "a.yaml"),
+				duplicateInfo("ConfigMap", "default", "b", "b.yaml"),
+				duplicateInfo("ConfigMap", "default", "a", "c.yaml"),
+				duplicateInfo("ConfigMap", "default", "a", "d.yaml"),
+			},
+			policy:          DuplicatePolicyLastWins,
+			expectedSources: []string{"b.yaml", "d.yaml"},
+			expectedDuplicates: []string{
+				"default_a__ConfigMap is defined 3 times, in a.yaml, c.yaml, d.yaml",
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			infos, duplicates, err := removeDuplicates(tc.infos, tc.policy)
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.expectedErr, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			var sources []string
+			for _, info := range infos {
+				sources = append(sources, info.Source)
+			}
+			assert.Equal(t, tc.expectedSources, sources)
+			var actualDuplicates []string
+			for _, d := range duplicates {
+				actualDuplicates = append(actualDuplicates, d.String())
+			}
+			assert.Equal(t, tc.expectedDuplicates, actualDuplicates)
+		})
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, p := range []DuplicatePolicy{DuplicatePolicyFail, DuplicatePolicyLastWins} {
+		parsed, err := ParseDuplicatePolicy(p.String())
+		assert.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+	_, err := ParseDuplicatePolicy("first-wins")
+	assert.Error(t, err)
+}
@@ -0,0 +1,350 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	goerrors "github.com/go-errors/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// ErrorReason classifies the errors reported by the Applier and
+// the Destroyer, so callers can decide whether to retry or abort
+// without having to parse the error messages.
+type ErrorReason string
+
+const (
+	// ReasonUnknown is used for errors that don't fall into
+	// one of the other categories.
+	ReasonUnknown ErrorReason = "Unknown"
+	// ReasonValidation means the manifests could not be read or were
+	// rejected as invalid. Retrying will not help.
+	ReasonValidation ErrorReason = "Validation"
+	// ReasonForbidden means the user is not allowed to perform
+	// the operation. Retrying will not help.
+	ReasonForbidden ErrorReason = "Forbidden"
+	// ReasonConflict means the resource was changed by someone else
+	// or already exists. Retrying might succeed.
+	ReasonConflict ErrorReason = "Conflict"
+	// ReasonTransient means the apiserver, a webhook or the network
+	// failed in a way that is likely to be temporary.
+	ReasonTransient ErrorReason = "Transient"
+	// ReasonTimeout means the resources did not reach the desired
+	// status before the timeout.
+	ReasonTimeout ErrorReason = "Timeout"
+	// ReasonInventoryConflict means a resource belongs to a different
+	// inventory, or to no inventory, and the inventory policy doesn't
+	// allow taking it over. Retrying will not help.
+	ReasonInventoryConflict ErrorReason = "InventoryConflict"
+	// ReasonCancelled means the run was cancelled, for example by an
+	// interrupt, before it was complete.
+	ReasonCancelled ErrorReason = "Cancelled"
+)
+
+var (
+	// ErrTimeout is matched by errors with the ReasonTimeout reason.
+	ErrTimeout = errors.New("timed out")
+	// ErrCancelled is matched by errors with the ReasonCancelled reason.
+	ErrCancelled = errors.New("cancelled")
+	// ErrInventoryNotFound is matched if the package doesn't
+	// contain a grouping object.
+	ErrInventoryNotFound = prune.ErrInventoryNotFound
+	// ErrMultipleInventories is matched if the package contains
+	// more than one grouping object.
+	ErrMultipleInventories = prune.ErrMultipleInventories
+	// ErrAdoptionDenied is matched if the inventory policy didn't
+	// allow taking over a resource.
+	ErrAdoptionDenied = prune.ErrAdoptionDenied
+)
+
+// Error is the error type used for the errors in error events. It
+// wraps the underlying error together with its classification and
+// the exit code the process should use.
+type Error struct {
+	Reason ErrorReason
+	Err    error
+	code   int
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match ErrTimeout for timeouts, and ErrCancelled
+// for cancelled runs. Unlike errors.Is itself, it also looks through
+// aggregated errors and errors wrapped with go-errors, which doesn't
+// support unwrapping.
+func (e *Error) Is(target error) bool {
+	if target == ErrTimeout && e.Reason == ReasonTimeout {
+		return true
+	}
+	if target == ErrCancelled && e.Reason == ReasonCancelled {
+		return true
+	}
+	return walkErrors(e.Err, func(err error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// As looks through aggregated errors and errors wrapped with
+// go-errors for an error that matches target.
+func (e *Error) As(target interface{}) bool {
+	return walkErrors(e.Err, func(err error) bool {
+		return errors.As(err, target)
+	})
+}
+
+// ExitCode returns the exit code.
+func (e *Error) ExitCode() int {
+	return e.code
+}
+
+// Retriable returns true if the operation might succeed
+// if it is tried again.
+func (e *Error) Retriable() bool {
+	switch e.Reason {
+	case ReasonConflict, ReasonTransient, ReasonTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// withExitCode returns an error that wraps the given error
+// and will make the process exit with the given code. The error
+// is classified based on the underlying error, or on the exit code
+// if the underlying error doesn't tell.
+func withExitCode(err error, code int) error {
+	reason := ReasonForError(err)
+	if reason == ReasonUnknown {
+		switch code {
+		case ExitValidationError:
+			reason = ReasonValidation
+		case ExitReconcileTimeout:
+			reason = ReasonTimeout
+		}
+	}
+	return &Error{
+		Reason: reason,
+		Err:    err,
+		code:   code,
+	}
+}
+
+// ReasonForError returns the classification of the error. It looks
+// through the wrapped errors, and for aggregated errors it returns the
+// reason of the first error that can't be retried, or otherwise the
+// reason of the first error.
+func ReasonForError(err error) ErrorReason {
+	return reasonForError(err, nil)
+}
+
+// reasonForError is ReasonForError, with the errors that match
+// any of the matchers classified as transient.
+func reasonForError(err error, matchers []ErrorMatcher) ErrorReason {
+	if err == nil {
+		return ReasonUnknown
+	}
+	if e, ok := err.(*Error); ok {
+		if len(matchers) == 0 {
+			return e.Reason
+		}
+		// The reason was set without the matchers, so the wrapped
+		// error is classified again.
+		if reason := reasonForError(e.Err, matchers); reason != ReasonUnknown {
+			return reason
+		}
+		return e.Reason
+	}
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		reason := ReasonUnknown
+		for _, e := range agg.Errors() {
+			r := reasonForError(e, matchers)
+			if r == ReasonValidation || r == ReasonForbidden || r == ReasonInventoryConflict {
+				return r
+			}
+			if reason == ReasonUnknown {
+				reason = r
+			}
+		}
+		return reason
+	}
+	wrapped := unwrap(err)
+	if wrapped == nil && matchesAny(err, matchers) {
+		return ReasonTransient
+	}
+	if reason := classifyError(err); reason != ReasonUnknown {
+		return reason
+	}
+	if wrapped != nil {
+		return reasonForError(wrapped, matchers)
+	}
+	return ReasonUnknown
+}
+
+// ErrorMatcher returns true if an error is one that should be retried,
+// in addition to the errors that are classified as transient, like the
+// errors of a webhook that is known to fail now and then. Matchers are
+// only called with errors that don't wrap other errors, like the ones
+// returned by the API server, so the errors aggregated by a run are
+// matched one by one.
+type ErrorMatcher func(err error) bool
+
+// MessageMatcher returns an ErrorMatcher that matches
+// the errors whose message contains the substring.
+func MessageMatcher(substr string) ErrorMatcher {
+	return func(err error) bool {
+		return strings.Contains(err.Error(), substr)
+	}
+}
+
+// matchesAny returns true if any of the matchers matches the error.
+func matchesAny(err error, matchers []ErrorMatcher) bool {
+	for _, m := range matchers {
+		if m(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetriableErrors returns the error classified as transient if the
+// errors it wraps are either transient or matched by the matchers, so
+// Retriable and IsRetriable return true for it.
+func withRetriableErrors(err error, matchers []ErrorMatcher) error {
+	e, ok := err.(*Error)
+	if !ok || len(matchers) == 0 || e.Retriable() {
+		return err
+	}
+	if reasonForError(e, matchers) != ReasonTransient {
+		return err
+	}
+	return &Error{Reason: ReasonTransient, Err: e.Err, code: e.code}
+}
+
+// classifyError returns the classification of a single error
+// without looking at any wrapped errors.
+func classifyError(err error) ErrorReason {
+	if _, ok := err.(*prune.InventoryOverlapError); ok {
+		return ReasonInventoryConflict
+	}
+	if _, ok := err.(*PermissionError); ok {
+		return ReasonForbidden
+	}
+	if _, ok := err.(*NamespaceTerminatingError); ok {
+		// The namespaces are usually gone after a while.
+		return ReasonTransient
+	}
+	switch {
+	case err == context.DeadlineExceeded, err == ErrTimeout:
+		return ReasonTimeout
+	case err == context.Canceled, err == ErrCancelled:
+		return ReasonCancelled
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), apierrors.IsNotAcceptable(err),
+		apierrors.IsUnsupportedMediaType(err), apierrors.IsMethodNotSupported(err):
+		return ReasonValidation
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return ReasonForbidden
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return ReasonConflict
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		return ReasonTransient
+	case utilnet.IsConnectionRefused(err), utilnet.IsConnectionReset(err), utilnet.IsProbableEOF(err):
+		return ReasonTransient
+	case strings.Contains(err.Error(), "failed calling webhook"):
+		// Webhook failures are reported with different status codes
+		// depending on the failure, but all mean the webhook
+		// itself could not be reached.
+		return ReasonTransient
+	}
+	return ReasonUnknown
+}
+
+// unwrap returns the error wrapped by err, or nil if there is none.
+func unwrap(err error) error {
+	switch e := err.(type) {
+	case *goerrors.Error:
+		return e.Err
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	}
+	return nil
+}
+
+// walkErrors calls fn for err and all the errors it wraps or
+// aggregates, until fn returns true.
+func walkErrors(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+		if agg, ok := err.(utilerrors.Aggregate); ok {
+			for _, e := range agg.Errors() {
+				if walkErrors(e, fn) {
+					return true
+				}
+			}
+			return false
+		}
+		err = unwrap(err)
+	}
+	return false
+}
+
+// IsValidationError returns true if the manifests were invalid.
+func IsValidationError(err error) bool {
+	return ReasonForError(err) == ReasonValidation
+}
+
+// IsForbiddenError returns true if the user was not allowed
+// to perform the operation.
+func IsForbiddenError(err error) bool {
+	return ReasonForError(err) == ReasonForbidden
+}
+
+// IsConflictError returns true if the resource was modified by
+// someone else or already exists.
+func IsConflictError(err error) bool {
+	return ReasonForError(err) == ReasonConflict
+}
+
+// IsTransientError returns true if the error is likely to be temporary.
+func IsTransientError(err error) bool {
+	return ReasonForError(err) == ReasonTransient
+}
+
+// IsTimeoutError returns true if the resources did not reach the
+// desired status before the timeout.
+func IsTimeoutError(err error) bool {
+	return ReasonForError(err) == ReasonTimeout
+}
+
+// IsInventoryConflictError returns true if the inventory policy
+// didn't allow taking over a resource.
+func IsInventoryConflictError(err error) bool {
+	return ReasonForError(err) == ReasonInventoryConflict
+}
+
+// IsCancelledError returns true if the run was cancelled
+// before it was complete.
+func IsCancelledError(err error) bool {
+	return ReasonForError(err) == ReasonCancelled
+}
+
+// IsRetriable returns true if the operation might succeed
+// if it is tried again.
+func IsRetriable(err error) bool {
+	return (&Error{Reason: ReasonForError(err)}).Retriable()
+}
@@ -0,0 +1,221 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+func TestReasonForError(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	testCases := map[string]struct {
+		err       error
+		reason    ErrorReason
+		retriable bool
+	}{
+		"unknown": {
+			err:    fmt.Errorf("something failed"),
+			reason: ReasonUnknown,
+		},
+		"invalid": {
+			err:    apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "foo", nil),
+			reason: ReasonValidation,
+		},
+		"forbidden": {
+			err:    apierrors.NewForbidden(gr, "foo", fmt.Errorf("not allowed")),
+			reason: ReasonForbidden,
+		},
+		"conflict wrapped by go-errors": {
+			err:       errors.WrapPrefix(apierrors.NewConflict(gr, "foo", fmt.Errorf("modified")), "error applying resources", 1),
+			reason:    ReasonConflict,
+			retriable: true,
+		},
+		"webhook": {
+			err: fmt.Errorf(`Internal error occurred: failed calling webhook "validate.example.com": ` +
+				`connection refused`),
+			reason:    ReasonTransient,
+			retriable: true,
+		},
+		"server timeout": {
+			err:       apierrors.NewServerTimeout(gr, "patch", 1),
+			reason:    ReasonTransient,
+			retriable: true,
+		},
+		"deadline exceeded": {
+			err:       context.DeadlineExceeded,
+			reason:    ReasonTimeout,
+			retriable: true,
+		},
+		"cancelled run": {
+			err:    withExitCode(fmt.Errorf("run %w after applying 1 of 2 resource(s)", ErrCancelled), ExitCancelled),
+			reason: ReasonCancelled,
+		},
+		"aggregate prefers errors that can't be retried": {
+			err: utilerrors.NewAggregate([]error{
+				apierrors.NewConflict(gr, "foo", fmt.Errorf("modified")),
+				apierrors.NewForbidden(gr, "bar", fmt.Errorf("not allowed")),
+			}),
+			reason: ReasonForbidden,
+		},
+		"validation exit code": {
+			err:    withExitCode(fmt.Errorf("error reading resources"), ExitValidationError),
+			reason: ReasonValidation,
+		},
+		"reconcile timeout exit code": {
+			err:       withExitCode(fmt.Errorf("timed out"), ExitReconcileTimeout),
+			reason:    ReasonTimeout,
+			retriable: true,
+		},
+		"inventory overlap": {
+			err: utilerrors.NewAggregate([]error{
+				&prune.InventoryOverlapError{Owner: "other"},
+			}),
+			reason: ReasonInventoryConflict,
+		},
+		"apply error keeps the underlying reason": {
+			err:    withExitCode(apierrors.NewForbidden(gr, "foo", fmt.Errorf("not allowed")), ExitApplyError),
+			reason: ReasonForbidden,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.reason, ReasonForError(tc.err))
+			assert.Equal(t, tc.retriable, IsRetriable(tc.err))
+		})
+	}
+}
+
+func TestErrorExitCode(t *testing.T) {
+	err := withExitCode(fmt.Errorf("prune failed"), ExitPruneError)
+
+	assert.Equal(t, ExitPruneError, ExitCode(err))
+	assert.Equal(t, "prune failed", err.Error())
+	if e, ok := err.(*Error); assert.True(t, ok) {
+		assert.Equal(t, "prune failed", e.Unwrap().Error())
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	overlap := &prune.InventoryOverlapError{Owner: "other"}
+	testCases := map[string]struct {
+		err    error
+		target error
+	}{
+		"timeout": {
+			err:    withExitCode(fmt.Errorf("%w waiting for resources to be deleted", ErrTimeout), ExitReconcileTimeout),
+			target: ErrTimeout,
+		},
+		"deadline exceeded": {
+			err:    withExitCode(errors.WrapPrefix(context.DeadlineExceeded, "error applying resources", 1), ExitApplyError),
+			target: ErrTimeout,
+		},
+		"context cancelled": {
+			err:    withExitCode(errors.WrapPrefix(context.Canceled, "error applying resources", 1), ExitApplyError),
+			target: ErrCancelled,
+		},
+		"inventory not found wrapped by go-errors": {
+			err:    withExitCode(errors.WrapPrefix(prune.ErrInventoryNotFound, "error pruning resources", 1), ExitPruneError),
+			target: ErrInventoryNotFound,
+		},
+		"multiple inventories": {
+			err:    withExitCode(&prune.MultipleInventoriesError{Names: []string{"a", "b"}}, ExitValidationError),
+			target: ErrMultipleInventories,
+		},
+		"adoption denied in aggregate": {
+			err: withExitCode(utilerrors.NewAggregate([]error{
+				fmt.Errorf("failed"),
+				overlap,
+			}), ExitApplyError),
+			target: ErrAdoptionDenied,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.True(t, stderrors.Is(tc.err, tc.target))
+		})
+	}
+}
+
+func TestErrorAs(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	err := withExitCode(errors.WrapPrefix(&prune.PruneError{
+		Err: apierrors.NewForbidden(gr, "foo", fmt.Errorf("not allowed")),
+	}, "error pruning resources", 1), ExitPruneError)
+
+	var pruneErr *prune.PruneError
+	if assert.True(t, stderrors.As(err, &pruneErr)) {
+		assert.True(t, apierrors.IsForbidden(pruneErr.Err))
+	}
+	assert.Equal(t, ReasonForbidden, ReasonForError(err))
+	var overlapErr *prune.InventoryOverlapError
+	assert.False(t, stderrors.As(err, &overlapErr))
+}
+
+func TestWithRetriableErrors(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	webhook := apierrors.NewBadRequest(`admission webhook "flaky.example.com" denied the request: ` +
+		`context deadline exceeded`)
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "foo", nil)
+	matcher := MessageMatcher(`webhook "flaky.example.com"`)
+	testCases := map[string]struct {
+		err       error
+		matchers  []ErrorMatcher
+		retriable bool
+	}{
+		"no matchers": {
+			err: withExitCode(errors.WrapPrefix(webhook, "error applying resources", 1), ExitApplyError),
+		},
+		"matched": {
+			err:       withExitCode(errors.WrapPrefix(webhook, "error applying resources", 1), ExitApplyError),
+			matchers:  []ErrorMatcher{matcher},
+			retriable: true,
+		},
+		"not matched": {
+			err:      withExitCode(errors.WrapPrefix(invalid, "error applying resources", 1), ExitApplyError),
+			matchers: []ErrorMatcher{matcher},
+		},
+		"all aggregated errors matched or transient": {
+			err: withExitCode(errors.WrapPrefix(utilerrors.NewAggregate([]error{
+				webhook, apierrors.NewServerTimeout(gr, "patch", 1),
+			}), "error applying resources", 1), ExitApplyError),
+			matchers:  []ErrorMatcher{matcher},
+			retriable: true,
+		},
+		"an aggregated error not matched": {
+			err: withExitCode(errors.WrapPrefix(utilerrors.NewAggregate([]error{
+				webhook, invalid,
+			}), "error applying resources", 1), ExitApplyError),
+			matchers: []ErrorMatcher{matcher},
+		},
+		"custom matcher": {
+			err: withExitCode(fmt.Errorf("error pruning resources: %w", apierrors.NewForbidden(gr, "foo",
+				fmt.Errorf("not yet allowed"))), ExitPruneError),
+			matchers: []ErrorMatcher{func(err error) bool {
+				return apierrors.IsForbidden(err)
+			}},
+			retriable: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := withRetriableErrors(tc.err, tc.matchers)
+			assert.Equal(t, tc.retriable, IsRetriable(err))
+			assert.Equal(t, ExitCode(tc.err), ExitCode(err))
+			assert.Equal(t, tc.err.Error(), err.Error())
+		})
+	}
+}
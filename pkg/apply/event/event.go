@@ -4,8 +4,12 @@
 package event
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/runtime"
-	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 // Type determines the type of events that are available.
@@ -18,6 +22,8 @@ const (
 	StatusType
 	PruneType
 	DeleteType
+	ProgressType
+	DiffType
 )
 
 // Event is the type of the objects that will be returned through
@@ -28,6 +34,15 @@ type Event struct {
 	// Type is the type of event.
 	Type Type
 
+	// Timestamp is the time when the event was created.
+	Timestamp time.Time
+
+	// Started is set for events that report the end of some work,
+	// like applying or pruning a resource or completing a phase. It
+	// is the time when that work started. For status events, it is
+	// the time when the wait started.
+	Started time.Time
+
 	// ErrorEvent contains information about any errors encountered.
 	ErrorEvent ErrorEvent
 
@@ -37,7 +52,7 @@ type Event struct {
 
 	// StatusEvents contains information about the status of one of
 	// the applied resources.
-	StatusEvent wait.Event
+	StatusEvent pollevent.Event
 
 	// PruneEvent contains information about objects that have been
 	// pruned.
@@ -46,10 +61,32 @@ type Event struct {
 	// DeleteEvent contains information about object that have been
 	// deleted.
 	DeleteEvent DeleteEvent
+
+	// ProgressEvent contains information about how far along the
+	// current phase is.
+	ProgressEvent ProgressEvent
+
+	// DiffEvent contains the changes that will be made to a
+	// resource when it is applied.
+	DiffEvent DiffEvent
+}
+
+// Duration returns the time between when the work reported by the
+// event started and the event was created. It is zero if the event
+// doesn't have a start time.
+func (e Event) Duration() time.Duration {
+	if e.Started.IsZero() {
+		return 0
+	}
+	return e.Timestamp.Sub(e.Started)
 }
 
+// ErrorEvent contains an error encountered during apply, status
+// or prune. If the error is specific to a single resource, it is
+// identified by the Identifier.
 type ErrorEvent struct {
-	Err error
+	Err        error
+	Identifier *object.ObjMetadata
 }
 
 //go:generate stringer -type=ApplyEventType
@@ -63,17 +100,23 @@ const (
 //go:generate stringer -type=ApplyEventOperation
 type ApplyEventOperation int
 
+// Filtered is used for resources that were not applied, because
+// they didn't match the label selector.
 const (
 	ServersideApplied ApplyEventOperation = iota
 	Created
 	Unchanged
 	Configured
+	Filtered
 )
 
+// ApplyEvent contains information about a resource that has
+// been applied, or signals that all resources have been applied.
 type ApplyEvent struct {
-	Type      ApplyEventType
-	Operation ApplyEventOperation
-	Object    runtime.Object
+	Type       ApplyEventType
+	Operation  ApplyEventOperation
+	Object     runtime.Object
+	Identifier object.ObjMetadata
 }
 
 //go:generate stringer -type=PruneEventType
@@ -84,9 +127,23 @@ const (
 	PruneEventCompleted
 )
 
+//go:generate stringer -type=PruneEventOperation
+type PruneEventOperation int
+
+// PruneSkipped is used for resources that were left in the
+// cluster instead of being pruned.
+const (
+	Pruned PruneEventOperation = iota
+	PruneSkipped
+)
+
+// PruneEvent contains information about a resource that has
+// been pruned, or signals that the prune has completed.
 type PruneEvent struct {
-	Type   PruneEventType
-	Object runtime.Object
+	Type       PruneEventType
+	Operation  PruneEventOperation
+	Object     runtime.Object
+	Identifier object.ObjMetadata
 }
 
 //go:generate stringer -type=DeleteEventType
@@ -97,7 +154,110 @@ const (
 	DeleteEventCompleted
 )
 
+// DeleteEvent contains information about a resource that has
+// been deleted, or signals that the destroy has completed.
 type DeleteEvent struct {
-	Type   DeleteEventType
-	Object runtime.Object
+	Type       DeleteEventType
+	Object     runtime.Object
+	Identifier object.ObjMetadata
+}
+
+// DiffEvent contains the unified diff between the live version
+// of a resource and the local configuration.
+type DiffEvent struct {
+	Identifier object.ObjMetadata
+	Diff       string
+	// Prune is true if the resource will be pruned. The Diff
+	// is empty in that case.
+	Prune bool
+}
+
+//go:generate stringer -type=ProgressPhase
+type ProgressPhase int
+
+const (
+	ApplyPhase ProgressPhase = iota
+	WaitPhase
+	PrunePhase
+	DeletePhase
+	ConditionPhase
+)
+
+// ProgressEvent reports how many of the resources in a phase have
+// been handled so far. They are emitted as the phase makes progress,
+// so long running operations can show that they are still moving.
+type ProgressEvent struct {
+	Phase ProgressPhase
+	// Completed is the number of resources that have been applied,
+	// pruned or deleted. For the WaitPhase, it is the number of
+	// resources that have reached the desired status, and for the
+	// ConditionPhase the number of wait conditions that are met.
+	Completed int
+	// Total is the number of resources in the phase.
+	Total int
+	// ETA is the estimated time remaining for the phase, based on
+	// the average time spent on each completed resource. It is zero
+	// if no estimate is available.
+	ETA time.Duration
+}
+
+// NewProgressEvent returns a progress Event for a phase that
+// started at the given time. The ETA is only estimated for
+// phases where the work is done by us, since how long it takes
+// for resources to reconcile can't be predicted from the
+// progress so far.
+func NewProgressEvent(phase ProgressPhase, completed, total int, started time.Time) Event {
+	now := time.Now()
+	var eta time.Duration
+	if phase != WaitPhase && phase != ConditionPhase && completed > 0 && completed < total {
+		perResource := now.Sub(started) / time.Duration(completed)
+		eta = perResource * time.Duration(total-completed)
+	}
+	return Event{
+		Type:      ProgressType,
+		Timestamp: now,
+		ProgressEvent: ProgressEvent{
+			Phase:     phase,
+			Completed: completed,
+			Total:     total,
+			ETA:       eta,
+		},
+	}
+}
+
+// Severity describes how important an event is. It can be used to
+// only show the events that need attention.
+type Severity int
+
+const (
+	// SeverityInfo is used for events that report normal progress.
+	SeverityInfo Severity = iota
+	// SeverityWarning is used for events that might need attention,
+	// like resources that didn't reach the desired status in time.
+	SeverityWarning
+	// SeverityError is used for errors and failed resources.
+	SeverityError
+)
+
+// Severity returns the severity of the event.
+func (e Event) Severity() Severity {
+	switch e.Type {
+	case ErrorType:
+		return SeverityError
+	case StatusType:
+		switch e.StatusEvent.EventType {
+		case pollevent.ErrorEvent:
+			return SeverityError
+		case pollevent.AbortedEvent:
+			return SeverityWarning
+		case pollevent.ResourceUpdateEvent:
+			switch e.StatusEvent.Resource.Status {
+			case status.FailedStatus:
+				return SeverityError
+			case status.UnknownStatus:
+				return SeverityWarning
+			}
+		}
+	}
+	return SeverityInfo
 }
@@ -0,0 +1,80 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProgressEvent(t *testing.T) {
+	testCases := map[string]struct {
+		phase       ProgressPhase
+		completed   int
+		total       int
+		elapsed     time.Duration
+		expectedETA bool
+	}{
+		"apply in progress": {
+			phase:       ApplyPhase,
+			completed:   2,
+			total:       4,
+			elapsed:     10 * time.Second,
+			expectedETA: true,
+		},
+		"nothing completed": {
+			phase:       PrunePhase,
+			completed:   0,
+			total:       4,
+			elapsed:     10 * time.Second,
+			expectedETA: false,
+		},
+		"all completed": {
+			phase:       DeletePhase,
+			completed:   4,
+			total:       4,
+			elapsed:     10 * time.Second,
+			expectedETA: false,
+		},
+		"waiting for reconcile": {
+			phase:       WaitPhase,
+			completed:   2,
+			total:       4,
+			elapsed:     10 * time.Second,
+			expectedETA: false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			e := NewProgressEvent(tc.phase, tc.completed, tc.total, time.Now().Add(-tc.elapsed))
+
+			assert.Equal(t, ProgressType, e.Type)
+			assert.Equal(t, tc.phase, e.ProgressEvent.Phase)
+			assert.Equal(t, tc.completed, e.ProgressEvent.Completed)
+			assert.Equal(t, tc.total, e.ProgressEvent.Total)
+			if tc.expectedETA {
+				// Two resources took about 10 seconds, so the
+				// remaining two should take about the same.
+				assert.InDelta(t, tc.elapsed.Seconds(), e.ProgressEvent.ETA.Seconds(), 1)
+			} else {
+				assert.Equal(t, time.Duration(0), e.ProgressEvent.ETA)
+			}
+		})
+	}
+}
+
+func TestEventDuration(t *testing.T) {
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	e := Event{
+		Timestamp: started.Add(3 * time.Second),
+	}
+	assert.Equal(t, time.Duration(0), e.Duration())
+
+	e.Started = started
+	assert.Equal(t, 3*time.Second, e.Duration())
+}
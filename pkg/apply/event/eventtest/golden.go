@@ -0,0 +1,57 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package eventtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden write
+// the transcripts to the golden files instead of comparing them, for
+// example with UPDATE_GOLDEN=true go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// AssertGolden compares the transcript of the events with the golden
+// file, and reports the differences as a test error.
+func AssertGolden(t testing.TB, path string, events []event.Event) {
+	t.Helper()
+	AssertGoldenTranscript(t, path, Transcript(events))
+}
+
+// AssertGoldenTranscript compares a transcript with the golden file,
+// and reports the differences as a test error.
+func AssertGoldenTranscript(t testing.TB, path, transcript string) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error creating the directory of the golden file: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(transcript), 0644); err != nil {
+			t.Fatalf("error writing golden file: %v", err)
+		}
+		return
+	}
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading golden file, run the test with %s=true to create it: %v", UpdateEnv, err)
+	}
+	if string(golden) == transcript {
+		return
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(golden)),
+		B:        difflib.SplitLines(transcript),
+		FromFile: path,
+		ToFile:   "transcript",
+		Context:  3,
+	})
+	t.Errorf("the transcript doesn't match the golden file, run the test with %s=true to update it:\n%s",
+		UpdateEnv, diff)
+}
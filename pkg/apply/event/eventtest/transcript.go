@@ -0,0 +1,182 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package eventtest turns the events from the applier and destroyer
+// into transcripts that can be compared with golden files, so changes
+// in the behavior show up as diffs in tests.
+//
+// A transcript has one line per event. Timestamps, durations and
+// progress events are left out, and the events whose order depends on
+// timing are sorted, so the same run always gives the same transcript.
+package eventtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// Collect returns all the events from the channel,
+// once it has been closed.
+func Collect(ch <-chan event.Event) []event.Event {
+	var events []event.Event
+	for e := range ch {
+		events = append(events, e)
+	}
+	return events
+}
+
+// Transcript returns the normalized transcript of the events.
+//
+// The status updates for the resources depend on when the status is
+// polled, so each run of status events is reduced to the last status
+// of every resource, sorted by resource, followed by the event that
+// ended the wait. Resources are pruned and deleted in no particular
+// order, so each run of prune and delete events is sorted as well.
+// The resources are applied in a fixed order, which is kept.
+func Transcript(events []event.Event) string {
+	var lines []string
+	for i := 0; i < len(events); {
+		e := events[i]
+		switch {
+		case e.Type == event.ProgressType:
+			i++
+		case e.Type == event.StatusType:
+			end := i
+			for end < len(events) && events[end].Type == event.StatusType {
+				end++
+			}
+			lines = append(lines, statusLines(events[i:end])...)
+			i = end
+		case isPruneUpdate(e) || isDeleteUpdate(e):
+			end := i
+			for end < len(events) && (isPruneUpdate(events[end]) || isDeleteUpdate(events[end])) {
+				end++
+			}
+			var run []string
+			for _, e := range events[i:end] {
+				run = append(run, line(e))
+			}
+			sort.Strings(run)
+			lines = append(lines, run...)
+			i = end
+		default:
+			lines = append(lines, line(e))
+			i++
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func isPruneUpdate(e event.Event) bool {
+	return e.Type == event.PruneType && e.PruneEvent.Type == event.PruneEventResourceUpdate
+}
+
+func isDeleteUpdate(e event.Event) bool {
+	return e.Type == event.DeleteType && e.DeleteEvent.Type == event.DeleteEventResourceUpdate
+}
+
+// line returns the transcript line for an event, other than the
+// progress and status events.
+func line(e event.Event) string {
+	switch e.Type {
+	case event.ErrorType:
+		if e.ErrorEvent.Identifier != nil {
+			return fmt.Sprintf("error %s: %v", identifier(*e.ErrorEvent.Identifier), e.ErrorEvent.Err)
+		}
+		return fmt.Sprintf("error: %v", e.ErrorEvent.Err)
+	case event.ApplyType:
+		if e.ApplyEvent.Type == event.ApplyEventCompleted {
+			return "apply completed"
+		}
+		return fmt.Sprintf("apply %s %s", strings.ToLower(e.ApplyEvent.Operation.String()),
+			identifier(e.ApplyEvent.Identifier))
+	case event.PruneType:
+		if e.PruneEvent.Type == event.PruneEventCompleted {
+			return "prune completed"
+		}
+		return fmt.Sprintf("prune %s %s", strings.ToLower(e.PruneEvent.Operation.String()),
+			identifier(e.PruneEvent.Identifier))
+	case event.DeleteType:
+		if e.DeleteEvent.Type == event.DeleteEventCompleted {
+			return "delete completed"
+		}
+		return fmt.Sprintf("delete %s", identifier(e.DeleteEvent.Identifier))
+	case event.DiffType:
+		verb := "diff"
+		if e.DiffEvent.Prune {
+			verb = "diff prune"
+		}
+		text := fmt.Sprintf("%s %s", verb, identifier(e.DiffEvent.Identifier))
+		for _, l := range strings.Split(strings.TrimRight(e.DiffEvent.Diff, "\n"), "\n") {
+			if l != "" {
+				text += "\n  " + l
+			}
+		}
+		return text
+	}
+	return fmt.Sprintf("unknown event %s", e.Type)
+}
+
+// statusLines returns the transcript lines for a run of status events.
+func statusLines(events []event.Event) []string {
+	last := make(map[string]string)
+	var end []string
+	for _, e := range events {
+		se := e.StatusEvent
+		switch se.EventType {
+		case pollevent.ResourceUpdateEvent:
+			if se.Resource == nil {
+				continue
+			}
+			id := resourceIdentifier(se.Resource.Identifier)
+			text := fmt.Sprintf("status %s %s", se.Resource.Status, id)
+			if se.Resource.Error != nil {
+				text += fmt.Sprintf(": %v", se.Resource.Error)
+			} else if se.Resource.Message != "" {
+				text += ": " + se.Resource.Message
+			}
+			last[id] = text
+		case pollevent.CompletedEvent:
+			end = append(end, fmt.Sprintf("status completed %s", se.AggregateStatus))
+		case pollevent.AbortedEvent:
+			end = append(end, fmt.Sprintf("status aborted %s", se.AggregateStatus))
+		case pollevent.ErrorEvent:
+			end = append(end, fmt.Sprintf("status error: %v", se.Error))
+		}
+	}
+	var ids []string
+	for id := range last {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var lines []string
+	for _, id := range ids {
+		lines = append(lines, last[id])
+	}
+	return append(lines, end...)
+}
+
+// identifier formats a resource like KIND[.GROUP] [NAMESPACE/]NAME.
+func identifier(id object.ObjMetadata) string {
+	return format(id.GroupKind.String(), id.Namespace, id.Name)
+}
+
+func resourceIdentifier(id wait.ResourceIdentifier) string {
+	return format(id.GroupKind.String(), id.Namespace, id.Name)
+}
+
+func format(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + " " + name
+	}
+	return kind + " " + namespace + "/" + name
+}
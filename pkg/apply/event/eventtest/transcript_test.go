@@ -0,0 +1,137 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package eventtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+var (
+	configMapGK  = schema.GroupKind{Kind: "ConfigMap"}
+	deploymentGK = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+)
+
+func applyEvent(op event.ApplyEventOperation, gk schema.GroupKind, namespace, name string) event.Event {
+	return event.Event{
+		Type:      event.ApplyType,
+		Timestamp: time.Now(),
+		ApplyEvent: event.ApplyEvent{
+			Operation:  op,
+			Identifier: object.ObjMetadata{GroupKind: gk, Namespace: namespace, Name: name},
+		},
+	}
+}
+
+func statusEvent(s status.Status, gk schema.GroupKind, name string) event.Event {
+	return event.Event{
+		Type:      event.StatusType,
+		Timestamp: time.Now(),
+		StatusEvent: pollevent.Event{
+			EventType: pollevent.ResourceUpdateEvent,
+			Resource: &pollevent.ObservedResource{
+				Identifier: wait.ResourceIdentifier{GroupKind: gk, Namespace: "default", Name: name},
+				Status:     s,
+				Message:    s.String(),
+			},
+		},
+	}
+}
+
+func pruneEvent(gk schema.GroupKind, name string) event.Event {
+	return event.Event{
+		Type: event.PruneType,
+		PruneEvent: event.PruneEvent{
+			Identifier: object.ObjMetadata{GroupKind: gk, Namespace: "default", Name: name},
+		},
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	testCases := map[string]struct {
+		events   []event.Event
+		expected string
+	}{
+		"no events": {},
+		"the apply order is kept and progress is left out": {
+			events: []event.Event{
+				applyEvent(event.Created, configMapGK, "default", "b"),
+				event.NewProgressEvent(event.ApplyPhase, 1, 2, time.Now()),
+				applyEvent(event.Unchanged, configMapGK, "default", "a"),
+				{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted}},
+			},
+			expected: "apply created ConfigMap default/b\n" +
+				"apply unchanged ConfigMap default/a\n" +
+				"apply completed\n",
+		},
+		"only the last status of every resource is kept": {
+			events: []event.Event{
+				statusEvent(status.InProgressStatus, deploymentGK, "web"),
+				statusEvent(status.CurrentStatus, configMapGK, "a"),
+				statusEvent(status.CurrentStatus, deploymentGK, "web"),
+				{Type: event.StatusType, StatusEvent: pollevent.Event{
+					EventType:       pollevent.CompletedEvent,
+					AggregateStatus: status.CurrentStatus,
+				}},
+			},
+			expected: "status Current ConfigMap default/a: Current\n" +
+				"status Current Deployment.apps default/web: Current\n" +
+				"status completed Current\n",
+		},
+		"prune events are sorted": {
+			events: []event.Event{
+				pruneEvent(deploymentGK, "web"),
+				pruneEvent(configMapGK, "b"),
+				{Type: event.PruneType, PruneEvent: event.PruneEvent{Type: event.PruneEventCompleted}},
+			},
+			expected: "prune pruned ConfigMap default/b\n" +
+				"prune pruned Deployment.apps default/web\n" +
+				"prune completed\n",
+		},
+		"errors and diffs": {
+			events: []event.Event{
+				{Type: event.DiffType, DiffEvent: event.DiffEvent{
+					Identifier: object.ObjMetadata{GroupKind: configMapGK, Name: "a", Namespace: "default"},
+					Diff:       "-a: 1\n+a: 2\n",
+				}},
+				{Type: event.ErrorType, ErrorEvent: event.ErrorEvent{Err: errors.New("boom")}},
+			},
+			expected: "diff ConfigMap default/a\n" +
+				"  -a: 1\n" +
+				"  +a: 2\n" +
+				"error: boom\n",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Transcript(tc.events))
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ch := make(chan event.Event, 2)
+	ch <- applyEvent(event.Created, configMapGK, "default", "a")
+	ch <- applyEvent(event.Created, configMapGK, "default", "b")
+	close(ch)
+	assert.Len(t, Collect(ch), 2)
+}
+
+func TestAssertGolden(t *testing.T) {
+	events := []event.Event{
+		applyEvent(event.Created, configMapGK, "default", "a"),
+		{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted}},
+	}
+	AssertGolden(t, "testdata/apply.golden", events)
+}
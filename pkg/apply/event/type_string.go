@@ -16,11 +16,13 @@ func _() {
 	_ = x[StatusType-2]
 	_ = x[PruneType-3]
 	_ = x[DeleteType-4]
+	_ = x[ProgressType-5]
+	_ = x[DiffType-6]
 }
 
-const _Type_name = "ErrorTypeApplyTypeStatusTypePruneTypeDeleteType"
+const _Type_name = "ErrorTypeApplyTypeStatusTypePruneTypeDeleteTypeProgressTypeDiffType"
 
-var _Type_index = [...]uint8{0, 9, 18, 28, 37, 47}
+var _Type_index = [...]uint8{0, 9, 18, 28, 37, 47, 59, 67}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
@@ -0,0 +1,74 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The exit codes used by the commands. They allow scripts and CI
+// pipelines to tell different kinds of failures apart without
+// parsing the output.
+const (
+	// ExitSuccess means all resources were applied, and if requested,
+	// reconciled and pruned.
+	ExitSuccess = 0
+	// ExitUnknownError is used for all errors that don't fall
+	// into one of the other categories.
+	ExitUnknownError = 1
+	// ExitApplyError means one or more resources could not be applied.
+	ExitApplyError = 2
+	// ExitPruneError means one or more resources could not be pruned
+	// or deleted.
+	ExitPruneError = 3
+	// ExitReconcileTimeout means the resources were applied, but did
+	// not all reach the Current status before the timeout.
+	ExitReconcileTimeout = 4
+	// ExitValidationError means the manifests could not be read or
+	// failed validation, so nothing was applied.
+	ExitValidationError = 5
+	// ExitDriftDetected means the live state of the cluster differs
+	// from the package.
+	ExitDriftDetected = 6
+	// ExitCancelled means the run was interrupted. The resources applied
+	// until then are recorded in the inventory, and nothing was pruned.
+	// It is the code shells use for processes terminated by SIGINT.
+	ExitCancelled = 130
+)
+
+// ExitCode returns the exit code the process should use for the given
+// error. The error can be wrapped after its exit code was set. Errors
+// that don't have a specific exit code will return ExitUnknownError.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	var coded interface{ ExitCode() int }
+	if walkErrors(err, func(err error) bool { return errors.As(err, &coded) }) {
+		return coded.ExitCode()
+	}
+	return ExitUnknownError
+}
+
+// osExit is used to terminate the process. It is a variable
+// so it can be replaced in tests.
+var osExit = os.Exit
+
+// CheckErr prints the error to the writer and exits the process with
+// the exit code for the error. It does nothing if the error is nil.
+func CheckErr(w io.Writer, err error) {
+	if err == nil {
+		return
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "error: ") {
+		msg = fmt.Sprintf("error: %s", msg)
+	}
+	fmt.Fprintln(w, msg)
+	osExit(ExitCode(err))
+}
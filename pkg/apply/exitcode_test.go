@@ -0,0 +1,69 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	testCases := map[string]struct {
+		err          error
+		expectedCode int
+	}{
+		"no error": {
+			err:          nil,
+			expectedCode: ExitSuccess,
+		},
+		"error without exit code": {
+			err:          fmt.Errorf("something failed"),
+			expectedCode: ExitUnknownError,
+		},
+		"apply error": {
+			err:          withExitCode(errors.WrapPrefix(fmt.Errorf("conflict"), "error applying resources", 1), ExitApplyError),
+			expectedCode: ExitApplyError,
+		},
+		"reconcile timeout": {
+			err:          withExitCode(fmt.Errorf("timed out"), ExitReconcileTimeout),
+			expectedCode: ExitReconcileTimeout,
+		},
+		"wrapped with a prefix": {
+			err:          errors.WrapPrefix(withExitCode(fmt.Errorf("invalid"), ExitValidationError), "error reading resources", 1),
+			expectedCode: ExitValidationError,
+		},
+		"wrapped with fmt": {
+			err:          fmt.Errorf("run failed: %w", withExitCode(fmt.Errorf("timed out"), ExitReconcileTimeout)),
+			expectedCode: ExitReconcileTimeout,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.expectedCode, ExitCode(tc.err))
+		})
+	}
+}
+
+func TestCheckErr(t *testing.T) {
+	defer func() { osExit = os.Exit }()
+	exitCode := -1
+	osExit = func(code int) {
+		exitCode = code
+	}
+
+	var buf bytes.Buffer
+	CheckErr(&buf, nil)
+	assert.Equal(t, -1, exitCode)
+	assert.Equal(t, "", buf.String())
+
+	CheckErr(&buf, withExitCode(fmt.Errorf("prune failed"), ExitPruneError))
+	assert.Equal(t, ExitPruneError, exitCode)
+	assert.Equal(t, "error: prune failed\n", buf.String())
+}
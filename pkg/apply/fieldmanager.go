@@ -0,0 +1,49 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+// fieldManagerFlag is the name of the flag added by
+// cmdutil.AddServerSideApplyFlags.
+const fieldManagerFlag = "field-manager"
+
+// fieldManagerClientGetter identifies all requests with the field
+// manager as the user agent. The API server records the user agent as
+// the manager in managedFields for requests that don't set a field
+// manager, which includes the patches done by a client-side apply.
+type fieldManagerClientGetter struct {
+	genericclioptions.RESTClientGetter
+	fieldManager string
+}
+
+func (f *fieldManagerClientGetter) ToRESTConfig() (*rest.Config, error) {
+	config, err := f.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	config = rest.CopyConfig(config)
+	config.UserAgent = f.fieldManager
+	return config, nil
+}
+
+// fieldManagerFromFlags returns the value of the field manager flag
+// if it has been set on the command line, or an empty string.
+func fieldManagerFromFlags(cmd *cobra.Command) string {
+	flag := cmd.Flags().Lookup(fieldManagerFlag)
+	if flag == nil || !flag.Changed {
+		return ""
+	}
+	return flag.Value.String()
+}
+
+// flagChanged returns true if the flag was set on the command line.
+func flagChanged(cmd *cobra.Command, name string) bool {
+	flag := cmd.Flags().Lookup(name)
+	return flag != nil && flag.Changed
+}
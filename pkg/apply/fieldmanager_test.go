@@ -0,0 +1,91 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func TestFieldManagerClientGetter(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	getter := &fieldManagerClientGetter{
+		RESTClientGetter: tf,
+		fieldManager:     "pipeline-a",
+	}
+	config, err := getter.ToRESTConfig()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "pipeline-a", config.UserAgent)
+
+	// The config of the wrapped getter must not be changed.
+	original, err := tf.ToRESTConfig()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, "pipeline-a", original.UserAgent)
+}
+
+func TestFieldManagerFromFlags(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"default is ignored": {
+			expected: "",
+		},
+		"set on the command line": {
+			args:     []string{"--field-manager=pipeline-a"},
+			expected: "pipeline-a",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmdutil.AddServerSideApplyFlags(cmd)
+			assert.NoError(t, cmd.Flags().Parse(tc.args))
+			assert.Equal(t, tc.expected, fieldManagerFromFlags(cmd))
+		})
+	}
+}
+
+func TestInitializeWrapsTheFactoryOnce(t *testing.T) {
+	cluster, err := fakecluster.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	factory := cluster.Factory()
+
+	applier := NewApplier(factory, genericclioptions.NewTestIOStreamsDiscard())
+	applier.FieldManager = "pipeline-a"
+	cmd := &cobra.Command{}
+	if !assert.NoError(t, applier.SetFlags(cmd)) {
+		return
+	}
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	if !assert.NoError(t, cmd.Flags().Set("filename", "-")) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if !assert.NoError(t, applier.Initialize(cmd, nil)) {
+			return
+		}
+		getter, ok := applier.discovery.RESTClientGetter.(*fieldManagerClientGetter)
+		if !assert.True(t, ok) {
+			return
+		}
+		assert.Equal(t, factory, getter.RESTClientGetter)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// EventFilter decides whether an event should be passed on.
+type EventFilter func(e event.Event) bool
+
+// eventTypeNames maps the names used on the command line
+// to the event types.
+var eventTypeNames = map[string]event.Type{
+	"error":    event.ErrorType,
+	"apply":    event.ApplyType,
+	"status":   event.StatusType,
+	"prune":    event.PruneType,
+	"delete":   event.DeleteType,
+	"progress": event.ProgressType,
+	"diff":     event.DiffType,
+}
+
+// severityNames maps the names used on the command line
+// to the severities.
+var severityNames = map[string]event.Severity{
+	"info":    event.SeverityInfo,
+	"warning": event.SeverityWarning,
+	"error":   event.SeverityError,
+}
+
+// FilterEvents returns a channel with only the events from the provided
+// channel that are accepted by all the filters. The returned channel is
+// closed when the provided channel is closed.
+func FilterEvents(ch <-chan event.Event, filters ...EventFilter) <-chan event.Event {
+	filtered := make(chan event.Event)
+	go func() {
+		defer close(filtered)
+		for e := range ch {
+			if accept(e, filters) {
+				filtered <- e
+			}
+		}
+	}()
+	return filtered
+}
+
+func accept(e event.Event, filters []EventFilter) bool {
+	for _, filter := range filters {
+		if !filter(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// TypeFilter accepts the events with one of the given types.
+func TypeFilter(types ...event.Type) EventFilter {
+	return func(e event.Event) bool {
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SeverityFilter accepts the events with at least the given severity.
+func SeverityFilter(min event.Severity) EventFilter {
+	return func(e event.Event) bool {
+		return e.Severity() >= min
+	}
+}
+
+// ParseEventTypes converts the names of event types, like apply or
+// prune, into the types.
+func ParseEventTypes(names []string) ([]event.Type, error) {
+	var types []event.Type
+	for _, name := range names {
+		t, found := eventTypeNames[strings.ToLower(strings.TrimSpace(name))]
+		if !found {
+			return nil, fmt.Errorf("unknown event type %q, must be one of %v", name, sortedKeys(eventTypeNames))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// ParseSeverity converts the name of a severity, one of info, warning
+// or error, into the severity.
+func ParseSeverity(name string) (event.Severity, error) {
+	s, found := severityNames[strings.ToLower(strings.TrimSpace(name))]
+	if !found {
+		return event.SeverityInfo, fmt.Errorf("unknown severity %q, must be one of [info warning error]", name)
+	}
+	return s, nil
+}
+
+func sortedKeys(m map[string]event.Type) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
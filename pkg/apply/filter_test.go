@@ -0,0 +1,128 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func TestFilterEvents(t *testing.T) {
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		statusEvent("foo", status.CurrentStatus, "Deployment is available"),
+		statusEvent("bar", status.FailedStatus, "Progress deadline exceeded"),
+		{
+			Type: event.StatusType,
+			StatusEvent: pollevent.Event{
+				EventType: pollevent.AbortedEvent,
+			},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type: event.PruneEventCompleted,
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		filters       []EventFilter
+		expectedCount int
+	}{
+		"no filters": {
+			filters:       nil,
+			expectedCount: 5,
+		},
+		"only status events": {
+			filters:       []EventFilter{TypeFilter(event.StatusType)},
+			expectedCount: 3,
+		},
+		"only warnings and errors": {
+			filters:       []EventFilter{SeverityFilter(event.SeverityWarning)},
+			expectedCount: 2,
+		},
+		"only errors for apply and prune": {
+			filters: []EventFilter{
+				TypeFilter(event.ApplyType, event.PruneType),
+				SeverityFilter(event.SeverityError),
+			},
+			expectedCount: 0,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			ch := make(chan event.Event)
+			go func() {
+				defer close(ch)
+				for _, e := range events {
+					ch <- e
+				}
+			}()
+
+			var filtered []event.Event
+			for e := range FilterEvents(ch, tc.filters...) {
+				filtered = append(filtered, e)
+			}
+			assert.Len(t, filtered, tc.expectedCount)
+		})
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	types, err := ParseEventTypes([]string{"apply", "Prune"})
+	assert.NoError(t, err)
+	assert.Equal(t, []event.Type{event.ApplyType, event.PruneType}, types)
+
+	_, err = ParseEventTypes([]string{"unknown"})
+	assert.Error(t, err)
+}
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("warning")
+	assert.NoError(t, err)
+	assert.Equal(t, event.SeverityWarning, severity)
+
+	_, err = ParseSeverity("fatal")
+	assert.Error(t, err)
+}
+
+func TestPrinterOptionsFiltering(t *testing.T) {
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	printerOptions := NewPrinterOptions()
+	printerOptions.EventTypes = []string{"prune"}
+	printer, err := printerOptions.ToPrinter(ioStreams)
+	assert.NoError(t, err)
+
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		ch <- event.Event{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		}
+		ch <- event.Event{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type: event.PruneEventCompleted,
+			},
+		}
+	}()
+	printer.Print(ch)
+
+	assert.Equal(t, "prune completed\n", out.String())
+}
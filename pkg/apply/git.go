@@ -0,0 +1,155 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitPrefix forces a URL to be treated as a git repository,
+// like in kustomize remote bases.
+const gitPrefix = "git::"
+
+// commitRegexp matches full commit ids, which never
+// change, so they don't need to be fetched again.
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// gitReference is a directory in a git repository at a given ref.
+type gitReference struct {
+	repo   string
+	subdir string
+	ref    string
+}
+
+// isGitURL returns true if the filename refers to a git repository
+// rather than a single manifest. Like for kustomize remote bases, these
+// are URLs with the git:: prefix, ssh URLs, and http(s) URLs that have
+// a ref parameter, a .git suffix on the repository, or a subdirectory
+// separated by //, for example
+// https://github.com/org/repo//manifests/prod?ref=v1.0.0.
+func isGitURL(filename string) bool {
+	if strings.HasPrefix(filename, gitPrefix) || strings.HasPrefix(filename, "git@") ||
+		strings.HasPrefix(filename, "ssh://") {
+		return true
+	}
+	if !isURL(filename) {
+		return false
+	}
+	u, err := url.Parse(filename)
+	if err != nil {
+		return false
+	}
+	return u.Query().Get("ref") != "" || strings.HasSuffix(u.Path, ".git") ||
+		strings.Contains(u.Path, ".git/") || strings.Contains(u.Path, "//")
+}
+
+// parseGitURL splits the URL into the repository, the subdirectory
+// and the ref.
+func parseGitURL(rawURL string) (gitReference, error) {
+	s := strings.TrimPrefix(rawURL, gitPrefix)
+	var ref string
+	if i := strings.Index(s, "?"); i >= 0 {
+		query, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return gitReference{}, fmt.Errorf("invalid git URL %q: %v", rawURL, err)
+		}
+		ref = query.Get("ref")
+		s = s[:i]
+	}
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	repo, subdir := s, ""
+	if i := strings.Index(s[start:], "//"); i >= 0 {
+		repo, subdir = s[:start+i], s[start+i+2:]
+	}
+	subdir = filepath.Clean("/" + subdir)[1:]
+	if repo == "" || repo[start:] == "" {
+		return gitReference{}, fmt.Errorf("invalid git URL %q: no repository", rawURL)
+	}
+	return gitReference{repo: repo, subdir: subdir, ref: ref}, nil
+}
+
+// defaultGitCacheDir returns the directory the repositories are
+// cached in, so later runs only need to fetch what has changed.
+func defaultGitCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "kapply", "git")
+}
+
+// checkout fetches the ref of the repository into the cache and
+// returns the path of the subdirectory. Every repository is cached in
+// its own directory. Commits that are already in the cache are not
+// fetched again, other refs are fetched on every run since they can
+// move.
+func (r *remoteManifests) checkout(rawURL string) (string, error) {
+	reference, err := parseGitURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(reference.repo))
+	dir := filepath.Join(r.gitCacheDir, hex.EncodeToString(sum[:8]))
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+		if err := runGit(dir, "init", "-q"); err != nil {
+			return "", fmt.Errorf("error cloning %s: %v", reference.repo, err)
+		}
+		if err := runGit(dir, "remote", "add", "origin", reference.repo); err != nil {
+			return "", fmt.Errorf("error cloning %s: %v", reference.repo, err)
+		}
+	}
+
+	target := "FETCH_HEAD"
+	if commitRegexp.MatchString(reference.ref) && runGit(dir, "cat-file", "-e", reference.ref+"^{commit}") == nil {
+		target = reference.ref
+	} else {
+		ref := reference.ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if err := runGit(dir, "fetch", "-q", "--depth", "1", "origin", ref); err != nil {
+			return "", fmt.Errorf("error fetching %s from %s: %v", ref, reference.repo, err)
+		}
+	}
+	if err := runGit(dir, "checkout", "-q", "--force", target); err != nil {
+		return "", fmt.Errorf("error checking out %s: %v", rawURL, err)
+	}
+
+	path := filepath.Join(dir, reference.subdir)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%s not found in %s", reference.subdir, reference.repo)
+	}
+	return path, nil
+}
+
+// runGit runs git in the directory and returns the error
+// output if it fails.
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGitURL(t *testing.T) {
+	testCases := map[string]bool{
+		"https://github.com/org/repo//manifests?ref=v1": true,
+		"https://github.com/org/repo?ref=v1":            true,
+		"https://github.com/org/repo.git":               true,
+		"git::https://example.com/repo":                 true,
+		"git@github.com:org/repo.git//manifests":        true,
+		"ssh://git@github.com/org/repo.git":             true,
+		"https://example.com/release.yaml":              false,
+		"https://org.github.io/repo/release.yaml":       false,
+		"oci://registry.example.com/app@sha256:abc":     false,
+		"manifests/deployment.yaml":                     false,
+	}
+	for filename, expected := range testCases {
+		assert.Equal(t, expected, isGitURL(filename), filename)
+	}
+}
+
+func TestParseGitURL(t *testing.T) {
+	testCases := map[string]struct {
+		url         string
+		expected    gitReference
+		expectedErr bool
+	}{
+		"subdirectory and ref": {
+			url:      "https://github.com/org/repo//manifests/prod?ref=v1.0.0",
+			expected: gitReference{repo: "https://github.com/org/repo", subdir: "manifests/prod", ref: "v1.0.0"},
+		},
+		"no subdirectory": {
+			url:      "git::https://example.com/repo.git",
+			expected: gitReference{repo: "https://example.com/repo.git"},
+		},
+		"scp-like": {
+			url:      "git@github.com:org/repo.git//manifests?ref=main",
+			expected: gitReference{repo: "git@github.com:org/repo.git", subdir: "manifests", ref: "main"},
+		},
+		"subdirectory outside the repository": {
+			url:      "https://github.com/org/repo//../../etc?ref=v1",
+			expected: gitReference{repo: "https://github.com/org/repo", subdir: "etc", ref: "v1"},
+		},
+		"no repository": {
+			url:         "git::https://",
+			expectedErr: true,
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			reference, err := parseGitURL(tc.url)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, reference)
+		})
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a repository with the manifest changed after the tag.
+	repo := filepath.Join(dir, "repo")
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		assert.NoError(t, runGit(repo, args...))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "manifests"), 0700))
+	manifest := filepath.Join(repo, "manifests", "cm.yaml")
+	git("init", "-q")
+	assert.NoError(t, ioutil.WriteFile(manifest, []byte("name: v1\n"), 0600))
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	assert.NoError(t, ioutil.WriteFile(manifest, []byte("name: v2\n"), 0600))
+	git("commit", "-q", "-am", "v2")
+
+	r := newRemoteManifests(false)
+	r.gitCacheDir = filepath.Join(dir, "cache")
+	repoURL := "git::file://" + repo
+
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{url: repoURL + "//manifests?ref=v1", expected: "name: v1"},
+		{url: repoURL + "//manifests", expected: "name: v2"},
+		// The second checkout of the tag uses the cached repository.
+		{url: repoURL + "//manifests?ref=v1", expected: "name: v1"},
+	} {
+		path, err := r.checkout(tc.url)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, strings.HasPrefix(path, r.gitCacheDir))
+		content, err := ioutil.ReadFile(filepath.Join(path, "cm.yaml"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), tc.expected)
+	}
+
+	_, err = r.checkout(repoURL + "//missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// globMeta contains the characters that make a filename a pattern.
+const globMeta = "*?["
+
+// isGlob returns true if the filename is a shell-style pattern.
+func isGlob(filename string) bool {
+	return strings.ContainsAny(filename, globMeta)
+}
+
+// expandGlob returns the files that match the pattern, in lexical
+// order so the manifests are always read in the same order. The
+// pattern uses the syntax of filepath.Match for every path segment,
+// and ** matches any number of directories. It is an error if no
+// files match, since that usually means the pattern is wrong.
+func expandGlob(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	// Only walk the part of the tree that can match.
+	var base []string
+	for len(segments) > 0 && !isGlob(segments[0]) {
+		base = append(base, segments[0])
+		segments = segments[1:]
+	}
+	root := strings.Join(base, "/")
+	if root == "" {
+		root = "."
+		if strings.HasPrefix(pattern, "/") {
+			root = "/"
+		}
+	}
+	for _, segment := range segments {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	var matches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if matchSegments(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error expanding pattern %q: %v", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match pattern %q", pattern)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchSegments returns true if the path segments match the
+// pattern segments, where ** matches zero or more segments.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	// The pattern has been validated, so there is no error.
+	matched, _ := filepath.Match(pattern[0], path[0])
+	return matched && matchSegments(pattern[1:], path[1:])
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"b.yaml",
+		"a.yaml",
+		"README.md",
+		"apps/web/deployment.yaml",
+		"apps/web/service.yml",
+		"apps/db/statefulset.yaml",
+		"apps/db/nested/configmap.yaml",
+	} {
+		path := filepath.Join(dir, name)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) ||
+			!assert.NoError(t, ioutil.WriteFile(path, []byte{}, 0600)) {
+			return
+		}
+	}
+
+	testCases := map[string]struct {
+		pattern     string
+		expected    []string
+		expectedErr bool
+	}{
+		"single directory": {
+			pattern:  "*.yaml",
+			expected: []string{"a.yaml", "b.yaml"},
+		},
+		"any depth": {
+			pattern: "**/*.yaml",
+			expected: []string{
+				"a.yaml",
+				"apps/db/nested/configmap.yaml",
+				"apps/db/statefulset.yaml",
+				"apps/web/deployment.yaml",
+				"b.yaml",
+			},
+		},
+		"directory wildcard": {
+			pattern:  "apps/*/*.y*ml",
+			expected: []string{"apps/db/statefulset.yaml", "apps/web/deployment.yaml", "apps/web/service.yml"},
+		},
+		"any depth below a directory": {
+			pattern:  "apps/db/**",
+			expected: []string{"apps/db/nested/configmap.yaml", "apps/db/statefulset.yaml"},
+		},
+		"no matches": {
+			pattern:     "*.json",
+			expectedErr: true,
+		},
+		"missing directory": {
+			pattern:     "missing/*.yaml",
+			expectedErr: true,
+		},
+		"invalid pattern": {
+			pattern:     "[.yaml",
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			matches, err := expandGlob(filepath.Join(dir, tc.pattern))
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			var expected []string
+			for _, name := range tc.expected {
+				expected = append(expected, filepath.Join(dir, name))
+			}
+			assert.Equal(t, expected, matches)
+		})
+	}
+}
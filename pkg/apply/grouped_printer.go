@@ -0,0 +1,105 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// GroupedPrinter collects the events for every resource and prints
+// them grouped by resource once the channel is closed. This makes it
+// easy to follow what happened to each resource, since the events
+// for different resources are not interleaved.
+type GroupedPrinter struct {
+	IOStreams genericclioptions.IOStreams
+}
+
+// resourceHistory is the list of things that happened to a resource.
+type resourceHistory struct {
+	id    object.ObjMetadata
+	lines []string
+}
+
+// add appends the line to the history, unless it is the same
+// as the last one. Status is polled, so the same status is
+// often reported many times.
+func (r *resourceHistory) add(line string) {
+	if len(r.lines) > 0 && r.lines[len(r.lines)-1] == line {
+		return
+	}
+	r.lines = append(r.lines, line)
+}
+
+// Print blocks until the channel is closed and then prints the
+// history of every resource in the order they were first seen.
+// If an error event is received, the history so far is printed
+// before the error is reported.
+func (g *GroupedPrinter) Print(ch <-chan event.Event) {
+	var order []string
+	histories := make(map[string]*resourceHistory)
+	history := func(id object.ObjMetadata) *resourceHistory {
+		key := id.String()
+		h, found := histories[key]
+		if !found {
+			h = &resourceHistory{id: id}
+			histories[key] = h
+			order = append(order, key)
+		}
+		return h
+	}
+
+	for e := range ch {
+		switch e.Type {
+		case event.ErrorType:
+			g.printHistories(order, histories)
+			CheckErr(g.IOStreams.ErrOut, e.ErrorEvent.Err)
+		case event.ApplyType:
+			if e.ApplyEvent.Type == event.ApplyEventResourceUpdate {
+				history(e.ApplyEvent.Identifier).add(operationToString(e.ApplyEvent.Operation))
+			}
+		case event.StatusType:
+			if e.StatusEvent.EventType == pollevent.ResourceUpdateEvent {
+				r := e.StatusEvent.Resource
+				id := object.ObjMetadata{
+					Namespace: r.Identifier.Namespace,
+					Name:      r.Identifier.Name,
+					GroupKind: r.Identifier.GroupKind,
+				}
+				history(id).add(fmt.Sprintf("%s: %s", r.Status, r.Message))
+			}
+		case event.PruneType:
+			if e.PruneEvent.Type == event.PruneEventResourceUpdate {
+				if e.PruneEvent.Operation == event.PruneSkipped {
+					history(e.PruneEvent.Identifier).add("prune skipped")
+				} else {
+					history(e.PruneEvent.Identifier).add("pruned")
+				}
+			}
+		case event.DeleteType:
+			if e.DeleteEvent.Type == event.DeleteEventResourceUpdate {
+				history(e.DeleteEvent.Identifier).add("deleted")
+			}
+		}
+	}
+	g.printHistories(order, histories)
+}
+
+func (g *GroupedPrinter) printHistories(order []string, histories map[string]*resourceHistory) {
+	for _, key := range order {
+		h := histories[key]
+		heading := resourceIDToString(h.id.GroupKind, h.id.Name)
+		if h.id.Namespace != "" {
+			heading = fmt.Sprintf("%s (namespace %s)", heading, h.id.Namespace)
+		}
+		fmt.Fprintln(g.IOStreams.Out, heading)
+		for _, line := range h.lines {
+			fmt.Fprintf(g.IOStreams.Out, "  %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestGroupedPrinter(t *testing.T) {
+	deploymentID := func(name string) object.ObjMetadata {
+		return object.ObjMetadata{
+			Namespace: "default",
+			Name:      name,
+			GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		}
+	}
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Configured,
+				Identifier: deploymentID("foo"),
+			},
+		},
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Created,
+				Identifier: deploymentID("bar"),
+			},
+		},
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		statusEvent("foo", status.InProgressStatus, "Replicas: 0/1"),
+		statusEvent("bar", status.CurrentStatus, "Deployment is available"),
+		statusEvent("foo", status.InProgressStatus, "Replicas: 0/1"),
+		statusEvent("foo", status.CurrentStatus, "Deployment is available"),
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type:       event.PruneEventResourceUpdate,
+				Identifier: deploymentID("old"),
+			},
+		},
+	}
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	printer := &GroupedPrinter{IOStreams: ioStreams}
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		for _, e := range events {
+			ch <- e
+		}
+	}()
+	printer.Print(ch)
+
+	expected := `deployment.apps/foo (namespace default)
+  configured
+  InProgress: Replicas: 0/1
+  Current: Deployment is available
+deployment.apps/bar (namespace default)
+  created
+  Current: Deployment is available
+deployment.apps/old (namespace default)
+  pruned
+`
+	assert.Equal(t, expected, out.String())
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/yaml"
+)
+
+// renderedChart is the name of the file the rendered
+// Helm chart is written to.
+const renderedChart = "helm-chart.yaml"
+
+// helmInventoryPrefix is the prefix of the inventory id generated
+// for Helm releases, so it doesn't collide with the ids of packages.
+const helmInventoryPrefix = "helm-"
+
+// HelmOptions contains the Helm chart to render and apply instead of,
+// or together with, the manifests.
+type HelmOptions struct {
+	// Chart is the path of the chart directory or archive. No chart
+	// is rendered if it is empty.
+	Chart string
+	// ValuesFiles are merged in order, so values in later files
+	// override the ones in earlier files and in the chart.
+	ValuesFiles []string
+	// ReleaseName is the name of the release the chart is rendered
+	// for. The name of the chart is used if it is empty.
+	ReleaseName string
+	// Namespace is the namespace of the release.
+	Namespace string
+}
+
+// addHelmFlags adds the flags for rendering a Helm chart.
+func addHelmFlags(cmd *cobra.Command, o *HelmOptions) {
+	cmd.Flags().StringVar(&o.Chart, "helm-chart", o.Chart,
+		"Path of a Helm chart directory or archive to render and use as manifests. A grouping object is "+
+			"generated for the release if the chart doesn't contain one and no other manifests are given.")
+	cmd.Flags().StringSliceVar(&o.ValuesFiles, "helm-values", o.ValuesFiles,
+		"Values file for the Helm chart. Can be repeated, later files take precedence.")
+	cmd.Flags().StringVar(&o.ReleaseName, "helm-release-name", o.ReleaseName,
+		"Name of the release the Helm chart is rendered for. Defaults to the name of the chart.")
+}
+
+// renderChart renders the Helm chart and writes the manifests to the
+// temporary directory, so it can be read like any other manifest. If
+// requireGrouping is true and the chart doesn't contain a grouping
+// object template, one is generated for the release.
+func (r *remoteManifests) renderChart(o HelmOptions, requireGrouping bool) (string, error) {
+	content, err := renderChart(o, requireGrouping)
+	if err != nil {
+		return "", err
+	}
+	return r.write(renderedChart, content)
+}
+
+// renderChart returns the manifests of the chart, rendered the way
+// `helm template` does. The custom resource definitions of the chart
+// come first, followed by the templates in the order of their names.
+func renderChart(o HelmOptions, requireGrouping bool) ([]byte, error) {
+	chrt, err := loader.Load(o.Chart)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Helm chart %s: %v", o.Chart, err)
+	}
+	values := map[string]interface{}{}
+	for _, file := range o.ValuesFiles {
+		fileValues, err := chartutil.ReadValuesFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Helm values %s: %v", file, err)
+		}
+		values = chartutil.CoalesceTables(fileValues.AsMap(), values)
+	}
+	releaseName := o.ReleaseName
+	if releaseName == "" {
+		releaseName = chrt.Name()
+	}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: o.Namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering Helm chart %s: %v", o.Chart, err)
+	}
+	rendered, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering Helm chart %s: %v", o.Chart, err)
+	}
+
+	var manifests []string
+	for _, crd := range chrt.CRDs() {
+		manifests = append(manifests, string(crd.Data))
+	}
+	var names []string
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// The notes are printed by helm after an install,
+		// they are not manifests.
+		if strings.HasSuffix(name, "NOTES.txt") || strings.TrimSpace(rendered[name]) == "" {
+			continue
+		}
+		manifests = append(manifests, rendered[name])
+	}
+
+	var buf bytes.Buffer
+	for _, manifest := range manifests {
+		buf.WriteString("---\n")
+		buf.WriteString(strings.TrimSpace(manifest))
+		buf.WriteString("\n")
+	}
+	found, err := containsGroupingObject(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error reading rendered Helm chart %s: %v", o.Chart, err)
+	}
+	if requireGrouping && !found {
+		inventory, err := helmGroupingObject(releaseName)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(inventory)
+	}
+	return buf.Bytes(), nil
+}
+
+// helmGroupingObject returns the YAML for the grouping object template
+// of the release. The inventory id is derived from the release name,
+// so every apply of the release finds the previously applied resources.
+func helmGroupingObject(releaseName string) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName(helmInventoryPrefix + releaseName)
+	obj.SetLabels(map[string]string{
+		prune.GroupingLabel: helmInventoryPrefix + releaseName,
+	})
+	return yaml.Marshal(obj.Object)
+}
+
+// containsGroupingObject returns true if any of the objects
+// in the manifests is a grouping object.
+func containsGroupingObject(content []byte) (bool, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if prune.IsGroupingTemplate(obj) {
+			return true, nil
+		}
+	}
+}
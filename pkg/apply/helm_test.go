@@ -0,0 +1,158 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var helmChartFiles = map[string]string{
+	"Chart.yaml":  "apiVersion: v2\nname: test-chart\nversion: 0.1.0\n",
+	"values.yaml": "replicas: 1\nimage: nginx\n",
+	"templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+      - image: {{ .Values.image }}
+`,
+	"templates/NOTES.txt": "Installed {{ .Release.Name }}.\n",
+}
+
+func TestRenderChart(t *testing.T) {
+	testCases := map[string]struct {
+		files            map[string]string
+		valuesFiles      map[string]string
+		releaseName      string
+		filenames        []string
+		expected         []string
+		notExpected      []string
+		expectedErr      bool
+		expectedNumFiles int
+	}{
+		"generated grouping object": {
+			releaseName: "foo",
+			expected: []string{
+				"name: foo",
+				"namespace: test-namespace",
+				"replicas: 1",
+				"cli-utils.sigs.k8s.io/inventory-id: helm-foo",
+			},
+			notExpected:      []string{"Installed"},
+			expectedNumFiles: 1,
+		},
+		"release name defaults to the chart name": {
+			expected:         []string{"name: test-chart", "inventory-id: helm-test-chart"},
+			expectedNumFiles: 1,
+		},
+		"values files in order": {
+			valuesFiles: map[string]string{
+				"a.yaml": "replicas: 2\nimage: httpd\n",
+				"b.yaml": "replicas: 3\n",
+			},
+			expected:         []string{"replicas: 3", "image: httpd"},
+			expectedNumFiles: 1,
+		},
+		"grouping object from -f": {
+			filenames:        []string{"inventory.yaml"},
+			expected:         []string{"kind: Deployment"},
+			notExpected:      []string{"inventory-id"},
+			expectedNumFiles: 2,
+		},
+		"grouping object in the chart": {
+			files: map[string]string{
+				"templates/inventory.yaml": kustomizeGroupingObject,
+			},
+			expected:         []string{"inventory-id: test-inventory"},
+			notExpected:      []string{"helm-test-chart"},
+			expectedNumFiles: 1,
+		},
+		"invalid template": {
+			files: map[string]string{
+				"templates/broken.yaml": "{{ .Values.missing.field }}",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "helm-test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			chartDir := filepath.Join(dir, "chart")
+			for _, files := range []map[string]string{helmChartFiles, tc.files} {
+				for name, content := range files {
+					path := filepath.Join(chartDir, name)
+					if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+						return
+					}
+					if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+						return
+					}
+				}
+			}
+			var valuesFiles []string
+			for _, name := range []string{"a.yaml", "b.yaml"} {
+				content, found := tc.valuesFiles[name]
+				if !found {
+					continue
+				}
+				path := filepath.Join(dir, name)
+				if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+					return
+				}
+				valuesFiles = append(valuesFiles, path)
+			}
+
+			r := newRemoteManifests(false)
+			defer r.cleanup()
+			filenames := tc.filenames
+			flags := &genericclioptions.FileNameFlags{
+				Filenames: &filenames,
+			}
+			fileNameFlags, err := r.resolvePaths(nil, flags, HelmOptions{
+				Chart:       chartDir,
+				ValuesFiles: valuesFiles,
+				ReleaseName: tc.releaseName,
+				Namespace:   "test-namespace",
+			})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			resolved := *fileNameFlags.Filenames
+			if !assert.Len(t, resolved, tc.expectedNumFiles) {
+				return
+			}
+			content, err := ioutil.ReadFile(resolved[len(resolved)-1])
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, s := range tc.expected {
+				assert.True(t, strings.Contains(string(content), s), "expected %q in %s", s, content)
+			}
+			for _, s := range tc.notExpected {
+				assert.False(t, strings.Contains(string(content), s), "unexpected %q in %s", s, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// If the HistoryLimit of the Applier is set, every successful run records
+// the manifests it applied as a revision of the package. The revisions
+// are kept in Secrets next to the grouping object, since the manifests
+// can contain sensitive values, and the Rollbacker applies them again.
+
+const (
+	// HistoryLabel is set on the Secrets of the revisions
+	// to the inventory ID of the package.
+	HistoryLabel = "cli-utils.sigs.k8s.io/history-of"
+	// RevisionLabel is set on the Secrets of the revisions
+	// to the number of the revision.
+	RevisionLabel = "cli-utils.sigs.k8s.io/revision"
+	// DefaultHistoryLimit is the number of revisions kept by the
+	// rollback command, which records the rollback as a revision.
+	DefaultHistoryLimit = 10
+
+	historySecretType = corev1.SecretType("cli-utils.sigs.k8s.io/history")
+	historyDataKey    = "manifests.json.gz"
+)
+
+// Revision is a set of manifests that was applied.
+type Revision struct {
+	// Number increases with every run. The first revision is 1.
+	Number int
+	// Created is when the run that applied the revision completed.
+	Created time.Time
+	// Objects are the manifests, including the grouping object.
+	Objects []*unstructured.Unstructured
+}
+
+// history reads and records the revisions of an inventory.
+type history struct {
+	client      kubernetes.Interface
+	namespace   string
+	inventoryID string
+}
+
+// newHistory returns the history of the inventory of the grouping object.
+func newHistory(client kubernetes.Interface, groupingInfo *resource.Info) (*history, error) {
+	accessor, err := meta.Accessor(groupingInfo.Object)
+	if err != nil {
+		return nil, err
+	}
+	inventoryID := strings.TrimSpace(accessor.GetLabels()[prune.GroupingLabel])
+	if inventoryID == "" {
+		return nil, fmt.Errorf("grouping object %s has no inventory id", groupingInfo.Name)
+	}
+	return &history{
+		client:      client,
+		namespace:   groupingInfo.Namespace,
+		inventoryID: inventoryID,
+	}, nil
+}
+
+// secrets returns the Secrets of the revisions, oldest first.
+func (h *history) secrets() ([]corev1.Secret, error) {
+	list, err := h.client.CoreV1().Secrets(h.namespace).List(metav1.ListOptions{
+		LabelSelector: HistoryLabel + "=" + h.inventoryID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing revisions: %v", err)
+	}
+	secrets := list.Items
+	sort.Slice(secrets, func(i, j int) bool {
+		return revisionNumber(secrets[i]) < revisionNumber(secrets[j])
+	})
+	return secrets, nil
+}
+
+// revisions returns the revisions, oldest first.
+func (h *history) revisions() ([]Revision, error) {
+	secrets, err := h.secrets()
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(secrets))
+	for _, s := range secrets {
+		objs, err := decodeObjects(s.Data[historyDataKey])
+		if err != nil {
+			return nil, fmt.Errorf("error reading revision %d: %v", revisionNumber(s), err)
+		}
+		revisions = append(revisions, Revision{
+			Number:  revisionNumber(s),
+			Created: s.CreationTimestamp.Time,
+			Objects: objs,
+		})
+	}
+	return revisions, nil
+}
+
+// record stores the objects as the next revision, and deletes the
+// oldest revisions so no more than limit are kept.
+func (h *history) record(objs []*unstructured.Unstructured, limit int) (int, error) {
+	secrets, err := h.secrets()
+	if err != nil {
+		return 0, err
+	}
+	number := 1
+	if len(secrets) > 0 {
+		number = revisionNumber(secrets[len(secrets)-1]) + 1
+	}
+	data, err := encodeObjects(objs)
+	if err != nil {
+		return 0, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", inventoryObjectName("history", h.inventoryID), number),
+			Namespace: h.namespace,
+			Labels: map[string]string{
+				HistoryLabel:  h.inventoryID,
+				RevisionLabel: strconv.Itoa(number),
+			},
+		},
+		Type: historySecretType,
+		Data: map[string][]byte{historyDataKey: data},
+	}
+	if _, err := h.client.CoreV1().Secrets(h.namespace).Create(secret); err != nil {
+		return 0, fmt.Errorf("error recording revision %d: %v", number, err)
+	}
+	for i := 0; i < len(secrets)+1-limit; i++ {
+		err := h.client.CoreV1().Secrets(h.namespace).Delete(secrets[i].Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("error deleting revision %d: %v", revisionNumber(secrets[i]), err)
+		}
+	}
+	return number, nil
+}
+
+// revisionNumber returns the number of the revision stored in the
+// Secret, or 0 if the label is missing or invalid.
+func revisionNumber(s corev1.Secret) int {
+	n, _ := strconv.Atoi(s.Labels[RevisionLabel])
+	return n
+}
+
+// encodeObjects returns the objects as a gzipped JSON list.
+func encodeObjects(objs []*unstructured.Unstructured) ([]byte, error) {
+	items := make([]map[string]interface{}, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.Object)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeObjects reads the objects written by encodeObjects.
+func decodeObjects(data []byte) ([]*unstructured.Unstructured, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, err
+	}
+	objs := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		objs = append(objs, &unstructured.Unstructured{Object: item})
+	}
+	return objs, nil
+}
+
+// snapshotObjects returns copies of the objects of the resources as
+// they were read, before the run changes them.
+func snapshotObjects(infos []*resource.Info) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		if u, ok := info.Object.(*unstructured.Unstructured); ok {
+			objs = append(objs, u.DeepCopy())
+			continue
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: content})
+	}
+	return objs, nil
+}
+
+// HistoryTask records the manifests of a successful run as a revision.
+type HistoryTask struct {
+	applier *Applier
+	objects []*unstructured.Unstructured
+}
+
+// Name returns HistoryTaskName.
+func (t *HistoryTask) Name() string {
+	return HistoryTaskName
+}
+
+// Run records the revision, and deletes the revisions
+// beyond the HistoryLimit.
+func (t *HistoryTask) Run(_ context.Context, tc *TaskContext) error {
+	a := t.applier
+	groupingInfo, found := prune.FindGroupingObject(tc.Infos)
+	if !found {
+		return ErrInventoryNotFound
+	}
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return errors.WrapPrefix(err, "error recording revision", 1)
+	}
+	h, err := newHistory(client, groupingInfo)
+	if err != nil {
+		return errors.WrapPrefix(err, "error recording revision", 1)
+	}
+	number, err := h.record(t.objects, a.HistoryLimit)
+	if err != nil {
+		a.logger().Error(err, "error recording revision")
+		return errors.WrapPrefix(err, "error recording revision", 1)
+	}
+	a.logger().V(1).Info("recorded revision", "revision", number, "inventoryID", h.inventoryID)
+	return nil
+}
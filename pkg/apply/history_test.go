@@ -0,0 +1,76 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEncodeObjects(t *testing.T) {
+	cm := configMap("cm", map[string]string{"app": "test"})
+	cm.Object["data"] = map[string]interface{}{"key": "value"}
+	objs := []*unstructured.Unstructured{configMap("inventory", nil), cm}
+
+	data, err := encodeObjects(objs)
+	require.NoError(t, err)
+	decoded, err := decodeObjects(data)
+	require.NoError(t, err)
+	assert.Equal(t, objs, decoded)
+
+	_, err = decodeObjects([]byte("not gzipped"))
+	assert.Error(t, err)
+}
+
+func TestHistoryRecord(t *testing.T) {
+	testCases := map[string]struct {
+		records   int
+		limit     int
+		revisions []int
+	}{
+		"first revision": {
+			records:   1,
+			limit:     3,
+			revisions: []int{1},
+		},
+		"below the limit": {
+			records:   3,
+			limit:     3,
+			revisions: []int{1, 2, 3},
+		},
+		"oldest revisions are deleted": {
+			records:   5,
+			limit:     2,
+			revisions: []int{4, 5},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			h := &history{client: fake.NewSimpleClientset(), namespace: "default", inventoryID: "test"}
+			for i := 1; i <= tc.records; i++ {
+				number, err := h.record([]*unstructured.Unstructured{configMap("inventory", nil)}, tc.limit)
+				require.NoError(t, err)
+				assert.Equal(t, i, number)
+			}
+			// The history of another inventory is kept apart.
+			other := &history{client: h.client, namespace: "default", inventoryID: "other"}
+			_, err := other.record(nil, tc.limit)
+			require.NoError(t, err)
+
+			revisions, err := h.revisions()
+			require.NoError(t, err)
+			var numbers []int
+			for _, rev := range revisions {
+				numbers = append(numbers, rev.Number)
+				assert.Len(t, rev.Objects, 1)
+			}
+			assert.Equal(t, tc.revisions, numbers)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// InjectAnnotation declares the fields of a resource that are filled
+// with values from ConfigMaps or Secrets in the cluster when it is
+// applied, so a package can adapt to the cluster without templating.
+// The value is a comma-separated list of injections in the format
+// PATH=KIND/NAMESPACE/NAME/KEY, where PATH is the dot-separated path
+// to the field and KIND is ConfigMap or Secret, for example
+// "data.domain=ConfigMap/kube-system/cluster-info/domain".
+const InjectAnnotation = "cli-utils.sigs.k8s.io/inject"
+
+// Injection is a field that is filled with a value from the cluster.
+type Injection struct {
+	// Path is the path to the field. Only maps can be traversed,
+	// fields in lists can't be injected.
+	Path []string
+	// Kind is ConfigMap or Secret.
+	Kind      string
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (i Injection) String() string {
+	return fmt.Sprintf("%s=%s/%s/%s/%s", strings.Join(i.Path, "."), i.Kind, i.Namespace, i.Name, i.Key)
+}
+
+// ParseInjections parses the value of the InjectAnnotation.
+func ParseInjections(value string) ([]Injection, error) {
+	var injections []Injection
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("injection %q must be in the format PATH=KIND/NAMESPACE/NAME/KEY", s)
+		}
+		path := strings.Split(parts[0], ".")
+		for _, p := range path {
+			if p == "" {
+				return nil, fmt.Errorf("injection %q has an empty path element", s)
+			}
+		}
+		source := strings.Split(parts[1], "/")
+		if len(source) != 4 || source[1] == "" || source[2] == "" || source[3] == "" {
+			return nil, fmt.Errorf("injection %q must be in the format PATH=KIND/NAMESPACE/NAME/KEY", s)
+		}
+		if source[0] != "ConfigMap" && source[0] != "Secret" {
+			return nil, fmt.Errorf("injection %q must read from a ConfigMap or a Secret", s)
+		}
+		injections = append(injections, Injection{
+			Path:      path,
+			Kind:      source[0],
+			Namespace: source[1],
+			Name:      source[2],
+			Key:       source[3],
+		})
+	}
+	return injections, nil
+}
+
+// injector reads the values for the injections from the cluster. Every
+// ConfigMap and Secret is only read once per run.
+type injector struct {
+	client kubernetes.Interface
+	values map[string]map[string]string
+}
+
+// injectValues fills the fields declared by the InjectAnnotation of the
+// resources. It returns the fields filled from Secrets, which must be
+// treated as sensitive.
+func injectValues(client kubernetes.Interface, infos []*resource.Info) ([]object.SensitiveField, error) {
+	i := &injector{
+		client: client,
+		values: make(map[string]map[string]string),
+	}
+	var sensitive []object.SensitiveField
+	for _, info := range infos {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		value, found := accessor.GetAnnotations()[InjectAnnotation]
+		if !found {
+			continue
+		}
+		objMeta := infoToObjMetadata(info)
+		injections, err := ParseInjections(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", objMeta.String(), err)
+		}
+		u, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("%s: values can only be injected into unstructured objects",
+				objMeta.String())
+		}
+		for _, injection := range injections {
+			v, err := i.value(injection)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", objMeta.String(), err)
+			}
+			if err := unstructured.SetNestedField(u.Object, v, injection.Path...); err != nil {
+				return nil, fmt.Errorf("%s: error injecting %s: %v", objMeta.String(),
+					strings.Join(injection.Path, "."), err)
+			}
+			if injection.Kind == "Secret" {
+				sensitive = append(sensitive, object.SensitiveField{
+					GroupKind: u.GroupVersionKind().GroupKind(),
+					Path:      injection.Path,
+				})
+			}
+		}
+	}
+	return sensitive, nil
+}
+
+// value returns the value of the key in the ConfigMap or Secret.
+func (i *injector) value(injection Injection) (string, error) {
+	id := injection.Kind + "/" + injection.Namespace + "/" + injection.Name
+	values, found := i.values[id]
+	if !found {
+		var err error
+		values, err = i.read(injection)
+		if err != nil {
+			return "", err
+		}
+		i.values[id] = values
+	}
+	v, found := values[injection.Key]
+	if !found {
+		return "", fmt.Errorf("key %s not found in %s %s/%s", injection.Key, injection.Kind,
+			injection.Namespace, injection.Name)
+	}
+	return v, nil
+}
+
+// read returns the data of the ConfigMap or Secret.
+func (i *injector) read(injection Injection) (map[string]string, error) {
+	values := make(map[string]string)
+	if injection.Kind == "Secret" {
+		secret, err := i.client.CoreV1().Secrets(injection.Namespace).Get(injection.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error reading Secret %s/%s: %v", injection.Namespace, injection.Name, err)
+		}
+		for k, v := range secret.Data {
+			values[k] = string(v)
+		}
+		for k, v := range secret.StringData {
+			values[k] = v
+		}
+		return values, nil
+	}
+	cm, err := i.client.CoreV1().ConfigMaps(injection.Namespace).Get(injection.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading ConfigMap %s/%s: %v", injection.Namespace, injection.Name, err)
+	}
+	for k, v := range cm.Data {
+		values[k] = v
+	}
+	return values, nil
+}
+
+// injectValues fills the fields declared by the InjectAnnotation of the
+// resources, and redacts the fields filled from Secrets in the output.
+func (a *Applier) injectValues(infos []*resource.Info) error {
+	if !hasInjections(infos) {
+		return nil
+	}
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return err
+	}
+	sensitive, err := injectValues(client, infos)
+	if err != nil {
+		return err
+	}
+	a.SensitiveFields = append(a.SensitiveFields, sensitive...)
+	return nil
+}
+
+// hasInjections returns true if any of the resources
+// has the InjectAnnotation.
+func hasInjections(infos []*resource.Info) bool {
+	for _, info := range infos {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			continue
+		}
+		if _, found := accessor.GetAnnotations()[InjectAnnotation]; found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestParseInjections(t *testing.T) {
+	testCases := map[string]struct {
+		value       string
+		expected    []string
+		expectedErr bool
+	}{
+		"single": {
+			value:    "data.domain=ConfigMap/kube-system/cluster-info/domain",
+			expected: []string{"data.domain=ConfigMap/kube-system/cluster-info/domain"},
+		},
+		"several": {
+			value: " spec.host=ConfigMap/kube-system/cluster-info/domain, data.token=Secret/app/creds/token,",
+			expected: []string{
+				"spec.host=ConfigMap/kube-system/cluster-info/domain",
+				"data.token=Secret/app/creds/token",
+			},
+		},
+		"no source": {
+			value:       "data.domain",
+			expectedErr: true,
+		},
+		"empty path element": {
+			value:       "data..domain=ConfigMap/kube-system/cluster-info/domain",
+			expectedErr: true,
+		},
+		"no key": {
+			value:       "data.domain=ConfigMap/kube-system/cluster-info",
+			expectedErr: true,
+		},
+		"unsupported kind": {
+			value:       "data.domain=Service/kube-system/dns/domain",
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			injections, err := ParseInjections(tc.value)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			var actual []string
+			for _, i := range injections {
+				actual = append(actual, i.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func injectInfo(annotation string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("app")
+	if annotation != "" {
+		obj.SetAnnotations(map[string]string{InjectAnnotation: annotation})
+	}
+	return &resource.Info{Namespace: "default", Name: "app", Object: obj}
+}
+
+func TestInjectValues(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "cluster-info"},
+			Data:       map[string]string{"domain": "example.com", "region": "eu-west-1"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds"},
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		},
+	)
+
+	testCases := map[string]struct {
+		annotation        string
+		expected          map[string]interface{}
+		expectedSensitive []object.SensitiveField
+		expectedErr       bool
+	}{
+		"no annotation": {
+			expected: map[string]interface{}{"existing": "value"},
+		},
+		"configmap and secret": {
+			annotation: "data.domain=ConfigMap/kube-system/cluster-info/domain," +
+				"data.region=ConfigMap/kube-system/cluster-info/region,data.token=Secret/default/creds/token",
+			expected: map[string]interface{}{
+				"existing": "value",
+				"domain":   "example.com",
+				"region":   "eu-west-1",
+				"token":    "s3cr3t",
+			},
+			expectedSensitive: []object.SensitiveField{
+				{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Path: []string{"data", "token"}},
+			},
+		},
+		"missing source": {
+			annotation:  "data.domain=ConfigMap/kube-system/missing/domain",
+			expectedErr: true,
+		},
+		"missing key": {
+			annotation:  "data.domain=ConfigMap/kube-system/cluster-info/zone",
+			expectedErr: true,
+		},
+		"field is not a map": {
+			annotation:  "data.existing.nested=ConfigMap/kube-system/cluster-info/domain",
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			info := injectInfo(tc.annotation)
+			u := info.Object.(*unstructured.Unstructured)
+			u.Object["data"] = map[string]interface{}{"existing": "value"}
+			sensitive, err := injectValues(client, []*resource.Info{info})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expected, u.Object["data"])
+			assert.Equal(t, tc.expectedSensitive, sensitive)
+		})
+	}
+}
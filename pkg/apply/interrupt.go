@@ -0,0 +1,40 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// WithInterrupt returns a context that is cancelled when the process is
+// interrupted, so a run can stop applying resources and record what it
+// applied in the inventory, instead of being terminated halfway. A
+// message telling so is printed to the writer. A second interrupt
+// terminates the process right away. The returned function stops
+// listening for interrupts, and must be called once the run is done.
+func WithInterrupt(ctx context.Context, w io.Writer) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		select {
+		case <-signals:
+			// Stopping the notifications restores the default
+			// handling, which terminates the process.
+			signal.Stop(signals)
+			fmt.Fprintln(w, "Interrupted, waiting for the requests in flight to finish. "+
+				"Interrupt again to exit right away.")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
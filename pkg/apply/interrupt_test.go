@@ -0,0 +1,42 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to the process on windows")
+	}
+	var out bytes.Buffer
+	ctx, cancel := WithInterrupt(context.Background(), &out)
+	defer cancel()
+
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(os.Interrupt))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("context was not cancelled by the interrupt")
+	}
+	assert.Contains(t, out.String(), "Interrupted")
+}
+
+func TestWithInterruptCancel(t *testing.T) {
+	ctx, cancel := WithInterrupt(context.Background(), &bytes.Buffer{})
+	cancel()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
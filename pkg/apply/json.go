@@ -0,0 +1,71 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+)
+
+// stdinManifest is the name of the file the manifests read
+// from stdin are written to.
+const stdinManifest = "stdin.yaml"
+
+// readStdin copies the manifests from stdin to the temporary directory,
+// so they are normalized like the ones in files. The manifests are
+// copied as they are read, so they are never all in memory.
+func (r *remoteManifests) readStdin(index int) (string, error) {
+	f, err := r.create(fmt.Sprintf("%d-%s", index, stdinManifest))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		return "", fmt.Errorf("error reading stdin: %v", err)
+	}
+	return f.Name(), f.Close()
+}
+
+// isJSONArray returns true if the first character of the
+// content that is not whitespace starts a JSON array.
+func isJSONArray(reader *bufio.Reader) (bool, error) {
+	for {
+		c, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !unicode.IsSpace(c) {
+			return c == '[', reader.UnreadRune()
+		}
+	}
+}
+
+// decodeJSONArray calls fn with every object in the JSON array, one at
+// a time, so the array is never all in memory.
+func decodeJSONArray(reader io.Reader, fn func(obj map[string]interface{}) error) error {
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("invalid JSON array of objects: %v", err)
+	}
+	for decoder.More() {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			return fmt.Errorf("invalid JSON array of objects: %v", err)
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("invalid JSON array of objects: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// JSONEventVersion is the version of the schema for the events
+// printed by the JSONPrinter. It must be changed whenever a field
+// is removed or changes meaning, so consumers can detect it.
+const JSONEventVersion = "v1"
+
+// JSONEvent is the representation of a single event printed by
+// the JSONPrinter. Every event is printed as a JSON object on a
+// separate line, so the output can be parsed as a stream.
+type JSONEvent struct {
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
+	// Started and Duration are only set for events that report
+	// the end of some work. The duration is given in seconds.
+	Started  string  `json:"started,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	// Type is one of apply, status, prune, delete, diff, progress or error.
+	Type string `json:"type"`
+	// EventType describes what happened, for example resourceApplied
+	// for a single resource or completed when a step has finished. For
+	// progress events it is the phase: apply, wait, prune or delete.
+	EventType string `json:"eventType"`
+	Operation string `json:"operation,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Reason is the classification of the error, for example
+	// Validation or Conflict. It is only set for error events.
+	Reason string `json:"reason,omitempty"`
+	// Diff is only set for events with the diff type.
+	Diff string `json:"diff,omitempty"`
+	// Progress is only set for events with the progress type.
+	Progress *JSONProgress `json:"progress,omitempty"`
+}
+
+// JSONProgress is the representation of a ProgressEvent. The ETA
+// is given in seconds and is zero if no estimate is available.
+type JSONProgress struct {
+	Completed int     `json:"completed"`
+	Total     int     `json:"total"`
+	ETA       float64 `json:"eta"`
+}
+
+// JSONPrinter prints every event from the channel as a JSON
+// object on its own line (JSON Lines).
+type JSONPrinter struct {
+	IOStreams genericclioptions.IOStreams
+}
+
+// Print outputs the events from the provided channel as JSON
+// Lines on StdOut. This function will block until the channel
+// is closed.
+func (j *JSONPrinter) Print(ch <-chan event.Event) {
+	for e := range ch {
+		je := toJSONEvent(e)
+		b, err := json.Marshal(je)
+		CheckErr(j.IOStreams.ErrOut, err)
+		fmt.Fprintf(j.IOStreams.Out, "%s\n", string(b))
+		if e.Type == event.ErrorType {
+			CheckErr(j.IOStreams.ErrOut, e.ErrorEvent.Err)
+		}
+	}
+}
+
+func toJSONEvent(e event.Event) JSONEvent {
+	timestamp := e.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	je := JSONEvent{
+		Version:   JSONEventVersion,
+		Timestamp: timestamp.UTC().Format(time.RFC3339Nano),
+	}
+	if !e.Started.IsZero() {
+		je.Started = e.Started.UTC().Format(time.RFC3339Nano)
+		je.Duration = timestamp.Sub(e.Started).Seconds()
+	}
+	switch e.Type {
+	case event.ErrorType:
+		je.Type = "error"
+		je.EventType = "error"
+		if e.ErrorEvent.Identifier != nil {
+			setIdentifier(&je, *e.ErrorEvent.Identifier)
+		}
+		if e.ErrorEvent.Err != nil {
+			je.Error = e.ErrorEvent.Err.Error()
+			je.Reason = string(ReasonForError(e.ErrorEvent.Err))
+		}
+	case event.ApplyType:
+		je.Type = "apply"
+		if e.ApplyEvent.Type == event.ApplyEventCompleted {
+			je.EventType = "completed"
+		} else {
+			je.EventType = "resourceApplied"
+			je.Operation = strings.ToLower(e.ApplyEvent.Operation.String())
+			setIdentifier(&je, e.ApplyEvent.Identifier)
+		}
+	case event.StatusType:
+		je.Type = "status"
+		se := e.StatusEvent
+		switch se.EventType {
+		case pollevent.ResourceUpdateEvent:
+			je.EventType = "resourceStatus"
+			id := se.Resource.Identifier
+			setIdentifier(&je, object.ObjMetadata{
+				Namespace: id.Namespace,
+				Name:      id.Name,
+				GroupKind: id.GroupKind,
+			})
+			je.Status = se.Resource.Status.String()
+			je.Message = se.Resource.Message
+			if se.Resource.Error != nil {
+				je.Error = se.Resource.Error.Error()
+			}
+		case pollevent.CompletedEvent:
+			je.EventType = "completed"
+			je.Status = se.AggregateStatus.String()
+		case pollevent.AbortedEvent:
+			je.EventType = "aborted"
+			je.Status = se.AggregateStatus.String()
+		case pollevent.ErrorEvent:
+			je.EventType = "error"
+			if se.Error != nil {
+				je.Error = se.Error.Error()
+			}
+		}
+	case event.PruneType:
+		je.Type = "prune"
+		if e.PruneEvent.Type == event.PruneEventCompleted {
+			je.EventType = "completed"
+		} else {
+			je.EventType = "resourcePruned"
+			if e.PruneEvent.Operation == event.PruneSkipped {
+				je.Operation = "skipped"
+			}
+			setIdentifier(&je, e.PruneEvent.Identifier)
+		}
+	case event.DiffType:
+		je.Type = "diff"
+		je.EventType = "resourceDiff"
+		setIdentifier(&je, e.DiffEvent.Identifier)
+		je.Diff = e.DiffEvent.Diff
+		if e.DiffEvent.Prune {
+			je.Operation = "prune"
+		}
+	case event.ProgressType:
+		je.Type = "progress"
+		pe := e.ProgressEvent
+		je.EventType = strings.TrimSuffix(strings.ToLower(pe.Phase.String()), "phase")
+		je.Progress = &JSONProgress{
+			Completed: pe.Completed,
+			Total:     pe.Total,
+			ETA:       pe.ETA.Seconds(),
+		}
+	case event.DeleteType:
+		je.Type = "delete"
+		if e.DeleteEvent.Type == event.DeleteEventCompleted {
+			je.EventType = "completed"
+		} else {
+			je.EventType = "resourceDeleted"
+			setIdentifier(&je, e.DeleteEvent.Identifier)
+		}
+	}
+	return je
+}
+
+func setIdentifier(je *JSONEvent, id object.ObjMetadata) {
+	je.Group = id.GroupKind.Group
+	je.Kind = id.GroupKind.Kind
+	je.Namespace = id.Namespace
+	je.Name = id.Name
+}
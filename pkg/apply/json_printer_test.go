@@ -0,0 +1,172 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestJSONPrinter(t *testing.T) {
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	deploymentID := object.ObjMetadata{
+		Namespace: "default",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+
+	events := []event.Event{
+		{
+			Type:      event.ApplyType,
+			Timestamp: timestamp,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Configured,
+				Identifier: deploymentID,
+			},
+		},
+		{
+			Type:      event.ApplyType,
+			Timestamp: timestamp,
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		{
+			Type:      event.StatusType,
+			Timestamp: timestamp,
+			StatusEvent: pollevent.Event{
+				EventType:       pollevent.ResourceUpdateEvent,
+				AggregateStatus: status.InProgressStatus,
+				Resource: &pollevent.ObservedResource{
+					Identifier: wait.ResourceIdentifier{
+						GroupKind: deploymentID.GroupKind,
+						Namespace: deploymentID.Namespace,
+						Name:      deploymentID.Name,
+					},
+					Status:  status.InProgressStatus,
+					Message: "Replicas: 0/1",
+				},
+			},
+		},
+		{
+			Type:      event.PruneType,
+			Timestamp: timestamp,
+			PruneEvent: event.PruneEvent{
+				Type:       event.PruneEventResourceUpdate,
+				Identifier: deploymentID,
+			},
+		},
+		{
+			Type:      event.ProgressType,
+			Timestamp: timestamp,
+			ProgressEvent: event.ProgressEvent{
+				Phase:     event.PrunePhase,
+				Completed: 1,
+				Total:     2,
+				ETA:       1500 * time.Millisecond,
+			},
+		},
+	}
+
+	expected := []JSONEvent{
+		{
+			Version:   JSONEventVersion,
+			Timestamp: "2020-01-02T03:04:05Z",
+			Type:      "apply",
+			EventType: "resourceApplied",
+			Operation: "configured",
+			Group:     "apps",
+			Kind:      "Deployment",
+			Namespace: "default",
+			Name:      "foo",
+		},
+		{
+			Version:   JSONEventVersion,
+			Timestamp: "2020-01-02T03:04:05Z",
+			Type:      "apply",
+			EventType: "completed",
+		},
+		{
+			Version:   JSONEventVersion,
+			Timestamp: "2020-01-02T03:04:05Z",
+			Type:      "status",
+			EventType: "resourceStatus",
+			Group:     "apps",
+			Kind:      "Deployment",
+			Namespace: "default",
+			Name:      "foo",
+			Status:    "InProgress",
+			Message:   "Replicas: 0/1",
+		},
+		{
+			Version:   JSONEventVersion,
+			Timestamp: "2020-01-02T03:04:05Z",
+			Type:      "prune",
+			EventType: "resourcePruned",
+			Group:     "apps",
+			Kind:      "Deployment",
+			Namespace: "default",
+			Name:      "foo",
+		},
+		{
+			Version:   JSONEventVersion,
+			Timestamp: "2020-01-02T03:04:05Z",
+			Type:      "progress",
+			EventType: "prune",
+			Progress: &JSONProgress{
+				Completed: 1,
+				Total:     2,
+				ETA:       1.5,
+			},
+		},
+	}
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	printer := &JSONPrinter{IOStreams: ioStreams}
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		for _, e := range events {
+			ch <- e
+		}
+	}()
+	printer.Print(ch)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, len(expected))
+	for i, line := range lines {
+		var je JSONEvent
+		err := json.Unmarshal([]byte(line), &je)
+		assert.NoError(t, err)
+		assert.Equal(t, expected[i], je)
+	}
+}
+
+func TestGetPrinter(t *testing.T) {
+	ioStreams, _, _, _ := genericclioptions.NewTestIOStreams()
+	for _, output := range SupportedOutputs() {
+		t.Run(output, func(t *testing.T) {
+			printer, err := GetPrinter(output, ioStreams)
+			assert.NoError(t, err)
+			assert.NotNil(t, printer)
+		})
+	}
+
+	_, err := GetPrinter("yaml", ioStreams)
+	assert.EqualError(t, err, fmt.Sprintf("unknown output format %q, must be one of %v",
+		"yaml", SupportedOutputs()))
+}
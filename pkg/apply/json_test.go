@@ -0,0 +1,143 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestJSONManifests(t *testing.T) {
+	testCases := map[string]struct {
+		filename      string
+		content       string
+		expectedNames []string
+		expectedErr   bool
+	}{
+		"single object": {
+			filename:      "cm.json",
+			content:       `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`,
+			expectedNames: []string{"a"},
+		},
+		"stream of objects": {
+			filename: "cms.json",
+			content: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "b"}}`,
+			expectedNames: []string{"a", "b"},
+		},
+		"array of objects": {
+			filename: "cms.json",
+			content: `
+[
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}},
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "b"}}
+]`,
+			expectedNames: []string{"a", "b"},
+		},
+		"invalid array": {
+			filename:    "cms.json",
+			content:     `[1, 2]`,
+			expectedErr: true,
+		},
+		"yaml is unchanged": {
+			filename:      "cm.yaml",
+			content:       "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			expectedNames: []string{"a"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "json-test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, tc.filename)
+			if !assert.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0600)) {
+				return
+			}
+
+			r := newRemoteManifests(false)
+			defer r.cleanup()
+			filenames := []string{path}
+			fileNameFlags, err := r.resolvePaths(nil, &genericclioptions.FileNameFlags{
+				Filenames: &filenames,
+			}, HelmOptions{})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			tf := cmdtesting.NewTestFactory()
+			defer tf.Cleanup()
+			infos, err := tf.NewBuilder().
+				Local().
+				Unstructured().
+				FilenameParam(false, &resource.FilenameOptions{Filenames: *fileNameFlags.Filenames}).
+				Flatten().
+				Do().
+				Infos()
+			if !assert.NoError(t, err) {
+				return
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestDecodeJSONArray(t *testing.T) {
+	testCases := map[string]struct {
+		content       string
+		expectedNames []string
+		expectedErr   bool
+	}{
+		"empty array": {
+			content: `[]`,
+		},
+		"objects": {
+			content:       `[{"metadata": {"name": "a"}}, {"metadata": {"name": "b"}}]`,
+			expectedNames: []string{"a", "b"},
+		},
+		"not an object": {
+			content:     `[{"metadata": {"name": "a"}}, 1]`,
+			expectedErr: true,
+		},
+		"unterminated": {
+			content:     `[{"metadata": {"name": "a"}}`,
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var names []string
+			err := decodeJSONArray(strings.NewReader(tc.content), func(obj map[string]interface{}) error {
+				names = append(names, obj["metadata"].(map[string]interface{})["name"].(string))
+				return nil
+			})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
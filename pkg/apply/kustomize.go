@@ -0,0 +1,51 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// kustomizedManifest is the name of the file the output of the
+// kustomization is written to.
+const kustomizedManifest = "kustomized.yaml"
+
+// kustomize builds the kustomization in the directory and writes the
+// output to the temporary directory, so it can be read like any other
+// manifest. If requireGrouping is true, the output must contain the
+// grouping object template, since there are no other manifests it
+// could come from.
+func (r *remoteManifests) kustomize(dir string, requireGrouping bool) (string, error) {
+	k := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions())
+	resMap, err := k.Run(dir)
+	if err != nil {
+		return "", fmt.Errorf("error building kustomization %s: %v", dir, err)
+	}
+	if requireGrouping && !hasGroupingObject(resMap.Resources()) {
+		return "", fmt.Errorf("kustomization %s does not contain a grouping object template; "+
+			"add a ConfigMap with the %s label to its resources", dir, prune.GroupingLabel)
+	}
+	content, err := resMap.AsYaml()
+	if err != nil {
+		return "", fmt.Errorf("error building kustomization %s: %v", dir, err)
+	}
+	return r.write(kustomizedManifest, content)
+}
+
+// hasGroupingObject returns true if any of the kustomized
+// resources is a grouping object.
+func hasGroupingObject(resources []*resource.Resource) bool {
+	for _, res := range resources {
+		if prune.IsGroupingTemplate(&unstructured.Unstructured{Object: res.Map()}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const kustomizeGroupingObject = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: test-inventory
+`
+
+const kustomizeDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+spec:
+  replicas: 1
+`
+
+func TestKustomize(t *testing.T) {
+	testCases := map[string]struct {
+		files         map[string]string
+		filenames     []string
+		expectedNames []string
+		expectedErr   bool
+	}{
+		"kustomization with grouping object": {
+			files: map[string]string{
+				"kustomization.yaml": "namePrefix: test-\nresources:\n- inventory.yaml\n- deployment.yaml\n",
+				"inventory.yaml":     kustomizeGroupingObject,
+				"deployment.yaml":    kustomizeDeployment,
+			},
+			expectedNames: []string{"name: test-inventory", "name: test-foo"},
+		},
+		"grouping object from -f": {
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- deployment.yaml\n",
+				"deployment.yaml":    kustomizeDeployment,
+			},
+			filenames:     []string{"inventory.yaml"},
+			expectedNames: []string{"name: foo"},
+		},
+		"no grouping object": {
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- deployment.yaml\n",
+				"deployment.yaml":    kustomizeDeployment,
+			},
+			expectedErr: true,
+		},
+		"invalid kustomization": {
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- missing.yaml\n",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kustomize-test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)) {
+					return
+				}
+			}
+
+			r := newRemoteManifests(false)
+			defer r.cleanup()
+			filenames := tc.filenames
+			flags := &genericclioptions.FileNameFlags{
+				Filenames: &filenames,
+				Kustomize: &dir,
+			}
+			fileNameFlags, err := r.resolvePaths(nil, flags, HelmOptions{})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Nil(t, fileNameFlags.Kustomize)
+			resolved := *fileNameFlags.Filenames
+			if !assert.Len(t, resolved, len(tc.filenames)+1) {
+				return
+			}
+			content, err := ioutil.ReadFile(resolved[len(resolved)-1])
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, name := range tc.expectedNames {
+				assert.True(t, strings.Contains(string(content), name), "expected %q in %s", name, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/kubectl/pkg/cmd/util"
+)
+
+// Default timings of the leader election, the same as the ones
+// of the controllers of Kubernetes.
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElection coordinates the replicas of a controller that embeds
+// the Applier, so only one of them applies and prunes an inventory at a
+// time. The replicas take turns holding a Lease in the cluster. The
+// commands don't use it, since they are run once by a single user.
+//
+//	election := apply.NewLeaderElection(factory, namespace, inventoryID)
+//	err := election.Run(ctx, func(ctx context.Context) {
+//		for e := range applier.Run(ctx) {
+//			...
+//		}
+//	})
+type LeaderElection struct {
+	// Namespace and Name identify the Lease used as the lock.
+	Namespace string
+	Name      string
+	// Identity tells the replicas apart. The hostname with a
+	// random suffix is used if it is empty.
+	Identity string
+	// LeaseDuration is how long the other replicas wait before
+	// taking over a lock that is no longer renewed.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the replica holding the lock tries
+	// to renew it before giving up.
+	RenewDeadline time.Duration
+	// RetryPeriod is the time between two attempts to acquire
+	// or renew the lock.
+	RetryPeriod time.Duration
+
+	factory util.Factory
+}
+
+// NewLeaderElection returns a LeaderElection for the inventory with
+// the given ID, with a Lease in the namespace as the lock.
+func NewLeaderElection(factory util.Factory, namespace, inventoryID string) *LeaderElection {
+	return &LeaderElection{
+		Namespace:     namespace,
+		Name:          inventoryObjectName("lock", inventoryID),
+		LeaseDuration: DefaultLeaseDuration,
+		RenewDeadline: DefaultRenewDeadline,
+		RetryPeriod:   DefaultRetryPeriod,
+		factory:       factory,
+	}
+}
+
+// Run blocks until this replica holds the lock, and then calls run.
+// The context passed to run is cancelled if the lock is lost, so a run
+// of the Applier stops applying resources before another replica can
+// take over. The lock is released once run returns, and so is Run.
+// Run returns an error if the lock was lost, and the error of the
+// context if it was cancelled before the lock was acquired.
+func (l *LeaderElection) Run(ctx context.Context, run func(ctx context.Context)) error {
+	lock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	// The election only stops once run has returned, even if ctx is
+	// cancelled, since the lock must be held while resources are
+	// still being applied.
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	defer stopElection()
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   l.LeaseDuration,
+		RenewDeadline:   l.RenewDeadline,
+		RetryPeriod:     l.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            l.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				close(started)
+				defer close(finished)
+				defer stopElection()
+				if ctx.Err() != nil {
+					return
+				}
+				runCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				go func() {
+					select {
+					case <-leaderCtx.Done():
+						cancel()
+					case <-runCtx.Done():
+					}
+				}()
+				run(runCtx)
+			},
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Stop waiting for the lock, unless run has started.
+			select {
+			case <-started:
+			default:
+				stopElection()
+			}
+		case <-electionCtx.Done():
+		}
+	}()
+	elector.Run(electionCtx)
+	if electionCtx.Err() == nil {
+		// The lock was lost. Wait for run to return, so the
+		// caller can't start again while it is still applying.
+		<-finished
+		return fmt.Errorf("lost the lock %s/%s", l.Namespace, l.Name)
+	}
+	select {
+	case <-started:
+		<-finished
+		return nil
+	default:
+		return ctx.Err()
+	}
+}
+
+// lock returns the Lease lock with the identity of the replica.
+func (l *LeaderElection) lock() (resourcelock.Interface, error) {
+	clientset, err := l.factory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	identity := l.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		identity = hostname + "_" + string(uuid.NewUUID())
+	}
+	return resourcelock.New(resourcelock.LeasesResourceLock, l.Namespace, l.Name,
+		clientset.CoreV1(), clientset.CoordinationV1(), resourcelock.ResourceLockConfig{
+			Identity: identity,
+		})
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// inventoryObjectName returns the name of an object kept for the
+// inventory, like the Lease for its lock. Inventory IDs are label
+// values, which can contain characters that are not allowed in names,
+// so a hash of the ID keeps the names of different inventories apart.
+func inventoryObjectName(kind, inventoryID string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(inventoryID))
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(inventoryID), "-"), "-")
+	if name == "" {
+		return fmt.Sprintf("inventory-%s-%08x", kind, h.Sum32())
+	}
+	return fmt.Sprintf("inventory-%s-%s-%08x", kind, name, h.Sum32())
+}
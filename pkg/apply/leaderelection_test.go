@@ -0,0 +1,132 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func newTestLeaderElection(cluster *fakecluster.Cluster, identity string) *LeaderElection {
+	l := NewLeaderElection(cluster.Factory(), fakecluster.DefaultNamespace, "test")
+	l.Identity = identity
+	l.LeaseDuration = time.Second
+	l.RenewDeadline = 500 * time.Millisecond
+	l.RetryPeriod = 50 * time.Millisecond
+	return l
+}
+
+func newLeaseCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	cluster.AddKind(fakecluster.Kind{
+		GroupVersionKind: schema.GroupVersionKind{Group: "coordination.k8s.io", Version: "v1", Kind: "Lease"},
+		Namespaced:       true,
+	})
+	return cluster
+}
+
+func TestLeaderElectionRunsOneAtATime(t *testing.T) {
+	cluster := newLeaseCluster(t)
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	var order []string
+	run := func(identity string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			order = append(order, identity)
+			mu.Unlock()
+			time.Sleep(200 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, identity := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(i int, identity string) {
+			defer wg.Done()
+			errs[i] = newTestLeaderElection(cluster, identity).Run(context.Background(), run(identity))
+		}(i, identity)
+	}
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Equal(t, 1, maxRunning)
+	assert.ElementsMatch(t, []string{"a", "b"}, order)
+}
+
+func TestLeaderElectionCancelledWhileWaiting(t *testing.T) {
+	cluster := newLeaseCluster(t)
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- newTestLeaderElection(cluster, "a").Run(context.Background(), func(context.Context) {
+			close(holding)
+			<-release
+		})
+	}()
+	<-holding
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	called := false
+	err := newTestLeaderElection(cluster, "b").Run(ctx, func(context.Context) {
+		called = true
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, called)
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestInventoryObjectName(t *testing.T) {
+	testCases := map[string]struct {
+		inventoryID string
+		prefix      string
+	}{
+		"valid name": {
+			inventoryID: "my-app",
+			prefix:      "inventory-lock-my-app-",
+		},
+		"invalid characters": {
+			inventoryID: "My_App.v1",
+			prefix:      "inventory-lock-my-app-v1-",
+		},
+		"only invalid characters": {
+			inventoryID: "__",
+			prefix:      "inventory-lock-",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			name := inventoryObjectName("lock", tc.inventoryID)
+			assert.Contains(t, name, tc.prefix)
+			assert.Empty(t, validation.IsDNS1123Subdomain(name))
+		})
+	}
+	assert.NotEqual(t, inventoryObjectName("lock", "my_app"), inventoryObjectName("lock", "my-app"))
+}
@@ -0,0 +1,250 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestExtensions are the extensions of the files the
+// builder reads from directories.
+var manifestExtensions = []string{".json", ".yaml", ".yml"}
+
+// normalize returns the paths with every manifest the builder can't read
+// as is replaced by a file it can read: JSON arrays of objects and Lists
+// that contain other Lists are expanded into the objects, since the
+// builder can't read arrays and only flattens a single level of Lists. Directories
+// that contain such manifests are replaced by their manifests, in the
+// order the builder reads them. Other paths are returned unchanged.
+func (r *remoteManifests) normalize(paths []string, recursive bool) ([]string, error) {
+	var normalized []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			// The builder reports the error.
+			normalized = append(normalized, path)
+			continue
+		}
+		if !info.IsDir() {
+			path, _, err := r.normalizeFile(path)
+			if err != nil {
+				return nil, err
+			}
+			normalized = append(normalized, path)
+			continue
+		}
+		files, err := manifestFiles(path, recursive)
+		if err != nil {
+			return nil, err
+		}
+		var dirPaths []string
+		changed := false
+		for _, file := range files {
+			filePath, fileChanged, err := r.normalizeFile(file)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || fileChanged
+			dirPaths = append(dirPaths, filePath)
+		}
+		if !changed {
+			dirPaths = []string{path}
+		}
+		normalized = append(normalized, dirPaths...)
+	}
+	return normalized, nil
+}
+
+// manifestFiles returns the manifests in the directory the way the
+// builder finds them: in lexical order, only in subdirectories if
+// recursive is true, and only files with a manifest extension.
+func manifestFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(path)
+		for _, e := range manifestExtensions {
+			if ext == e {
+				files = append(files, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", dir, err)
+	}
+	return files, nil
+}
+
+// normalizeFile returns the path of a file with the variables substituted
+// and the manifests expanded, and true if the file had to be changed.
+// Otherwise it returns the path unchanged.
+func (r *remoteManifests) normalizeFile(path string) (string, bool, error) {
+	substituted := false
+	if r.variables != nil {
+		var err error
+		if path, err = r.substituteFile(path); err != nil {
+			return "", false, err
+		}
+		substituted = true
+	}
+	path, expanded, err := r.expandFile(path)
+	return path, substituted || expanded, err
+}
+
+// expandFile returns the path of a file with the expanded manifests
+// and true if the manifests in the file had to be changed. Otherwise it
+// returns the path unchanged. The manifests are decoded one at a time,
+// so large files are never all in memory.
+func (r *remoteManifests) expandFile(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	isArray, err := isJSONArray(reader)
+	if err != nil {
+		return "", false, err
+	}
+	if !isArray {
+		nested, err := hasNestedLists(reader)
+		if err != nil || !nested {
+			// The builder reports the error with more context.
+			return path, false, nil
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", false, err
+		}
+		reader.Reset(f)
+	}
+
+	r.normalized++
+	out, err := r.create(fmt.Sprintf("normalized-%d-%s", r.normalized, filepath.Base(path)))
+	if err != nil {
+		return "", false, err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	writeObjects := func(obj map[string]interface{}) error {
+		for _, o := range flattenList(obj) {
+			if err := writeDocument(w, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if isArray {
+		err = decodeJSONArray(reader, writeObjects)
+	} else {
+		err = decodeDocuments(reader, writeObjects)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		return "", false, err
+	}
+	return out.Name(), true, out.Close()
+}
+
+// hasNestedLists returns true if any List in the
+// manifests contains another List.
+func hasNestedLists(reader io.Reader) (bool, error) {
+	nested := false
+	err := decodeDocuments(reader, func(doc map[string]interface{}) error {
+		items, _ := listItems(doc)
+		for _, item := range items {
+			if _, ok := listItems(item); ok {
+				nested = true
+			}
+		}
+		return nil
+	})
+	return nested, err
+}
+
+// decodeDocuments calls fn with every YAML document or JSON
+// object in the manifests, skipping empty documents.
+func decodeDocuments(reader io.Reader, fn func(doc map[string]interface{}) error) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// writeDocument writes the object as a YAML document.
+func writeDocument(w io.Writer, obj map[string]interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "---\n"); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// flattenList returns the objects in the List and in
+// the Lists it contains.
+func flattenList(obj map[string]interface{}) []map[string]interface{} {
+	items, isList := listItems(obj)
+	if !isList {
+		return []map[string]interface{}{obj}
+	}
+	var objs []map[string]interface{}
+	for _, item := range items {
+		objs = append(objs, flattenList(item)...)
+	}
+	return objs
+}
+
+// listItems returns the items of the object and true if it is a List,
+// like v1 List or any other kind ending in List with an items field.
+func listItems(obj map[string]interface{}) ([]map[string]interface{}, bool) {
+	kind, _ := obj["kind"].(string)
+	rawItems, found := obj["items"]
+	if !strings.HasSuffix(kind, "List") || !found {
+		return nil, false
+	}
+	list, _ := rawItems.([]interface{})
+	var items []map[string]interface{}
+	for _, rawItem := range list {
+		if item, ok := rawItem.(map[string]interface{}); ok {
+			items = append(items, item)
+		}
+	}
+	return items, true
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+var nestedList = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: b
+  - apiVersion: v1
+    kind: ConfigMapList
+    items:
+    - apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: c
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: d
+`
+
+var flatList = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: e
+`
+
+var singleObject = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: f
+`
+
+func TestNormalizeLists(t *testing.T) {
+	testCases := map[string]struct {
+		files            map[string]string
+		filename         string
+		recursive        bool
+		expectedNames    []string
+		expectedResolved int
+		unchanged        bool
+	}{
+		"nested lists in a file": {
+			files:         map[string]string{"list.yaml": nestedList},
+			filename:      "list.yaml",
+			expectedNames: []string{"a", "b", "c", "d"},
+		},
+		"flat list is unchanged": {
+			files:         map[string]string{"list.yaml": flatList},
+			filename:      "list.yaml",
+			expectedNames: []string{"e"},
+			unchanged:     true,
+		},
+		"directory without nested lists is unchanged": {
+			files: map[string]string{
+				"a.yaml": flatList,
+				"b.yaml": singleObject,
+			},
+			expectedNames: []string{"e", "f"},
+			unchanged:     true,
+		},
+		"directory with nested lists": {
+			files: map[string]string{
+				"a.yaml":     nestedList,
+				"b.yaml":     singleObject,
+				"README.md":  "not a manifest",
+				"sub/c.yaml": flatList,
+			},
+			expectedNames:    []string{"a", "b", "c", "d", "f"},
+			expectedResolved: 2,
+		},
+		"recursive directory with nested lists": {
+			files: map[string]string{
+				"a.yaml":     nestedList,
+				"b.yaml":     singleObject,
+				"sub/c.yaml": flatList,
+			},
+			recursive:        true,
+			expectedNames:    []string{"a", "b", "c", "d", "f", "e"},
+			expectedResolved: 3,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "list-test")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) ||
+					!assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+					return
+				}
+			}
+
+			r := newRemoteManifests(false)
+			defer r.cleanup()
+			filenames := []string{filepath.Join(dir, tc.filename)}
+			fileNameFlags, err := r.resolvePaths(nil, &genericclioptions.FileNameFlags{
+				Filenames: &filenames,
+				Recursive: &tc.recursive,
+			}, HelmOptions{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			resolved := *fileNameFlags.Filenames
+			if tc.unchanged {
+				assert.Equal(t, filenames, resolved)
+			} else if tc.expectedResolved > 0 {
+				assert.Len(t, resolved, tc.expectedResolved)
+			}
+
+			tf := cmdtesting.NewTestFactory()
+			defer tf.Cleanup()
+			infos, err := tf.NewBuilder().
+				Local().
+				Unstructured().
+				FilenameParam(false, &resource.FilenameOptions{
+					Filenames: resolved,
+					Recursive: tc.recursive,
+				}).
+				Flatten().
+				Do().
+				Infos()
+			if !assert.NoError(t, err) {
+				return
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// logRecord is a message logged through a recordingLogger.
+type logRecord struct {
+	name string
+	msg  string
+	err  error
+}
+
+// recordingLogger records the messages logged at every level.
+type recordingLogger struct {
+	name    string
+	mu      *sync.Mutex
+	records *[]logRecord
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mu: &sync.Mutex{}, records: &[]logRecord{}}
+}
+
+func (l *recordingLogger) record(msg string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.records = append(*l.records, logRecord{name: l.name, msg: msg, err: err})
+}
+
+func (l *recordingLogger) Info(msg string, _ ...interface{}) { l.record(msg, nil) }
+
+func (l *recordingLogger) Error(err error, msg string, _ ...interface{}) { l.record(msg, err) }
+
+func (l *recordingLogger) Enabled() bool { return true }
+
+func (l *recordingLogger) V(int) logr.InfoLogger { return l }
+
+func (l *recordingLogger) WithValues(...interface{}) logr.Logger { return l }
+
+func (l *recordingLogger) WithName(name string) logr.Logger {
+	c := *l
+	if c.name != "" {
+		name = c.name + "." + name
+	}
+	c.name = name
+	return &c
+}
+
+// messages returns the messages logged under the name.
+func (l *recordingLogger) messages(name string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var msgs []string
+	for _, r := range *l.records {
+		if r.name == name {
+			msgs = append(msgs, r.msg)
+		}
+	}
+	return msgs
+}
+
+func TestApplierLogger(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	logger := newRecordingLogger()
+	inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+
+	for _, objs := range [][]*unstructured.Unstructured{
+		{inventory, configMap("a", nil), configMap("b", nil)},
+		// b is pruned by the second run.
+		{inventory, configMap("a", nil)},
+	} {
+		applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+			WithNoWait())
+		require.NoError(t, err)
+		applier.Logger = logger
+		cmd := &cobra.Command{}
+		require.NoError(t, applier.SetFlags(cmd))
+		cmdutil.AddValidateFlags(cmd)
+		cmdutil.AddServerSideApplyFlags(cmd)
+		require.NoError(t, cmd.Flags().Set("filename", "-"))
+		require.NoError(t, applier.Initialize(cmd, nil))
+		for e := range applier.RunObjects(context.Background(), objs) {
+			require.NotEqual(t, event.ErrorType, e.Type, "unexpected error: %v", e.ErrorEvent.Err)
+		}
+	}
+
+	assert.NotEmpty(t, logger.messages(""))
+	assert.Contains(t, logger.messages("prune"), "pruning resource")
+}
+
+func TestNullLoggerFallback(t *testing.T) {
+	applier := NewApplier(nil, genericclioptions.NewTestIOStreamsDiscard())
+	assert.Equal(t, log.NullLogger{}, applier.logger())
+	destroyer := NewDestroyer(nil, genericclioptions.NewTestIOStreamsDiscard())
+	assert.Equal(t, log.NullLogger{}, destroyer.logger())
+
+	logger := newRecordingLogger()
+	applier.Logger = logger
+	assert.Equal(t, logger, applier.logger())
+	destroyer.Logger = logger
+	assert.Equal(t, logger, destroyer.logger())
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// helmReleaseAnnotation is set by Helm on the resources of a release.
+const helmReleaseAnnotation = "meta.helm.sh/release-name"
+
+// ForeignManagers is a resource of the package whose
+// fields are also changed by other managers.
+type ForeignManagers struct {
+	Identifier object.ObjMetadata
+	// Managers are the names of the other managers, like helm,
+	// kubectl-edit or the name of a controller.
+	Managers []string
+}
+
+func (f ForeignManagers) String() string {
+	s := resourceIDToString(f.Identifier.GroupKind, f.Identifier.Name)
+	if f.Identifier.Namespace != "" {
+		s += fmt.Sprintf(" (namespace %s)", f.Identifier.Namespace)
+	}
+	return fmt.Sprintf("%s is also managed by %s", s, strings.Join(f.Managers, ", "))
+}
+
+// ForeignManagersError is returned before anything is applied if the
+// inventory policy is strict, and other managers change the resources
+// of the package. Applying them would undo the changes of the other
+// managers, which would then undo the apply again.
+type ForeignManagersError struct {
+	Objects []ForeignManagers
+}
+
+func (e *ForeignManagersError) Error() string {
+	lines := []string{fmt.Sprintf("%d resource(s) are managed by other tools; remove them from the package, "+
+		"or stop the other tools from changing them:", len(e.Objects))}
+	for _, o := range e.Objects {
+		lines = append(lines, "  "+o.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkManagers finds the resources that are also changed by other
+// managers. With a strict inventory policy, they are returned in a
+// ForeignManagersError, otherwise a warning is printed for each of them.
+func (a *Applier) checkManagers(infos []*resource.Info) error {
+	own := a.ownManagers()
+	var foreign []ForeignManagers
+	for _, info := range infos {
+		if prune.IsGroupingObject(info.Object) {
+			continue
+		}
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		live, err := helper.Get(info.Namespace, info.Name, false)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		acc, err := meta.Accessor(live)
+		if err != nil {
+			return err
+		}
+		if managers := foreignManagers(acc, own); len(managers) > 0 {
+			foreign = append(foreign, ForeignManagers{Identifier: infoToObjMetadata(info), Managers: managers})
+		}
+	}
+	if len(foreign) == 0 {
+		return nil
+	}
+	if a.InventoryPolicy == prune.InventoryPolicyStrict {
+		return &ForeignManagersError{Objects: foreign}
+	}
+	for _, f := range foreign {
+		a.logger().Info("resource is managed by other tools", "object", f.Identifier.String(),
+			"managers", f.Managers)
+		fmt.Fprintf(a.ApplyOptions.ErrOut, "warning: %s\n", f.String())
+	}
+	return nil
+}
+
+// ownManagers returns the names the API server records as the manager
+// of the changes made by the Applier. Without a field manager, this is
+// the default manager of the server-side apply, or the name of the
+// program taken from the user agent for client-side applies.
+func (a *Applier) ownManagers() sets.String {
+	if a.FieldManager != "" {
+		return sets.NewString(a.FieldManager)
+	}
+	if a.ApplyOptions.ServerSideApply && a.ApplyOptions.FieldManager != "" {
+		return sets.NewString(a.ApplyOptions.FieldManager)
+	}
+	userAgent := rest.DefaultKubernetesUserAgent()
+	if config, err := a.factory.ToRESTConfig(); err == nil && config.UserAgent != "" {
+		userAgent = config.UserAgent
+	}
+	return sets.NewString(strings.SplitN(userAgent, "/", 2)[0])
+}
+
+// foreignManagers returns the sorted names of the managers, other than
+// the own ones, that set fields of the resource. Managers that only set
+// the status or the metadata are left out, since controllers record
+// their progress there without changing what is applied.
+func foreignManagers(obj metav1.Object, own sets.String) []string {
+	managers := sets.NewString()
+	for _, entry := range obj.GetManagedFields() {
+		if own.Has(entry.Manager) || !setsSpec(entry.FieldsV1) {
+			continue
+		}
+		managers.Insert(entry.Manager)
+	}
+	if release := obj.GetAnnotations()[helmReleaseAnnotation]; release != "" {
+		managers.Delete("helm")
+		managers.Insert(fmt.Sprintf("helm (release %s)", release))
+	}
+	if managers.Len() == 0 {
+		return nil
+	}
+	return managers.List()
+}
+
+// setsSpec returns true if the fields include more than the status
+// and the metadata. Unknown fields are taken as including the spec.
+func setsSpec(fields *metav1.FieldsV1) bool {
+	if fields == nil {
+		return true
+	}
+	var set map[string]interface{}
+	if err := json.Unmarshal(fields.Raw, &set); err != nil {
+		return true
+	}
+	for field := range set {
+		if field != "f:status" && field != "f:metadata" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func managedFieldsEntry(manager, fields string) metav1.ManagedFieldsEntry {
+	entry := metav1.ManagedFieldsEntry{Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate}
+	if fields != "" {
+		entry.FieldsType = "FieldsV1"
+		entry.FieldsV1 = &metav1.FieldsV1{Raw: []byte(fields)}
+	}
+	return entry
+}
+
+func TestForeignManagers(t *testing.T) {
+	testCases := map[string]struct {
+		managedFields []metav1.ManagedFieldsEntry
+		annotations   map[string]string
+		expected      []string
+	}{
+		"no managers": {},
+		"own managers": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kapply", `{"f:data":{}}`),
+				managedFieldsEntry("pipeline", `{"f:data":{}}`),
+			},
+		},
+		"controller setting the status": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kube-controller-manager", `{"f:metadata":{"f:annotations":{}},"f:status":{}}`),
+			},
+		},
+		"other managers": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kubectl-edit", `{"f:data":{}}`),
+				managedFieldsEntry("autoscaler", `{"f:spec":{"f:replicas":{}}}`),
+				managedFieldsEntry("unknown", ""),
+			},
+			expected: []string{"autoscaler", "kubectl-edit", "unknown"},
+		},
+		"helm release": {
+			managedFields: []metav1.ManagedFieldsEntry{managedFieldsEntry("helm", `{"f:data":{}}`)},
+			annotations:   map[string]string{helmReleaseAnnotation: "app"},
+			expected:      []string{"helm (release app)"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			obj := configMap("cm", nil)
+			obj.SetManagedFields(tc.managedFields)
+			obj.SetAnnotations(tc.annotations)
+			assert.Equal(t, tc.expected, foreignManagers(obj, sets.NewString("kapply", "pipeline")))
+		})
+	}
+}
+
+func TestRunForeignManagers(t *testing.T) {
+	testCases := map[string]struct {
+		policy    prune.InventoryPolicy
+		expectErr bool
+	}{
+		"adopt-if-no-inventory": {policy: prune.InventoryPolicyAdoptIfNoInventory},
+		"strict":                {policy: prune.InventoryPolicyStrict, expectErr: true},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			live := configMap("a", nil)
+			live.SetNamespace(fakecluster.DefaultNamespace)
+			live.SetAnnotations(map[string]string{prune.OwningInventoryAnnotation: "test"})
+			live.SetManagedFields([]metav1.ManagedFieldsEntry{managedFieldsEntry("kubectl-edit", `{"f:data":{}}`)})
+			require.NoError(t, cluster.Add(live))
+
+			ioStreams, _, _, errOut := genericclioptions.NewTestIOStreams()
+			applier, err := NewApplierWithOptions(cluster.Factory(), ioStreams,
+				WithNoWait(), WithFieldManager("pipeline"), WithInventoryPolicy(tc.policy))
+			require.NoError(t, err)
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			var errs []error
+			for e := range applier.RunObjects(context.Background(),
+				[]*unstructured.Unstructured{inventory, configMap("a", nil)}) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+
+			if !tc.expectErr {
+				assert.Empty(t, errs)
+				assert.Contains(t, errOut.String(), "warning: configmap/a (namespace default) is also managed by kubectl-edit")
+				return
+			}
+			require.Len(t, errs, 1)
+			var managersErr *ForeignManagersError
+			require.True(t, errors.As(errs[0], &managersErr))
+			require.Len(t, managersErr.Objects, 1)
+			assert.Equal(t, []string{"kubectl-edit"}, managersErr.Objects[0].Managers)
+			assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+			assert.Empty(t, groupingObjectNames(cluster))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/metrics"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+)
+
+// recordMetrics reports every event from the channel to the recorder
+// and then forwards it on the returned channel, which is closed when
+// the input channel is closed. If the recorder is nil, the input
+// channel is returned as-is.
+func recordMetrics(recorder metrics.Recorder, in <-chan event.Event) <-chan event.Event {
+	if recorder == nil {
+		return in
+	}
+	out := make(chan event.Event)
+	go func() {
+		defer close(out)
+		for e := range in {
+			recordEvent(recorder, e)
+			out <- e
+		}
+	}()
+	return out
+}
+
+func recordEvent(recorder metrics.Recorder, e event.Event) {
+	switch e.Type {
+	case event.ErrorType:
+		recorder.Failed(string(ReasonForError(e.ErrorEvent.Err)))
+	case event.ApplyType:
+		if e.ApplyEvent.Type == event.ApplyEventCompleted {
+			recorder.PhaseCompleted(metrics.PhaseApply, e.Duration())
+			return
+		}
+		if e.ApplyEvent.Operation == event.Filtered {
+			return
+		}
+		recorder.ResourceApplied(e.ApplyEvent.Identifier.GroupKind,
+			strings.ToLower(e.ApplyEvent.Operation.String()))
+	case event.StatusType:
+		switch e.StatusEvent.EventType {
+		case pollevent.CompletedEvent, pollevent.AbortedEvent:
+			recorder.PhaseCompleted(metrics.PhaseWait, e.Duration())
+		case pollevent.ErrorEvent:
+			recorder.Failed(string(ReasonForError(e.StatusEvent.Error)))
+		}
+	case event.PruneType:
+		if e.PruneEvent.Type == event.PruneEventCompleted {
+			recorder.PhaseCompleted(metrics.PhasePrune, e.Duration())
+			return
+		}
+		if e.PruneEvent.Operation == event.Pruned {
+			recorder.ResourcePruned(e.PruneEvent.Identifier.GroupKind)
+		}
+	case event.DeleteType:
+		if e.DeleteEvent.Type == event.DeleteEventCompleted {
+			recorder.PhaseCompleted(metrics.PhaseDelete, e.Duration())
+			return
+		}
+		recorder.ResourceDeleted(e.DeleteEvent.Identifier.GroupKind)
+	}
+}
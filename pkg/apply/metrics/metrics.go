@@ -0,0 +1,55 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics defines the interface used by the Applier and the
+// Destroyer to report metrics, together with a no-op implementation
+// and an implementation backed by Prometheus.
+package metrics
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The phases reported to PhaseCompleted.
+const (
+	PhaseApply  = "apply"
+	PhaseWait   = "wait"
+	PhasePrune  = "prune"
+	PhaseDelete = "delete"
+)
+
+// Recorder is notified about everything that happens during a run
+// of the Applier or the Destroyer. Implementations must be safe for
+// concurrent use, since several runs can happen at the same time.
+type Recorder interface {
+	// ResourceApplied is called for every resource that has been
+	// applied, with the operation, for example created or unchanged.
+	ResourceApplied(gk schema.GroupKind, operation string)
+	// ResourcePruned is called for every resource that has been pruned.
+	ResourcePruned(gk schema.GroupKind)
+	// ResourceDeleted is called for every resource that has been deleted.
+	ResourceDeleted(gk schema.GroupKind)
+	// Failed is called for every error, with the classification
+	// of the error, for example Validation or Timeout.
+	Failed(reason string)
+	// PhaseCompleted is called when a phase has completed, with
+	// the time it took.
+	PhaseCompleted(phase string, duration time.Duration)
+}
+
+// NoopRecorder is a Recorder that discards everything.
+type NoopRecorder struct{}
+
+var _ Recorder = NoopRecorder{}
+
+func (NoopRecorder) ResourceApplied(schema.GroupKind, string) {}
+
+func (NoopRecorder) ResourcePruned(schema.GroupKind) {}
+
+func (NoopRecorder) ResourceDeleted(schema.GroupKind) {}
+
+func (NoopRecorder) Failed(string) {}
+
+func (NoopRecorder) PhaseCompleted(string, time.Duration) {}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PrometheusRecorder is a Recorder that exposes the metrics
+// as Prometheus counters and histograms.
+type PrometheusRecorder struct {
+	applied *prometheus.CounterVec
+	pruned  *prometheus.CounterVec
+	deleted *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+	phases  *prometheus.HistogramVec
+}
+
+var _ Recorder = &PrometheusRecorder{}
+
+// NewPrometheusRecorder creates the metrics with the given namespace,
+// for example "kapply", and registers them with the registerer.
+func NewPrometheusRecorder(namespace string, registerer prometheus.Registerer) (*PrometheusRecorder, error) {
+	r := &PrometheusRecorder{
+		applied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "resources_applied_total",
+			Help:      "Number of resources applied, by group, kind and operation.",
+		}, []string{"group", "kind", "operation"}),
+		pruned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "resources_pruned_total",
+			Help:      "Number of resources pruned, by group and kind.",
+		}, []string{"group", "kind"}),
+		deleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "resources_deleted_total",
+			Help:      "Number of resources deleted, by group and kind.",
+		}, []string{"group", "kind"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Number of errors, by reason.",
+		}, []string{"reason"}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "Time taken by the apply, wait, prune and delete phases.",
+			Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"phase"}),
+	}
+	for _, c := range []prometheus.Collector{r.applied, r.pruned, r.deleted, r.errors, r.phases} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *PrometheusRecorder) ResourceApplied(gk schema.GroupKind, operation string) {
+	r.applied.WithLabelValues(gk.Group, gk.Kind, operation).Inc()
+}
+
+func (r *PrometheusRecorder) ResourcePruned(gk schema.GroupKind) {
+	r.pruned.WithLabelValues(gk.Group, gk.Kind).Inc()
+}
+
+func (r *PrometheusRecorder) ResourceDeleted(gk schema.GroupKind) {
+	r.deleted.WithLabelValues(gk.Group, gk.Kind).Inc()
+}
+
+func (r *PrometheusRecorder) Failed(reason string) {
+	r.errors.WithLabelValues(reason).Inc()
+}
+
+func (r *PrometheusRecorder) PhaseCompleted(phase string, duration time.Duration) {
+	r.phases.WithLabelValues(phase).Observe(duration.Seconds())
+}
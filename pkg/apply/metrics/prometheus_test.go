@@ -0,0 +1,44 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPrometheusRecorder(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r, err := NewPrometheusRecorder("kapply", registry)
+	assert.NoError(t, err)
+
+	gk := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	r.ResourceApplied(gk, "created")
+	r.ResourceApplied(gk, "created")
+	r.ResourcePruned(gk)
+	r.Failed("Timeout")
+	r.PhaseCompleted(PhaseWait, 3*time.Second)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(r.applied.WithLabelValues("apps", "Deployment", "created")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.pruned.WithLabelValues("apps", "Deployment")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(r.deleted.WithLabelValues("apps", "Deployment")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.errors.WithLabelValues("Timeout")))
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	assert.True(t, names["kapply_phase_duration_seconds"])
+
+	// Registering the same metrics twice fails.
+	_, err = NewPrometheusRecorder("kapply", registry)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+type fakeRecorder struct {
+	calls []string
+}
+
+func (f *fakeRecorder) ResourceApplied(gk schema.GroupKind, operation string) {
+	f.calls = append(f.calls, fmt.Sprintf("applied %s %s", gk, operation))
+}
+
+func (f *fakeRecorder) ResourcePruned(gk schema.GroupKind) {
+	f.calls = append(f.calls, fmt.Sprintf("pruned %s", gk))
+}
+
+func (f *fakeRecorder) ResourceDeleted(gk schema.GroupKind) {
+	f.calls = append(f.calls, fmt.Sprintf("deleted %s", gk))
+}
+
+func (f *fakeRecorder) Failed(reason string) {
+	f.calls = append(f.calls, fmt.Sprintf("failed %s", reason))
+}
+
+func (f *fakeRecorder) PhaseCompleted(phase string, duration time.Duration) {
+	f.calls = append(f.calls, fmt.Sprintf("%s completed in %s", phase, duration))
+}
+
+func TestRecordMetrics(t *testing.T) {
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	deployment := object.ObjMetadata{
+		Namespace: "default",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Created,
+				Identifier: deployment,
+			},
+		},
+		{
+			Type:      event.ApplyType,
+			Started:   started,
+			Timestamp: started.Add(time.Second),
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type:       event.PruneEventResourceUpdate,
+				Identifier: deployment,
+			},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type:       event.PruneEventResourceUpdate,
+				Operation:  event.PruneSkipped,
+				Identifier: deployment,
+			},
+		},
+		{
+			Type: event.ErrorType,
+			ErrorEvent: event.ErrorEvent{
+				Err: withExitCode(fmt.Errorf("timed out"), ExitReconcileTimeout),
+			},
+		},
+	}
+
+	in := make(chan event.Event)
+	go func() {
+		defer close(in)
+		for _, e := range events {
+			in <- e
+		}
+	}()
+	recorder := &fakeRecorder{}
+	var received []event.Event
+	for e := range recordMetrics(recorder, in) {
+		received = append(received, e)
+	}
+
+	assert.Equal(t, events, received)
+	assert.Equal(t, []string{
+		"applied Deployment.apps created",
+		"apply completed in 1s",
+		"pruned Deployment.apps",
+		"failed Timeout",
+	}, recorder.calls)
+}
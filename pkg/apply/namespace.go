@@ -0,0 +1,93 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+var bindingGroupKinds = sets.NewString("RoleBinding.rbac.authorization.k8s.io",
+	"ClusterRoleBinding.rbac.authorization.k8s.io")
+
+// setNamespace moves all the namespaced resources, including the
+// grouping object, to the namespace. Cluster-scoped resources are kept
+// as they are. The ServiceAccounts bound by the RoleBindings and
+// ClusterRoleBindings of the package are moved with the resources, so
+// the bindings keep referring to the ServiceAccounts of the package.
+func setNamespace(infos []*resource.Info, namespace string) error {
+	defined := definedKinds(infos)
+	moved := sets.NewString()
+	for _, info := range infos {
+		namespaced, err := isNamespaced(info, defined)
+		if err != nil {
+			return err
+		}
+		if !namespaced {
+			continue
+		}
+		acc, err := meta.Accessor(info.Object)
+		if err != nil {
+			return err
+		}
+		if acc.GetNamespace() != "" {
+			moved.Insert(acc.GetNamespace())
+		}
+		acc.SetNamespace(namespace)
+		info.Namespace = namespace
+	}
+
+	for _, info := range infos {
+		u, ok := info.Object.(*unstructured.Unstructured)
+		if !ok || !bindingGroupKinds.Has(u.GroupVersionKind().GroupKind().String()) {
+			continue
+		}
+		subjects, found, err := unstructured.NestedSlice(u.Object, "subjects")
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		for _, s := range subjects {
+			subject, ok := s.(map[string]interface{})
+			if !ok || subject["kind"] != "ServiceAccount" {
+				continue
+			}
+			if ns, _ := subject["namespace"].(string); moved.Has(ns) {
+				subject["namespace"] = namespace
+			}
+		}
+		if err := unstructured.SetNestedSlice(u.Object, subjects, "subjects"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNamespaced returns true if the resource is namespaced. Kinds that
+// are not served yet are looked up in the CustomResourceDefinitions of
+// the package, and are otherwise taken as namespaced if the manifest
+// sets a namespace.
+func isNamespaced(info *resource.Info, defined map[schema.GroupKind]*resource.Info) (bool, error) {
+	if info.Mapping != nil {
+		return info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+	}
+	gk := info.Object.GetObjectKind().GroupVersionKind().GroupKind()
+	if crd, found := defined[gk]; found {
+		scope, _, err := unstructured.NestedString(crd.Object.(*unstructured.Unstructured).Object, "spec", "scope")
+		if err != nil {
+			return false, err
+		}
+		return scope == "Namespaced", nil
+	}
+	acc, err := meta.Accessor(info.Object)
+	if err != nil {
+		return false, err
+	}
+	return acc.GetNamespace() != "", nil
+}
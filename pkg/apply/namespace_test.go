@@ -0,0 +1,146 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func scopedInfo(apiVersion, kind, namespace, name string, scope meta.RESTScope) *resource.Info {
+	info := validatorInfo(apiVersion, kind, namespace, name, "")
+	info.Mapping = &meta.RESTMapping{Scope: scope}
+	return info
+}
+
+func TestSetNamespace(t *testing.T) {
+	binding := scopedInfo("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "", "binding", meta.RESTScopeRoot)
+	binding.Object.(*unstructured.Unstructured).Object["subjects"] = []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": "app", "namespace": "default"},
+		map[string]interface{}{"kind": "ServiceAccount", "name": "other", "namespace": "kube-system"},
+		map[string]interface{}{"kind": "User", "name": "jane"},
+	}
+	crd := validatorInfo("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "crons.example.com", "")
+	crd.Object.(*unstructured.Unstructured).Object["spec"] = map[string]interface{}{
+		"group": "example.com",
+		"scope": "Namespaced",
+		"names": map[string]interface{}{"kind": "Cron"},
+	}
+	infos := []*resource.Info{
+		scopedInfo("v1", "ConfigMap", "default", "inventory", meta.RESTScopeNamespace),
+		scopedInfo("v1", "ServiceAccount", "default", "app", meta.RESTScopeNamespace),
+		scopedInfo("v1", "ConfigMap", "", "config", meta.RESTScopeNamespace),
+		scopedInfo("v1", "Namespace", "", "default", meta.RESTScopeRoot),
+		binding,
+		crd,
+		// The kinds that are not served yet are
+		// looked up in the package.
+		validatorInfo("example.com/v1", "Cron", "", "cron", ""),
+		validatorInfo("example.com/v1", "Unknown", "default", "unknown", ""),
+	}
+
+	require.NoError(t, setNamespace(infos, "apps"))
+
+	namespaces := make(map[string]string)
+	for _, info := range infos {
+		assert.Equal(t, info.Namespace, info.Object.(*unstructured.Unstructured).GetNamespace())
+		namespaces[info.Name] = info.Namespace
+	}
+	assert.Equal(t, map[string]string{
+		"inventory":         "apps",
+		"app":               "apps",
+		"config":            "apps",
+		"default":           "",
+		"binding":           "",
+		"crons.example.com": "",
+		"cron":              "apps",
+		"unknown":           "apps",
+	}, namespaces)
+	subjects, _, err := unstructured.NestedSlice(binding.Object.(*unstructured.Unstructured).Object, "subjects")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": "app", "namespace": "apps"},
+		map[string]interface{}{"kind": "ServiceAccount", "name": "other", "namespace": "kube-system"},
+		map[string]interface{}{"kind": "User", "name": "jane"},
+	}, subjects)
+}
+
+func TestRunTargetNamespace(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait(), WithTargetNamespace("apps"))
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+	cm := configMap("config", nil)
+	cm.SetNamespace("default")
+	for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{inventory, cm}) {
+		require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	assert.NotNil(t, cluster.Get(gvk, "apps", "config"))
+	assert.Nil(t, cluster.Get(gvk, "default", "config"))
+	var inventories []string
+	for _, obj := range cluster.Objects() {
+		if prune.IsGroupingObject(obj) {
+			inventories = append(inventories, obj.GetNamespace())
+		}
+	}
+	assert.Equal(t, []string{"apps"}, inventories)
+}
+
+func TestRunTargetNamespacePrunes(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait(), WithTargetNamespace("apps"))
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+	run := func(names ...string) {
+		objs := []*unstructured.Unstructured{configMap("inventory", map[string]string{prune.GroupingLabel: "test"})}
+		for _, name := range names {
+			objs = append(objs, configMap(name, nil))
+		}
+		for e := range applier.RunObjects(context.Background(), objs) {
+			require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+		}
+	}
+
+	// The target namespace is not the namespace of the context, so the
+	// past grouping objects must be looked up in the target namespace.
+	require.NotEqual(t, "apps", fakecluster.DefaultNamespace)
+	run("config", "removed")
+	run("config")
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	assert.NotNil(t, cluster.Get(gvk, "apps", "config"))
+	assert.Nil(t, cluster.Get(gvk, "apps", "removed"))
+	assert.Len(t, groupingObjectNames(cluster), 1)
+}
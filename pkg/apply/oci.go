@@ -0,0 +1,289 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ociPrefix is the prefix of references to packages in OCI registries,
+// for example oci://registry.example.com/team/app@sha256:<hex>.
+const ociPrefix = "oci://"
+
+// ociManifestMediaTypes are the manifest formats accepted from
+// the registry.
+var ociManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// bearerParamRegexp matches the parameters of a Bearer challenge.
+var bearerParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociReference is a package in an OCI registry, pinned by digest.
+type ociReference struct {
+	registry   string
+	repository string
+	digest     string
+}
+
+// ociManifest contains the fields of an OCI image manifest
+// that are needed to pull the package.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// isOCI returns true if the filename is a reference to
+// a package in an OCI registry.
+func isOCI(filename string) bool {
+	return strings.HasPrefix(filename, ociPrefix)
+}
+
+// parseOCIReference parses a reference of the form
+// oci://REGISTRY/REPOSITORY@sha256:HEX. References with only a tag are
+// not accepted, since tags can be moved to other content after the
+// package was reviewed.
+func parseOCIReference(ref string) (ociReference, error) {
+	s := strings.TrimPrefix(ref, ociPrefix)
+	at := strings.LastIndex(s, "@")
+	if at < 0 {
+		return ociReference{}, fmt.Errorf("OCI reference %s must be pinned by digest, "+
+			"for example %sregistry.example.com/app@sha256:<hex>", ref, ociPrefix)
+	}
+	name, digest := s[:at], s[at+1:]
+	if !strings.HasPrefix(digest, "sha256:") {
+		return ociReference{}, fmt.Errorf("unsupported digest %q in OCI reference %s, must be sha256:<hex>", digest, ref)
+	}
+	if sum := strings.TrimPrefix(digest, "sha256:"); len(sum) != sha256.Size*2 {
+		return ociReference{}, fmt.Errorf("invalid digest %q in OCI reference %s", digest, ref)
+	}
+	slash := strings.Index(name, "/")
+	if slash <= 0 || slash == len(name)-1 {
+		return ociReference{}, fmt.Errorf("OCI reference %s must contain a registry and a repository", ref)
+	}
+	repository := name[slash+1:]
+	// A tag in front of the digest is allowed, but ignored.
+	if colon := strings.LastIndex(repository, ":"); colon >= 0 {
+		repository = repository[:colon]
+	}
+	return ociReference{
+		registry:   name[:slash],
+		repository: repository,
+		digest:     digest,
+	}, nil
+}
+
+// pull downloads the package from the OCI registry and verifies the
+// digests of the manifest and of every layer. Layers that are tar
+// archives are extracted, other layers are used as manifests. It
+// returns the paths of the manifests. The index is used to give every
+// manifest a unique file name.
+func (r *remoteManifests) pull(ref string, index int) ([]string, error) {
+	reference, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	puller := &ociPuller{client: r.client, reference: reference}
+	content, err := puller.get("manifests", reference.digest, ociManifestMediaTypes)
+	if err != nil {
+		return nil, fmt.Errorf("error pulling %s: %v", ref, err)
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("error pulling %s: invalid manifest: %v", ref, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("error pulling %s: the image has no layers", ref)
+	}
+
+	var paths []string
+	for i, layer := range manifest.Layers {
+		content, err := puller.get("blobs", layer.Digest, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error pulling %s: %v", ref, err)
+		}
+		prefix := fmt.Sprintf("oci-%d-%d", index, i)
+		if !strings.Contains(layer.MediaType, "tar") {
+			path, err := r.write(prefix+".yaml", content)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+			continue
+		}
+		layerPaths, err := r.extract(prefix, content, strings.HasSuffix(layer.MediaType, "gzip"))
+		if err != nil {
+			return nil, fmt.Errorf("error pulling %s: %v", ref, err)
+		}
+		paths = append(paths, layerPaths...)
+	}
+	return paths, nil
+}
+
+// extract writes the manifests in the tar archive to the temporary
+// directory, in the order they appear in the archive. Other files are
+// skipped.
+func (r *remoteManifests) extract(prefix string, content []byte, gzipped bool) ([]string, error) {
+	var reader io.Reader = bytes.NewReader(content)
+	if gzipped {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	var paths []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ext := filepath.Ext(header.Name)
+		if header.Typeflag != tar.TypeReg || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("%s-%d-%s", prefix, len(paths), filepath.Base(header.Name))
+		path, err := r.write(name, data)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+}
+
+// ociPuller downloads content from a registry with the distribution
+// API. Anonymous bearer tokens are requested when the registry asks
+// for them.
+type ociPuller struct {
+	client    *http.Client
+	reference ociReference
+	token     string
+}
+
+// get downloads the manifest or blob with the digest, and verifies
+// that the content matches it.
+func (p *ociPuller) get(kind, digest string, accept []string) ([]byte, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s/%s", p.reference.registry, p.reference.repository, kind, digest)
+	resp, err := p.do(u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && p.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if p.token, err = p.fetchToken(challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = p.do(u, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", u, resp.Status)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", u, err)
+	}
+	sum := sha256.Sum256(content)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != digest {
+		return nil, fmt.Errorf("digest mismatch for %s: expected %s, got %s", u, digest, actual)
+	}
+	return content, nil
+}
+
+func (p *ociPuller) do(u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", u, err)
+	}
+	return resp, nil
+}
+
+// fetchToken requests an anonymous token for pulling from the
+// repository, as described by the Bearer challenge.
+func (p *ociPuller) fetchToken(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported authentication %q required by %s", challenge, p.reference.registry)
+	}
+	params := map[string]string{}
+	for _, m := range bearerParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid authentication realm in %q", challenge)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", p.reference.repository)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	resp, err := p.client.Get(realm.String())
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting token from %s: %s", realm.Host, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error requesting token: %v", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+	return "", fmt.Errorf("no token returned by %s", realm.Host)
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ociDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func tarGz(t *testing.T, files map[string]string, order []string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range order {
+		content := files[name]
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestParseOCIReference(t *testing.T) {
+	digest := ociDigest([]byte("manifest"))
+	testCases := map[string]struct {
+		ref         string
+		expected    ociReference
+		expectedErr bool
+	}{
+		"pinned by digest": {
+			ref:      "oci://registry.example.com/team/app@" + digest,
+			expected: ociReference{registry: "registry.example.com", repository: "team/app", digest: digest},
+		},
+		"tag and digest": {
+			ref:      "oci://localhost:5000/app:v1@" + digest,
+			expected: ociReference{registry: "localhost:5000", repository: "app", digest: digest},
+		},
+		"only a tag": {
+			ref:         "oci://registry.example.com/app:v1",
+			expectedErr: true,
+		},
+		"unsupported digest": {
+			ref:         "oci://registry.example.com/app@sha512:abc",
+			expectedErr: true,
+		},
+		"no repository": {
+			ref:         "oci://registry.example.com@" + digest,
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			reference, err := parseOCIReference(tc.ref)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, reference)
+		})
+	}
+}
+
+func TestPull(t *testing.T) {
+	layer := tarGz(t, map[string]string{
+		"package/b.yaml":    "kind: ConfigMap\nmetadata:\n  name: b\n",
+		"package/a.yaml":    "kind: ConfigMap\nmetadata:\n  name: a\n",
+		"package/README.md": "not a manifest",
+	}, []string{"package/b.yaml", "package/README.md", "package/a.yaml"})
+	rawLayer := []byte("kind: ConfigMap\nmetadata:\n  name: raw\n")
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"layers": []map[string]string{
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": ociDigest(layer)},
+			{"mediaType": "application/yaml", "digest": ociDigest(rawLayer)},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	blobs := map[string][]byte{
+		ociDigest(layer):    layer,
+		ociDigest(rawLayer): rawLayer,
+	}
+
+	testCases := map[string]struct {
+		manifestDigest string
+		requireToken   bool
+		expected       []string
+		expectedErr    bool
+	}{
+		"package": {
+			manifestDigest: ociDigest(manifest),
+			expected:       []string{"name: b", "name: a", "name: raw"},
+		},
+		"anonymous token": {
+			manifestDigest: ociDigest(manifest),
+			requireToken:   true,
+			expected:       []string{"name: b", "name: a", "name: raw"},
+		},
+		"digest mismatch": {
+			manifestDigest: ociDigest([]byte("other")),
+			expectedErr:    true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var server *httptest.Server
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/token" {
+					assert.Equal(t, "repository:team/app:pull", r.URL.Query().Get("scope"))
+					_, _ = w.Write([]byte(`{"token": "secret"}`))
+					return
+				}
+				if tc.requireToken && r.Header.Get("Authorization") != "Bearer secret" {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch {
+				case strings.HasPrefix(r.URL.Path, "/v2/team/app/manifests/"):
+					_, _ = w.Write(manifest)
+				case strings.HasPrefix(r.URL.Path, "/v2/team/app/blobs/"):
+					blob, found := blobs[strings.TrimPrefix(r.URL.Path, "/v2/team/app/blobs/")]
+					if !found {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					_, _ = w.Write(blob)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			r := newRemoteManifests(false)
+			r.client = server.Client()
+			defer r.cleanup()
+			registry := strings.TrimPrefix(server.URL, "https://")
+			paths, err := r.pull("oci://"+registry+"/team/app@"+tc.manifestDigest, 0)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) || !assert.Len(t, paths, len(tc.expected)) {
+				return
+			}
+			for i, path := range paths {
+				content, err := ioutil.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Contains(t, string(content), tc.expected[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"time"
+
+	"github.com/go-errors/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observers"
+)
+
+// ApplierOption is a functional option for configuring an Applier
+// created with NewApplierWithOptions.
+type ApplierOption func(*Applier)
+
+// WithClients sets the clients the Applier uses to talk to the
+// cluster. The clients that are nil are created from the factory.
+func WithClients(clients Clients) ApplierOption {
+	return func(a *Applier) {
+		a.clients = clients
+		a.PruneOptions = prune.NewPruneOptionsWithClients(clients.DynamicClient, clients.Mapper)
+	}
+}
+
+// WithPrune enables or disables pruning. It is enabled by default.
+func WithPrune(enabled bool) ApplierOption {
+	return func(a *Applier) {
+		a.NoPrune = !enabled
+	}
+}
+
+// WithPruneTimeout makes the Applier wait up to the given duration
+// for the pruned resources to be removed from the cluster.
+func WithPruneTimeout(timeout time.Duration) ApplierOption {
+	return func(a *Applier) {
+		a.PruneTimeout = timeout
+	}
+}
+
+// WithInventoryPolicy sets which resources can be taken over
+// by the inventory, and which can be pruned.
+func WithInventoryPolicy(policy prune.InventoryPolicy) ApplierOption {
+	return func(a *Applier) {
+		a.InventoryPolicy = policy
+	}
+}
+
+// WithServerSideApply makes the Applier use server-side apply. If
+// forceConflicts is true, the fields owned by other managers are
+// taken over instead of failing the apply. The --server-side and
+// --force-conflicts flags take precedence if they are set.
+func WithServerSideApply(forceConflicts bool) ApplierOption {
+	return func(a *Applier) {
+		a.serverSideApply = true
+		a.forceConflicts = forceConflicts
+	}
+}
+
+// WithFieldManager sets the name recorded as the manager in the
+// managedFields of the applied resources.
+func WithFieldManager(name string) ApplierOption {
+	return func(a *Applier) {
+		a.FieldManager = name
+	}
+}
+
+// WithDryRun sets whether the changes are made in the cluster, or
+// only evaluated on the client or the server.
+func WithDryRun(strategy common.DryRunStrategy) ApplierOption {
+	return func(a *Applier) {
+		a.DryRunStrategy = strategy
+	}
+}
+
+// WithTimeout makes the Applier wait up to the given duration for
+// the resources to reach the Current status. A value of 0 means the
+// Applier waits until the context passed to Run is cancelled.
+func WithTimeout(timeout time.Duration) ApplierOption {
+	return func(a *Applier) {
+		a.StatusOptions.Wait = true
+		a.StatusOptions.Timeout = timeout
+	}
+}
+
+// WithNoWait makes the Applier return as soon as the resources have
+// been applied, and record in the inventory that their reconcile
+// status is unknown.
+func WithNoWait() ApplierOption {
+	return func(a *Applier) {
+		a.StatusOptions.NoWait = true
+	}
+}
+
+// WithPollInterval sets how often the status of the resources
+// is polled while waiting for them.
+func WithPollInterval(interval time.Duration) ApplierOption {
+	return func(a *Applier) {
+		a.StatusOptions.period = interval
+	}
+}
+
+// WithStatusObservers sets the factory for the observers that compute
+// the status of every resource, so custom resources can report their
+// status in their own way. The resources with CEL rules, set with
+// annotations or WithReadinessRules, still get their status from the
+// rules. It can't be combined with a StatusPoller in the Clients.
+func WithStatusObservers(f observer.ObserversFactoryFunc) ApplierOption {
+	return func(a *Applier) {
+		a.observersFactory = f
+	}
+}
+
+// WithReadinessRules computes the status of the resources of
+// the GroupKinds with the CEL rules.
+func WithReadinessRules(rules map[schema.GroupKind]observers.CELRule) ApplierOption {
+	return func(a *Applier) {
+		a.ReadinessRules = rules
+	}
+}
+
+// WithRetriableErrors adds matchers for errors that are reported
+// as transient, in addition to the built-in ones.
+func WithRetriableErrors(matchers ...ErrorMatcher) ApplierOption {
+	return func(a *Applier) {
+		a.RetriableErrors = append(a.RetriableErrors, matchers...)
+	}
+}
+
+// WithTargetNamespace applies all the namespaced resources
+// of the package to the namespace.
+func WithTargetNamespace(namespace string) ApplierOption {
+	return func(a *Applier) {
+		a.TargetNamespace = namespace
+	}
+}
+
+// NewApplierWithOptions returns an Applier configured with the options.
+// It returns an error if the options can't be combined. The fields of
+// the Applier stay exported, since they are bound to the command line
+// flags by SetFlags and can still be set after it is created, so the
+// combination is validated again by Initialize and Run.
+func NewApplierWithOptions(factory util.Factory, ioStreams genericclioptions.IOStreams,
+	opts ...ApplierOption) (*Applier, error) {
+	a := NewApplier(factory, ioStreams)
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := a.validateOptions(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// validateOptions returns an error if the options
+// of the Applier contradict each other.
+func (a *Applier) validateOptions() error {
+	if a.serverSideApply && a.DryRunStrategy.ClientDryRun() {
+		return errors.New("server-side apply can only be used with a server dry-run")
+	}
+	if a.StatusOptions.NoWait && a.StatusOptions.Wait {
+		return errors.New("the Applier can not both wait and not wait for the resources")
+	}
+	if a.NoPrune && a.PruneTimeout > 0 {
+		return errors.New("a prune timeout can not be used when pruning is disabled")
+	}
+	if a.StatusOptions.period <= 0 {
+		return errors.New("the status poll interval must be greater than 0")
+	}
+	if a.observersFactory != nil && a.clients.StatusPoller != nil {
+		return errors.New("status observers can not be used together with a StatusPoller")
+	}
+	if len(a.ReadinessRules) > 0 && a.clients.StatusPoller != nil {
+		return errors.New("readiness rules can not be used together with a StatusPoller")
+	}
+	return nil
+}
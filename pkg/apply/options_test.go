@@ -0,0 +1,177 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observers"
+)
+
+func TestNewApplierWithOptions(t *testing.T) {
+	testCases := map[string]struct {
+		opts      []ApplierOption
+		expectErr bool
+	}{
+		"no options": {},
+		"all compatible options": {
+			opts: []ApplierOption{
+				WithPrune(true),
+				WithPruneTimeout(time.Minute),
+				WithInventoryPolicy(prune.InventoryPolicyAdoptIfNoInventory),
+				WithServerSideApply(true),
+				WithFieldManager("pipeline"),
+				WithDryRun(common.DryRunServer),
+				WithTimeout(time.Minute),
+				WithPollInterval(time.Second),
+				WithStatusObservers(observe.DefaultObserversFactoryFunc),
+				WithRetriableErrors(MessageMatcher("webhook")),
+				WithTargetNamespace("apps"),
+			},
+		},
+		"server-side apply with client dry-run": {
+			opts:      []ApplierOption{WithServerSideApply(false), WithDryRun(common.DryRunClient)},
+			expectErr: true,
+		},
+		"wait and no wait": {
+			opts:      []ApplierOption{WithTimeout(time.Minute), WithNoWait()},
+			expectErr: true,
+		},
+		"prune timeout without prune": {
+			opts:      []ApplierOption{WithPrune(false), WithPruneTimeout(time.Minute)},
+			expectErr: true,
+		},
+		"invalid poll interval": {
+			opts:      []ApplierOption{WithPollInterval(0)},
+			expectErr: true,
+		},
+		"status observers with status poller": {
+			opts: []ApplierOption{
+				WithClients(Clients{StatusPoller: &fakeStatusPoller{}}),
+				WithStatusObservers(observe.DefaultObserversFactoryFunc),
+			},
+			expectErr: true,
+		},
+		"readiness rules with a status poller": {
+			opts: []ApplierOption{
+				WithClients(Clients{StatusPoller: &fakeStatusPoller{}}),
+				WithReadinessRules(map[schema.GroupKind]observers.CELRule{
+					{Group: "example.com", Kind: "Cron"}: {Ready: "object.status.ready"},
+				}),
+			},
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			_, err := NewApplierWithOptions(nil, genericclioptions.NewTestIOStreamsDiscard(), tc.opts...)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServerSideApplyOption(t *testing.T) {
+	testCases := map[string]struct {
+		flags                map[string]string
+		expectServerSide     bool
+		expectForceConflicts bool
+	}{
+		"no flags": {
+			expectServerSide:     true,
+			expectForceConflicts: true,
+		},
+		"force conflicts flag": {
+			flags:            map[string]string{"force-conflicts": "false"},
+			expectServerSide: true,
+		},
+		"server-side flag": {
+			flags:                map[string]string{"server-side": "false"},
+			expectForceConflicts: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithServerSideApply(true), WithPrune(false))
+			require.NoError(t, err)
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			for name, value := range tc.flags {
+				require.NoError(t, cmd.Flags().Set(name, value))
+			}
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			// The option applies unless the flags are set.
+			assert.Equal(t, tc.expectServerSide, applier.ApplyOptions.ServerSideApply)
+			assert.Equal(t, tc.expectForceConflicts, applier.ApplyOptions.ForceConflicts)
+			assert.True(t, applier.NoPrune)
+		})
+	}
+}
+
+func TestOptionsValidatedAfterConstruction(t *testing.T) {
+	newApplier := func(t *testing.T, cluster *fakecluster.Cluster) (*Applier, *cobra.Command) {
+		applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+			WithPrune(false))
+		require.NoError(t, err)
+		cmd := &cobra.Command{}
+		require.NoError(t, applier.SetFlags(cmd))
+		cmdutil.AddValidateFlags(cmd)
+		cmdutil.AddServerSideApplyFlags(cmd)
+		require.NoError(t, cmd.Flags().Set("filename", "-"))
+		return applier, cmd
+	}
+
+	t.Run("initialize", func(t *testing.T) {
+		cluster, err := fakecluster.New()
+		require.NoError(t, err)
+		applier, cmd := newApplier(t, cluster)
+		applier.PruneTimeout = time.Minute
+		assert.Error(t, applier.Initialize(cmd, nil))
+	})
+
+	t.Run("run", func(t *testing.T) {
+		cluster, err := fakecluster.New()
+		require.NoError(t, err)
+		applier, cmd := newApplier(t, cluster)
+		require.NoError(t, applier.Initialize(cmd, nil))
+		applier.PruneTimeout = time.Minute
+		var errs []error
+		for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+			configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		}) {
+			if e.Type == event.ErrorType {
+				errs = append(errs, e.ErrorEvent.Err)
+			}
+		}
+		require.Len(t, errs, 1)
+		assert.Equal(t, ExitValidationError, ExitCode(errs[0]))
+		assert.Empty(t, groupingObjectNames(cluster))
+	})
+}
@@ -7,17 +7,44 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
-func processPaths(paths []string) genericclioptions.FileNameFlags {
-	// No arguments means we are reading from StdIn
+// processPaths returns the FileNameFlags for reading the manifests from
+// the paths given as arguments together with the files and directories
+// from the -f flags and the kustomization from the -k flag. The paths
+// given as arguments are always read recursively, while the -f flags are
+// only read recursively if -R is set. No paths at all means we are
+// reading from StdIn.
+func processPaths(paths []string, flags *genericclioptions.FileNameFlags) genericclioptions.FileNameFlags {
+	var fileNames []string
+	var kustomize string
+	recursive := false
+	if flags != nil {
+		if flags.Filenames != nil {
+			fileNames = append(fileNames, *flags.Filenames...)
+		}
+		if flags.Recursive != nil {
+			recursive = *flags.Recursive
+		}
+		if flags.Kustomize != nil {
+			kustomize = *flags.Kustomize
+		}
+	}
+	if len(paths) > 0 {
+		fileNames = append(fileNames, paths...)
+		recursive = true
+	}
+
 	fileNameFlags := genericclioptions.FileNameFlags{}
-	if len(paths) == 0 {
-		fileNames := []string{"-"}
+	if kustomize != "" {
+		fileNameFlags.Kustomize = &kustomize
+	}
+	if len(fileNames) == 0 && kustomize == "" {
+		fileNames = []string{"-"}
 		fileNameFlags.Filenames = &fileNames
 		return fileNameFlags
 	}
-
-	t := true
-	fileNameFlags.Filenames = &paths
-	fileNameFlags.Recursive = &t
+	fileNameFlags.Filenames = &fileNames
+	if recursive {
+		fileNameFlags.Recursive = &recursive
+	}
 	return fileNameFlags
 }
@@ -12,8 +12,12 @@ import (
 
 func TestProcessPaths(t *testing.T) {
 	trueVal := true
+	falseVal := false
+	kustomizeDir := "overlay"
+	var noFilenames []string
 	testCases := map[string]struct {
 		paths                 []string
+		flags                 *genericclioptions.FileNameFlags
 		expectedFileNameFlags genericclioptions.FileNameFlags
 	}{
 		"empty slice means reading from StdIn": {
@@ -36,11 +40,59 @@ func TestProcessPaths(t *testing.T) {
 				Recursive: &trueVal,
 			},
 		},
+		"filename flags are read recursively only with -R": {
+			flags: &genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base", "overlay"},
+				Recursive: &falseVal,
+			},
+			expectedFileNameFlags: genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base", "overlay"},
+			},
+		},
+		"filename flags with -R": {
+			flags: &genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base", "overlay"},
+				Recursive: &trueVal,
+			},
+			expectedFileNameFlags: genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base", "overlay"},
+				Recursive: &trueVal,
+			},
+		},
+		"filename flags are combined with the arguments": {
+			paths: []string{"dep.yaml"},
+			flags: &genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base"},
+				Recursive: &falseVal,
+			},
+			expectedFileNameFlags: genericclioptions.FileNameFlags{
+				Filenames: &[]string{"base", "dep.yaml"},
+				Recursive: &trueVal,
+			},
+		},
+		"kustomization only": {
+			flags: &genericclioptions.FileNameFlags{
+				Kustomize: &kustomizeDir,
+			},
+			expectedFileNameFlags: genericclioptions.FileNameFlags{
+				Filenames: &noFilenames,
+				Kustomize: &kustomizeDir,
+			},
+		},
+		"empty filename flags means reading from StdIn": {
+			flags: &genericclioptions.FileNameFlags{
+				Filenames: &[]string{},
+				Recursive: &falseVal,
+			},
+			expectedFileNameFlags: genericclioptions.FileNameFlags{
+				Filenames: &[]string{"-"},
+			},
+		},
 	}
 
 	for tn, tc := range testCases {
 		t.Run(tn, func(t *testing.T) {
-			fileNameFlags := processPaths(tc.paths)
+			fileNameFlags := processPaths(tc.paths, tc.flags)
 
 			assert.DeepEqual(t, tc.expectedFileNameFlags, fileNameFlags)
 		})
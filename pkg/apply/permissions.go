@@ -0,0 +1,186 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-errors/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// DeniedAction is an action on a resource the user is not allowed to do.
+type DeniedAction struct {
+	Identifier object.ObjMetadata
+	// Verb is the verb of the request, like create, patch or delete.
+	Verb string
+	// Reason is the reason given by the authorizer, if any.
+	Reason string
+}
+
+// String returns the verb and the resource, like
+// "create deployment.apps/app (namespace bar)".
+func (d DeniedAction) String() string {
+	s := fmt.Sprintf("%s %s", d.Verb, resourceIDToString(d.Identifier.GroupKind, d.Identifier.Name))
+	if d.Identifier.Namespace != "" {
+		s += fmt.Sprintf(" (namespace %s)", d.Identifier.Namespace)
+	}
+	if d.Reason != "" {
+		s += ": " + d.Reason
+	}
+	return s
+}
+
+// PermissionError is returned before anything is applied if the
+// permissions are checked, and the user is not allowed to apply or
+// prune some of the resources.
+type PermissionError struct {
+	// User is the user that was impersonated, if any.
+	User   string
+	Denied []DeniedAction
+}
+
+func (e *PermissionError) Error() string {
+	user := "the user"
+	if e.User != "" {
+		user = e.User
+	}
+	lines := []string{fmt.Sprintf("%s is not allowed to perform %d action(s) of the run:", user, len(e.Denied))}
+	for _, d := range e.Denied {
+		lines = append(lines, "  "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// impersonating returns the user or the groups impersonated by the
+// config, or an empty string if there is no impersonation.
+func impersonating(config *rest.Config) string {
+	if config == nil {
+		return ""
+	}
+	if config.Impersonate.UserName != "" {
+		return config.Impersonate.UserName
+	}
+	return strings.Join(config.Impersonate.Groups, ",")
+}
+
+// permissionCheck is a request whose authorization is checked.
+type permissionCheck struct {
+	id       object.ObjMetadata
+	verb     string
+	resource string
+}
+
+// checkPermissions asks the API server whether the user is allowed to
+// apply the resources and to prune the resources that are no longer in
+// the package, and returns a PermissionError with every action that is
+// denied. With impersonation, the impersonated user is checked.
+func (a *Applier) checkPermissions(infos []*resource.Info) error {
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return errors.WrapPrefix(err, "error creating client", 1)
+	}
+	var pruneSet []*object.ObjMetadata
+	if !a.NoPrune {
+		pruneSet, err = a.pruner().PruneSet(infos)
+		if err != nil {
+			return errors.WrapPrefix(err, "error reading inventory", 1)
+		}
+	}
+	var denied []DeniedAction
+	for _, info := range infos {
+		if info.Mapping == nil {
+			continue
+		}
+		check := permissionCheck{id: infoToObjMetadata(info), verb: "get", resource: info.Mapping.Resource.Resource}
+		d, err := reviewPermission(client, check)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			denied = append(denied, *d)
+			continue
+		}
+		// Existing resources are patched, the others are created.
+		live, err := getLive(info)
+		if err != nil {
+			return errors.WrapPrefix(err, "error reading live resources", 1)
+		}
+		check.verb = "create"
+		if live != nil {
+			check.verb = "patch"
+		}
+		d, err = reviewPermission(client, check)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			denied = append(denied, *d)
+		}
+	}
+
+	if len(pruneSet) > 0 {
+		mapper, err := restMapper(a.clients, a.factory, false)
+		if err != nil {
+			return errors.WrapPrefix(err, "error getting RESTMapper", 1)
+		}
+		for _, id := range pruneSet {
+			mapping, err := mapper.RESTMapping(id.GroupKind)
+			if meta.IsNoMatchError(err) {
+				// The kind is no longer served, so nothing is deleted.
+				continue
+			}
+			if err != nil {
+				return errors.WrapPrefix(err, "error getting the kinds served by the cluster", 1)
+			}
+			d, err := reviewPermission(client, permissionCheck{id: *id, verb: "delete",
+				resource: mapping.Resource.Resource})
+			if err != nil {
+				return err
+			}
+			if d != nil {
+				denied = append(denied, *d)
+			}
+		}
+	}
+
+	if len(denied) == 0 {
+		return nil
+	}
+	var user string
+	if config, err := a.factory.ToRESTConfig(); err == nil {
+		user = impersonating(config)
+	}
+	return &PermissionError{User: user, Denied: denied}
+}
+
+// reviewPermission returns the denied action if the
+// user is not allowed to perform the check.
+func reviewPermission(client kubernetes.Interface, check permissionCheck) (*DeniedAction, error) {
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: check.id.Namespace,
+				Verb:      check.verb,
+				Group:     check.id.GroupKind.Group,
+				Resource:  check.resource,
+				Name:      check.id.Name,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error checking whether %s is allowed on %s: %v", check.verb,
+			resourceIDToString(check.id.GroupKind, check.id.Name), err)
+	}
+	if review.Status.Allowed {
+		return nil, nil
+	}
+	return &DeniedAction{Identifier: check.id, Verb: check.verb, Reason: review.Status.Reason}, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+// authorizer returns a clientset that allows all the requests,
+// except the ones with a verb and name in denied.
+func authorizer(denied map[string]string) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			attrs := review.Spec.ResourceAttributes
+			review.Status.Allowed = denied[attrs.Verb] != attrs.Name
+			if !review.Status.Allowed {
+				review.Status.Reason = "RBAC: not allowed"
+			}
+			return true, review, nil
+		})
+	return client
+}
+
+func TestCheckPermissions(t *testing.T) {
+	testCases := map[string]struct {
+		denied   map[string]string
+		expected []string
+	}{
+		"all allowed": {},
+		"denied actions": {
+			denied: map[string]string{"create": "new", "patch": "existing", "delete": "old"},
+			expected: []string{
+				"patch configmap/existing (namespace default): RBAC: not allowed",
+				"create configmap/new (namespace default): RBAC: not allowed",
+				"delete configmap/old (namespace default): RBAC: not allowed",
+			},
+		},
+		"get denied": {
+			denied:   map[string]string{"get": "existing"},
+			expected: []string{"get configmap/existing (namespace default): RBAC: not allowed"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			newApplier := func(opts ...ApplierOption) *Applier {
+				applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+					append(opts, WithNoWait())...)
+				require.NoError(t, err)
+				cmd := &cobra.Command{}
+				require.NoError(t, applier.SetFlags(cmd))
+				cmdutil.AddValidateFlags(cmd)
+				cmdutil.AddServerSideApplyFlags(cmd)
+				require.NoError(t, cmd.Flags().Set("filename", "-"))
+				require.NoError(t, applier.Initialize(cmd, nil))
+				return applier
+			}
+			inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			for e := range newApplier().RunObjects(context.Background(), []*unstructured.Unstructured{
+				inventory.DeepCopy(), configMap("existing", nil), configMap("old", nil),
+			}) {
+				require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+			}
+			before := cluster.Objects()
+
+			applier := newApplier(WithClients(Clients{Clientset: authorizer(tc.denied)}),
+				WithDryRun(common.DryRunClient))
+			applier.CheckPermissions = true
+			var errs []error
+			for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+				inventory.DeepCopy(), configMap("existing", nil), configMap("new", nil),
+			}) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+			assert.Equal(t, before, cluster.Objects())
+			if len(tc.expected) == 0 {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			var permissionErr *PermissionError
+			require.True(t, errors.As(errs[0], &permissionErr))
+			var denied []string
+			for _, d := range permissionErr.Denied {
+				denied = append(denied, d.String())
+			}
+			assert.Equal(t, tc.expected, denied)
+			assert.True(t, IsForbiddenError(errs[0]))
+			assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+		})
+	}
+}
+
+func TestImpersonating(t *testing.T) {
+	assert.Equal(t, "", impersonating(nil))
+	assert.Equal(t, "", impersonating(&rest.Config{}))
+	assert.Equal(t, "system:serviceaccount:app:deployer", impersonating(&rest.Config{
+		Impersonate: rest.ImpersonationConfig{UserName: "system:serviceaccount:app:deployer"},
+	}))
+	assert.Equal(t, "team-a,team-b", impersonating(&rest.Config{
+		Impersonate: rest.ImpersonationConfig{Groups: []string{"team-a", "team-b"}},
+	}))
+}
+
+func TestPermissionError(t *testing.T) {
+	err := &PermissionError{User: "alice", Denied: []DeniedAction{{Verb: "delete"}}}
+	assert.Contains(t, err.Error(), "alice is not allowed to perform 1 action(s) of the run:\n  delete")
+	err.User = ""
+	assert.Contains(t, err.Error(), "the user is not allowed")
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// The Applier and the Destroyer run the resources through a pipeline:
+// the Planner orders them, the Actuator applies them, the StatusPoller
+// waits for them to reconcile, the InventoryClient records the outcome
+// in the grouping object and the Pruner deletes the resources that are
+// no longer in the package. Every stage can be replaced, or wrapped to
+// add checks, by setting the corresponding field. The default is used
+// if a field is nil.
+
+// Planner decides in which order the resources are applied.
+type Planner interface {
+	// Plan returns the resources in the order they should be
+	// applied. The run is aborted if it returns an error. Resources
+	// that are left out are removed from the inventory, so they
+	// are pruned.
+	Plan(ctx context.Context, infos []*resource.Info) ([]*resource.Info, error)
+}
+
+// Actuator applies the resources to the cluster.
+type Actuator interface {
+	// Apply applies the resources in the given order.
+	Apply(ctx context.Context, infos []*resource.Info) error
+}
+
+// InventoryClient records the outcome of a run in the grouping object.
+type InventoryClient interface {
+	// WriteStatus records the status of every resource, and the
+	// aggregate status, in the grouping object in the cluster.
+	WriteStatus(infos []*resource.Info, statuses map[string]status.Status, aggregateStatus status.Status) error
+	// WriteApplied records in the grouping object in the cluster that
+	// only the given resources were applied, when a run stops before
+	// applying all of them. The grouping object is among the resources.
+	WriteApplied(applied []*resource.Info) error
+}
+
+// Pruner deletes the resources that are in the previous inventories,
+// but not in the current one. It is implemented by prune.PruneOptions.
+type Pruner interface {
+	// Prune deletes the resources, and the previous grouping objects,
+	// and sends an event for each of them on the channel.
+	Prune(infos []*resource.Info, eventChannel chan<- event.Event) error
+	// PruneSet returns the resources that Prune would delete,
+	// without changing anything in the cluster.
+	PruneSet(infos []*resource.Info) ([]*object.ObjMetadata, error)
+}
+
+var _ Pruner = &prune.PruneOptions{}
+
+// SortingPlanner is the default Planner. It sorts the resources so
+// the ones that others depend on, like namespaces and CRDs, are
+// applied first.
+type SortingPlanner struct{}
+
+// Plan sorts the resources, keeping the order of the manifests
+// for resources of the same kind.
+func (SortingPlanner) Plan(_ context.Context, infos []*resource.Info) ([]*resource.Info, error) {
+	sort.Stable(ResourceInfos(infos))
+	return infos, nil
+}
+
+// KubectlActuator is the default Actuator. It applies the resources
+// with the kubectl ApplyOptions, whose printer reports every applied
+// resource as an event.
+type KubectlActuator struct {
+	// ApplyOptions are used to apply the resources. If they are nil,
+	// the copy of the ApplyOptions made for the run is used, which is
+	// needed for runs of the same Applier to happen at the same time.
+	ApplyOptions *apply.ApplyOptions
+}
+
+// Apply applies the resources with the ApplyOptions.
+func (k *KubectlActuator) Apply(ctx context.Context, infos []*resource.Info) error {
+	o := k.ApplyOptions
+	if o == nil {
+		o = applyOptionsFrom(ctx)
+	}
+	if o == nil {
+		return fmt.Errorf("no ApplyOptions to apply the resources with")
+	}
+	o.SetObjects(infos)
+	return o.Run()
+}
+
+type applyOptionsKey struct{}
+
+// withApplyOptions returns a context that carries the
+// ApplyOptions of the run to the KubectlActuator.
+func withApplyOptions(ctx context.Context, o *apply.ApplyOptions) context.Context {
+	return context.WithValue(ctx, applyOptionsKey{}, o)
+}
+
+// applyOptionsFrom returns the ApplyOptions of the run,
+// or nil if the context doesn't carry any.
+func applyOptionsFrom(ctx context.Context) *apply.ApplyOptions {
+	o, _ := ctx.Value(applyOptionsKey{}).(*apply.ApplyOptions)
+	return o
+}
+
+// GroupingObjectInventoryClient is the default InventoryClient. It
+// updates the grouping object with the clients of the resources.
+type GroupingObjectInventoryClient struct{}
+
+// WriteStatus fetches the current grouping object from the cluster,
+// records the statuses in it and updates it in the cluster. This gives
+// later runs and other observers a record of the outcome of the last
+// reconcile. If the grouping object was modified concurrently, the
+// update conflicts, and the statuses are merged into the grouping
+// object read again, up to the steps of the inventoryUpdateBackoff.
+func (GroupingObjectInventoryClient) WriteStatus(infos []*resource.Info, statuses map[string]status.Status,
+	aggregateStatus status.Status) error {
+	return updateGroupingObject(infos, func(u *unstructured.Unstructured) error {
+		return prune.AddStatusToGroupingObj(u, statuses, aggregateStatus)
+	})
+}
+
+// WriteApplied removes the resources that were not applied from the
+// inventory of the grouping object in the cluster, so the next run
+// doesn't expect them to exist.
+func (GroupingObjectInventoryClient) WriteApplied(applied []*resource.Info) error {
+	return updateGroupingObject(applied, func(u *unstructured.Unstructured) error {
+		return prune.RetainInventoryInGroupingObj(u, applied)
+	})
+}
+
+// updateGroupingObject reads the grouping object from the cluster,
+// changes it with the function and updates it, retrying on conflicts.
+func updateGroupingObject(infos []*resource.Info, update func(*unstructured.Unstructured) error) error {
+	groupingInfo, found := prune.FindGroupingObject(infos)
+	if !found {
+		return ErrInventoryNotFound
+	}
+	helper := resource.NewHelper(groupingInfo.Client, groupingInfo.Mapping)
+	return retry.RetryOnConflict(inventoryUpdateBackoff, func() error {
+		obj, err := helper.Get(groupingInfo.Namespace, groupingInfo.Name, false)
+		if err != nil {
+			return err
+		}
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return fmt.Errorf("grouping object is not an Unstructured: %#v", obj)
+		}
+		if err := update(u); err != nil {
+			return err
+		}
+		_, err = helper.Replace(groupingInfo.Namespace, groupingInfo.Name, true, u)
+		return err
+	})
+}
+
+// inventoryUpdateBackoff bounds the retries of the updates of the
+// grouping object that conflict with a concurrent modification.
+var inventoryUpdateBackoff = retry.DefaultRetry
+
+// planner returns the Planner, or the SortingPlanner if none is set.
+func (a *Applier) planner() Planner {
+	if a.Planner == nil {
+		return SortingPlanner{}
+	}
+	return a.Planner
+}
+
+// actuator returns the Actuator, or a KubectlActuator with the
+// ApplyOptions of the run if none is set.
+func (a *Applier) actuator() Actuator {
+	if a.Actuator == nil {
+		return &KubectlActuator{}
+	}
+	return a.Actuator
+}
+
+// inventoryClient returns the InventoryClient, or the
+// GroupingObjectInventoryClient if none is set.
+func (a *Applier) inventoryClient() InventoryClient {
+	if a.InventoryClient == nil {
+		return GroupingObjectInventoryClient{}
+	}
+	return a.InventoryClient
+}
+
+// pruner returns the Pruner, or the PruneOptions if none is set.
+func (a *Applier) pruner() Pruner {
+	if a.Pruner == nil {
+		return a.PruneOptions
+	}
+	return a.Pruner
+}
+
+// pruner returns the Pruner, or the PruneOptions if none is set.
+func (d *Destroyer) pruner() Pruner {
+	if d.Pruner == nil {
+		return d.PruneOptions
+	}
+	return d.Pruner
+}
@@ -0,0 +1,244 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// recordingPlanner records the resources it planned, and
+// delegates to the wrapped Planner.
+type recordingPlanner struct {
+	Planner
+	planned int
+}
+
+func (p *recordingPlanner) Plan(ctx context.Context, infos []*resource.Info) ([]*resource.Info, error) {
+	p.planned = len(infos)
+	return p.Planner.Plan(ctx, infos)
+}
+
+// policyActuator rejects resources with the given name, and
+// applies the others with the wrapped Actuator.
+type policyActuator struct {
+	Actuator
+	denied string
+}
+
+func (p *policyActuator) Apply(ctx context.Context, infos []*resource.Info) error {
+	for _, info := range infos {
+		if info.Name == p.denied {
+			return fmt.Errorf("%s is not allowed", info.Name)
+		}
+	}
+	return p.Actuator.Apply(ctx, infos)
+}
+
+func TestApplierPipeline(t *testing.T) {
+	testCases := map[string]struct {
+		denied    string
+		expectErr bool
+	}{
+		"wrapped actuator applies": {
+			denied: "other",
+		},
+		"wrapped actuator rejects": {
+			denied:    "cm",
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			dir, err := ioutil.TempDir("", "pipeline-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			write := func(name, content string) {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+			}
+			write("grouping.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: inventory\n  labels:\n"+
+				"    cli-utils.sigs.k8s.io/inventory-id: test\n")
+			write("cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")
+
+			applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", dir))
+			require.NoError(t, applier.Initialize(cmd, nil))
+			planner := &recordingPlanner{Planner: applier.planner()}
+			applier.Planner = planner
+			applier.Actuator = &policyActuator{Actuator: applier.actuator(), denied: tc.denied}
+
+			var errs []error
+			for e := range applier.Run(context.Background()) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+			assert.Equal(t, 2, planner.planned)
+			gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+			found := cluster.Get(gvk, fakecluster.DefaultNamespace, "cm") != nil
+			if tc.expectErr {
+				assert.Len(t, errs, 1)
+				assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+				assert.False(t, found)
+				return
+			}
+			assert.Empty(t, errs)
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestPreRunGatePlan(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier := NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+	var plans []Plan
+	applier.PreRunGate = func(plan Plan) error {
+		plans = append(plans, plan)
+		return nil
+	}
+
+	inventory := map[string]string{prune.GroupingLabel: "test"}
+	for _, objs := range [][]*unstructured.Unstructured{
+		{configMap("inventory", inventory), configMap("a", nil), configMap("b", nil)},
+		{configMap("inventory", inventory), configMap("a", nil)},
+	} {
+		for e := range applier.RunObjects(context.Background(), objs) {
+			if e.Type == event.ErrorType {
+				t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+			}
+		}
+	}
+
+	require.Len(t, plans, 2)
+	assert.Len(t, plans[0].Apply, 3)
+	assert.Empty(t, plans[0].Delete)
+	// The plan is computed from the grouping object that is applied,
+	// which already has the inventory of the second run.
+	assert.Len(t, plans[1].Apply, 2)
+	if assert.Len(t, plans[1].Delete, 1) {
+		assert.Equal(t, "b", plans[1].Delete[0].Name)
+	}
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	assert.Nil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "b"))
+}
+
+// conflictingTransport modifies the grouping object in the Cluster
+// before the first updates of it reach the Cluster, like a concurrent
+// run would, so they conflict.
+type conflictingTransport struct {
+	cluster   *fakecluster.Cluster
+	conflicts int
+}
+
+func (c *conflictingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPut && c.conflicts > 0 {
+		c.conflicts--
+		obj := c.cluster.Get(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			fakecluster.DefaultNamespace, "inventory")
+		if err := unstructured.SetNestedField(obj.Object, "", "data", "default_other__ConfigMap"); err != nil {
+			return nil, err
+		}
+		if err := c.cluster.Update(obj); err != nil {
+			return nil, err
+		}
+	}
+	return c.cluster.RoundTrip(req)
+}
+
+func TestGroupingObjectInventoryClientConflicts(t *testing.T) {
+	testCases := map[string]struct {
+		conflicts int
+		expectErr bool
+	}{
+		"no conflict": {},
+		"retried after conflicts": {
+			conflicts: 2,
+		},
+		"too many conflicts": {
+			conflicts: 100,
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			grouping := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			grouping.SetNamespace(fakecluster.DefaultNamespace)
+			grouping.Object["data"] = map[string]interface{}{"default_cm__ConfigMap": ""}
+			cluster, err := fakecluster.New(grouping)
+			require.NoError(t, err)
+
+			config := cluster.RESTConfig()
+			config.Transport = &conflictingTransport{cluster: cluster, conflicts: tc.conflicts}
+			config.ContentConfig = resource.UnstructuredPlusDefaultContentConfig()
+			config.GroupVersion = &schema.GroupVersion{Version: "v1"}
+			config.APIPath = "/api"
+			client, err := rest.RESTClientFor(config)
+			require.NoError(t, err)
+			info := &resource.Info{
+				Client: client,
+				Mapping: &meta.RESTMapping{
+					Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+					GroupVersionKind: grouping.GroupVersionKind(),
+					Scope:            meta.RESTScopeNamespace,
+				},
+				Namespace: grouping.GetNamespace(),
+				Name:      grouping.GetName(),
+				Object:    grouping,
+			}
+
+			err = GroupingObjectInventoryClient{}.WriteStatus([]*resource.Info{info},
+				map[string]status.Status{"default_cm__ConfigMap": status.CurrentStatus}, status.CurrentStatus)
+			if tc.expectErr {
+				assert.True(t, apierrors.IsConflict(err), "expected a conflict, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			live := cluster.Get(grouping.GroupVersionKind(), grouping.GetNamespace(), grouping.GetName())
+			data, _, _ := unstructured.NestedStringMap(live.Object, "data")
+			expected := map[string]string{"default_cm__ConfigMap": "Current"}
+			if tc.conflicts > 0 {
+				// The concurrent change is kept.
+				expected["default_other__ConfigMap"] = "Unknown"
+			}
+			assert.Equal(t, expected, data)
+			assert.Equal(t, "Current", live.GetAnnotations()[prune.GroupingStatus])
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package poller provides a library for waiting until a set of
+// resources, identified by their ObjMetadata, have reached a desired
+// status in the cluster. It builds on the observe library, but hides
+// the details of how the observer is configured so it can be used by
+// both the CLI and controllers.
+package poller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/aggregator"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultPollInterval is the poll interval used if no
+	// other value is provided through WithPollInterval.
+	DefaultPollInterval = 2 * time.Second
+)
+
+// Option is a functional option for configuring a StatusPoller.
+type Option func(*StatusPoller)
+
+// WithPollInterval sets how often the cluster is polled for the
+// latest state of the resources.
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *StatusPoller) {
+		s.pollInterval = interval
+	}
+}
+
+// WithTimeout sets the maximum time a call to Wait will run before it
+// gives up. A value of 0 means no timeout, so the wait only ends when
+// the desired status is reached or the context is cancelled.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *StatusPoller) {
+		s.timeout = timeout
+	}
+}
+
+// WithReaderFactory sets the factory function used to create the
+// ClusterReader for each call to Wait. The default uses LIST calls
+// to cache all the needed resources before every polling loop.
+func WithReaderFactory(f observer.ReaderFactoryFunc) Option {
+	return func(s *StatusPoller) {
+		s.readerFactoryFunc = f
+	}
+}
+
+// WithObserversFactory sets the factory function used to create the
+// resource observers that compute status for each resource.
+func WithObserversFactory(f observer.ObserversFactoryFunc) Option {
+	return func(s *StatusPoller) {
+		s.observersFactoryFunc = f
+	}
+}
+
+// StatusPoller waits for sets of resources to reach a desired status.
+// A StatusPoller can be used for any number of calls to Wait.
+type StatusPoller struct {
+	reader client.Reader
+	mapper meta.RESTMapper
+
+	pollInterval         time.Duration
+	timeout              time.Duration
+	readerFactoryFunc    observer.ReaderFactoryFunc
+	observersFactoryFunc observer.ObserversFactoryFunc
+}
+
+// NewStatusPoller returns a new StatusPoller that will use the provided
+// reader and mapper to look up resources in the cluster.
+func NewStatusPoller(reader client.Reader, mapper meta.RESTMapper, opts ...Option) *StatusPoller {
+	s := &StatusPoller{
+		reader:               reader,
+		mapper:               mapper,
+		pollInterval:         DefaultPollInterval,
+		readerFactoryFunc:    observe.DefaultReaderFactoryFunc,
+		observersFactoryFunc: observe.DefaultObserversFactoryFunc,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Wait polls the resources identified by the given ObjMetadata until all
+// of them have reached the desired status, the context is cancelled or the
+// timeout is reached. The only supported desired statuses are Current, which
+// also accepts resources that are NotFound, and NotFound, which is used to
+// wait for resources to be deleted. Updates are provided through the
+// returned channel, which is closed when the wait is over.
+func (s *StatusPoller) Wait(ctx context.Context, objs []*object.ObjMetadata, desired status.Status) <-chan event.Event {
+	aggregatorFactoryFunc, err := aggregatorFactoryFuncForStatus(desired)
+	if err != nil {
+		ch := make(chan event.Event, 1)
+		ch <- event.Event{
+			EventType: event.ErrorEvent,
+			Error:     err,
+		}
+		close(ch)
+		return ch
+	}
+
+	var cancel context.CancelFunc
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	o := &observer.Observer{
+		Reader:                s.reader,
+		Mapper:                s.mapper,
+		AggregatorFactoryFunc: aggregatorFactoryFunc,
+		ReaderFactoryFunc:     s.readerFactoryFunc,
+		ObserversFactoryFunc:  s.observersFactoryFunc,
+	}
+	observerCh := o.Observe(ctx, ObjMetadataToIdentifiers(objs), s.pollInterval, false)
+
+	// Forward the events so the context can be cancelled once the
+	// observer has shut down. This makes sure we don't leak the
+	// context created for the timeout.
+	ch := make(chan event.Event)
+	go func() {
+		defer cancel()
+		defer close(ch)
+		for e := range observerCh {
+			ch <- e
+		}
+	}()
+	return ch
+}
+
+// WaitForCurrent waits for all the given resources to become Current.
+func (s *StatusPoller) WaitForCurrent(ctx context.Context, objs []*object.ObjMetadata) <-chan event.Event {
+	return s.Wait(ctx, objs, status.CurrentStatus)
+}
+
+// WaitForDeleted waits for all the given resources to be removed
+// from the cluster.
+func (s *StatusPoller) WaitForDeleted(ctx context.Context, objs []*object.ObjMetadata) <-chan event.Event {
+	return s.Wait(ctx, objs, status.NotFoundStatus)
+}
+
+func aggregatorFactoryFuncForStatus(desired status.Status) (observer.AggregatorFactoryFunc, error) {
+	switch desired {
+	case status.CurrentStatus:
+		return observe.DefaultAggregatorFactoryFunc, nil
+	case status.NotFoundStatus:
+		return func(identifiers []wait.ResourceIdentifier) observer.StatusAggregator {
+			return aggregator.NewAllNotFoundStatusAggregator(identifiers)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported desired status %s", desired)
+	}
+}
+
+// ObjMetadataToIdentifiers converts the ObjMetadata into the
+// ResourceIdentifiers used by the observe library.
+func ObjMetadataToIdentifiers(objs []*object.ObjMetadata) []wait.ResourceIdentifier {
+	identifiers := make([]wait.ResourceIdentifier, 0, len(objs))
+	for _, obj := range objs {
+		identifiers = append(identifiers, wait.ResourceIdentifier{
+			GroupKind: obj.GroupKind,
+			Name:      obj.Name,
+			Namespace: obj.Namespace,
+		})
+	}
+	return identifiers
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package poller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/testutil"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var deploymentObj = &object.ObjMetadata{
+	Namespace: "default",
+	Name:      "foo",
+	GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+}
+
+func TestWait(t *testing.T) {
+	testCases := map[string]struct {
+		desired            status.Status
+		observedStatuses   []status.Status
+		timeout            time.Duration
+		expectedEventTypes []event.EventType
+	}{
+		"wait for current": {
+			desired:          status.CurrentStatus,
+			observedStatuses: []status.Status{status.InProgressStatus, status.CurrentStatus},
+			expectedEventTypes: []event.EventType{
+				event.ResourceUpdateEvent,
+				event.ResourceUpdateEvent,
+				event.CompletedEvent,
+			},
+		},
+		"wait for deleted": {
+			desired:          status.NotFoundStatus,
+			observedStatuses: []status.Status{status.TerminatingStatus, status.NotFoundStatus},
+			expectedEventTypes: []event.EventType{
+				event.ResourceUpdateEvent,
+				event.ResourceUpdateEvent,
+				event.CompletedEvent,
+			},
+		},
+		"timeout before current": {
+			desired:          status.CurrentStatus,
+			observedStatuses: []status.Status{status.InProgressStatus},
+			timeout:          100 * time.Millisecond,
+			expectedEventTypes: []event.EventType{
+				event.ResourceUpdateEvent,
+				event.AbortedEvent,
+			},
+		},
+		"unsupported desired status": {
+			desired: status.FailedStatus,
+			expectedEventTypes: []event.EventType{
+				event.ErrorEvent,
+			},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			fakeObserver := &fakeResourceObserver{
+				statuses: tc.observedStatuses,
+			}
+			statusPoller := NewStatusPoller(nil, testutil.NewFakeRESTMapper(),
+				WithPollInterval(10*time.Millisecond),
+				WithTimeout(tc.timeout),
+				WithReaderFactory(func(_ client.Reader, _ meta.RESTMapper, _ []wait.ResourceIdentifier) (
+					observer.ClusterReader, error) {
+					return testutil.NewNoopObserverReader(), nil
+				}),
+				WithObserversFactory(func(_ observer.ClusterReader, _ meta.RESTMapper) (
+					map[schema.GroupKind]observer.ResourceObserver, observer.ResourceObserver) {
+					return map[schema.GroupKind]observer.ResourceObserver{}, fakeObserver
+				}),
+			)
+
+			ch := statusPoller.Wait(context.Background(), []*object.ObjMetadata{deploymentObj}, tc.desired)
+
+			var eventTypes []event.EventType
+			for e := range ch {
+				eventTypes = append(eventTypes, e.EventType)
+			}
+			assert.DeepEqual(t, tc.expectedEventTypes, eventTypes)
+		})
+	}
+}
+
+func TestObjMetadataToIdentifiers(t *testing.T) {
+	identifiers := ObjMetadataToIdentifiers([]*object.ObjMetadata{deploymentObj})
+
+	assert.DeepEqual(t, []wait.ResourceIdentifier{
+		{
+			GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+			Name:      "foo",
+			Namespace: "default",
+		},
+	}, identifiers)
+}
+
+// fakeResourceObserver returns the statuses in order for each call
+// to Observe, and then keeps returning the last one.
+type fakeResourceObserver struct {
+	statuses []status.Status
+	count    int
+}
+
+func (f *fakeResourceObserver) Observe(_ context.Context, identifier wait.ResourceIdentifier) *event.ObservedResource {
+	s := f.statuses[len(f.statuses)-1]
+	if f.count < len(f.statuses) {
+		s = f.statuses[f.count]
+	}
+	f.count++
+	return &event.ObservedResource{
+		Identifier: identifier,
+		Status:     s,
+	}
+}
+
+func (f *fakeResourceObserver) ObserveObject(_ context.Context, _ *unstructured.Unstructured) *event.ObservedResource {
+	return nil
+}
+
+func (f *fakeResourceObserver) SetComputeStatusFunc(_ observer.ComputeStatusFunc) {}
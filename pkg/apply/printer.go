@@ -0,0 +1,209 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+const (
+	// EventsOutput prints the events in the default format
+	// for kubectl.
+	EventsOutput = "events"
+	// JSONOutput prints every event as a JSON object on
+	// a separate line.
+	JSONOutput = "json"
+	// GroupedOutput prints the events for every resource grouped
+	// together once the operation has finished.
+	GroupedOutput = "grouped"
+	// TableOutput prints a table with the last action and
+	// the latest status of every resource.
+	TableOutput = "table"
+)
+
+// Printer prints the events from the channel returned from the
+// Applier or Destroyer. Embedders can provide their own implementation,
+// for example to forward the events into their UI, and make it
+// available as an output format with RegisterPrinter.
+type Printer interface {
+	// Print blocks until the channel is closed.
+	Print(ch <-chan event.Event)
+}
+
+// PrinterFactory creates a new Printer that will write to the
+// provided IOStreams.
+type PrinterFactory func(ioStreams genericclioptions.IOStreams) Printer
+
+var (
+	printersMu       sync.RWMutex
+	printerFactories = map[string]PrinterFactory{
+		EventsOutput: func(ioStreams genericclioptions.IOStreams) Printer {
+			return &BasicPrinter{IOStreams: ioStreams}
+		},
+		JSONOutput: func(ioStreams genericclioptions.IOStreams) Printer {
+			return &JSONPrinter{IOStreams: ioStreams}
+		},
+		GroupedOutput: func(ioStreams genericclioptions.IOStreams) Printer {
+			return &GroupedPrinter{IOStreams: ioStreams}
+		},
+		TableOutput: func(ioStreams genericclioptions.IOStreams) Printer {
+			return &TablePrinter{IOStreams: ioStreams}
+		},
+	}
+)
+
+// RegisterPrinter makes a Printer available as an output format
+// under the given name. It returns an error if the name is empty, or a
+// printer has already been registered with the same name.
+func RegisterPrinter(name string, factory PrinterFactory) error {
+	if name == "" {
+		return fmt.Errorf("printer name must not be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("printer %q has no factory", name)
+	}
+	printersMu.Lock()
+	defer printersMu.Unlock()
+	if _, found := printerFactories[name]; found {
+		return fmt.Errorf("printer %q is already registered", name)
+	}
+	printerFactories[name] = factory
+	return nil
+}
+
+// SupportedOutputs returns the output formats that can be
+// passed to GetPrinter.
+func SupportedOutputs() []string {
+	printersMu.RLock()
+	defer printersMu.RUnlock()
+	var outputs []string
+	for name := range printerFactories {
+		outputs = append(outputs, name)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// GetPrinter returns a Printer for the given output format.
+func GetPrinter(output string, ioStreams genericclioptions.IOStreams) (Printer, error) {
+	printersMu.RLock()
+	factory, found := printerFactories[output]
+	printersMu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown output format %q, must be one of %v", output, SupportedOutputs())
+	}
+	return factory(ioStreams), nil
+}
+
+func NewPrinterOptions() *PrinterOptions {
+	return &PrinterOptions{
+		Output:      EventsOutput,
+		MinSeverity: "info",
+	}
+}
+
+// PrinterOptions captures the command line flags that decide
+// how events are printed.
+type PrinterOptions struct {
+	Output  string
+	Quiet   bool
+	Summary bool
+	// EventTypes limits the printed events to the ones with the
+	// given types. All events are printed if it is empty.
+	EventTypes []string
+	// MinSeverity limits the printed events to the ones with
+	// at least the given severity.
+	MinSeverity string
+	NoColor     bool
+}
+
+func (p *PrinterOptions) AddFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&p.Output, "output", "o", p.Output,
+		fmt.Sprintf("Output format, must be one of %v", SupportedOutputs()))
+	c.Flags().BoolVarP(&p.Quiet, "quiet", "q", p.Quiet,
+		"Only print failures. Per-resource progress and the final counts are not printed.")
+	c.Flags().BoolVar(&p.Summary, "summary", p.Summary,
+		"Only print the final counts and any failures once the operation completes.")
+	c.Flags().StringSliceVar(&p.EventTypes, "event-types", p.EventTypes,
+		"Only print events of the given types. Supported types are apply, status, prune, delete, diff, progress and error.")
+	c.Flags().StringVar(&p.MinSeverity, "min-severity", p.MinSeverity,
+		"Only print events with at least the given severity. Must be one of info, warning or error.")
+	c.Flags().BoolVar(&p.NoColor, "no-color", p.NoColor,
+		"If true, don't use colors in the output. Colors are only used when printing to a terminal.")
+}
+
+// ToPrinter returns the Printer selected by the flags. If any of the
+// filtering flags are set, only the events that match them will be
+// passed on to the printer.
+func (p *PrinterOptions) ToPrinter(ioStreams genericclioptions.IOStreams) (Printer, error) {
+	printer, err := p.toPrinter(ioStreams)
+	if err != nil {
+		return nil, err
+	}
+	var filters []EventFilter
+	if len(p.EventTypes) > 0 {
+		types, err := ParseEventTypes(p.EventTypes)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, TypeFilter(types...))
+	}
+	if p.MinSeverity != "" {
+		severity, err := ParseSeverity(p.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+		if severity > event.SeverityInfo {
+			filters = append(filters, SeverityFilter(severity))
+		}
+	}
+	if len(filters) == 0 {
+		return printer, nil
+	}
+	return &filteringPrinter{
+		printer: printer,
+		filters: filters,
+	}, nil
+}
+
+func (p *PrinterOptions) toPrinter(ioStreams genericclioptions.IOStreams) (Printer, error) {
+	if p.Quiet || p.Summary {
+		if p.Output != EventsOutput {
+			return nil, fmt.Errorf("--quiet and --summary can not be combined with --output=%s", p.Output)
+		}
+		return &SummaryPrinter{
+			IOStreams: ioStreams,
+			Quiet:     p.Quiet,
+		}, nil
+	}
+	printer, err := GetPrinter(p.Output, ioStreams)
+	if err != nil {
+		return nil, err
+	}
+	if basicPrinter, ok := printer.(*BasicPrinter); ok {
+		basicPrinter.NoColor = p.NoColor
+	}
+	return printer, nil
+}
+
+// filteringPrinter only passes on the events accepted by the
+// filters to the wrapped printer. Error events are always passed
+// on, so failures are reported even if they are filtered out.
+type filteringPrinter struct {
+	printer Printer
+	filters []EventFilter
+}
+
+func (f *filteringPrinter) Print(ch <-chan event.Event) {
+	filter := func(e event.Event) bool {
+		return e.Type == event.ErrorType || accept(e, f.filters)
+	}
+	f.printer.Print(FilterEvents(ch, filter))
+}
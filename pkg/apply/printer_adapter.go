@@ -4,12 +4,16 @@
 package apply
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/printers"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 // KubectlPrinterAdapter is a workaround for capturing progress from
@@ -19,26 +23,95 @@ import (
 // printing the info, it emits it as an event on the provided channel.
 type KubectlPrinterAdapter struct {
 	ch chan<- event.Event
+
+	// progress is used to emit progress events after every applied
+	// resource. No progress events are emitted if it is nil.
+	progress *progressCounter
+
+	// sensitiveFields are redacted in the objects included
+	// in the events.
+	sensitiveFields []object.SensitiveField
+
+	// ctx and tracer are used to create a span for every
+	// applied resource.
+	ctx    context.Context
+	tracer trace.Tracer
 }
 
 // resourcePrinterImpl implements the ResourcePrinter interface. But
 // instead of printing, it emits information on the provided channel.
 type resourcePrinterImpl struct {
-	applyOperation event.ApplyEventOperation
-	ch             chan<- event.Event
+	applyOperation  event.ApplyEventOperation
+	ch              chan<- event.Event
+	progress        *progressCounter
+	sensitiveFields []object.SensitiveField
+	ctx             context.Context
+	tracer          trace.Tracer
+}
+
+// progressCounter keeps track of the progress of a phase and creates
+// the progress events.
+type progressCounter struct {
+	phase     event.ProgressPhase
+	completed int
+	total     int
+	started   time.Time
+	// last is when the last resource was completed, or when the
+	// phase started if no resources have been completed yet.
+	last time.Time
+}
+
+func newProgressCounter(phase event.ProgressPhase, total int) *progressCounter {
+	now := time.Now()
+	return &progressCounter{
+		phase:   phase,
+		total:   total,
+		started: now,
+		last:    now,
+	}
+}
+
+// inc records that one more resource has been completed and returns
+// the progress event for the new state.
+func (p *progressCounter) inc() event.Event {
+	p.completed++
+	p.last = time.Now()
+	return event.NewProgressEvent(p.phase, p.completed, p.total, p.started)
 }
 
 // PrintObj takes the provided object and operation and emits
 // it on the channel.
 func (r *resourcePrinterImpl) PrintObj(obj runtime.Object, _ io.Writer) error {
+	identifier, err := object.RuntimeToObjMeta(obj)
+	if err != nil {
+		return err
+	}
+	var started time.Time
+	if r.progress != nil {
+		started = r.progress.last
+	}
+	now := time.Now()
+	if r.tracer != nil && r.ctx != nil {
+		// The resource has already been applied, so the span
+		// is created after the fact from the recorded times.
+		_, span := r.tracer.Start(r.ctx, spanApplyObject, trace.WithTimestamp(started),
+			trace.WithAttributes(objectAttributes(identifier)...))
+		span.End(trace.WithTimestamp(now))
+	}
 	r.ch <- event.Event{
-		Type: event.ApplyType,
+		Type:      event.ApplyType,
+		Timestamp: now,
+		Started:   started,
 		ApplyEvent: event.ApplyEvent{
-			Type:      event.ApplyEventResourceUpdate,
-			Operation: r.applyOperation,
-			Object:    obj,
+			Type:       event.ApplyEventResourceUpdate,
+			Operation:  r.applyOperation,
+			Object:     object.Redact(obj, r.sensitiveFields),
+			Identifier: identifier,
 		},
 	}
+	if r.progress != nil {
+		r.ch <- r.progress.inc()
+	}
 	return nil
 }
 
@@ -50,8 +123,12 @@ func (p *KubectlPrinterAdapter) toPrinterFunc() toPrinterFunc {
 	return func(operation string) (printers.ResourcePrinter, error) {
 		applyOperation, err := operationToApplyOperationConst(operation)
 		return &resourcePrinterImpl{
-			ch:             p.ch,
-			applyOperation: applyOperation,
+			ch:              p.ch,
+			applyOperation:  applyOperation,
+			progress:        p.progress,
+			sensitiveFields: p.sensitiveFields,
+			ctx:             p.ctx,
+			tracer:          p.tracer,
 		}, err
 	}
 }
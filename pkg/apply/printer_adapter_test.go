@@ -10,7 +10,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 func TestKubectlPrinterAdapter(t *testing.T) {
@@ -47,4 +49,49 @@ func TestKubectlPrinterAdapter(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, event.ServersideApplied, msg.ApplyEvent.Operation)
 	assert.Equal(t, &deployment, msg.ApplyEvent.Object)
+	assert.Equal(t, object.ObjMetadata{
+		Namespace: "namespace",
+		Name:      "name",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}, msg.ApplyEvent.Identifier)
+	assert.False(t, msg.Timestamp.IsZero())
+}
+
+func TestKubectlPrinterAdapterProgress(t *testing.T) {
+	ch := make(chan event.Event)
+	adapter := KubectlPrinterAdapter{
+		ch:       ch,
+		progress: newProgressCounter(event.ApplyPhase, 2),
+	}
+
+	resourcePrinter, err := adapter.toPrinterFunc()("created")
+	assert.NoError(t, err)
+
+	deployment := appsv1.Deployment{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+
+	go func() {
+		defer close(ch)
+		_ = resourcePrinter.PrintObj(&deployment, &bytes.Buffer{})
+	}()
+
+	var events []event.Event
+	for e := range ch {
+		events = append(events, e)
+	}
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, event.ApplyType, events[0].Type)
+	assert.Equal(t, event.ProgressType, events[1].Type)
+	assert.Equal(t, event.ApplyPhase, events[1].ProgressEvent.Phase)
+	assert.Equal(t, 1, events[1].ProgressEvent.Completed)
+	assert.Equal(t, 2, events[1].ProgressEvent.Total)
 }
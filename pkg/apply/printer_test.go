@@ -0,0 +1,141 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+type recordingPrinter struct {
+	ioStreams genericclioptions.IOStreams
+	events    []event.Event
+}
+
+func (r *recordingPrinter) Print(ch <-chan event.Event) {
+	for e := range ch {
+		r.events = append(r.events, e)
+	}
+}
+
+func recordingPrinterFactory(ioStreams genericclioptions.IOStreams) Printer {
+	return &recordingPrinter{ioStreams: ioStreams}
+}
+
+// unregisterPrinters removes the printers registered by a test.
+func unregisterPrinters(names ...string) {
+	printersMu.Lock()
+	defer printersMu.Unlock()
+	for _, name := range names {
+		delete(printerFactories, name)
+	}
+}
+
+func TestRegisterPrinter(t *testing.T) {
+	defer unregisterPrinters("recording")
+	require.NoError(t, RegisterPrinter("recording", recordingPrinterFactory))
+	assert.Contains(t, SupportedOutputs(), "recording")
+
+	printerOptions := NewPrinterOptions()
+	cmd := &cobra.Command{}
+	printerOptions.AddFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("output", "recording"))
+	ioStreams := genericclioptions.NewTestIOStreamsDiscard()
+	printer, err := printerOptions.ToPrinter(ioStreams)
+	require.NoError(t, err)
+	recording, ok := printer.(*recordingPrinter)
+	require.True(t, ok, "unexpected printer %T", printer)
+	assert.Equal(t, ioStreams, recording.ioStreams)
+
+	ch := make(chan event.Event, 1)
+	ch <- event.Event{Type: event.ApplyType}
+	close(ch)
+	printer.Print(ch)
+	assert.Len(t, recording.events, 1)
+}
+
+func TestRegisterPrinterErrors(t *testing.T) {
+	testCases := map[string]struct {
+		name    string
+		factory PrinterFactory
+	}{
+		"built-in name": {
+			name:    JSONOutput,
+			factory: recordingPrinterFactory,
+		},
+		"empty name": {
+			factory: recordingPrinterFactory,
+		},
+		"no factory": {
+			name: "recording",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Error(t, RegisterPrinter(tc.name, tc.factory))
+			// The printer registered before is kept.
+			printer, err := GetPrinter(JSONOutput, genericclioptions.NewTestIOStreamsDiscard())
+			require.NoError(t, err)
+			assert.IsType(t, &JSONPrinter{}, printer)
+		})
+	}
+
+	defer unregisterPrinters("recording")
+	require.NoError(t, RegisterPrinter("recording", recordingPrinterFactory))
+	assert.Error(t, RegisterPrinter("recording", recordingPrinterFactory))
+}
+
+func TestGetPrinterUnknownOutput(t *testing.T) {
+	_, err := GetPrinter("yaml", genericclioptions.NewTestIOStreamsDiscard())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprint(SupportedOutputs()))
+}
+
+func TestRegisterPrinterConcurrently(t *testing.T) {
+	const count = 20
+	var names []string
+	for i := 0; i < count; i++ {
+		names = append(names, fmt.Sprintf("concurrent-%d", i))
+	}
+	defer unregisterPrinters(names...)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*count)
+	for _, name := range names {
+		wg.Add(2)
+		// Every printer is registered twice, so exactly one
+		// registration of each name must fail.
+		for i := 0; i < 2; i++ {
+			go func(name string) {
+				defer wg.Done()
+				errs <- RegisterPrinter(name, recordingPrinterFactory)
+				_, _ = GetPrinter(name, genericclioptions.NewTestIOStreamsDiscard())
+				_ = SupportedOutputs()
+			}(name)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, count, failed)
+	for _, name := range names {
+		printer, err := GetPrinter(name, genericclioptions.NewTestIOStreamsDiscard())
+		require.NoError(t, err)
+		assert.IsType(t, &recordingPrinter{}, printer)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+// benchmarkSizes are the numbers of resources and kinds
+// of the packages the benchmarks are run with.
+var benchmarkSizes = []struct {
+	objects int
+	kinds   int
+}{
+	{objects: 100, kinds: 5},
+	{objects: 1000, kinds: 20},
+	{objects: 5000, kinds: 50},
+}
+
+// benchmarkInfos returns a grouping object followed by the
+// resources of a generated package, starting at the given index.
+func benchmarkInfos(p fakecluster.Package, start int) []*resource.Info {
+	grouping := groupingObj.DeepCopy()
+	infos := []*resource.Info{{
+		Namespace: testNamespace,
+		Name:      grouping.GetName(),
+		Object:    grouping,
+	}}
+	for _, obj := range p.Objects[start:] {
+		infos = append(infos, &resource.Info{
+			Namespace: testNamespace,
+			Name:      obj.GetName(),
+			Object:    obj,
+		})
+	}
+	return infos
+}
+
+// benchmarkGroupingObject returns a grouping object with the
+// inventory of the resources of the package from the given index.
+func benchmarkGroupingObject(b *testing.B, p fakecluster.Package, start int) *resource.Info {
+	infos := benchmarkInfos(p, start)
+	if err := AddInventoryToGroupingObj(infos); err != nil {
+		b.Fatal(err)
+	}
+	return infos[0]
+}
+
+func BenchmarkAddInventoryToGroupingObj(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("objects=%d,kinds=%d", size.objects, size.kinds), func(b *testing.B) {
+			infos := benchmarkInfos(fakecluster.GeneratePackage(size.objects, size.kinds), 0)
+			grouping := infos[0].Object.(*unstructured.Unstructured)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// The grouping object gets a suffix every
+				// time, so it is started from scratch.
+				infos[0].Object = grouping.DeepCopy()
+				infos[0].Name = grouping.GetName()
+				if err := AddInventoryToGroupingObj(infos); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnionPastInventory(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("objects=%d,kinds=%d", size.objects, size.kinds), func(b *testing.B) {
+			p := fakecluster.GeneratePackage(size.objects, size.kinds)
+			// Two previous applies that overlap in half of the resources.
+			past := []*resource.Info{
+				benchmarkGroupingObject(b, p, 0),
+				benchmarkGroupingObject(b, p, size.objects/2),
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := unionPastInventory(past); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCalcPruneSet(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("objects=%d,kinds=%d", size.objects, size.kinds), func(b *testing.B) {
+			p := fakecluster.GeneratePackage(size.objects, size.kinds)
+			past := []*resource.Info{benchmarkGroupingObject(b, p, 0)}
+			// A tenth of the resources were removed from the package.
+			current := benchmarkGroupingObject(b, p, size.objects/10)
+			po := NewPruneOptions()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pruneSet, err := po.calcPruneSet(current, past)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if pruneSet.Size() != size.objects/10 {
+					b.Fatalf("expected %d resources to prune, got %d", size.objects/10, pruneSet.Size())
+				}
+			}
+		})
+	}
+}
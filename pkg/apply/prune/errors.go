@@ -0,0 +1,57 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+var (
+	// ErrInventoryNotFound is returned when the objects don't
+	// contain a grouping object.
+	ErrInventoryNotFound = errors.New("no grouping object found")
+	// ErrMultipleInventories is matched by a MultipleInventoriesError.
+	ErrMultipleInventories = errors.New("multiple grouping objects found")
+	// ErrAdoptionDenied is matched by an InventoryOverlapError, which
+	// is returned when the inventory policy doesn't allow taking over
+	// a resource.
+	ErrAdoptionDenied = errors.New("inventory policy does not allow adopting the resource")
+)
+
+// MultipleInventoriesError is returned if a package contains more
+// than one grouping object.
+type MultipleInventoriesError struct {
+	// Names lists the grouping objects, together with the
+	// file they were read from if it is known.
+	Names []string
+}
+
+func (e *MultipleInventoriesError) Error() string {
+	return fmt.Sprintf("found %d grouping objects in the package (%s), but a package must contain exactly one",
+		len(e.Names), strings.Join(e.Names, ", "))
+}
+
+// Is makes errors.Is match ErrMultipleInventories.
+func (e *MultipleInventoriesError) Is(target error) bool {
+	return target == ErrMultipleInventories
+}
+
+// PruneError is returned if a resource could not be pruned. It
+// wraps the error returned by the apiserver.
+type PruneError struct {
+	Object object.ObjMetadata
+	Err    error
+}
+
+func (e *PruneError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Object.String(), e.Err)
+}
+
+func (e *PruneError) Unwrap() error {
+	return e.Err
+}
@@ -23,11 +23,18 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/kubectl/pkg/cmd/apply"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 const (
-	GroupingLabel = "cli-utils.sigs.k8s.io/inventory-id"
-	GroupingHash  = "cli-utils.sigs.k8s.io/inventory-hash"
+	GroupingLabel  = "cli-utils.sigs.k8s.io/inventory-id"
+	GroupingHash   = "cli-utils.sigs.k8s.io/inventory-hash"
+	GroupingStatus = "cli-utils.sigs.k8s.io/inventory-status"
+	// GroupingAnnotation marks a ConfigMap as the grouping object
+	// template when the inventory id can't be set as a label, for
+	// example because the labels are generated by another tool.
+	GroupingAnnotation = "cli-utils.sigs.k8s.io/inventory-id"
 )
 
 // retrieveGroupingLabel returns the string value of the GroupingLabel
@@ -50,6 +57,91 @@ func retrieveGroupingLabel(obj runtime.Object) (string, error) {
 	return strings.TrimSpace(groupingLabel), nil
 }
 
+// SetInventoryID replaces the value of the grouping label of the
+// grouping object with the inventory id, so the same grouping object
+// template can be used for several inventories. Returns an error if
+// there is no grouping object.
+func SetInventoryID(infos []*resource.Info, inventoryID string) error {
+	groupingInfo, found := FindGroupingObject(infos)
+	if !found {
+		return ErrInventoryNotFound
+	}
+	accessor, err := meta.Accessor(groupingInfo.Object)
+	if err != nil {
+		return err
+	}
+	labels := accessor.GetLabels()
+	labels[GroupingLabel] = inventoryID
+	accessor.SetLabels(labels)
+	return nil
+}
+
+// DetectGroupingObject finds the grouping object template among the
+// objects of a package, no matter which file it is in. Besides objects
+// with the grouping label, ConfigMaps with the grouping annotation are
+// detected, and the annotation is copied to the label so the template
+// is handled like any other. Returns an error if the package contains
+// no grouping object, or more than one.
+func DetectGroupingObject(infos []*resource.Info) error {
+	var candidates []*resource.Info
+	for _, info := range infos {
+		if info != nil && IsGroupingTemplate(info.Object) {
+			candidates = append(candidates, info)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return fmt.Errorf("%w in the package; add a ConfigMap with the %s label, "+
+			"for example with the init command", ErrInventoryNotFound, GroupingLabel)
+	case 1:
+	default:
+		var names []string
+		for _, info := range candidates {
+			name := info.Name
+			if info.Source != "" {
+				name = fmt.Sprintf("%s in %s", info.Name, info.Source)
+			}
+			names = append(names, name)
+		}
+		return &MultipleInventoriesError{Names: names}
+	}
+	info := candidates[0]
+	if IsGroupingObject(info.Object) {
+		return nil
+	}
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return err
+	}
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[GroupingLabel] = strings.TrimSpace(accessor.GetAnnotations()[GroupingAnnotation])
+	accessor.SetLabels(labels)
+	return nil
+}
+
+// IsGroupingTemplate returns true if the object is the grouping object
+// template of a package, either because it has the grouping label or
+// because it is a ConfigMap with the grouping annotation.
+func IsGroupingTemplate(obj runtime.Object) bool {
+	return IsGroupingObject(obj) || hasGroupingAnnotation(obj)
+}
+
+// hasGroupingAnnotation returns true if the object is a ConfigMap
+// with a non-empty grouping annotation.
+func hasGroupingAnnotation(obj runtime.Object) bool {
+	if obj == nil || obj.GetObjectKind().GroupVersionKind().Kind != "ConfigMap" {
+		return false
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(accessor.GetAnnotations()[GroupingAnnotation]) != ""
+}
+
 // IsGroupingObject returns true if the passed object has the
 // grouping label.
 // TODO(seans3): Check type is ConfigMap.
@@ -105,7 +197,7 @@ func PrependGroupingObject(o *apply.ApplyOptions) func() error {
 		}
 		_, exists := FindGroupingObject(infos)
 		if !exists {
-			return fmt.Errorf("no grouping object found")
+			return ErrInventoryNotFound
 		}
 		if err := AddInventoryToGroupingObj(infos); err != nil {
 			return err
@@ -146,18 +238,24 @@ func AddInventoryToGroupingObj(infos []*resource.Info) error {
 				return fmt.Errorf("creating inventory; object is nil")
 			}
 			gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
-			objMetadata, err := createObjMetadata(info.Namespace, info.Name, gk)
+			objMetadata, err := object.CreateObjMetadata(info.Namespace, info.Name, gk)
 			if err != nil {
 				return err
 			}
-			inventoryMap[objMetadata.String()] = ""
+			// The inventory must be parsed again when pruning,
+			// so invalid resources are rejected here.
+			invStr, err := object.FormatObjMetadata(*objMetadata)
+			if err != nil {
+				return err
+			}
+			inventoryMap[invStr] = ""
 		}
 	}
 
 	// If we've found the grouping object, store the object metadata inventory
 	// in the grouping config map.
 	if groupingObj == nil {
-		return fmt.Errorf("grouping object not found")
+		return ErrInventoryNotFound
 	}
 
 	if len(inventoryMap) > 0 {
@@ -198,8 +296,8 @@ func AddInventoryToGroupingObj(infos []*resource.Info) error {
 // structs, or if the grouping object is not in Unstructured format; nil
 // otherwise. If a grouping object does not exist, or it does not have a
 // "data" map, then returns an empty slice and no error.
-func RetrieveInventoryFromGroupingObj(infos []*resource.Info) ([]*ObjMetadata, error) {
-	inventory := []*ObjMetadata{}
+func RetrieveInventoryFromGroupingObj(infos []*resource.Info) ([]*object.ObjMetadata, error) {
+	inventory := []*object.ObjMetadata{}
 	groupingInfo, exists := FindGroupingObject(infos)
 	if exists {
 		groupingObj, ok := groupingInfo.Object.(*unstructured.Unstructured)
@@ -214,7 +312,7 @@ func RetrieveInventoryFromGroupingObj(infos []*resource.Info) ([]*ObjMetadata, e
 		}
 		if exists {
 			for invStr := range invMap {
-				inv, err := parseObjMetadata(invStr)
+				inv, err := object.ParseObjMetadata(invStr)
 				if err != nil {
 					return inventory, err
 				}
@@ -245,7 +343,7 @@ func ClearGroupingObj(infos []*resource.Info) error {
 		}
 	}
 	if groupingObj == nil {
-		return fmt.Errorf("grouping object not found")
+		return ErrInventoryNotFound
 	}
 	// Clears the inventory map of the ConfigMap "data" section.
 	emptyMap := map[string]string{}
@@ -258,6 +356,83 @@ func ClearGroupingObj(infos []*resource.Info) error {
 	return nil
 }
 
+// RetainInventoryInGroupingObj removes the items from the inventory of
+// the grouping object that are not among the passed resources. It is
+// used when a run stops before all the resources are applied, so the
+// inventory doesn't record resources that don't exist. The name and
+// the hash of the grouping object are left as they are, since it has
+// already been applied under that name. Returns an error if the passed
+// object is not a grouping object or if the inventory can not be updated.
+func RetainInventoryInGroupingObj(obj *unstructured.Unstructured, infos []*resource.Info) error {
+	if !IsGroupingObject(obj) {
+		return fmt.Errorf("object is not a grouping object")
+	}
+	invMap, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		return fmt.Errorf("error retrieving inventory from grouping object")
+	}
+	retained := map[string]bool{}
+	for _, info := range infos {
+		if info.Object == nil || IsGroupingObject(info.Object) {
+			continue
+		}
+		gk := info.Object.GetObjectKind().GroupVersionKind().GroupKind()
+		objMetadata, err := object.CreateObjMetadata(info.Namespace, info.Name, gk)
+		if err != nil {
+			return err
+		}
+		invStr, err := object.FormatObjMetadata(*objMetadata)
+		if err != nil {
+			return err
+		}
+		retained[invStr] = true
+	}
+	for invStr := range invMap {
+		if !retained[invStr] {
+			delete(invMap, invStr)
+		}
+	}
+	return unstructured.SetNestedStringMap(obj.UnstructuredContent(), invMap, "data")
+}
+
+// AddStatusToGroupingObj records the last observed status of each
+// inventory item as the value for the item in the "data" section of
+// the grouping object, and the aggregate status as an annotation. Items
+// in the inventory without an entry in the statuses map are set to
+// Unknown. The keys in the statuses map are the strings returned by
+// ObjMetadata.String(). Returns an error if the passed object is not
+// a grouping object or if the inventory can not be updated.
+func AddStatusToGroupingObj(obj *unstructured.Unstructured, statuses map[string]status.Status,
+	aggregate status.Status) error {
+	if !IsGroupingObject(obj) {
+		return fmt.Errorf("object is not a grouping object")
+	}
+	invMap, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		return fmt.Errorf("error retrieving inventory from grouping object")
+	}
+	for invStr := range invMap {
+		s, found := statuses[invStr]
+		if !found {
+			s = status.UnknownStatus
+		}
+		invMap[invStr] = s.String()
+	}
+	if len(invMap) > 0 {
+		err = unstructured.SetNestedStringMap(obj.UnstructuredContent(), invMap, "data")
+		if err != nil {
+			return err
+		}
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[GroupingStatus] = aggregate.String()
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
 // calcInventoryHash returns an unsigned int32 representing the hash
 // of the inventory strings. If there is an error writing bytes to
 // the hash, then the error is returned; nil is returned otherwise.
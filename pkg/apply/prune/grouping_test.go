@@ -4,7 +4,9 @@
 package prune
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -12,8 +14,11 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/kubectl/pkg/cmd/apply"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 var testNamespace = "test-grouping-namespace"
@@ -254,6 +259,101 @@ func TestFindGroupingObject(t *testing.T) {
 	}
 }
 
+func TestSetInventoryID(t *testing.T) {
+	groupingInfo := copyGroupingInfo()
+	infos := []*resource.Info{pod1Info, groupingInfo}
+	if err := SetInventoryID(infos, "instance-2"); err != nil {
+		t.Fatalf("unexpected error setting inventory id: %v", err)
+	}
+	actual, err := retrieveGroupingLabel(groupingInfo.Object)
+	if err != nil {
+		t.Fatalf("unexpected error retrieving grouping label: %v", err)
+	}
+	if actual != "instance-2" {
+		t.Errorf("expected inventory id instance-2, got %s", actual)
+	}
+
+	if err := SetInventoryID([]*resource.Info{pod1Info}, "instance-2"); err == nil {
+		t.Errorf("expected error when there is no grouping object")
+	}
+}
+
+func annotatedGroupingInfo(kind string) *resource.Info {
+	return &resource.Info{
+		Namespace: testNamespace,
+		Name:      groupingObjName,
+		Source:    "inventory.yaml",
+		Object: &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       kind,
+				"metadata": map[string]interface{}{
+					"name":      groupingObjName,
+					"namespace": testNamespace,
+					"annotations": map[string]interface{}{
+						GroupingAnnotation: testGroupingLabel,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDetectGroupingObject(t *testing.T) {
+	tests := map[string]struct {
+		infos       []*resource.Info
+		expectedErr error
+		inventory   string
+	}{
+		"labeled grouping object": {
+			infos:     []*resource.Info{pod1Info, copyGroupingInfo()},
+			inventory: testGroupingLabel,
+		},
+		"annotated grouping object": {
+			infos:     []*resource.Info{pod1Info, annotatedGroupingInfo("ConfigMap")},
+			inventory: testGroupingLabel,
+		},
+		"annotation on another kind": {
+			infos:       []*resource.Info{pod1Info, annotatedGroupingInfo("Secret")},
+			expectedErr: ErrInventoryNotFound,
+		},
+		"no grouping object": {
+			infos:       []*resource.Info{pod1Info, pod2Info},
+			expectedErr: ErrInventoryNotFound,
+		},
+		"multiple grouping objects": {
+			infos:       []*resource.Info{copyGroupingInfo(), pod1Info, annotatedGroupingInfo("ConfigMap")},
+			expectedErr: ErrMultipleInventories,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := DetectGroupingObject(test.infos)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Errorf("expected error %v, but received %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			groupingInfo, found := FindGroupingObject(test.infos)
+			if !found {
+				t.Fatalf("expected the grouping object to be found after detection")
+			}
+			actual, err := retrieveGroupingLabel(groupingInfo.Object)
+			if err != nil {
+				t.Fatalf("unexpected error retrieving grouping label: %v", err)
+			}
+			if actual != test.inventory {
+				t.Errorf("expected inventory id %s, got %s", test.inventory, actual)
+			}
+		})
+	}
+}
+
 func TestSortGroupingObject(t *testing.T) {
 	tests := []struct {
 		infos  []*resource.Info
@@ -317,7 +417,7 @@ func TestSortGroupingObject(t *testing.T) {
 func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 	tests := []struct {
 		infos    []*resource.Info
-		expected []*ObjMetadata
+		expected []*object.ObjMetadata
 		isError  bool
 	}{
 		// No grouping object is an error.
@@ -341,25 +441,25 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 		},
 		{
 			infos:    []*resource.Info{copyGroupingInfo()},
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  false,
 		},
 		// More than one grouping object is an error.
 		{
 			infos:    []*resource.Info{copyGroupingInfo(), copyGroupingInfo()},
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  true,
 		},
 		// More than one grouping object is an error.
 		{
 			infos:    []*resource.Info{copyGroupingInfo(), pod1Info, copyGroupingInfo()},
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  true,
 		},
 		// Basic test case: one grouping object, one pod.
 		{
 			infos: []*resource.Info{copyGroupingInfo(), pod1Info},
-			expected: []*ObjMetadata{
+			expected: []*object.ObjMetadata{
 				{
 					Namespace: testNamespace,
 					Name:      pod1Name,
@@ -373,7 +473,7 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 		},
 		{
 			infos: []*resource.Info{pod1Info, copyGroupingInfo()},
-			expected: []*ObjMetadata{
+			expected: []*object.ObjMetadata{
 				{
 					Namespace: testNamespace,
 					Name:      pod1Name,
@@ -387,7 +487,7 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 		},
 		{
 			infos: []*resource.Info{pod1Info, pod2Info, copyGroupingInfo(), pod3Info},
-			expected: []*ObjMetadata{
+			expected: []*object.ObjMetadata{
 				{
 					Namespace: testNamespace,
 					Name:      pod1Name,
@@ -417,7 +517,7 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 		},
 		{
 			infos: []*resource.Info{pod1Info, pod2Info, pod3Info, copyGroupingInfo()},
-			expected: []*ObjMetadata{
+			expected: []*object.ObjMetadata{
 				{
 					Namespace: testNamespace,
 					Name:      pod1Name,
@@ -447,7 +547,7 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 		},
 		{
 			infos: []*resource.Info{copyGroupingInfo(), pod1Info, pod2Info, pod3Info},
-			expected: []*ObjMetadata{
+			expected: []*object.ObjMetadata{
 				{
 					Namespace: testNamespace,
 					Name:      pod1Name,
@@ -519,6 +619,39 @@ func TestAddRetrieveInventoryToFromGroupingObject(t *testing.T) {
 	}
 }
 
+func TestAddInventoryWithEscapedNames(t *testing.T) {
+	role := &unstructured.Unstructured{}
+	role.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	role.SetKind("ClusterRole")
+	role.SetName("system:auth-delegator")
+	infos := []*resource.Info{copyGroupingInfo(), {Name: role.GetName(), Object: role}}
+	if err := AddInventoryToGroupingObj(infos); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, _, _ := unstructured.NestedStringMap(infos[0].Object.(*unstructured.Unstructured).Object, "data")
+	for key := range data {
+		// The keys must be valid in a ConfigMap.
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			t.Errorf("invalid ConfigMap key %q: %v", key, errs)
+		}
+	}
+	retrieved, err := RetrieveInventoryFromGroupingObj(infos)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(retrieved) != 1 || retrieved[0].Name != "system:auth-delegator" {
+		t.Errorf("expected the ClusterRole in the inventory, got %v", retrieved)
+	}
+
+	// Resources that could not be parsed again are rejected.
+	invalid := role.DeepCopy()
+	invalid.SetName("a/b")
+	infos = []*resource.Info{copyGroupingInfo(), {Name: invalid.GetName(), Object: invalid}}
+	if err := AddInventoryToGroupingObj(infos); err == nil {
+		t.Errorf("expected an error for an invalid name")
+	}
+}
+
 func TestAddSuffixToName(t *testing.T) {
 	tests := []struct {
 		info     *resource.Info
@@ -721,3 +854,122 @@ func copyGroupingInfo() *resource.Info {
 	}
 	return groupingInfo
 }
+
+func TestAddStatusToGroupingObject(t *testing.T) {
+	tests := map[string]struct {
+		obj       *unstructured.Unstructured
+		statuses  map[string]status.Status
+		aggregate status.Status
+		expected  map[string]string
+		isError   bool
+	}{
+		"Non-grouping object should error": {
+			obj:     pod1.DeepCopy(),
+			isError: true,
+		},
+		"Grouping object without inventory only gets the aggregate status": {
+			obj:       copyGroupingInfo().Object.(*unstructured.Unstructured),
+			aggregate: status.CurrentStatus,
+			expected:  nil,
+		},
+		"Statuses are stored for inventory items": {
+			obj: createGroupingInfo("test-1", pod1Info, pod2Info).Object.(*unstructured.Unstructured),
+			statuses: map[string]status.Status{
+				pod1Inv.String(): status.CurrentStatus,
+				pod2Inv.String(): status.InProgressStatus,
+			},
+			aggregate: status.InProgressStatus,
+			expected: map[string]string{
+				pod1Inv.String(): "Current",
+				pod2Inv.String(): "InProgress",
+			},
+		},
+		"Inventory items without status are Unknown": {
+			obj: createGroupingInfo("test-1", pod1Info, pod2Info).Object.(*unstructured.Unstructured),
+			statuses: map[string]status.Status{
+				pod1Inv.String(): status.CurrentStatus,
+				pod3Inv.String(): status.CurrentStatus,
+			},
+			aggregate: status.UnknownStatus,
+			expected: map[string]string{
+				pod1Inv.String(): "Current",
+				pod2Inv.String(): "Unknown",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := AddStatusToGroupingObj(tc.obj, tc.statuses, tc.aggregate)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Should have produced an error, but returned none.")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Received unexpected error: %#v", err)
+			}
+			data, _, _ := unstructured.NestedStringMap(tc.obj.Object, "data")
+			if !reflect.DeepEqual(tc.expected, data) && !(len(tc.expected) == 0 && len(data) == 0) {
+				t.Errorf("Expected data (%v), got (%v)", tc.expected, data)
+			}
+			if actual := tc.obj.GetAnnotations()[GroupingStatus]; actual != tc.aggregate.String() {
+				t.Errorf("Expected aggregate status (%s), got (%s)", tc.aggregate, actual)
+			}
+		})
+	}
+}
+
+func TestRetainInventoryInGroupingObject(t *testing.T) {
+	tests := map[string]struct {
+		obj      *unstructured.Unstructured
+		infos    []*resource.Info
+		expected map[string]string
+		isError  bool
+	}{
+		"Non-grouping object should error": {
+			obj:     pod1.DeepCopy(),
+			isError: true,
+		},
+		"Only the passed resources are kept": {
+			obj:   createGroupingInfo("test-1", pod1Info, pod2Info, pod3Info).Object.(*unstructured.Unstructured),
+			infos: []*resource.Info{copyGroupingInfo(), pod1Info, pod3Info},
+			expected: map[string]string{
+				pod1Inv.String(): "",
+				pod3Inv.String(): "",
+			},
+		},
+		"Passed resources that are not in the inventory are not added": {
+			obj:   createGroupingInfo("test-1", pod1Info).Object.(*unstructured.Unstructured),
+			infos: []*resource.Info{pod1Info, pod2Info},
+			expected: map[string]string{
+				pod1Inv.String(): "",
+			},
+		},
+		"Nothing applied empties the inventory": {
+			obj:      createGroupingInfo("test-1", pod1Info, pod2Info).Object.(*unstructured.Unstructured),
+			infos:    []*resource.Info{copyGroupingInfo()},
+			expected: map[string]string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := RetainInventoryInGroupingObj(tc.obj, tc.infos)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("Should have produced an error, but returned none.")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Received unexpected error: %#v", err)
+			}
+			data, _, _ := unstructured.NestedStringMap(tc.obj.Object, "data")
+			if !reflect.DeepEqual(tc.expected, data) {
+				t.Errorf("Expected data (%v), got (%v)", tc.expected, data)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
 // Inventory encapsulates a grouping of unique Inventory
@@ -17,21 +19,21 @@ import (
 // which ensures there are no duplicates. Allows set
 // operations such as merging sets and subtracting sets.
 type Inventory struct {
-	set map[string]*ObjMetadata
+	set map[string]*object.ObjMetadata
 }
 
 // NewInventory returns a pointer to an Inventory
 // struct grouping the passed Inventory items.
-func NewInventory(items []*ObjMetadata) *Inventory {
-	inventory := Inventory{set: map[string]*ObjMetadata{}}
+func NewInventory(items []*object.ObjMetadata) *Inventory {
+	inventory := Inventory{set: map[string]*object.ObjMetadata{}}
 	inventory.AddItems(items)
 	return &inventory
 }
 
 // GetItems returns the set of pointers to ObjMetadata
 // structs.
-func (is *Inventory) GetItems() []*ObjMetadata {
-	items := []*ObjMetadata{}
+func (is *Inventory) GetItems() []*object.ObjMetadata {
+	items := []*object.ObjMetadata{}
 	for _, item := range is.set {
 		items = append(items, item)
 	}
@@ -40,7 +42,7 @@ func (is *Inventory) GetItems() []*ObjMetadata {
 
 // AddItems adds Inventory structs to the set which
 // are not already in the set.
-func (is *Inventory) AddItems(items []*ObjMetadata) {
+func (is *Inventory) AddItems(items []*object.ObjMetadata) {
 	for _, item := range items {
 		if item != nil {
 			is.set[item.String()] = item
@@ -52,7 +54,7 @@ func (is *Inventory) AddItems(items []*ObjMetadata) {
 // set if it exists in the set. Returns true if the
 // ObjMetadata item was deleted, false if it did not exist
 // in the set.
-func (is *Inventory) DeleteItem(item *ObjMetadata) bool {
+func (is *Inventory) DeleteItem(item *object.ObjMetadata) bool {
 	if item == nil {
 		return false
 	}
@@ -72,8 +74,10 @@ func (is *Inventory) Merge(other *Inventory) (*Inventory, error) {
 		return nil, fmt.Errorf("inventory to merge is nil")
 	}
 	// Copy the current Inventory into result
-	result := NewInventory(is.GetItems())
-	result.AddItems(other.GetItems())
+	result := is.copy()
+	for key, item := range other.set {
+		result.set[key] = item
+	}
 	return result, nil
 }
 
@@ -85,14 +89,24 @@ func (is *Inventory) Subtract(other *Inventory) (*Inventory, error) {
 		return nil, fmt.Errorf("inventory to subtract is nil")
 	}
 	// Copy the current Inventory into result
-	result := NewInventory(is.GetItems())
+	result := is.copy()
 	// Remove each item in "other" which exists in "result"
-	for _, item := range other.GetItems() {
-		result.DeleteItem(item)
+	for key := range other.set {
+		delete(result.set, key)
 	}
 	return result, nil
 }
 
+// copy returns a new Inventory with the same items. The items are
+// keyed by their string already, so they are not formatted again.
+func (is *Inventory) copy() *Inventory {
+	result := &Inventory{set: make(map[string]*object.ObjMetadata, len(is.set))}
+	for key, item := range is.set {
+		result.set[key] = item
+	}
+	return result
+}
+
 // Equals returns true if the "other" inventory set is the same
 // as this current inventory set. Relies on the fact that the
 // inventory items are sorted for the String() function.
@@ -0,0 +1,72 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// recordingLogger records the messages logged at every level.
+type recordingLogger struct {
+	messages *[]string
+}
+
+func (l recordingLogger) Info(msg string, _ ...interface{}) { *l.messages = append(*l.messages, msg) }
+
+func (l recordingLogger) Error(_ error, msg string, _ ...interface{}) {
+	*l.messages = append(*l.messages, msg)
+}
+
+func (l recordingLogger) Enabled() bool { return true }
+
+func (l recordingLogger) V(int) logr.InfoLogger { return l }
+
+func (l recordingLogger) WithValues(...interface{}) logr.Logger { return l }
+
+func (l recordingLogger) WithName(string) logr.Logger { return l }
+
+func TestPruneLogger(t *testing.T) {
+	po := NewPruneOptions()
+	if logger := po.logger(); logger != (log.NullLogger{}) {
+		t.Errorf("expected the null logger without a Logger, got %T", logger)
+	}
+
+	pastGroupingInfo := createGroupingInfo("test-1", pod1Info, pod2Info)
+	pastGroupingInfo.Object.(*unstructured.Unstructured).SetName("past-grouping-obj")
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pastGroupingInfo.Object,
+		pod1.DeepCopy(), pod2.DeepCopy())
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+	var messages []string
+	po = NewPruneOptionsWithClients(client, mapper)
+	po.Logger = recordingLogger{messages: &messages}
+	if err := po.Initialize(nil, testNamespace); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eventChannel := make(chan event.Event, 10)
+	current := createGroupingInfo("test-1", pod1Info)
+	if err := po.Prune([]*resource.Info{current, pod1Info}, eventChannel); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := false
+	for _, msg := range messages {
+		found = found || msg == "pruning resource"
+	}
+	if !found {
+		t.Errorf("expected the prune to be logged through the Logger, got %v", messages)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// OwningInventoryAnnotation is set on every applied resource to the
+// inventory id of the grouping object it was applied with. It is used
+// to detect resources that belong to a different inventory.
+const OwningInventoryAnnotation = "cli-utils.sigs.k8s.io/owning-inventory"
+
+// InventoryPolicy determines whether a resource that already exists
+// in the cluster can be taken over by the inventory being applied.
+type InventoryPolicy int
+
+const (
+	// InventoryPolicyAdoptIfNoInventory allows taking over resources
+	// that don't belong to any inventory, but not the ones that belong
+	// to a different inventory. This is the default, so resources
+	// applied before the owning inventory was recorded are adopted.
+	InventoryPolicyAdoptIfNoInventory InventoryPolicy = iota
+	// InventoryPolicyStrict only allows applying resources that don't
+	// exist yet or already belong to the inventory.
+	InventoryPolicyStrict
+	// InventoryPolicyForceAdopt takes over resources regardless of
+	// which inventory they belong to.
+	InventoryPolicyForceAdopt
+)
+
+var inventoryPolicyNames = map[InventoryPolicy]string{
+	InventoryPolicyAdoptIfNoInventory: "adopt-if-no-inventory",
+	InventoryPolicyStrict:             "strict",
+	InventoryPolicyForceAdopt:         "force-adopt",
+}
+
+func (p InventoryPolicy) String() string {
+	if name, found := inventoryPolicyNames[p]; found {
+		return name
+	}
+	return fmt.Sprintf("InventoryPolicy(%d)", int(p))
+}
+
+// ParseInventoryPolicy returns the InventoryPolicy with the given
+// name, which must be one of strict, adopt-if-no-inventory or
+// force-adopt.
+func ParseInventoryPolicy(name string) (InventoryPolicy, error) {
+	for p, n := range inventoryPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return InventoryPolicyAdoptIfNoInventory, fmt.Errorf(
+		"invalid inventory policy %q, must be one of strict, adopt-if-no-inventory or force-adopt", name)
+}
+
+// InventoryOverlapError is returned if a resource can not be applied,
+// because the inventory policy doesn't allow taking it over.
+type InventoryOverlapError struct {
+	Object object.ObjMetadata
+	// Owner is the inventory id of the current owner. It is
+	// empty if the resource doesn't belong to any inventory.
+	Owner  string
+	Policy InventoryPolicy
+}
+
+func (e *InventoryOverlapError) Error() string {
+	if e.Owner == "" {
+		return fmt.Sprintf("%s already exists and does not belong to any inventory; "+
+			"use the adopt-if-no-inventory or force-adopt inventory policy to take it over", e.Object.String())
+	}
+	return fmt.Sprintf("%s already exists and belongs to inventory %q; "+
+		"use the force-adopt inventory policy to take it over", e.Object.String(), e.Owner)
+}
+
+// Is makes errors.Is match ErrAdoptionDenied.
+func (e *InventoryOverlapError) Is(target error) bool {
+	return target == ErrAdoptionDenied
+}
+
+// owningInventory returns the value of the OwningInventoryAnnotation
+// for the object, or an empty string if it isn't set.
+func owningInventory(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return accessor.GetAnnotations()[OwningInventoryAnnotation], nil
+}
+
+// CanApply returns an InventoryOverlapError if the policy doesn't
+// allow the inventory with the given id to take over the live version
+// of a resource. A nil live object means the resource doesn't exist.
+func CanApply(inventoryID string, live runtime.Object, policy InventoryPolicy) error {
+	if live == nil || policy == InventoryPolicyForceAdopt {
+		return nil
+	}
+	owner, err := owningInventory(live)
+	if err != nil {
+		return err
+	}
+	if owner == inventoryID || (owner == "" && policy == InventoryPolicyAdoptIfNoInventory) {
+		return nil
+	}
+	id, err := object.RuntimeToObjMeta(live)
+	if err != nil {
+		return err
+	}
+	return &InventoryOverlapError{
+		Object: id,
+		Owner:  owner,
+		Policy: policy,
+	}
+}
+
+// CanPrune returns true if the inventory with the given id can
+// delete the live resource. Resources that belong to a different
+// inventory are never pruned, since they have been taken over.
+// Resources without an owning inventory can only be pruned if the
+// policy is not strict.
+func CanPrune(inventoryID string, live runtime.Object, policy InventoryPolicy) (bool, error) {
+	owner, err := owningInventory(live)
+	if err != nil {
+		return false, err
+	}
+	if owner == "" {
+		return policy != InventoryPolicyStrict, nil
+	}
+	return owner == inventoryID, nil
+}
+
+// AddOwningInventory sets the OwningInventoryAnnotation on all the
+// objects, except the grouping object, to the inventory id of the
+// grouping object. It returns the inventory id.
+func AddOwningInventory(infos []*resource.Info) (string, error) {
+	groupingInfo, found := FindGroupingObject(infos)
+	if !found {
+		return "", ErrInventoryNotFound
+	}
+	inventoryID, err := retrieveGroupingLabel(groupingInfo.Object)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		if IsGroupingObject(info.Object) {
+			continue
+		}
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return "", err
+		}
+		annotations := accessor.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[OwningInventoryAnnotation] = inventoryID
+		accessor.SetAnnotations(annotations)
+	}
+	return inventoryID, nil
+}
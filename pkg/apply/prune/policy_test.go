@@ -0,0 +1,157 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// podOwnedBy returns a copy of pod1 with the owning inventory
+// annotation set to the owner, unless the owner is empty.
+func podOwnedBy(owner string) *unstructured.Unstructured {
+	pod := pod1.DeepCopy()
+	if owner != "" {
+		pod.SetAnnotations(map[string]string{OwningInventoryAnnotation: owner})
+	}
+	return pod
+}
+
+func TestParseInventoryPolicy(t *testing.T) {
+	for _, p := range []InventoryPolicy{
+		InventoryPolicyAdoptIfNoInventory,
+		InventoryPolicyStrict,
+		InventoryPolicyForceAdopt,
+	} {
+		parsed, err := ParseInventoryPolicy(p.String())
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", p, err)
+		}
+		if parsed != p {
+			t.Errorf("expected %s, got %s", p, parsed)
+		}
+	}
+	if _, err := ParseInventoryPolicy("adopt"); err == nil {
+		t.Errorf("expected error parsing invalid inventory policy")
+	}
+}
+
+func TestCanApply(t *testing.T) {
+	testCases := map[string]struct {
+		live        runtime.Object
+		policy      InventoryPolicy
+		expectedErr bool
+	}{
+		"resource does not exist": {
+			live:   nil,
+			policy: InventoryPolicyStrict,
+		},
+		"same inventory with strict policy": {
+			live:   podOwnedBy(testGroupingLabel),
+			policy: InventoryPolicyStrict,
+		},
+		"no inventory with strict policy": {
+			live:        podOwnedBy(""),
+			policy:      InventoryPolicyStrict,
+			expectedErr: true,
+		},
+		"no inventory with adopt-if-no-inventory policy": {
+			live:   podOwnedBy(""),
+			policy: InventoryPolicyAdoptIfNoInventory,
+		},
+		"other inventory with adopt-if-no-inventory policy": {
+			live:        podOwnedBy("other"),
+			policy:      InventoryPolicyAdoptIfNoInventory,
+			expectedErr: true,
+		},
+		"other inventory with force-adopt policy": {
+			live:   podOwnedBy("other"),
+			policy: InventoryPolicyForceAdopt,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := CanApply(testGroupingLabel, tc.live, tc.policy)
+			if tc.expectedErr {
+				if _, ok := err.(*InventoryOverlapError); !ok {
+					t.Errorf("expected InventoryOverlapError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestCanPrune(t *testing.T) {
+	testCases := map[string]struct {
+		owner    string
+		policy   InventoryPolicy
+		expected bool
+	}{
+		"same inventory": {
+			owner:    testGroupingLabel,
+			policy:   InventoryPolicyStrict,
+			expected: true,
+		},
+		"no inventory with strict policy": {
+			owner:    "",
+			policy:   InventoryPolicyStrict,
+			expected: false,
+		},
+		"no inventory with adopt-if-no-inventory policy": {
+			owner:    "",
+			policy:   InventoryPolicyAdoptIfNoInventory,
+			expected: true,
+		},
+		"other inventory with force-adopt policy": {
+			owner:    "other",
+			policy:   InventoryPolicyForceAdopt,
+			expected: false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			canPrune, err := CanPrune(testGroupingLabel, podOwnedBy(tc.owner), tc.policy)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if canPrune != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, canPrune)
+			}
+		})
+	}
+}
+
+func TestAddOwningInventory(t *testing.T) {
+	infos := []*resource.Info{
+		{Object: groupingObj.DeepCopy()},
+		{Object: pod1.DeepCopy()},
+	}
+	inventoryID, err := AddOwningInventory(infos)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if inventoryID != testGroupingLabel {
+		t.Errorf("expected inventory id %s, got %s", testGroupingLabel, inventoryID)
+	}
+	annotations := infos[1].Object.(*unstructured.Unstructured).GetAnnotations()
+	if annotations[OwningInventoryAnnotation] != testGroupingLabel {
+		t.Errorf("expected owning inventory annotation, got %v", annotations)
+	}
+	if _, found := infos[0].Object.(*unstructured.Unstructured).GetAnnotations()[OwningInventoryAnnotation]; found {
+		t.Errorf("expected no owning inventory annotation on the grouping object")
+	}
+
+	if _, err := AddOwningInventory([]*resource.Info{{Object: pod1.DeepCopy()}}); err == nil {
+		t.Errorf("expected error without grouping object")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// BootstrappingLabel is set by the API server on the default
+// RBAC roles and bindings the cluster depends on.
+const BootstrappingLabel = "kubernetes.io/bootstrapping"
+
+// DefaultProtectedNamespaces are the namespaces protected from being
+// pruned or destroyed, since they hold the components of the cluster.
+var DefaultProtectedNamespaces = []string{"kube-system", "kube-public"}
+
+// IsProtected returns true if the resource is critical to the cluster,
+// so it can't be deleted by a prune or a destroy. These are the
+// protected namespaces and all the resources in them, the bootstrapping
+// RBAC resources, and the system PriorityClasses.
+func IsProtected(obj runtime.Object, namespaces []string) (bool, error) {
+	acc, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
+	for _, ns := range namespaces {
+		if acc.GetNamespace() == ns || (gk.Group == "" && gk.Kind == "Namespace" && acc.GetName() == ns) {
+			return true, nil
+		}
+	}
+	if _, found := acc.GetLabels()[BootstrappingLabel]; found {
+		return true, nil
+	}
+	// The names with this prefix are reserved for the
+	// priority classes of the system components.
+	if gk.Group == "scheduling.k8s.io" && gk.Kind == "PriorityClass" && strings.HasPrefix(acc.GetName(), "system-") {
+		return true, nil
+	}
+	return false, nil
+}
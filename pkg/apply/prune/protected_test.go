@@ -0,0 +1,117 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+func protectedTestObject(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+func TestIsProtected(t *testing.T) {
+	testCases := map[string]struct {
+		obj      *unstructured.Unstructured
+		expected bool
+	}{
+		"resource in a protected namespace": {
+			obj:      protectedTestObject("v1", "ConfigMap", "kube-system", "cm", nil),
+			expected: true,
+		},
+		"protected namespace": {
+			obj:      protectedTestObject("v1", "Namespace", "", "kube-public", nil),
+			expected: true,
+		},
+		"bootstrapping cluster role": {
+			obj: protectedTestObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "admin",
+				map[string]string{BootstrappingLabel: "rbac-defaults"}),
+			expected: true,
+		},
+		"system priority class": {
+			obj:      protectedTestObject("scheduling.k8s.io/v1", "PriorityClass", "", "system-node-critical", nil),
+			expected: true,
+		},
+		"resource in another namespace": {
+			obj: protectedTestObject("v1", "ConfigMap", "default", "cm", nil),
+		},
+		"other namespace": {
+			obj: protectedTestObject("v1", "Namespace", "", "apps", nil),
+		},
+		"other priority class": {
+			obj: protectedTestObject("scheduling.k8s.io/v1", "PriorityClass", "", "high", nil),
+		},
+		"custom resource named like a protected namespace": {
+			obj: protectedTestObject("example.com/v1", "Namespace", "", "kube-system", nil),
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			protected, err := IsProtected(tc.obj, DefaultProtectedNamespaces)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if protected != tc.expected {
+				t.Errorf("expected protected to be %t, got %t", tc.expected, protected)
+			}
+		})
+	}
+}
+
+func TestPruneSkipsProtectedResources(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		pastGroupingInfo := createGroupingInfo("test-1", pod1Info, pod2Info)
+		pastGroupingObj := pastGroupingInfo.Object.(*unstructured.Unstructured)
+		pastGroupingObj.SetName("past-grouping-obj")
+		client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pastGroupingObj, pod1.DeepCopy(), pod2.DeepCopy())
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+		po := NewPruneOptionsWithClients(client, mapper)
+		po.ProtectedNamespaces = []string{testNamespace}
+		po.AllowProtectedDeletion = allow
+		if err := po.Initialize(nil, testNamespace); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		current := createGroupingInfo("test-1", pod1Info)
+		eventChannel := make(chan event.Event, 10)
+		if err := po.Prune([]*resource.Info{current, pod1Info}, eventChannel); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(eventChannel)
+
+		podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+		_, err := client.Resource(podsGVR).Namespace(testNamespace).Get(pod2Name, metav1.GetOptions{})
+		if kept := err == nil; kept == allow {
+			t.Errorf("allowed deletion %t: expected %s to be kept: %t, got %t", allow, pod2Name, !allow, kept)
+		}
+		skipped := false
+		for e := range eventChannel {
+			if e.Type == event.PruneType && e.PruneEvent.Operation == event.PruneSkipped {
+				skipped = skipped || e.PruneEvent.Identifier.Equals(pod2Inv)
+			}
+		}
+		if skipped == allow {
+			t.Errorf("allowed deletion %t: expected a skipped prune event for %s: %t, got %t", allow, pod2Inv,
+				!allow, skipped)
+		}
+	}
+}
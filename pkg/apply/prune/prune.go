@@ -13,67 +13,146 @@ package prune
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/kubectl/pkg/cmd/util"
-	"k8s.io/kubectl/pkg/validation"
 	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// resettableMapper is a mapper that caches the discovery
+// information, like the DeferredDiscoveryRESTMapper.
+type resettableMapper interface {
+	Reset()
+}
+
 // PruneOptions encapsulates the necessary information to
 // implement the prune functionality.
 type PruneOptions struct {
 	client    dynamic.Interface
-	builder   *resource.Builder
 	mapper    meta.RESTMapper
 	namespace string
-	// The currently applied objects (as Infos), including the
-	// current grouping object. These objects are used to
-	// calculate the prune set after retreiving the previous
-	// grouping objects.
-	currentGroupingObject *resource.Info
-	// The set of retrieved grouping objects (as Infos) selected
-	// by the grouping label. This set should also include the
-	// current grouping object. Stored here to make testing
-	// easier by manually setting the retrieved grouping infos.
-	pastGroupingObjects      []*resource.Info
-	retrievedGroupingObjects bool
-
-	DryRun    bool
-	validator validation.Schema
-
-	// TODO: DeleteOptions--cascade?
+
+	// DryRunStrategy determines if the resources are deleted, or
+	// if the deletes are only evaluated on the client or server.
+	DryRunStrategy common.DryRunStrategy
+
+	// SensitiveFields are redacted in the objects included
+	// in the prune events.
+	SensitiveFields []object.SensitiveField
+
+	// WaitForDeletion is called with the resources that have been
+	// deleted, before the previous grouping objects are deleted. If
+	// it returns an error, the grouping objects are kept, so the
+	// resources are still part of the inventory. Any events should
+	// be sent on the channel. No wait is done if it is nil.
+	WaitForDeletion func(objs []*object.ObjMetadata, eventChannel chan<- event.Event) error
+
+	// Logger is used to log the resources that are pruned.
+	// Nothing is logged if it is nil.
+	Logger logr.Logger
+
+	// InventoryPolicy determines which resources can be pruned. The
+	// resources that belong to a different inventory are never pruned.
+	InventoryPolicy InventoryPolicy
+
+	// PropagationPolicy determines how the dependents of the pruned
+	// resources are deleted. The default of the server is used if
+	// it is empty.
+	PropagationPolicy metav1.DeletionPropagation
+
+	// GracePeriodSeconds is the time the pruned resources are given
+	// to terminate gracefully. The default of every resource is used
+	// if it is nil.
+	GracePeriodSeconds *int64
+
+	// ProtectedNamespaces are the namespaces that are never pruned, and
+	// whose resources are never pruned, together with the other
+	// resources the cluster depends on. It is DefaultProtectedNamespaces
+	// for new PruneOptions.
+	ProtectedNamespaces []string
+
+	// AllowProtectedDeletion allows pruning the protected resources.
+	AllowProtectedDeletion bool
+
+	// RecoverUnrecorded adds the resources of the inventory that a
+	// failed run applied, but recorded in no grouping object, to the
+	// prune set. Finding them lists the resources of every namespaced
+	// kind of the inventories, so it is only done if it is set.
+	RecoverUnrecorded bool
+}
+
+// propagationPolicyNames maps the names used on the command
+// line to the propagation policies.
+var propagationPolicyNames = map[string]metav1.DeletionPropagation{
+	"background": metav1.DeletePropagationBackground,
+	"foreground": metav1.DeletePropagationForeground,
+	"orphan":     metav1.DeletePropagationOrphan,
+}
+
+// ParsePropagationPolicy returns the DeletionPropagation with the
+// given name, which must be one of background, foreground or orphan.
+func ParsePropagationPolicy(name string) (metav1.DeletionPropagation, error) {
+	policy, found := propagationPolicyNames[strings.ToLower(name)]
+	if !found {
+		return "", fmt.Errorf("invalid propagation policy %q, must be one of background, foreground or orphan",
+			name)
+	}
+	return policy, nil
 }
 
 // NewPruneOptions returns a struct (PruneOptions) encapsulating the necessary
 // information to run the prune. Returns an error if an error occurs
 // gathering this information.
 func NewPruneOptions() *PruneOptions {
-	po := &PruneOptions{}
+	po := &PruneOptions{
+		ProtectedNamespaces: append([]string{}, DefaultProtectedNamespaces...),
+	}
 	return po
 }
 
+// NewPruneOptionsWithClients returns PruneOptions that use the given
+// client and mapper, instead of creating them from the factory passed
+// to Initialize. This allows controllers to reuse their clients, and
+// tests to use fakes.
+func NewPruneOptionsWithClients(client dynamic.Interface, mapper meta.RESTMapper) *PruneOptions {
+	return &PruneOptions{
+		client:              client,
+		mapper:              mapper,
+		ProtectedNamespaces: append([]string{}, DefaultProtectedNamespaces...),
+	}
+}
+
+// Initialize sets the namespace of the grouping objects, and creates
+// the client and mapper from the factory unless they were passed to
+// NewPruneOptionsWithClients.
 func (po *PruneOptions) Initialize(factory util.Factory, namespace string) error {
 	var err error
 	// Fields copied from ApplyOptions.
 	po.namespace = namespace
-	// Client/Builder fields from the Factory.
-	po.client, err = factory.DynamicClient()
-	if err != nil {
-		return err
-	}
-	po.builder = factory.NewBuilder()
-	po.mapper, err = factory.ToRESTMapper()
-	if err != nil {
-		return err
+	// Client fields from the Factory.
+	if po.client == nil {
+		po.client, err = factory.DynamicClient()
+		if err != nil {
+			return err
+		}
 	}
-	po.validator, err = factory.Validator(false)
-	if err != nil {
-		return err
+	if po.mapper == nil {
+		po.mapper, err = factory.ToRESTMapper()
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -82,20 +161,18 @@ func (po *PruneOptions) Initialize(factory util.Factory, namespace string) error
 // that have the same label as the current grouping object. Removes
 // the current grouping object from this set. Returns an error
 // if there is a problem retrieving the grouping objects.
-func (po *PruneOptions) getPreviousGroupingObjects() ([]*resource.Info, error) {
-	// Ensures the "pastGroupingObjects" is set.
-	if !po.retrievedGroupingObjects {
-		if err := po.retrievePreviousGroupingObjects(); err != nil {
-			return nil, err
-		}
+func (po *PruneOptions) getPreviousGroupingObjects(currentGroupingObject *resource.Info) ([]*resource.Info, error) {
+	retrievedGroupingInfos, err := po.retrievePreviousGroupingObjects(currentGroupingObject)
+	if err != nil {
+		return nil, err
 	}
 	// Remove the current grouping info from the previous grouping infos.
-	current, err := infoToObjMetadata(po.currentGroupingObject)
+	current, err := infoToObjMetadata(currentGroupingObject)
 	if err != nil {
 		return nil, err
 	}
 	pastGroupInfos := []*resource.Info{}
-	for _, pastInfo := range po.pastGroupingObjects {
+	for _, pastInfo := range retrievedGroupingInfos {
 		past, err := infoToObjMetadata(pastInfo)
 		if err != nil {
 			return nil, err
@@ -108,50 +185,62 @@ func (po *PruneOptions) getPreviousGroupingObjects() ([]*resource.Info, error) {
 }
 
 // retrievePreviousGroupingObjects requests the previous grouping objects
-// using the grouping label from the current grouping object. Sets
-// the field "pastGroupingObjects". Returns an error if the grouping
-// label doesn't exist for the current currentGroupingObject does not
-// exist or if the call to retrieve the past grouping objects fails.
-func (po *PruneOptions) retrievePreviousGroupingObjects() error {
+// using the grouping label from the current grouping object, in the
+// namespace of the current grouping object. The namespace passed to
+// Initialize is only used when the grouping object has none. The result
+// also includes the current grouping object if it exists in the cluster.
+// Returns an error if the grouping label doesn't exist for the current
+// grouping object or if the call to retrieve the past grouping objects
+// fails. Nothing is stored in the PruneOptions, so they can be used by
+// several runs at the same time.
+func (po *PruneOptions) retrievePreviousGroupingObjects(currentGroupingObject *resource.Info) ([]*resource.Info, error) {
 	// Get the grouping label for this grouping object, and create
 	// a label selector from it.
-	if po.currentGroupingObject == nil || po.currentGroupingObject.Object == nil {
-		return fmt.Errorf("missing current grouping object")
+	if currentGroupingObject == nil || currentGroupingObject.Object == nil {
+		return nil, fmt.Errorf("missing current grouping object")
 	}
-	groupingLabel, err := retrieveGroupingLabel(po.currentGroupingObject.Object)
+	groupingLabel, err := retrieveGroupingLabel(currentGroupingObject.Object)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	labelSelector := fmt.Sprintf("%s=%s", GroupingLabel, groupingLabel)
-	retrievedGroupingInfos, err := po.builder.
-		Unstructured().
-		// TODO: Check if this validator is necessary.
-		Schema(po.validator).
-		ContinueOnError().
-		NamespaceParam(po.namespace).DefaultNamespace().
-		ResourceTypes("configmap").
-		LabelSelectorParam(labelSelector).
-		Flatten().
-		Do().
-		Infos()
+	mapping, err := po.mapper.RESTMapping(schema.GroupKind{Kind: "ConfigMap"})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	po.pastGroupingObjects = retrievedGroupingInfos
-	po.retrievedGroupingObjects = true
-	return nil
+	namespace := currentGroupingObject.Namespace
+	if namespace == "" {
+		namespace = po.namespace
+	}
+	list, err := po.client.Resource(mapping.Resource).Namespace(namespace).List(metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	retrievedGroupingInfos := make([]*resource.Info, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		retrievedGroupingInfos = append(retrievedGroupingInfos, &resource.Info{
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Mapping:   mapping,
+			Object:    obj,
+		})
+	}
+	return retrievedGroupingInfos, nil
 }
 
 // infoToObjMetadata transforms the object represented by the passed "info"
 // into its Inventory representation. Returns error if the passed Info
 // is nil, or the Object in the Info is empty.
-func infoToObjMetadata(info *resource.Info) (*ObjMetadata, error) {
+func infoToObjMetadata(info *resource.Info) (*object.ObjMetadata, error) {
 	if info == nil || info.Object == nil {
 		return nil, fmt.Errorf("empty resource.Info can not calculate as inventory")
 	}
 	obj := info.Object
 	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
-	return createObjMetadata(info.Namespace, info.Name, gk)
+	return object.CreateObjMetadata(info.Namespace, info.Name, gk)
 }
 
 // unionPastInventory takes a set of grouping objects (infos), returning the
@@ -160,15 +249,11 @@ func infoToObjMetadata(info *resource.Info) (*ObjMetadata, error) {
 // grouping objects, or if unable to retrieve the inventory from any
 // grouping object.
 func unionPastInventory(infos []*resource.Info) (*Inventory, error) {
-	inventorySet := NewInventory([]*ObjMetadata{})
-	for _, info := range infos {
-		inv, err := RetrieveInventoryFromGroupingObj([]*resource.Info{info})
-		if err != nil {
-			return nil, err
-		}
-		inventorySet.AddItems(inv)
+	keys, err := inventoryKeys(infos)
+	if err != nil {
+		return nil, err
 	}
-	return inventorySet, nil
+	return parseInventory(keys)
 }
 
 // calcPruneSet returns the Inventory representing the objects to
@@ -178,21 +263,85 @@ func unionPastInventory(infos []*resource.Info) (*Inventory, error) {
 //
 //   prune set = (prev1 U prev2 U ... U prevN) - (curr1, curr2, ..., currN)
 //
-// Returns an error if we are unable to retrieve the set of previously
-// applied objects, or if we are unable to get the currently applied objects
-// from the current grouping object.
-func (po *PruneOptions) calcPruneSet(pastGroupingInfos []*resource.Info) (*Inventory, error) {
-	pastInventory, err := unionPastInventory(pastGroupingInfos)
+// The sets are calculated from the keys stored in the grouping objects,
+// so only the objects in the prune set are parsed. Returns an error if
+// we are unable to retrieve the set of previously applied objects, or
+// if we are unable to get the currently applied objects from the
+// current grouping object.
+func (po *PruneOptions) calcPruneSet(currentGroupingObject *resource.Info,
+	pastGroupingInfos []*resource.Info) (*Inventory, error) {
+	keys, err := inventoryKeys(pastGroupingInfos)
+	if err != nil {
+		return nil, err
+	}
+	currentKeys, err := inventoryKeys([]*resource.Info{currentGroupingObject})
 	if err != nil {
 		return nil, err
 	}
-	// Current grouping object as inventory set.
-	c := []*resource.Info{po.currentGroupingObject}
-	currentInv, err := RetrieveInventoryFromGroupingObj(c)
+	for key := range currentKeys {
+		delete(keys, key)
+	}
+	pruneSet, err := parseInventory(keys)
 	if err != nil {
 		return nil, err
 	}
-	return pastInventory.Subtract(NewInventory(currentInv))
+	// The grouping objects are never in the prune set, even if they are
+	// recorded in an inventory. The current one holds the inventory of
+	// this apply, and the previous ones are only deleted once it has
+	// been applied.
+	for _, info := range append([]*resource.Info{currentGroupingObject}, pastGroupingInfos...) {
+		if info == nil || !IsGroupingObject(info.Object) {
+			continue
+		}
+		groupingObj, err := infoToObjMetadata(info)
+		if err != nil {
+			return nil, err
+		}
+		pruneSet.DeleteItem(groupingObj)
+	}
+	return pruneSet, nil
+}
+
+// inventoryKeys returns the union of the inventory strings stored in
+// the "data" section of the grouping objects. The grouping objects are
+// read without being copied. Objects that are not grouping objects
+// are ignored, like RetrieveInventoryFromGroupingObj does.
+func inventoryKeys(infos []*resource.Info) (map[string]bool, error) {
+	keys := map[string]bool{}
+	for _, info := range infos {
+		if info == nil || !IsGroupingObject(info.Object) {
+			continue
+		}
+		groupingObj, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("grouping object is not an Unstructured: %#v", info.Object)
+		}
+		data, found, err := unstructured.NestedFieldNoCopy(groupingObj.Object, "data")
+		if err != nil || !found {
+			continue
+		}
+		invMap, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error retrieving inventory from grouping object")
+		}
+		for key := range invMap {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// parseInventory returns the Inventory of the passed inventory strings.
+func parseInventory(keys map[string]bool) (*Inventory, error) {
+	inventory := NewInventory(nil)
+	for key := range keys {
+		inv, err := object.ParseObjMetadata(key)
+		if err != nil {
+			return nil, err
+		}
+		inventory.AddItems([]*object.ObjMetadata{inv})
+	}
+	return inventory, nil
 }
 
 // Prune deletes the set of resources which were previously applied
@@ -200,28 +349,37 @@ func (po *PruneOptions) calcPruneSet(pastGroupingInfos []*resource.Info) (*Inven
 // the current apply. Prune also delete all previous grouping
 // objects. Returns an error if there was a problem.
 func (po *PruneOptions) Prune(currentObjects []*resource.Info, eventChannel chan<- event.Event) error {
-	currentGroupingObject, found := FindGroupingObject(currentObjects)
-	if !found {
-		return fmt.Errorf("current grouping object not found during prune")
-	}
-	po.currentGroupingObject = currentGroupingObject
-	// Initialize past grouping objects as empty.
-	po.pastGroupingObjects = []*resource.Info{}
-	po.retrievedGroupingObjects = false
-
-	// Retrieve previous grouping objects, and calculate the
-	// union of the previous applies as an inventory set.
-	pastGroupingInfos, err := po.getPreviousGroupingObjects()
+	currentGroupingObject, pastGroupingInfos, pruneSet, err := po.pruneSet(currentObjects)
 	if err != nil {
 		return err
 	}
-	pruneSet, err := po.calcPruneSet(pastGroupingInfos)
+	inventoryID, err := retrieveGroupingLabel(currentGroupingObject.Object)
 	if err != nil {
 		return err
 	}
+	pruneObjs := pruneSet.GetItems()
+	// Delete the resources in the reverse order they are applied, so
+	// resources are deleted before the resources they depend on.
+	object.ReverseSortObjMetadata(pruneObjs)
+	po.logger().V(1).Info("calculated prune set", "count", len(pruneObjs),
+		"previousGroupingObjects", len(pastGroupingInfos))
+	pruneStarted := time.Now()
+	pruneTotal := len(pruneObjs) + len(pastGroupingInfos)
+	pruned := 0
+	var deleted []*object.ObjMetadata
+	refreshed := false
 	// Delete the prune objects.
-	for _, inv := range pruneSet.GetItems() {
+	for _, inv := range pruneObjs {
+		started := time.Now()
 		mapping, err := po.mapper.RESTMapping(inv.GroupKind)
+		if r, ok := po.mapper.(resettableMapper); ok && meta.IsNoMatchError(err) && !refreshed {
+			// The kind might have been registered moments ago, after
+			// the mapper read the discovery information, so it is
+			// read again once.
+			refreshed = true
+			r.Reset()
+			mapping, err = po.mapper.RESTMapping(inv.GroupKind)
+		}
 		if err != nil {
 			return err
 		}
@@ -232,41 +390,252 @@ func (po *PruneOptions) Prune(currentObjects []*resource.Info, eventChannel chan
 		if err != nil {
 			// Do not return if object to prune (delete) is not found
 			if apierrors.IsNotFound(err) {
+				po.logger().V(2).Info("resource to prune not found", "resource", inv.String())
+				pruned++
+				eventChannel <- event.NewProgressEvent(event.PrunePhase, pruned, pruneTotal, pruneStarted)
 				continue
 			}
+			return &PruneError{Object: *inv, Err: err}
+		}
+		canPrune, err := CanPrune(inventoryID, obj, po.InventoryPolicy)
+		if err != nil {
 			return err
 		}
-		if !po.DryRun {
-			err = namespacedClient.Delete(inv.Name, &metav1.DeleteOptions{})
+		switch {
+		case IsGroupingObject(obj):
+			// Grouping objects are never pruned as resources, since one
+			// of them might hold the current inventory. The previous
+			// ones are deleted below.
+			po.logger().Info("skipping prune of grouping object", "resource", inv.String())
+			canPrune = false
+		case !canPrune:
+			po.logger().V(1).Info("skipping prune of resource not owned by the inventory", "resource", inv.String(),
+				"inventoryPolicy", po.InventoryPolicy.String())
+		case !po.AllowProtectedDeletion:
+			protected, err := IsProtected(obj, po.ProtectedNamespaces)
 			if err != nil {
 				return err
 			}
+			if protected {
+				po.logger().Info("skipping prune of protected resource", "resource", inv.String())
+				canPrune = false
+			}
+		}
+		if !canPrune {
+			eventChannel <- event.Event{
+				Type:      event.PruneType,
+				Timestamp: time.Now(),
+				Started:   started,
+				PruneEvent: event.PruneEvent{
+					Type:       event.PruneEventResourceUpdate,
+					Operation:  event.PruneSkipped,
+					Object:     object.Redact(obj, po.SensitiveFields),
+					Identifier: *inv,
+				},
+			}
+			pruned++
+			eventChannel <- event.NewProgressEvent(event.PrunePhase, pruned, pruneTotal, pruneStarted)
+			continue
 		}
+		po.logger().V(1).Info("pruning resource", "resource", inv.String(), "dryRun", po.DryRunStrategy.String())
+		if !po.DryRunStrategy.ClientDryRun() {
+			err = namespacedClient.Delete(inv.Name, po.deleteOptions())
+			if err != nil {
+				return &PruneError{Object: *inv, Err: err}
+			}
+		}
+		deleted = append(deleted, inv)
 		eventChannel <- event.Event{
-			Type: event.PruneType,
+			Type:      event.PruneType,
+			Timestamp: time.Now(),
+			Started:   started,
 			PruneEvent: event.PruneEvent{
-				Type:   event.PruneEventResourceUpdate,
-				Object: obj,
+				Type:       event.PruneEventResourceUpdate,
+				Object:     object.Redact(obj, po.SensitiveFields),
+				Identifier: *inv,
 			},
 		}
+		pruned++
+		eventChannel <- event.NewProgressEvent(event.PrunePhase, pruned, pruneTotal, pruneStarted)
+	}
+	if po.WaitForDeletion != nil && len(deleted) > 0 && !po.DryRunStrategy.ClientOrServerDryRun() {
+		po.logger().V(1).Info("waiting for resources to be deleted", "count", len(deleted))
+		if err := po.WaitForDeletion(deleted, eventChannel); err != nil {
+			return err
+		}
 	}
 	// Delete previous grouping objects.
 	for _, pastGroupInfo := range pastGroupingInfos {
-		if !po.DryRun {
+		started := time.Now()
+		po.logger().V(1).Info("deleting previous grouping object", "name", pastGroupInfo.Name,
+			"namespace", pastGroupInfo.Namespace, "dryRun", po.DryRunStrategy.String())
+		if !po.DryRunStrategy.ClientDryRun() {
 			err = po.client.Resource(pastGroupInfo.Mapping.Resource).
 				Namespace(pastGroupInfo.Namespace).
-				Delete(pastGroupInfo.Name, &metav1.DeleteOptions{})
+				Delete(pastGroupInfo.Name, po.deleteOptions())
 			if err != nil {
 				return err
 			}
 		}
+		pastGroupIdentifier, err := infoToObjMetadata(pastGroupInfo)
+		if err != nil {
+			return err
+		}
 		eventChannel <- event.Event{
-			Type: event.PruneType,
+			Type:      event.PruneType,
+			Timestamp: time.Now(),
+			Started:   started,
 			PruneEvent: event.PruneEvent{
-				Type:   event.PruneEventResourceUpdate,
-				Object: pastGroupInfo.Object,
+				Type:       event.PruneEventResourceUpdate,
+				Object:     pastGroupInfo.Object,
+				Identifier: *pastGroupIdentifier,
 			},
 		}
+		pruned++
+		eventChannel <- event.NewProgressEvent(event.PrunePhase, pruned, pruneTotal, pruneStarted)
 	}
 	return nil
 }
+
+// PruneSet returns the resources that a Prune with the current objects
+// would delete, which are the resources in the previous grouping objects
+// that are not in the current one. They are returned in the order they
+// are deleted. Nothing is changed in the cluster.
+func (po *PruneOptions) PruneSet(currentObjects []*resource.Info) ([]*object.ObjMetadata, error) {
+	_, _, pruneSet, err := po.pruneSet(currentObjects)
+	if err != nil {
+		return nil, err
+	}
+	pruneObjs := pruneSet.GetItems()
+	object.ReverseSortObjMetadata(pruneObjs)
+	return pruneObjs, nil
+}
+
+// pruneSet finds the current grouping object, retrieves the previous
+// grouping objects, and calculates the union of the previous applies
+// minus the current objects as the set of resources to prune.
+func (po *PruneOptions) pruneSet(currentObjects []*resource.Info) (*resource.Info, []*resource.Info, *Inventory, error) {
+	currentGroupingObject, found := FindGroupingObject(currentObjects)
+	if !found {
+		return nil, nil, nil, fmt.Errorf("%w during prune", ErrInventoryNotFound)
+	}
+	pastGroupingInfos, err := po.getPreviousGroupingObjects(currentGroupingObject)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pruneSet, err := po.calcPruneSet(currentGroupingObject, pastGroupingInfos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if po.RecoverUnrecorded {
+		unrecorded, err := po.unrecordedObjects(currentGroupingObject, pastGroupingInfos)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pruneSet.AddItems(unrecorded)
+	}
+	return currentGroupingObject, pastGroupingInfos, pruneSet, nil
+}
+
+// unrecordedObjects returns the resources that belong to the inventory
+// of the current grouping object, but are recorded neither in it nor
+// in a previous grouping object. This happens if a run was interrupted
+// after applying resources, but before its grouping object was stored,
+// so they are added to the prune set to keep them from being left
+// behind. Since the same inventory id can be used in other namespaces,
+// only the namespace of the grouping object is searched, for the
+// namespaced kinds in the inventories. Cluster-scoped resources are
+// not searched, since the inventory they belong to can't be told
+// apart from the ones with the same id in other namespaces. Resources
+// that can't be listed are skipped.
+func (po *PruneOptions) unrecordedObjects(currentGroupingObject *resource.Info,
+	pastGroupingInfos []*resource.Info) ([]*object.ObjMetadata, error) {
+	inventoryID, err := retrieveGroupingLabel(currentGroupingObject.Object)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := inventoryKeys(append([]*resource.Info{currentGroupingObject}, pastGroupingInfos...))
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := parseInventory(keys)
+	if err != nil {
+		return nil, err
+	}
+	recordedKeys := make(map[string]bool, recorded.Size())
+	var kinds []schema.GroupKind
+	for _, obj := range recorded.GetItems() {
+		recordedKeys[obj.String()] = true
+		if obj.Namespace == currentGroupingObject.Namespace && !containsGroupKind(kinds, obj.GroupKind) {
+			kinds = append(kinds, obj.GroupKind)
+		}
+	}
+	var unrecorded []*object.ObjMetadata
+	for _, gk := range kinds {
+		mapping, err := po.mapper.RESTMapping(gk)
+		if err != nil || mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			continue
+		}
+		list, err := po.client.Resource(mapping.Resource).Namespace(currentGroupingObject.Namespace).
+			List(metav1.ListOptions{})
+		if err != nil {
+			po.logger().V(1).Info("skipping unrecorded resources that can't be listed", "kind", gk.String(),
+				"error", err.Error())
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if obj.GetAnnotations()[OwningInventoryAnnotation] != inventoryID {
+				continue
+			}
+			id, err := object.CreateObjMetadata(obj.GetNamespace(), obj.GetName(), gk)
+			if err != nil {
+				return nil, err
+			}
+			if !recordedKeys[id.String()] {
+				unrecorded = append(unrecorded, id)
+			}
+		}
+	}
+	if len(unrecorded) > 0 {
+		po.logger().Info("found resources of the inventory that are not recorded in a grouping object",
+			"count", len(unrecorded))
+	}
+	return unrecorded, nil
+}
+
+func containsGroupKind(kinds []schema.GroupKind, gk schema.GroupKind) bool {
+	for _, k := range kinds {
+		if k == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteOptions returns the options for the delete requests, which
+// make sure nothing is deleted when doing a server dry-run.
+func (po *PruneOptions) deleteOptions() *metav1.DeleteOptions {
+	options := &metav1.DeleteOptions{}
+	if po.PropagationPolicy != "" {
+		policy := po.PropagationPolicy
+		options.PropagationPolicy = &policy
+	}
+	if po.GracePeriodSeconds != nil {
+		gracePeriod := *po.GracePeriodSeconds
+		options.GracePeriodSeconds = &gracePeriod
+	}
+	if po.DryRunStrategy.ServerDryRun() {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	return options
+}
+
+// logger returns the Logger, or a logger that discards
+// everything if none has been set.
+func (po *PruneOptions) logger() logr.Logger {
+	if po.Logger == nil {
+		return log.NullLogger{}
+	}
+	return po.Logger
+}
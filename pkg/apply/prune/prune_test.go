@@ -6,11 +6,18 @@ package prune
 import (
 	"testing"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
-var pod1Inv = &ObjMetadata{
+var pod1Inv = &object.ObjMetadata{
 	Namespace: testNamespace,
 	Name:      pod1Name,
 	GroupKind: schema.GroupKind{
@@ -19,7 +26,7 @@ var pod1Inv = &ObjMetadata{
 	},
 }
 
-var pod2Inv = &ObjMetadata{
+var pod2Inv = &object.ObjMetadata{
 	Namespace: testNamespace,
 	Name:      pod2Name,
 	GroupKind: schema.GroupKind{
@@ -28,7 +35,7 @@ var pod2Inv = &ObjMetadata{
 	},
 }
 
-var pod3Inv = &ObjMetadata{
+var pod3Inv = &object.ObjMetadata{
 	Namespace: testNamespace,
 	Name:      pod3Name,
 	GroupKind: schema.GroupKind{
@@ -37,7 +44,7 @@ var pod3Inv = &ObjMetadata{
 	},
 }
 
-var groupingInv = &ObjMetadata{
+var groupingInv = &object.ObjMetadata{
 	Namespace: testNamespace,
 	Name:      groupingObjName,
 	GroupKind: schema.GroupKind{
@@ -49,7 +56,7 @@ var groupingInv = &ObjMetadata{
 func TestInfoToObjMetadata(t *testing.T) {
 	tests := map[string]struct {
 		info     *resource.Info
-		expected *ObjMetadata
+		expected *object.ObjMetadata
 		isError  bool
 	}{
 		"Nil info is an error": {
@@ -85,7 +92,7 @@ func TestInfoToObjMetadata(t *testing.T) {
 					t.Errorf("Receieved unexpected error: %s\n", err)
 				}
 				if !tc.expected.Equals(actual) {
-					t.Errorf("Expected ObjMetadata (%s), got (%s)\n", tc.expected, actual)
+					t.Errorf("Expected object.ObjMetadata (%s), got (%s)\n", tc.expected, actual)
 				}
 			}
 		})
@@ -110,39 +117,39 @@ func createGroupingInfo(_ string, children ...*resource.Info) *resource.Info {
 func TestUnionPastInventory(t *testing.T) {
 	tests := map[string]struct {
 		groupingInfos []*resource.Info
-		expected      []*ObjMetadata
+		expected      []*object.ObjMetadata
 	}{
 		"Empty grouping objects = empty inventory": {
 			groupingInfos: []*resource.Info{},
-			expected:      []*ObjMetadata{},
+			expected:      []*object.ObjMetadata{},
 		},
 		"No children in grouping object, equals no inventory": {
 			groupingInfos: []*resource.Info{createGroupingInfo("test-1")},
-			expected:      []*ObjMetadata{},
+			expected:      []*object.ObjMetadata{},
 		},
 		"Grouping object with Pod1 returns inventory with Pod1": {
 			groupingInfos: []*resource.Info{createGroupingInfo("test-1", pod1Info)},
-			expected:      []*ObjMetadata{pod1Inv},
+			expected:      []*object.ObjMetadata{pod1Inv},
 		},
 		"Grouping object with three pods returns inventory with three pods": {
 			groupingInfos: []*resource.Info{
 				createGroupingInfo("test-1", pod1Info, pod2Info, pod3Info),
 			},
-			expected: []*ObjMetadata{pod1Inv, pod2Inv, pod3Inv},
+			expected: []*object.ObjMetadata{pod1Inv, pod2Inv, pod3Inv},
 		},
 		"Two grouping objects with different pods returns inventory with both pods": {
 			groupingInfos: []*resource.Info{
 				createGroupingInfo("test-1", pod1Info),
 				createGroupingInfo("test-2", pod2Info),
 			},
-			expected: []*ObjMetadata{pod1Inv, pod2Inv},
+			expected: []*object.ObjMetadata{pod1Inv, pod2Inv},
 		},
 		"Two grouping objects with overlapping pods returns set of pods": {
 			groupingInfos: []*resource.Info{
 				createGroupingInfo("test-1", pod1Info, pod2Info),
 				createGroupingInfo("test-2", pod2Info, pod3Info),
 			},
-			expected: []*ObjMetadata{pod1Inv, pod2Inv, pod3Inv},
+			expected: []*object.ObjMetadata{pod1Inv, pod2Inv, pod3Inv},
 		},
 	}
 
@@ -164,26 +171,26 @@ func TestCalcPruneSet(t *testing.T) {
 	tests := map[string]struct {
 		past     []*resource.Info
 		current  *resource.Info
-		expected []*ObjMetadata
+		expected []*object.ObjMetadata
 		isError  bool
 	}{
 		"Object not unstructured--error": {
 			past:     []*resource.Info{nonUnstructuredGroupingInfo},
 			current:  &resource.Info{},
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  true,
 		},
 		"No past group objects--no prune set": {
 
 			past:     []*resource.Info{},
 			current:  createGroupingInfo("test-1"),
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  false,
 		},
 		"Empty past grouping object--no prune set": {
 			past:     []*resource.Info{createGroupingInfo("test-1")},
 			current:  createGroupingInfo("test-1"),
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  false,
 		},
 		"Pod1 - Pod1 = empty set": {
@@ -191,7 +198,7 @@ func TestCalcPruneSet(t *testing.T) {
 				createGroupingInfo("test-1", pod1Info),
 			},
 			current:  createGroupingInfo("test-1", pod1Info),
-			expected: []*ObjMetadata{},
+			expected: []*object.ObjMetadata{},
 			isError:  false,
 		},
 		"(Pod1, Pod2) - Pod1 = Pod2": {
@@ -199,7 +206,7 @@ func TestCalcPruneSet(t *testing.T) {
 				createGroupingInfo("test-1", pod1Info, pod2Info),
 			},
 			current:  createGroupingInfo("test-1", pod1Info),
-			expected: []*ObjMetadata{pod2Inv},
+			expected: []*object.ObjMetadata{pod2Inv},
 			isError:  false,
 		},
 		"(Pod1, Pod2) - Pod2 = Pod1": {
@@ -207,7 +214,7 @@ func TestCalcPruneSet(t *testing.T) {
 				createGroupingInfo("test-1", pod1Info, pod2Info),
 			},
 			current:  createGroupingInfo("test-1", pod2Info),
-			expected: []*ObjMetadata{pod1Inv},
+			expected: []*object.ObjMetadata{pod1Inv},
 			isError:  false,
 		},
 		"(Pod1, Pod2, Pod3) - Pod2 = Pod1, Pod3": {
@@ -216,7 +223,7 @@ func TestCalcPruneSet(t *testing.T) {
 				createGroupingInfo("test-1", pod2Info, pod3Info),
 			},
 			current:  createGroupingInfo("test-1", pod2Info),
-			expected: []*ObjMetadata{pod1Inv, pod3Inv},
+			expected: []*object.ObjMetadata{pod1Inv, pod3Inv},
 			isError:  false,
 		},
 	}
@@ -224,8 +231,7 @@ func TestCalcPruneSet(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			po := &PruneOptions{}
-			po.currentGroupingObject = tc.current
-			actual, err := po.calcPruneSet(tc.past)
+			actual, err := po.calcPruneSet(tc.current, tc.past)
 			expected := NewInventory(tc.expected)
 			if tc.isError && err == nil {
 				t.Errorf("Did not receive expected error.\n")
@@ -241,3 +247,303 @@ func TestCalcPruneSet(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteOptions(t *testing.T) {
+	tests := map[string]struct {
+		policy         string
+		isError        bool
+		expectedPolicy metav1.DeletionPropagation
+	}{
+		"no policy uses the server default": {
+			policy: "",
+		},
+		"background": {
+			policy:         "background",
+			expectedPolicy: metav1.DeletePropagationBackground,
+		},
+		"foreground": {
+			policy:         "Foreground",
+			expectedPolicy: metav1.DeletePropagationForeground,
+		},
+		"orphan": {
+			policy:         "orphan",
+			expectedPolicy: metav1.DeletePropagationOrphan,
+		},
+		"invalid policy": {
+			policy:  "cascade",
+			isError: true,
+		},
+	}
+
+	var gracePeriod int64 = 30
+	po := NewPruneOptions()
+	po.GracePeriodSeconds = &gracePeriod
+	options := po.deleteOptions()
+	if options.GracePeriodSeconds == nil || *options.GracePeriodSeconds != gracePeriod {
+		t.Errorf("expected grace period %d, got %v", gracePeriod, options.GracePeriodSeconds)
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			po := NewPruneOptions()
+			if tc.policy != "" {
+				policy, err := ParsePropagationPolicy(tc.policy)
+				if tc.isError {
+					if err == nil {
+						t.Fatalf("expected error for policy %q", tc.policy)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				po.PropagationPolicy = policy
+			}
+			options := po.deleteOptions()
+			if tc.expectedPolicy == "" {
+				if options.PropagationPolicy != nil {
+					t.Errorf("expected no propagation policy, got %s", *options.PropagationPolicy)
+				}
+				return
+			}
+			if options.PropagationPolicy == nil || *options.PropagationPolicy != tc.expectedPolicy {
+				t.Errorf("expected propagation policy %s, got %v", tc.expectedPolicy, options.PropagationPolicy)
+			}
+		})
+	}
+}
+
+func TestPruneWithClients(t *testing.T) {
+	pastGroupingInfo := createGroupingInfo("test-1", pod1Info, pod2Info)
+	pastGroupingObj := pastGroupingInfo.Object.(*unstructured.Unstructured)
+	pastGroupingObj.SetName("past-grouping-obj")
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pastGroupingObj, pod1.DeepCopy(), pod2.DeepCopy())
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+	po := NewPruneOptionsWithClients(client, mapper)
+	// The clients are not replaced by the ones from the factory.
+	if err := po.Initialize(nil, testNamespace); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current := createGroupingInfo("test-1", pod1Info)
+	eventChannel := make(chan event.Event, 10)
+	if err := po.Prune([]*resource.Info{current, pod1Info}, eventChannel); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	if _, err := client.Resource(podsGVR).Namespace(testNamespace).Get(pod1Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected %s to be kept: %v", pod1Name, err)
+	}
+	if _, err := client.Resource(podsGVR).Namespace(testNamespace).Get(pod2Name, metav1.GetOptions{}); err == nil {
+		t.Errorf("expected %s to be pruned", pod2Name)
+	}
+	configMapsGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	_, err := client.Resource(configMapsGVR).Namespace(testNamespace).Get("past-grouping-obj", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("expected the past grouping object to be deleted")
+	}
+}
+
+// lateKindMapper only knows the Pod kind once it has been reset,
+// like a cached mapper when the kind was registered after the
+// discovery information was read.
+type lateKindMapper struct {
+	*meta.DefaultRESTMapper
+	resets int
+}
+
+func (m *lateKindMapper) Reset() {
+	m.resets++
+	m.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+}
+
+func TestPruneRefreshesMapper(t *testing.T) {
+	pastGroupingInfo := createGroupingInfo("test-1", pod1Info, pod2Info)
+	pastGroupingObj := pastGroupingInfo.Object.(*unstructured.Unstructured)
+	pastGroupingObj.SetName("past-grouping-obj")
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pastGroupingObj, pod1.DeepCopy(), pod2.DeepCopy())
+	mapper := &lateKindMapper{DefaultRESTMapper: meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})}
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	po := NewPruneOptionsWithClients(client, mapper)
+	if err := po.Initialize(nil, testNamespace); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current := createGroupingInfo("test-1")
+	eventChannel := make(chan event.Event, 10)
+	if err := po.Prune([]*resource.Info{current}, eventChannel); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mapper.resets != 1 {
+		t.Errorf("expected the mapper to be reset once, but it was reset %d times", mapper.resets)
+	}
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	for _, name := range []string{pod1Name, pod2Name} {
+		if _, err := client.Resource(podsGVR).Namespace(testNamespace).Get(name, metav1.GetOptions{}); err == nil {
+			t.Errorf("expected %s to be pruned", name)
+		}
+	}
+}
+
+// recordInInventory adds the resources to the inventory of the
+// grouping object, without adding a suffix to its name.
+func recordInInventory(t *testing.T, groupingInfo *resource.Info, objs ...*object.ObjMetadata) {
+	groupingObj := groupingInfo.Object.(*unstructured.Unstructured)
+	data, _, _ := unstructured.NestedStringMap(groupingObj.Object, "data")
+	if data == nil {
+		data = map[string]string{}
+	}
+	for _, obj := range objs {
+		key, err := object.FormatObjMetadata(*obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data[key] = ""
+	}
+	if err := unstructured.SetNestedStringMap(groupingObj.Object, data, "data"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCalcPruneSetExcludesGroupingObjects(t *testing.T) {
+	current := createGroupingInfo("test-1", pod1Info)
+	currentInv, err := infoToObjMetadata(current)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shard := createGroupingInfo("test-1", pod3Info)
+	shard.Object.(*unstructured.Unstructured).SetName("test-grouping-shard")
+	shard.Name = "test-grouping-shard"
+	shardInv, err := infoToObjMetadata(shard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	past := createGroupingInfo("test-1", pod1Info, pod2Info)
+	past.Object.(*unstructured.Unstructured).SetName("test-grouping-past")
+	past.Name = "test-grouping-past"
+	// The past inventory records the current grouping object and the
+	// shard, like if they had been applied as resources.
+	recordInInventory(t, past, currentInv, shardInv)
+
+	po := &PruneOptions{}
+	actual, err := po.calcPruneSet(current, []*resource.Info{past, shard})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := NewInventory([]*object.ObjMetadata{pod2Inv, pod3Inv})
+	if !expected.Equals(actual) {
+		t.Errorf("Expected prune set (%s), got (%s)\n", expected, actual)
+	}
+}
+
+func TestPruneSkipsGroupingObjects(t *testing.T) {
+	// The grouping object of another inventory, which is not
+	// retrieved as one of the previous grouping objects.
+	other := groupingObj.DeepCopy()
+	other.SetName("other-grouping-obj")
+	other.SetLabels(map[string]string{GroupingLabel: "other"})
+	otherInv := &object.ObjMetadata{
+		Namespace: testNamespace,
+		Name:      other.GetName(),
+		GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+	}
+	pastGroupingInfo := createGroupingInfo("test-1", pod1Info, pod2Info)
+	pastGroupingObj := pastGroupingInfo.Object.(*unstructured.Unstructured)
+	pastGroupingObj.SetName("past-grouping-obj")
+	recordInInventory(t, pastGroupingInfo, otherInv)
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pastGroupingObj, other, pod1.DeepCopy(),
+		pod2.DeepCopy())
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+	po := NewPruneOptionsWithClients(client, mapper)
+	po.InventoryPolicy = InventoryPolicyForceAdopt
+	if err := po.Initialize(nil, testNamespace); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current := createGroupingInfo("test-1", pod1Info)
+	eventChannel := make(chan event.Event, 10)
+	if err := po.Prune([]*resource.Info{current, pod1Info}, eventChannel); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(eventChannel)
+
+	configMapsGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	_, err := client.Resource(configMapsGVR).Namespace(testNamespace).Get(other.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("expected the grouping object of the other inventory to be kept: %v", err)
+	}
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	if _, err := client.Resource(podsGVR).Namespace(testNamespace).Get(pod2Name, metav1.GetOptions{}); err == nil {
+		t.Errorf("expected %s to be pruned", pod2Name)
+	}
+	skipped := false
+	for e := range eventChannel {
+		if e.Type == event.PruneType && e.PruneEvent.Operation == event.PruneSkipped {
+			skipped = skipped || e.PruneEvent.Identifier.Equals(otherInv)
+		}
+	}
+	if !skipped {
+		t.Errorf("expected a skipped prune event for %s", otherInv)
+	}
+}
+
+func TestPruneSetRecoversUnrecordedObjects(t *testing.T) {
+	owned := func(obj unstructured.Unstructured, namespace, inventoryID string) *unstructured.Unstructured {
+		u := obj.DeepCopy()
+		u.SetNamespace(namespace)
+		u.SetAnnotations(map[string]string{OwningInventoryAnnotation: inventoryID})
+		return u
+	}
+	pastGroupingInfo := createGroupingInfo("test-1", pod1Info)
+	pastGroupingObj := pastGroupingInfo.Object.(*unstructured.Unstructured)
+	pastGroupingObj.SetName("past-grouping-obj")
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme,
+		pastGroupingObj,
+		owned(pod1, testNamespace, testGroupingLabel),
+		// Applied by an interrupted run, and not recorded.
+		owned(pod2, testNamespace, testGroupingLabel),
+		// Belongs to another inventory.
+		owned(pod3, testNamespace, "other"),
+		// The same inventory id in another namespace.
+		owned(pod2, "other-namespace", testGroupingLabel),
+	)
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+
+	testCases := map[string]struct {
+		recover  bool
+		expected []*object.ObjMetadata
+	}{
+		"recovered": {
+			recover:  true,
+			expected: []*object.ObjMetadata{pod2Inv},
+		},
+		// Without the option, nothing is listed.
+		"not recovered": {},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			po := NewPruneOptionsWithClients(client, mapper)
+			if err := po.Initialize(nil, testNamespace); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			po.RecoverUnrecorded = tc.recover
+			current := createGroupingInfo("test-1", pod1Info)
+			actual, err := po.PruneSet([]*resource.Info{current, pod1Info})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := NewInventory(tc.expected)
+			if !expected.Equals(NewInventory(actual)) {
+				t.Errorf("Expected prune set (%s), got (%v)\n", expected, actual)
+			}
+		})
+	}
+}
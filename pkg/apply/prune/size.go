@@ -0,0 +1,99 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+const (
+	// ConfigMapDataLimit is the maximum size in bytes of the data
+	// of a ConfigMap, as validated by the API server.
+	ConfigMapDataLimit = 1024 * 1024
+	// AnnotationsLimit is the maximum total size in bytes of the
+	// annotations of an object, as validated by the API server.
+	AnnotationsLimit = 256 * 1024
+)
+
+// InventoryTooLargeError is returned before anything is applied if the
+// grouping object would be larger than the API server accepts.
+type InventoryTooLargeError struct {
+	// Name is the name of the grouping object, and Items the
+	// number of resources in its inventory.
+	Name  string
+	Items int
+	// Size is the size of the grouping object and Limit the
+	// size allowed by the API server, both in bytes.
+	Size  int
+	Limit int
+	// ServerSide is true if the grouping object is applied
+	// with a server-side apply.
+	ServerSide bool
+}
+
+func (e *InventoryTooLargeError) Error() string {
+	msg := fmt.Sprintf("the inventory of %d resources in grouping object %s takes %d bytes, over the limit of %d "+
+		"bytes; split the package into several packages, each with its own grouping object", e.Items, e.Name,
+		e.Size, e.Limit)
+	if !e.ServerSide {
+		msg += ", or use a server-side apply, which doesn't copy the inventory to the " +
+			corev1.LastAppliedConfigAnnotation + " annotation"
+	}
+	return msg
+}
+
+// GroupingObjectSize returns the size in bytes the grouping object
+// takes once the statuses of the resources are recorded in it, and the
+// limit of the API server for that size. With a client-side apply, the
+// whole object is copied to an annotation, so the size of the
+// annotations is limited. Otherwise the size of the data is limited.
+func GroupingObjectSize(obj *unstructured.Unstructured, serverSide bool) (int, int, error) {
+	if !IsGroupingObject(obj) {
+		return 0, 0, fmt.Errorf("object is not a grouping object")
+	}
+	obj = obj.DeepCopy()
+	invMap, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		return 0, 0, fmt.Errorf("error retrieving inventory from grouping object")
+	}
+	// The statuses replace the empty values once the resources have
+	// been applied, so the longest one is counted for every resource.
+	longest := len(status.NotFoundStatus)
+	for _, s := range status.Statuses {
+		if len(s) > longest {
+			longest = len(s)
+		}
+	}
+	dataSize := 0
+	for key := range invMap {
+		invMap[key] = fmt.Sprintf("%*s", longest, "")
+		dataSize += len(key) + longest
+	}
+	if serverSide {
+		return dataSize, ConfigMapDataLimit, nil
+	}
+
+	if len(invMap) > 0 {
+		if err := unstructured.SetNestedStringMap(obj.Object, invMap, "data"); err != nil {
+			return 0, 0, err
+		}
+	}
+	annotations := obj.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	obj.SetAnnotations(annotations)
+	lastApplied, err := json.Marshal(obj.Object)
+	if err != nil {
+		return 0, 0, err
+	}
+	size := len(corev1.LastAppliedConfigAnnotation) + len(lastApplied)
+	for k, v := range annotations {
+		size += len(k) + len(v)
+	}
+	return size, AnnotationsLimit, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package prune
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGroupingObjectSize(t *testing.T) {
+	info := createGroupingInfo("test-1", pod1Info, pod2Info)
+	obj := info.Object.(*unstructured.Unstructured)
+	invMap, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every resource is counted with the longest status, Terminating.
+	expected := 0
+	for key := range invMap {
+		expected += len(key) + len("Terminating")
+	}
+
+	size, limit, err := GroupingObjectSize(obj, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size != expected || limit != ConfigMapDataLimit {
+		t.Errorf("expected size %d and limit %d, got %d and %d", expected, ConfigMapDataLimit, size, limit)
+	}
+
+	// The whole object is copied to the annotation by a client-side apply.
+	size, limit, err = GroupingObjectSize(obj, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size <= expected || limit != AnnotationsLimit {
+		t.Errorf("expected size over %d and limit %d, got %d and %d", expected, AnnotationsLimit, size, limit)
+	}
+	if _, found := obj.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; found {
+		t.Errorf("expected the grouping object to be left unchanged")
+	}
+
+	if _, _, err := GroupingObjectSize(pod1.DeepCopy(), true); err == nil {
+		t.Errorf("expected error for an object that is not a grouping object")
+	}
+}
+
+func TestInventoryTooLargeError(t *testing.T) {
+	err := &InventoryTooLargeError{Name: "inventory", Items: 10, Size: 300, Limit: 200}
+	if !strings.Contains(err.Error(), "split the package") {
+		t.Errorf("expected the error to suggest splitting the package, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), "server-side apply") {
+		t.Errorf("expected the error to suggest a server-side apply, got %q", err.Error())
+	}
+	err.ServerSide = true
+	if strings.Contains(err.Error(), "server-side apply") {
+		t.Errorf("expected no server-side apply suggestion, got %q", err.Error())
+	}
+}
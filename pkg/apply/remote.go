@@ -0,0 +1,304 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// checksumFragmentPrefix is used in the fragment of a manifest URL to pin
+// the SHA-256 checksum of the content, for example
+// https://example.com/release.yaml#sha256=<hex>.
+const checksumFragmentPrefix = "sha256="
+
+// remoteTimeout is the timeout for downloading a manifest.
+const remoteTimeout = 30 * time.Second
+
+// remoteManifests downloads the manifests given as http(s) URLs into a
+// temporary directory, so the checksum can be verified before they are
+// read. The directory is kept until cleanup is called, so the manifests
+// can be read again by every run.
+type remoteManifests struct {
+	client *http.Client
+	// requireChecksum makes it an error to use a URL
+	// without a pinned checksum.
+	requireChecksum bool
+	dir             string
+	// gitCacheDir is where the git repositories are cached. It
+	// is kept when the downloaded manifests are cleaned up.
+	gitCacheDir string
+	// variables are the values of the variables substituted in the
+	// manifests. It is nil if no variables are substituted.
+	variables map[string]string
+	// normalized is the number of manifests that were normalized,
+	// used to give every normalized manifest a unique file name.
+	normalized int
+	// mu guards dir.
+	mu sync.Mutex
+}
+
+func newRemoteManifests(requireChecksum bool) *remoteManifests {
+	return &remoteManifests{
+		client:          &http.Client{Timeout: remoteTimeout},
+		requireChecksum: requireChecksum,
+		gitCacheDir:     defaultGitCacheDir(),
+	}
+}
+
+// resolvePaths returns the FileNameFlags for the paths and the -f and -k
+// flags, like processPaths, after rendering the Helm chart, downloading
+// the manifests given as URLs, git URLs or OCI references and building
+// the kustomization, which can also be in a git repository, and
+// normalizing the manifests the builder can't read as is. The chart
+// and the kustomization are read like any other manifest, so the
+// Kustomize field is always left empty.
+func (r *remoteManifests) resolvePaths(paths []string, flags *genericclioptions.FileNameFlags,
+	helm HelmOptions) (genericclioptions.FileNameFlags, error) {
+	if helm.Chart != "" {
+		path, err := r.renderChart(helm, !hasManifests(paths, flags))
+		if err != nil {
+			r.cleanup()
+			return genericclioptions.FileNameFlags{}, err
+		}
+		flags = withFilename(flags, path)
+	}
+	fileNameFlags := processPaths(paths, flags)
+	resolved, err := r.resolve(*fileNameFlags.Filenames)
+	if err != nil {
+		r.cleanup()
+		return fileNameFlags, err
+	}
+	if fileNameFlags.Kustomize != nil {
+		dir := *fileNameFlags.Kustomize
+		if isGitURL(dir) {
+			dir, err = r.checkout(dir)
+			if err != nil {
+				r.cleanup()
+				return fileNameFlags, err
+			}
+		}
+		path, err := r.kustomize(dir, len(resolved) == 0)
+		if err != nil {
+			r.cleanup()
+			return fileNameFlags, err
+		}
+		resolved = append(resolved, path)
+		fileNameFlags.Kustomize = nil
+	}
+	recursive := fileNameFlags.Recursive != nil && *fileNameFlags.Recursive
+	if resolved, err = r.normalize(resolved, recursive); err != nil {
+		r.cleanup()
+		return fileNameFlags, err
+	}
+	fileNameFlags.Filenames = &resolved
+	return fileNameFlags, nil
+}
+
+// hasManifests returns true if any manifests are given as
+// arguments or with the -f and -k flags.
+func hasManifests(paths []string, flags *genericclioptions.FileNameFlags) bool {
+	if len(paths) > 0 {
+		return true
+	}
+	if flags == nil {
+		return false
+	}
+	return (flags.Filenames != nil && len(*flags.Filenames) > 0) ||
+		(flags.Kustomize != nil && *flags.Kustomize != "")
+}
+
+// withFilename returns a copy of the flags with the
+// filename added to the -f flags.
+func withFilename(flags *genericclioptions.FileNameFlags, filename string) *genericclioptions.FileNameFlags {
+	var fileNameFlags genericclioptions.FileNameFlags
+	var filenames []string
+	if flags != nil {
+		fileNameFlags = *flags
+		if flags.Filenames != nil {
+			filenames = append(filenames, *flags.Filenames...)
+		}
+	}
+	filenames = append(filenames, filename)
+	fileNameFlags.Filenames = &filenames
+	return &fileNameFlags
+}
+
+// isURL returns true if the filename is an http(s) URL.
+func isURL(filename string) bool {
+	return strings.HasPrefix(filename, "https://") || strings.HasPrefix(filename, "http://")
+}
+
+// resolve returns the filenames with every URL replaced by the path of
+// the downloaded manifest, every git URL replaced by the path of the
+// checked out directory, every OCI reference replaced by the paths of
+// the manifests in the package, and every pattern replaced by the
+// matching files. Other filenames are returned unchanged.
+func (r *remoteManifests) resolve(filenames []string) ([]string, error) {
+	var resolved []string
+	for i, filename := range filenames {
+		if isGitURL(filename) {
+			path, err := r.checkout(filename)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, path)
+			continue
+		}
+		if isOCI(filename) {
+			paths, err := r.pull(filename, i)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, paths...)
+			continue
+		}
+		if filename == "-" {
+			path, err := r.readStdin(i)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, path)
+			continue
+		}
+		if !isURL(filename) {
+			paths := []string{filename}
+			if isGlob(filename) {
+				var err error
+				paths, err = expandGlob(filename)
+				if err != nil {
+					return nil, err
+				}
+			}
+			resolved = append(resolved, paths...)
+			continue
+		}
+		path, err := r.fetch(filename, i)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved, nil
+}
+
+// fetch downloads the manifest and verifies its checksum if pinned. The
+// index is used to give every downloaded manifest a unique file name.
+func (r *remoteManifests) fetch(rawURL string, index int) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest URL %q: %v", rawURL, err)
+	}
+	var checksum string
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, checksumFragmentPrefix) {
+			return "", fmt.Errorf("invalid checksum %q for %s, must be %s<hex>", u.Fragment,
+				rawURL, checksumFragmentPrefix)
+		}
+		checksum = strings.ToLower(strings.TrimPrefix(u.Fragment, checksumFragmentPrefix))
+		u.Fragment = ""
+	}
+	if checksum == "" && r.requireChecksum {
+		return "", fmt.Errorf("no checksum for %s, add #%s<hex> to the URL", rawURL, checksumFragmentPrefix)
+	}
+
+	resp, err := r.client.Get(u.String())
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %v", u.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %s: %s", u.String(), resp.Status)
+	}
+	// The manifest is written to the file as it is downloaded, so
+	// it is never all in memory. The checksum is computed on the way.
+	f, err := r.create(fmt.Sprintf("manifest-%d.yaml", index))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("error downloading %s: %v", u.String(), err)
+	}
+	if checksum != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+			return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", u.String(), checksum, actual)
+		}
+	}
+	return f.Name(), f.Close()
+}
+
+// write stores the content in a file with the given name in the
+// temporary directory, creating the directory if needed, and returns
+// the path of the file. The extension tells the builder how to decode
+// the content. JSON is a subset of YAML, so .yaml works for both.
+func (r *remoteManifests) write(name string, content []byte) (string, error) {
+	f, err := r.create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// create creates a file with the given name in the temporary
+// directory, creating the directory if needed, for content that
+// is written as it is read.
+func (r *remoteManifests) create(name string) (*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dir == "" {
+		dir, err := ioutil.TempDir("", "remote-manifests")
+		if err != nil {
+			return nil, err
+		}
+		r.dir = dir
+	}
+	return os.OpenFile(filepath.Join(r.dir, name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+// cleanup removes the downloaded manifests. It is safe to call
+// on a nil remoteManifests.
+func (r *remoteManifests) cleanup() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeDir()
+}
+
+// contains returns true if the path is in the directory of the
+// manifests. It is safe to call on a nil remoteManifests.
+func (r *remoteManifests) contains(path string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dir != "" && filepath.Dir(path) == r.dir
+}
+
+func (r *remoteManifests) removeDir() {
+	if r.dir != "" {
+		_ = os.RemoveAll(r.dir)
+		r.dir = ""
+	}
+}
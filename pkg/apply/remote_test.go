@@ -0,0 +1,90 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const remoteManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+`
+
+func TestRemoteManifests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/release.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(remoteManifest))
+	}))
+	defer server.Close()
+	sum := sha256.Sum256([]byte(remoteManifest))
+	checksum := hex.EncodeToString(sum[:])
+
+	testCases := map[string]struct {
+		filename        string
+		requireChecksum bool
+		expectedErr     bool
+	}{
+		"local files are unchanged": {
+			filename: "deployment.yaml",
+		},
+		"url without checksum": {
+			filename: server.URL + "/release.yaml",
+		},
+		"url with matching checksum": {
+			filename:        server.URL + "/release.yaml#sha256=" + checksum,
+			requireChecksum: true,
+		},
+		"url with wrong checksum": {
+			filename:    server.URL + "/release.yaml#sha256=0123",
+			expectedErr: true,
+		},
+		"checksum required": {
+			filename:        server.URL + "/release.yaml",
+			requireChecksum: true,
+			expectedErr:     true,
+		},
+		"download fails": {
+			filename:    server.URL + "/missing.yaml",
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			remote := newRemoteManifests(tc.requireChecksum)
+			defer remote.cleanup()
+
+			resolved, err := remote.resolve([]string{tc.filename})
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if !isURL(tc.filename) {
+				assert.Equal(t, []string{tc.filename}, resolved)
+				return
+			}
+			content, err := ioutil.ReadFile(resolved[0])
+			assert.NoError(t, err)
+			assert.Equal(t, remoteManifest, string(content))
+
+			remote.cleanup()
+			_, err = os.Stat(resolved[0])
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -8,8 +8,14 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
+// ResourceInfos sorts the resources in the order they are applied: by
+// the priority of the kind, then by group, version and kind, and then
+// by namespace and name. Every resource has its own place in the order,
+// so the plan, the events and the diffs are the same on every run,
+// regardless of the order the manifests were read in.
 type ResourceInfos []*resource.Info
 
 var _ sort.Interface = ResourceInfos{}
@@ -22,52 +28,12 @@ func (a ResourceInfos) Less(i, j int) bool {
 	if !Equals(x, o) {
 		return IsLessThan(x, o)
 	}
-	// In case of tie, compare the namespace and name combination so that the output
+	// In case of tie, compare the namespace and then the name so that the output
 	// order is consistent irrespective of input order
-	return a[i].Namespace+a[i].Name < a[j].Namespace+a[j].Name
-}
-
-// An attempt to order things to help k8s, e.g.
-// a Service should come before things that refer to it.
-// Namespace should be first.
-// In some cases order just specified to provide determinism.
-var orderFirst = []string{
-	"Namespace",
-	"ResourceQuota",
-	"StorageClass",
-	"CustomResourceDefinition",
-	"MutatingWebhookConfiguration",
-	"ServiceAccount",
-	"PodSecurityPolicy",
-	"Role",
-	"ClusterRole",
-	"RoleBinding",
-	"ClusterRoleBinding",
-	"ConfigMap",
-	"Secret",
-	"Service",
-	"LimitRange",
-	"PriorityClass",
-	"Deployment",
-	"StatefulSet",
-	"CronJob",
-	"PodDisruptionBudget",
-}
-
-var orderLast = []string{
-	"ValidatingWebhookConfiguration",
-}
-
-// getIndexByKind returns the index of the kind respecting the order
-func getIndexByKind(kind string) int {
-	m := map[string]int{}
-	for i, n := range orderFirst {
-		m[n] = -len(orderFirst) + i
-	}
-	for i, n := range orderLast {
-		m[n] = 1 + i
+	if a[i].Namespace != a[j].Namespace {
+		return a[i].Namespace < a[j].Namespace
 	}
-	return m[kind]
+	return a[i].Name < a[j].Name
 }
 
 // Equals returns true if the GVK's have equal fields.
@@ -77,8 +43,8 @@ func Equals(x schema.GroupVersionKind, o schema.GroupVersionKind) bool {
 
 // IsLessThan compares two GVK's as per orderFirst and orderLast, returns boolean result.
 func IsLessThan(x schema.GroupVersionKind, o schema.GroupVersionKind) bool {
-	indexI := getIndexByKind(x.Kind)
-	indexJ := getIndexByKind(o.Kind)
+	indexI := object.KindIndex(x.Kind)
+	indexJ := object.KindIndex(o.Kind)
 	if indexI != indexJ {
 		return indexI < indexJ
 	}
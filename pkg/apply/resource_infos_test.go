@@ -122,3 +122,39 @@ func TestGvkEquals(t *testing.T) {
 
 	assert.Equal(t, Equals(gvk1, gvk2), true)
 }
+
+func TestResourceOrderingIsDeterministic(t *testing.T) {
+	newInfo := func(kind, namespace, name string) *resource.Info {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return &resource.Info{Namespace: namespace, Name: name, Object: obj}
+	}
+	expected := []string{
+		"Namespace//ab",
+		"ConfigMap/a/bc",
+		"ConfigMap/ab/c",
+		"ConfigMap/b/a",
+		"Pod/a/a",
+	}
+	// Every rotation of the input must give the same order.
+	for i := range expected {
+		infos := []*resource.Info{
+			newInfo("ConfigMap", "ab", "c"),
+			newInfo("Pod", "a", "a"),
+			newInfo("ConfigMap", "a", "bc"),
+			newInfo("Namespace", "", "ab"),
+			newInfo("ConfigMap", "b", "a"),
+		}
+		infos = append(infos[i:], infos[:i]...)
+		sort.Stable(ResourceInfos(infos))
+		var actual []string
+		for _, info := range infos {
+			actual = append(actual, info.Object.GetObjectKind().GroupVersionKind().Kind+"/"+
+				info.Namespace+"/"+info.Name)
+		}
+		assert.DeepEqual(t, expected, actual)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/kubectl/pkg/cmd/util"
+)
+
+// NewFactoryForConfig returns a factory whose clients are all created
+// from the config, instead of from a kubeconfig on disk. Everything set
+// in the config is kept, like a proxy, an exec credential plugin, or a
+// custom transport, so the Applier and the Destroyer work with any
+// authentication the caller has set up. The resources without a
+// namespace are applied to the given namespace, or to the default
+// namespace if it is empty.
+func NewFactoryForConfig(config *rest.Config, namespace string) util.Factory {
+	return util.NewFactory(newConfigGetter(config, namespace))
+}
+
+// NewApplierForConfig returns an Applier configured with the options,
+// that talks to the cluster with clients created from the config.
+func NewApplierForConfig(config *rest.Config, namespace string, ioStreams genericclioptions.IOStreams,
+	opts ...ApplierOption) (*Applier, error) {
+	return NewApplierWithOptions(NewFactoryForConfig(config, namespace), ioStreams, opts...)
+}
+
+// configGetter is a RESTClientGetter for a rest.Config.
+type configGetter struct {
+	config    *rest.Config
+	namespace string
+
+	// The discovery information is read once, and shared by all the
+	// mappers, like it is by the getter for a kubeconfig.
+	once      sync.Once
+	discovery discovery.CachedDiscoveryInterface
+	err       error
+}
+
+func newConfigGetter(config *rest.Config, namespace string) *configGetter {
+	return &configGetter{
+		config:    rest.CopyConfig(config),
+		namespace: namespace,
+	}
+}
+
+// ToRESTConfig returns a copy of the config, so the
+// clients created from it can't change it.
+func (g *configGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.config), nil
+}
+
+func (g *configGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	g.once.Do(func() {
+		client, err := discovery.NewDiscoveryClientForConfig(rest.CopyConfig(g.config))
+		if err != nil {
+			g.err = err
+			return
+		}
+		g.discovery = memory.NewMemCacheClient(client)
+	})
+	return g.discovery, g.err
+}
+
+func (g *configGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	client, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(client)
+	return restmapper.NewShortcutExpander(mapper, client), nil
+}
+
+func (g *configGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &configLoader{getter: g}
+}
+
+// configLoader is the ClientConfig of a configGetter. It only
+// provides the config and the namespace, since there is no kubeconfig.
+type configLoader struct {
+	getter *configGetter
+}
+
+// RawConfig returns an empty kubeconfig.
+func (l *configLoader) RawConfig() (clientcmdapi.Config, error) {
+	return *clientcmdapi.NewConfig(), nil
+}
+
+func (l *configLoader) ClientConfig() (*rest.Config, error) {
+	return l.getter.ToRESTConfig()
+}
+
+// Namespace returns the namespace of the getter. It is only the
+// default, so manifests can still set other namespaces.
+func (l *configLoader) Namespace() (string, bool, error) {
+	if l.getter.namespace == "" {
+		return "default", false, nil
+	}
+	return l.getter.namespace, false, nil
+}
+
+// ConfigAccess returns loading rules without any files,
+// since the config doesn't come from a kubeconfig.
+func (l *configLoader) ConfigAccess() clientcmd.ConfigAccess {
+	return &clientcmd.ClientConfigLoadingRules{}
+}
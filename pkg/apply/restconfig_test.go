@@ -0,0 +1,95 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewApplierForConfig(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	config := cluster.RESTConfig()
+	// The transport set up by the caller is used for every request.
+	var requests int32
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&requests, 1)
+			return rt.RoundTrip(req)
+		})
+	}
+
+	applier, err := NewApplierForConfig(config, "apps", genericclioptions.NewTestIOStreamsDiscard(), WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	other := configMap("other", nil)
+	other.SetNamespace("other")
+	for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}), configMap("cm", nil), other,
+	}) {
+		require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	// The resources without a namespace are applied to the namespace,
+	// and the others keep their own.
+	assert.NotNil(t, cluster.Get(gvk, "apps", "cm"))
+	assert.NotNil(t, cluster.Get(gvk, "other", "other"))
+	assert.NotZero(t, atomic.LoadInt32(&requests))
+}
+
+func TestConfigGetter(t *testing.T) {
+	config := &rest.Config{
+		Host: "https://example.com",
+		ExecProvider: &clientcmdapi.ExecConfig{
+			Command:    "credential-helper",
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+		},
+	}
+	getter := newConfigGetter(config, "")
+	config.Host = "https://changed.example.com"
+
+	restConfig, err := getter.ToRESTConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", restConfig.Host)
+	assert.Equal(t, "credential-helper", restConfig.ExecProvider.Command)
+	// The copies can't change the config of the getter.
+	restConfig.Host = "https://other.example.com"
+	restConfig, err = getter.ToRawKubeConfigLoader().ClientConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", restConfig.Host)
+
+	namespace, overridden, err := getter.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, "default", namespace)
+	assert.False(t, overridden)
+}
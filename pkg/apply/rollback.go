@@ -0,0 +1,149 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// NewRollbacker returns a new Rollbacker. The rollback is recorded as
+// a new revision, keeping up to DefaultHistoryLimit revisions.
+func NewRollbacker(factory util.Factory, ioStreams genericclioptions.IOStreams) *Rollbacker {
+	a := NewApplier(factory, ioStreams)
+	a.HistoryLimit = DefaultHistoryLimit
+	return &Rollbacker{
+		Applier: a,
+	}
+}
+
+// Rollbacker applies a previous revision of a package again, from the
+// history recorded by the Applier when its HistoryLimit is set. The
+// resources that were added since that revision are pruned, like for
+// any other apply.
+type Rollbacker struct {
+	// Applier applies the revision. The package passed to Initialize
+	// is only read to find the inventory whose history is used.
+	Applier *Applier
+	// Revision is the number of the revision that is applied. If it is
+	// zero, the revision before the last one is applied.
+	Revision int
+
+	history *history
+}
+
+// Initialize sets up the Rollbacker for the package given by
+// the paths and the flags.
+func (r *Rollbacker) Initialize(cmd *cobra.Command, paths []string) error {
+	return r.Applier.Initialize(cmd, paths)
+}
+
+// SetFlags configures the command line flags needed by the Rollbacker.
+func (r *Rollbacker) SetFlags(cmd *cobra.Command) error {
+	cmd.Flags().IntVar(&r.Revision, "to-revision", r.Revision,
+		"The revision to roll back to. If 0, the revision before the last one is used.")
+	return r.Applier.SetFlags(cmd)
+}
+
+// Revisions returns the recorded revisions of the package, oldest first.
+func (r *Rollbacker) Revisions() ([]Revision, error) {
+	h, err := r.readHistory()
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := h.revisions()
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error reading history", 1)
+	}
+	return revisions, nil
+}
+
+// Run applies the revision, and prunes the resources that are not in
+// it. The events are the same as the ones of a run of the Applier.
+func (r *Rollbacker) Run(ctx context.Context) <-chan event.Event {
+	revisions, err := r.Revisions()
+	if err != nil {
+		return errorEvent(err)
+	}
+	target, err := rollbackTarget(revisions, r.Revision)
+	if err != nil {
+		return errorEvent(err)
+	}
+	r.Applier.logger().Info("rolling back", "revision", target.Number)
+	return r.Applier.RunObjects(ctx, target.Objects)
+}
+
+// rollbackTarget returns the revision with the given number, or the
+// revision before the last one if the number is zero.
+func rollbackTarget(revisions []Revision, number int) (Revision, error) {
+	if number == 0 {
+		if len(revisions) < 2 {
+			return Revision{}, withExitCode(errors.New("no previous revision to roll back to"), ExitValidationError)
+		}
+		return revisions[len(revisions)-2], nil
+	}
+	for _, rev := range revisions {
+		if rev.Number == number {
+			return rev, nil
+		}
+	}
+	return Revision{}, withExitCode(fmt.Errorf("revision %d not found", number), ExitValidationError)
+}
+
+// readHistory reads the package to find its inventory, and returns the
+// history of the inventory. The package is only read once, and the
+// history is kept for the following calls.
+func (r *Rollbacker) readHistory() (*history, error) {
+	if r.history != nil {
+		return r.history, nil
+	}
+	a := r.Applier.newRun()
+
+	infos, err := a.ApplyOptions.GetObjects()
+	if err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error reading resources", 1), ExitValidationError)
+	}
+	if err := prune.DetectGroupingObject(infos); err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error finding grouping object", 1), ExitValidationError)
+	}
+	if a.InventoryID != "" {
+		if err := prune.SetInventoryID(infos, a.InventoryID); err != nil {
+			return nil, withExitCode(errors.WrapPrefix(err, "error setting inventory id", 1), ExitValidationError)
+		}
+	}
+	if a.TargetNamespace != "" {
+		if err := setNamespace(infos, a.TargetNamespace); err != nil {
+			return nil, withExitCode(errors.WrapPrefix(err, "error setting namespace", 1), ExitValidationError)
+		}
+	}
+	groupingInfo, found := prune.FindGroupingObject(infos)
+	if !found {
+		return nil, withExitCode(ErrInventoryNotFound, ExitValidationError)
+	}
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return nil, errors.WrapPrefix(err, "error creating client", 1)
+	}
+	h, err := newHistory(client, groupingInfo)
+	if err != nil {
+		return nil, withExitCode(errors.WrapPrefix(err, "error reading history", 1), ExitValidationError)
+	}
+	r.history = h
+	return h, nil
+}
+
+// errorEvent returns a closed channel with a single error event.
+func errorEvent(err error) <-chan event.Event {
+	ch := make(chan event.Event, 1)
+	sendErrorEvent(ch, err)
+	close(ch)
+	return ch
+}
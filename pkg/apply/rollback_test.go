@@ -0,0 +1,152 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+const rollbackManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    %s: test
+`
+
+func TestRollbacker(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	applier.HistoryLimit = DefaultHistoryLimit
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	withData := func(obj *unstructured.Unstructured, value string) *unstructured.Unstructured {
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+	for _, objs := range [][]*unstructured.Unstructured{
+		{inventory.DeepCopy(), withData(configMap("a", nil), "1")},
+		{inventory.DeepCopy(), withData(configMap("a", nil), "2"), configMap("b", nil)},
+	} {
+		for e := range applier.RunObjects(context.Background(), objs) {
+			require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "rollback-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "package.yaml"),
+		[]byte(fmt.Sprintf(rollbackManifests, prune.GroupingLabel)), 0600))
+
+	applier, err = NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	applier.HistoryLimit = DefaultHistoryLimit
+	rollbacker := &Rollbacker{Applier: applier}
+	cmd = &cobra.Command{}
+	require.NoError(t, rollbacker.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	require.NoError(t, rollbacker.Initialize(cmd, nil))
+
+	revisions, err := rollbacker.Revisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	plan, err := rollbacker.UndoPlan()
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.Revision)
+	assert.Equal(t, 1, plan.Target)
+	assert.Len(t, plan.Restore, 1)
+	assert.Len(t, plan.Delete, 1)
+
+	for e := range rollbacker.Run(context.Background()) {
+		require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+	}
+
+	// The first revision is applied again, and the resource
+	// added by the second one is pruned.
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	a := cluster.Get(gvk, fakecluster.DefaultNamespace, "a")
+	require.NotNil(t, a)
+	assert.Equal(t, "1", a.Object["data"].(map[string]interface{})["key"])
+	assert.Nil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "b"))
+
+	// The rollback is recorded as a new revision.
+	rollbacker.history = nil
+	revisions, err = rollbacker.Revisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, 3, revisions[2].Number)
+	assert.Len(t, revisions[2].Objects, 2)
+}
+
+func TestRollbackTarget(t *testing.T) {
+	revisions := []Revision{{Number: 3}, {Number: 4}, {Number: 5}}
+	testCases := map[string]struct {
+		revisions []Revision
+		number    int
+		expected  int
+		isError   bool
+	}{
+		"previous revision": {
+			revisions: revisions,
+			expected:  4,
+		},
+		"given revision": {
+			revisions: revisions,
+			number:    3,
+			expected:  3,
+		},
+		"revision not found": {
+			revisions: revisions,
+			number:    2,
+			isError:   true,
+		},
+		"no previous revision": {
+			revisions: revisions[:1],
+			isError:   true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			target, err := rollbackTarget(tc.revisions, tc.number)
+			if tc.isError {
+				assert.Error(t, err)
+				assert.Equal(t, ExitValidationError, ExitCode(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, target.Number)
+		})
+	}
+}
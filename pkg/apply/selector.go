@@ -0,0 +1,34 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// filterBySelector splits the infos into the ones whose labels match
+// the selector and the ones that don't. The grouping object always
+// matches, since it is needed to keep track of the inventory.
+func filterBySelector(infos []*resource.Info, selector labels.Selector) (matched, filtered []*resource.Info,
+	err error) {
+	for _, info := range infos {
+		if prune.IsGroupingObject(info.Object) {
+			matched = append(matched, info)
+			continue
+		}
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return nil, nil, err
+		}
+		if selector.Matches(labels.Set(accessor.GetLabels())) {
+			matched = append(matched, info)
+		} else {
+			filtered = append(filtered, info)
+		}
+	}
+	return matched, filtered, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+func labeledInfo(kind, name string, l map[string]string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	obj.SetLabels(l)
+	return &resource.Info{
+		Name:      name,
+		Namespace: "default",
+		Object:    obj,
+	}
+}
+
+func TestFilterBySelector(t *testing.T) {
+	grouping := labeledInfo("ConfigMap", "inventory", map[string]string{prune.GroupingLabel: "test"})
+	frontend := labeledInfo("ConfigMap", "frontend", map[string]string{"tier": "frontend"})
+	backend := labeledInfo("ConfigMap", "backend", map[string]string{"tier": "backend"})
+	unlabeled := labeledInfo("ConfigMap", "unlabeled", nil)
+	infos := []*resource.Info{grouping, frontend, backend, unlabeled}
+
+	testCases := map[string]struct {
+		selector         string
+		expectedMatched  []*resource.Info
+		expectedFiltered []*resource.Info
+	}{
+		"equality": {
+			selector:         "tier=frontend",
+			expectedMatched:  []*resource.Info{grouping, frontend},
+			expectedFiltered: []*resource.Info{backend, unlabeled},
+		},
+		"inequality": {
+			selector:         "tier!=frontend",
+			expectedMatched:  []*resource.Info{grouping, backend, unlabeled},
+			expectedFiltered: []*resource.Info{frontend},
+		},
+		"no match keeps the grouping object": {
+			selector:         "tier=database",
+			expectedMatched:  []*resource.Info{grouping},
+			expectedFiltered: []*resource.Info{frontend, backend, unlabeled},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			selector, err := labels.Parse(tc.selector)
+			if !assert.NoError(t, err) {
+				return
+			}
+			matched, filtered, err := filterBySelector(infos, selector)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expectedMatched, matched)
+			assert.Equal(t, tc.expectedFiltered, filtered)
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package apply
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -11,20 +12,59 @@ import (
 
 func NewStatusOptions() *StatusOptions {
 	return &StatusOptions{
-		wait:    false,
+		Wait:    false,
 		period:  2 * time.Second,
 		Timeout: time.Minute,
 	}
 }
 
 type StatusOptions struct {
-	wait    bool
+	// Wait enables waiting for all applied resources to reach
+	// the Current status after they have been applied.
+	Wait bool
+	// NoWait makes the Applier return as soon as the resources
+	// have been applied, and records in the inventory that their
+	// reconcile status is unknown. It can't be combined with Wait.
+	NoWait  bool
 	period  time.Duration
 	Timeout time.Duration
+
+	// reconcileTimeout is set from the --reconcile-timeout flag. It
+	// enables the wait and overrides the Timeout.
+	reconcileTimeout time.Duration
 }
 
 func (s *StatusOptions) AddFlags(c *cobra.Command) {
-	c.Flags().BoolVar(&s.wait, "wait-for-reconcile", s.wait, "Wait for all applied resources to reach the Current status.")
+	c.Flags().BoolVar(&s.Wait, "wait", s.Wait, "Wait for all applied resources to reach the Current status.")
+	c.Flags().BoolVar(&s.NoWait, "no-wait", s.NoWait, "Return as soon as the resources have been applied, "+
+		"without waiting for them to reconcile. The inventory records that their status is unknown.")
+	c.Flags().BoolVar(&s.Wait, "wait-for-reconcile", s.Wait, "Wait for all applied resources to reach the Current status.")
+	_ = c.Flags().MarkDeprecated("wait-for-reconcile", "use --wait instead")
+	c.Flags().DurationVar(&s.period, "status-poll-interval", s.period,
+		"How often the status of the resources is polled. A longer interval reduces the load on the API server.")
 	c.Flags().DurationVar(&s.period, "wait-polling-period", s.period, "Polling period for resource statuses.")
+	_ = c.Flags().MarkDeprecated("wait-polling-period", "use --status-poll-interval instead")
 	c.Flags().DurationVar(&s.Timeout, "wait-timeout", s.Timeout, "Timeout threshold for waiting for all resources to reach the Current status.")
+	_ = c.Flags().MarkDeprecated("wait-timeout", "use --reconcile-timeout instead")
+	c.Flags().DurationVar(&s.reconcileTimeout, "reconcile-timeout", s.reconcileTimeout,
+		"Wait up to the given duration for all applied resources to reach the Current status. If they don't, "+
+			"the resources that are not reconciled are reported and the command fails. A value of 0 means "+
+			"the wait is controlled by --wait.")
+}
+
+// complete applies the --reconcile-timeout flag, and verifies that
+// the poll interval is valid and that the wait is not both enabled
+// and disabled.
+func (s *StatusOptions) complete() error {
+	if s.reconcileTimeout > 0 {
+		s.Wait = true
+		s.Timeout = s.reconcileTimeout
+	}
+	if s.period <= 0 {
+		return fmt.Errorf("the status poll interval must be greater than 0")
+	}
+	if s.NoWait && s.Wait {
+		return fmt.Errorf("--no-wait can not be combined with --wait or --reconcile-timeout")
+	}
+	return nil
 }
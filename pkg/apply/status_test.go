@@ -0,0 +1,80 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusOptionsReconcileTimeout(t *testing.T) {
+	testCases := map[string]struct {
+		args            []string
+		expectedWait    bool
+		expectedTimeout time.Duration
+		expectedErr     bool
+	}{
+		"defaults": {
+			expectedWait:    false,
+			expectedTimeout: time.Minute,
+		},
+		"wait for reconcile": {
+			args:            []string{"--wait-for-reconcile"},
+			expectedWait:    true,
+			expectedTimeout: time.Minute,
+		},
+		"wait": {
+			args:            []string{"--wait"},
+			expectedWait:    true,
+			expectedTimeout: time.Minute,
+		},
+		"no wait": {
+			args:            []string{"--no-wait"},
+			expectedWait:    false,
+			expectedTimeout: time.Minute,
+		},
+		"status poll interval": {
+			args:            []string{"--status-poll-interval=10s"},
+			expectedWait:    false,
+			expectedTimeout: time.Minute,
+		},
+		"invalid status poll interval": {
+			args:        []string{"--status-poll-interval=0s"},
+			expectedErr: true,
+		},
+		"no wait with wait": {
+			args:        []string{"--no-wait", "--wait"},
+			expectedErr: true,
+		},
+		"no wait with reconcile timeout": {
+			args:        []string{"--no-wait", "--reconcile-timeout=5m"},
+			expectedErr: true,
+		},
+		"reconcile timeout enables the wait": {
+			args:            []string{"--reconcile-timeout=5m"},
+			expectedWait:    true,
+			expectedTimeout: 5 * time.Minute,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			s := NewStatusOptions()
+			s.AddFlags(cmd)
+			assert.NoError(t, cmd.Flags().Parse(tc.args))
+			err := s.complete()
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedWait, s.Wait)
+			assert.Equal(t, tc.expectedTimeout, s.Timeout)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// variableRegexp matches the references to variables in the manifests,
+// ${NAME}, and the escaped form $${NAME}, which is replaced by ${NAME}.
+var variableRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// SubstitutionOptions contains the values of the variables that are
+// substituted in the manifests when they are read, so one package can be
+// applied to several environments. References to variables are written
+// as ${NAME}, and $${NAME} is replaced by the literal ${NAME}. Nothing
+// is substituted if no values are given and FromEnv is false.
+type SubstitutionOptions struct {
+	// Values are the values of the variables. They take precedence
+	// over the values from the files and the environment.
+	Values map[string]string
+	// ValuesFiles are YAML files with a map of variable names to
+	// values. Values in later files override the ones in earlier files.
+	ValuesFiles []string
+	// FromEnv makes the environment variables available for
+	// substitution, with the lowest precedence.
+	FromEnv bool
+}
+
+// addSubstitutionFlags adds the flags for substituting variables.
+func addSubstitutionFlags(cmd *cobra.Command, o *SubstitutionOptions) {
+	cmd.Flags().StringToStringVar(&o.Values, "var", o.Values,
+		"Value of a variable referenced as ${NAME} in the manifests, in the format NAME=VALUE. Can be repeated.")
+	cmd.Flags().StringSliceVar(&o.ValuesFiles, "var-file", o.ValuesFiles,
+		"YAML file with the values of the variables referenced in the manifests. Can be repeated, later "+
+			"files take precedence. Values given with --var take precedence over the files.")
+	cmd.Flags().BoolVar(&o.FromEnv, "var-from-env", o.FromEnv,
+		"If true, the environment variables can be referenced in the manifests. The values given with "+
+			"--var and --var-file take precedence.")
+}
+
+// enabled returns true if variables are substituted.
+func (o SubstitutionOptions) enabled() bool {
+	return len(o.Values) > 0 || len(o.ValuesFiles) > 0 || o.FromEnv
+}
+
+// variables returns the values of all the variables, applying
+// the precedence of the sources.
+func (o SubstitutionOptions) variables() (map[string]string, error) {
+	vars := make(map[string]string)
+	if o.FromEnv {
+		for _, kv := range os.Environ() {
+			if i := strings.Index(kv, "="); i > 0 {
+				vars[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	for _, file := range o.ValuesFiles {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading variables %s: %v", file, err)
+		}
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return nil, fmt.Errorf("error reading variables %s: %v", file, err)
+		}
+		for name, value := range values {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("error reading variables %s: the value of %s is not a scalar", file, name)
+			case nil:
+				vars[name] = ""
+			default:
+				vars[name] = fmt.Sprint(value)
+			}
+		}
+	}
+	for name, value := range o.Values {
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// substitute copies the manifests from the reader to the writer, one
+// line at a time, with every reference to a variable replaced by its
+// value. It is an error if a referenced variable has no value, since
+// applying the reference would rarely be what was meant. All undefined
+// variables are listed in the error.
+func substitute(reader io.Reader, w io.Writer, vars map[string]string) error {
+	undefined := make(map[string]bool)
+	br := bufio.NewReader(reader)
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		line = variableRegexp.ReplaceAllStringFunc(line, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name := ref[len("${") : len(ref)-1]
+			value, found := vars[name]
+			if !found {
+				undefined[name] = true
+			}
+			return value
+		})
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(undefined) > 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no value for the variables %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// substituteFile writes a copy of the manifests in the file with the
+// variables substituted to the temporary directory, and returns its path.
+func (r *remoteManifests) substituteFile(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	r.normalized++
+	out, err := r.create(fmt.Sprintf("substituted-%d-%s", r.normalized, filepath.Base(path)))
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	if err := substitute(in, w, r.variables); err != nil {
+		return "", fmt.Errorf("error substituting variables in %s: %v", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return out.Name(), out.Close()
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{
+		"ENV":      "prod",
+		"REPLICAS": "3",
+		"EMPTY":    "",
+	}
+	testCases := map[string]struct {
+		content     string
+		expected    string
+		expectedErr string
+	}{
+		"no references": {
+			content:  "name: app\n",
+			expected: "name: app\n",
+		},
+		"references": {
+			content:  "name: app-${ENV}\nreplicas: ${REPLICAS}\nvalue: \"${EMPTY}\"",
+			expected: "name: app-prod\nreplicas: 3\nvalue: \"\"",
+		},
+		"escaped reference": {
+			content:  "script: echo $${HOME} ${ENV}\n",
+			expected: "script: echo ${HOME} prod\n",
+		},
+		"not a reference": {
+			content:  "a: $ENV\nb: ${1}\nc: ${ENV\n",
+			expected: "a: $ENV\nb: ${1}\nc: ${ENV\n",
+		},
+		"undefined variables": {
+			content:     "a: ${ZONE}\nb: ${ENV}\nc: ${REGION} ${ZONE}\n",
+			expectedErr: "no value for the variables REGION, ZONE",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := substitute(strings.NewReader(tc.content), &buf, vars)
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.expectedErr, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestSubstitutionVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "substitute-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.yaml":      "ENV: staging\nREPLICAS: 2\nZONE: a\n",
+		"b.yaml":      "ZONE: b\nDEBUG: false\nNOTHING:\n",
+		"nested.yaml": "MAP:\n  key: value\n",
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)) {
+			return
+		}
+	}
+	os.Setenv("SUBSTITUTE_TEST_ENV", "from-env")
+	os.Setenv("ZONE", "env-zone")
+	defer os.Unsetenv("SUBSTITUTE_TEST_ENV")
+	defer os.Unsetenv("ZONE")
+
+	vars, err := SubstitutionOptions{
+		Values:      map[string]string{"ENV": "prod"},
+		ValuesFiles: []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")},
+		FromEnv:     true,
+	}.variables()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "prod", vars["ENV"])
+	assert.Equal(t, "2", vars["REPLICAS"])
+	assert.Equal(t, "b", vars["ZONE"])
+	assert.Equal(t, "false", vars["DEBUG"])
+	assert.Equal(t, "", vars["NOTHING"])
+	assert.Equal(t, "from-env", vars["SUBSTITUTE_TEST_ENV"])
+
+	_, err = SubstitutionOptions{
+		ValuesFiles: []string{filepath.Join(dir, "nested.yaml")},
+	}.variables()
+	assert.Error(t, err)
+}
+
+func TestResolvePathsSubstitutesVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "substitute-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a-${ENV}\n",
+		"b.json": `[{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "b-${ENV}"}}]`,
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)) {
+			return
+		}
+	}
+
+	r := newRemoteManifests(false)
+	defer r.cleanup()
+	r.variables = map[string]string{"ENV": "prod"}
+	filenames := []string{dir}
+	fileNameFlags, err := r.resolvePaths(nil, &genericclioptions.FileNameFlags{
+		Filenames: &filenames,
+	}, HelmOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	resolved := *fileNameFlags.Filenames
+	if !assert.Len(t, resolved, 2) {
+		return
+	}
+	var content []string
+	for _, path := range resolved {
+		data, err := ioutil.ReadFile(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		content = append(content, string(data))
+	}
+	assert.Contains(t, content[0], "name: a-prod")
+	assert.Contains(t, content[1], "name: b-prod")
+
+	r.variables = map[string]string{}
+	filenames = []string{dir}
+	_, err = r.resolvePaths(nil, &genericclioptions.FileNameFlags{
+		Filenames: &filenames,
+	}, HelmOptions{})
+	assert.Error(t, err)
+}
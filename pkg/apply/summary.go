@@ -0,0 +1,194 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"sort"
+	"time"
+
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ResourceStatus is the last known status of a resource.
+type ResourceStatus struct {
+	Identifier object.ObjMetadata
+	Status     status.Status
+	Message    string
+}
+
+// Summary contains the outcome of a run of the Applier or Destroyer.
+// It is built from the events, so callers that consume the event
+// channel can pass every event to Update and get the counts without
+// having to tally the events themselves.
+type Summary struct {
+	// The number of resources for each apply operation.
+	Created           int
+	Configured        int
+	Unchanged         int
+	ServersideApplied int
+	// Filtered is the number of resources that were not applied,
+	// because they didn't match the label selector.
+	Filtered int
+	// Skipped is the number of resources that were left alone,
+	// for example because a policy didn't allow them to be pruned.
+	Skipped int
+	Pruned  int
+	Deleted int
+
+	// Reconciled is the number of resources that reached the
+	// Current status, or were deleted, while waiting.
+	Reconciled int
+	// Failed contains the resources that reached the Failed status.
+	Failed []ResourceStatus
+	// TimedOut contains the resources that had not reconciled
+	// when the wait timed out.
+	TimedOut []ResourceStatus
+	// Errors contains the errors reported through error events.
+	Errors []error
+	// WaitErrors contains the errors encountered while waiting
+	// for the resources to reconcile.
+	WaitErrors []error
+
+	// The durations of the phases. They are zero if the
+	// phase didn't complete.
+	ApplyDuration  time.Duration
+	WaitDuration   time.Duration
+	PruneDuration  time.Duration
+	DeleteDuration time.Duration
+
+	// ApplyCompleted and WaitCompleted are true if the
+	// events for the phases have been seen.
+	ApplyCompleted bool
+	WaitCompleted  bool
+	// WaitTimedOut is true if the wait was aborted before
+	// all resources were reconciled.
+	WaitTimedOut bool
+
+	statuses map[string]ResourceStatus
+}
+
+// NewSummary returns an empty Summary.
+func NewSummary() *Summary {
+	return &Summary{
+		statuses: make(map[string]ResourceStatus),
+	}
+}
+
+// Summarize reads all events from the channel and returns the Summary.
+// It blocks until the channel is closed.
+func Summarize(ch <-chan event.Event) *Summary {
+	s := NewSummary()
+	for e := range ch {
+		s.Update(e)
+	}
+	return s
+}
+
+// Applied returns the total number of resources that have been applied.
+func (s *Summary) Applied() int {
+	return s.Created + s.Configured + s.Unchanged + s.ServersideApplied
+}
+
+// Update adds the information from the event to the summary.
+func (s *Summary) Update(e event.Event) {
+	switch e.Type {
+	case event.ErrorType:
+		s.Errors = append(s.Errors, e.ErrorEvent.Err)
+	case event.ApplyType:
+		if e.ApplyEvent.Type == event.ApplyEventCompleted {
+			s.ApplyCompleted = true
+			s.ApplyDuration = e.Duration()
+			return
+		}
+		switch e.ApplyEvent.Operation {
+		case event.Created:
+			s.Created++
+		case event.Configured:
+			s.Configured++
+		case event.Unchanged:
+			s.Unchanged++
+		case event.ServersideApplied:
+			s.ServersideApplied++
+		case event.Filtered:
+			s.Filtered++
+		}
+	case event.StatusType:
+		se := e.StatusEvent
+		switch se.EventType {
+		case pollevent.ResourceUpdateEvent:
+			id := se.Resource.Identifier
+			objMeta := object.ObjMetadata{
+				Namespace: id.Namespace,
+				Name:      id.Name,
+				GroupKind: id.GroupKind,
+			}
+			s.statuses[objMeta.String()] = ResourceStatus{
+				Identifier: objMeta,
+				Status:     se.Resource.Status,
+				Message:    se.Resource.Message,
+			}
+		case pollevent.CompletedEvent:
+			s.WaitCompleted = true
+			s.WaitDuration = e.Duration()
+		case pollevent.AbortedEvent:
+			s.WaitCompleted = true
+			s.WaitTimedOut = true
+			s.WaitDuration = e.Duration()
+		case pollevent.ErrorEvent:
+			s.WaitErrors = append(s.WaitErrors, se.Error)
+		}
+		s.updateStatusCounts()
+	case event.PruneType:
+		if e.PruneEvent.Type == event.PruneEventCompleted {
+			s.PruneDuration = e.Duration()
+			return
+		}
+		if e.PruneEvent.Operation == event.PruneSkipped {
+			s.Skipped++
+		} else {
+			s.Pruned++
+		}
+	case event.DeleteType:
+		if e.DeleteEvent.Type == event.DeleteEventCompleted {
+			s.DeleteDuration = e.Duration()
+			return
+		}
+		s.Deleted++
+	}
+}
+
+// updateStatusCounts recomputes the status counts from the
+// latest status of every resource.
+func (s *Summary) updateStatusCounts() {
+	s.Reconciled = 0
+	s.Failed = nil
+	s.TimedOut = nil
+	for _, r := range s.statuses {
+		switch {
+		case r.Status == status.CurrentStatus || r.Status == status.NotFoundStatus:
+			s.Reconciled++
+		case r.Status == status.FailedStatus:
+			s.Failed = append(s.Failed, r)
+		case s.WaitTimedOut:
+			s.TimedOut = append(s.TimedOut, r)
+		}
+	}
+	sortResourceStatuses(s.Failed)
+	sortResourceStatuses(s.TimedOut)
+}
+
+// StatusCount returns the number of resources that have reported
+// a status while waiting.
+func (s *Summary) StatusCount() int {
+	return len(s.statuses)
+}
+
+func sortResourceStatuses(statuses []ResourceStatus) {
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Identifier.String() < statuses[j].Identifier.String()
+	})
+}
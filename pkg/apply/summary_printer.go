@@ -0,0 +1,115 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// SummaryPrinter doesn't print anything for the individual resources.
+// It only prints the final counts once the channel is closed, together
+// with any failures. If Quiet is true, the counts are also left out,
+// so nothing is printed unless something went wrong.
+type SummaryPrinter struct {
+	IOStreams genericclioptions.IOStreams
+	Quiet     bool
+}
+
+// Print blocks until the channel is closed and then prints the summary.
+// If an error event is received, the summary so far is printed before
+// the error is reported.
+func (s *SummaryPrinter) Print(ch <-chan event.Event) {
+	sum := NewSummary()
+	for e := range ch {
+		sum.Update(e)
+		if e.Type == event.ErrorType {
+			s.printSummary(sum)
+			CheckErr(s.IOStreams.ErrOut, e.ErrorEvent.Err)
+		}
+	}
+	s.printSummary(sum)
+}
+
+func (s *SummaryPrinter) printSummary(sum *Summary) {
+	if !s.Quiet {
+		if sum.ApplyCompleted || sum.Applied() > 0 {
+			fmt.Fprintf(s.IOStreams.Out, "%d resource(s) applied%s%s\n", sum.Applied(),
+				operationsToString(sum), durationToString(sum.ApplyDuration))
+		}
+		if sum.Filtered > 0 {
+			fmt.Fprintf(s.IOStreams.Out, "%d resource(s) filtered out by the selector\n", sum.Filtered)
+		}
+		if sum.WaitCompleted || sum.StatusCount() > 0 {
+			var timedOut string
+			if sum.WaitTimedOut {
+				timedOut = " (timed out)"
+			}
+			fmt.Fprintf(s.IOStreams.Out, "%d/%d resource(s) reconciled%s%s\n", sum.Reconciled, sum.StatusCount(),
+				durationToString(sum.WaitDuration), timedOut)
+		}
+		if sum.Pruned > 0 {
+			fmt.Fprintf(s.IOStreams.Out, "%d resource(s) pruned%s\n", sum.Pruned, durationToString(sum.PruneDuration))
+		}
+		if sum.Skipped > 0 {
+			fmt.Fprintf(s.IOStreams.Out, "%d resource(s) skipped\n", sum.Skipped)
+		}
+		if sum.Deleted > 0 {
+			fmt.Fprintf(s.IOStreams.Out, "%d resource(s) deleted%s\n", sum.Deleted, durationToString(sum.DeleteDuration))
+		}
+	}
+
+	// Failures are always printed. A resource has failed if it reached
+	// the Failed status, or if it was not reconciled before the wait
+	// was aborted.
+	var failures []string
+	for _, r := range append(append([]ResourceStatus{}, sum.Failed...), sum.TimedOut...) {
+		id := resourceIDToString(r.Identifier.GroupKind, r.Identifier.Name)
+		failures = append(failures, fmt.Sprintf("%s is %s: %s", id, r.Status, r.Message))
+	}
+	sort.Strings(failures)
+	for _, f := range failures {
+		fmt.Fprintf(s.IOStreams.ErrOut, "%s\n", f)
+	}
+	for _, err := range sum.WaitErrors {
+		fmt.Fprintf(s.IOStreams.ErrOut, "error waiting for status: %v\n", err)
+	}
+}
+
+// durationToString returns the duration of a phase, for
+// example " in 1.5s", or nothing if the duration is unknown.
+func durationToString(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" in %s", d.Round(time.Millisecond))
+}
+
+// operationsToString returns the number of resources for every
+// apply operation, for example " (2 created, 1 unchanged)".
+func operationsToString(sum *Summary) string {
+	var parts []string
+	for _, op := range []struct {
+		op    event.ApplyEventOperation
+		count int
+	}{
+		{event.Created, sum.Created},
+		{event.Configured, sum.Configured},
+		{event.Unchanged, sum.Unchanged},
+		{event.ServersideApplied, sum.ServersideApplied},
+	} {
+		if op.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", op.count, operationToString(op.op)))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(parts, ", "))
+}
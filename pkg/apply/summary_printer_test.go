@@ -0,0 +1,180 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+)
+
+func TestSummaryPrinter(t *testing.T) {
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:      event.ApplyEventResourceUpdate,
+				Operation: event.Created,
+			},
+		},
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:      event.ApplyEventResourceUpdate,
+				Operation: event.Unchanged,
+			},
+		},
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		statusEvent("foo", status.CurrentStatus, "Deployment is available"),
+		statusEvent("bar", status.InProgressStatus, "Replicas: 0/1"),
+		{
+			Type: event.StatusType,
+			StatusEvent: pollevent.Event{
+				EventType: pollevent.AbortedEvent,
+			},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type: event.PruneEventResourceUpdate,
+			},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type: event.PruneEventCompleted,
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		quiet          bool
+		expectedOut    string
+		expectedErrOut string
+	}{
+		"summary": {
+			quiet: false,
+			expectedOut: "2 resource(s) applied (1 created, 1 unchanged)\n" +
+				"1/2 resource(s) reconciled (timed out)\n" +
+				"1 resource(s) pruned\n",
+			expectedErrOut: "deployment.apps/bar is InProgress: Replicas: 0/1\n",
+		},
+		"quiet": {
+			quiet:          true,
+			expectedOut:    "",
+			expectedErrOut: "deployment.apps/bar is InProgress: Replicas: 0/1\n",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			ioStreams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			printer := &SummaryPrinter{
+				IOStreams: ioStreams,
+				Quiet:     tc.quiet,
+			}
+			ch := make(chan event.Event)
+			go func() {
+				defer close(ch)
+				for _, e := range events {
+					ch <- e
+				}
+			}()
+			printer.Print(ch)
+
+			assert.Equal(t, tc.expectedOut, out.String())
+			assert.Equal(t, tc.expectedErrOut, errOut.String())
+		})
+	}
+}
+
+func TestSummaryPrinterDurations(t *testing.T) {
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:      event.ApplyEventResourceUpdate,
+				Operation: event.Configured,
+			},
+		},
+		{
+			Type:      event.ApplyType,
+			Started:   started,
+			Timestamp: started.Add(1500 * time.Millisecond),
+			ApplyEvent: event.ApplyEvent{
+				Type: event.ApplyEventCompleted,
+			},
+		},
+		{
+			Type: event.DeleteType,
+			DeleteEvent: event.DeleteEvent{
+				Type: event.DeleteEventResourceUpdate,
+			},
+		},
+		{
+			Type:      event.DeleteType,
+			Started:   started,
+			Timestamp: started.Add(2 * time.Second),
+			DeleteEvent: event.DeleteEvent{
+				Type: event.DeleteEventCompleted,
+			},
+		},
+	}
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	printer := &SummaryPrinter{IOStreams: ioStreams}
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		for _, e := range events {
+			ch <- e
+		}
+	}()
+	printer.Print(ch)
+
+	assert.Equal(t, "1 resource(s) applied (1 configured) in 1.5s\n"+
+		"1 resource(s) deleted in 2s\n", out.String())
+}
+
+func TestPrinterOptions(t *testing.T) {
+	ioStreams, _, _, _ := genericclioptions.NewTestIOStreams()
+
+	printer, err := (&PrinterOptions{Output: EventsOutput, Summary: true}).ToPrinter(ioStreams)
+	assert.NoError(t, err)
+	assert.IsType(t, &SummaryPrinter{}, printer)
+
+	_, err = (&PrinterOptions{Output: JSONOutput, Quiet: true}).ToPrinter(ioStreams)
+	assert.Error(t, err)
+}
+
+func statusEvent(name string, s status.Status, message string) event.Event {
+	return event.Event{
+		Type: event.StatusType,
+		StatusEvent: pollevent.Event{
+			EventType: pollevent.ResourceUpdateEvent,
+			Resource: &pollevent.ObservedResource{
+				Identifier: wait.ResourceIdentifier{
+					GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+					Namespace: "default",
+					Name:      name,
+				},
+				Status:  s,
+				Message: message,
+			},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func TestSummarize(t *testing.T) {
+	events := []event.Event{
+		applyEvent(event.Created),
+		applyEvent(event.Created),
+		applyEvent(event.Configured),
+		applyEvent(event.Unchanged),
+		applyEvent(event.Filtered),
+		{
+			Type:       event.ApplyType,
+			ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted},
+		},
+		statusEvent("foo", status.InProgressStatus, "Replicas: 0/1"),
+		statusEvent("foo", status.CurrentStatus, "Deployment is available"),
+		statusEvent("bar", status.FailedStatus, "Progress deadline exceeded"),
+		statusEvent("baz", status.InProgressStatus, "Replicas: 0/1"),
+		{
+			Type:        event.StatusType,
+			StatusEvent: pollevent.Event{EventType: pollevent.AbortedEvent},
+		},
+		{
+			Type:       event.PruneType,
+			PruneEvent: event.PruneEvent{Type: event.PruneEventResourceUpdate},
+		},
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type:      event.PruneEventResourceUpdate,
+				Operation: event.PruneSkipped,
+			},
+		},
+		{
+			Type:       event.ErrorType,
+			ErrorEvent: event.ErrorEvent{Err: fmt.Errorf("timeout")},
+		},
+	}
+
+	ch := make(chan event.Event, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	close(ch)
+	sum := Summarize(ch)
+
+	assert.Equal(t, 2, sum.Created)
+	assert.Equal(t, 1, sum.Configured)
+	assert.Equal(t, 1, sum.Unchanged)
+	assert.Equal(t, 4, sum.Applied())
+	assert.Equal(t, 1, sum.Filtered)
+	assert.True(t, sum.ApplyCompleted)
+	assert.True(t, sum.WaitCompleted)
+	assert.True(t, sum.WaitTimedOut)
+	assert.Equal(t, 3, sum.StatusCount())
+	assert.Equal(t, 1, sum.Reconciled)
+	assert.Equal(t, 1, sum.Pruned)
+	assert.Equal(t, 1, sum.Skipped)
+	assert.Equal(t, 1, len(sum.Errors))
+
+	if assert.Equal(t, 1, len(sum.Failed)) {
+		assert.Equal(t, "bar", sum.Failed[0].Identifier.Name)
+		assert.Equal(t, status.FailedStatus, sum.Failed[0].Status)
+	}
+	if assert.Equal(t, 1, len(sum.TimedOut)) {
+		assert.Equal(t, "baz", sum.TimedOut[0].Identifier.Name)
+		assert.Equal(t, "Replicas: 0/1", sum.TimedOut[0].Message)
+	}
+}
+
+func applyEvent(op event.ApplyEventOperation) event.Event {
+	return event.Event{
+		Type: event.ApplyType,
+		ApplyEvent: event.ApplyEvent{
+			Type:      event.ApplyEventResourceUpdate,
+			Operation: op,
+		},
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/util/term"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+const (
+	// tableRefreshRate is how often the table is redrawn.
+	tableRefreshRate = time.Second
+
+	// moveUp is the ANSI escape code that moves the cursor up
+	// the given number of lines.
+	moveUp = "\u001b[%dA"
+	// eraseLine is the ANSI escape code that clears the current line.
+	eraseLine = "\u001b[2K"
+)
+
+// TablePrinter prints a table with a row for every resource, showing
+// the last action taken on it and its latest status. When writing to a
+// terminal, the table is redrawn in place at a regular interval.
+// Otherwise it is only printed once, when the channel is closed.
+type TablePrinter struct {
+	IOStreams genericclioptions.IOStreams
+}
+
+// tableRow is the latest information about a single resource.
+type tableRow struct {
+	identifier object.ObjMetadata
+	action     string
+	resource   *pollevent.ObservedResource
+}
+
+// tableState keeps the rows in the order the resources were first seen.
+type tableState struct {
+	rows            []*tableRow
+	index           map[string]*tableRow
+	aggregateStatus status.Status
+}
+
+func newTableState() *tableState {
+	return &tableState{
+		index: make(map[string]*tableRow),
+	}
+}
+
+// row returns the row for the resource, adding it if needed.
+func (t *tableState) row(id object.ObjMetadata) *tableRow {
+	key := id.String()
+	if r, found := t.index[key]; found {
+		return r
+	}
+	r := &tableRow{identifier: id}
+	t.rows = append(t.rows, r)
+	t.index[key] = r
+	return r
+}
+
+// update records the information from the event in the table.
+func (t *tableState) update(e event.Event) {
+	switch e.Type {
+	case event.ApplyType:
+		if e.ApplyEvent.Type == event.ApplyEventResourceUpdate {
+			t.row(e.ApplyEvent.Identifier).action = operationToString(e.ApplyEvent.Operation)
+		}
+	case event.PruneType:
+		if e.PruneEvent.Type == event.PruneEventResourceUpdate {
+			action := "pruned"
+			if e.PruneEvent.Operation == event.PruneSkipped {
+				action = "prune skipped"
+			}
+			t.row(e.PruneEvent.Identifier).action = action
+		}
+	case event.DeleteType:
+		if e.DeleteEvent.Type == event.DeleteEventResourceUpdate {
+			t.row(e.DeleteEvent.Identifier).action = "deleted"
+		}
+	case event.DiffType:
+		action := "changed"
+		if e.DiffEvent.Prune {
+			action = "will be pruned"
+		}
+		t.row(e.DiffEvent.Identifier).action = action
+	case event.StatusType:
+		se := e.StatusEvent
+		if se.EventType != pollevent.ErrorEvent {
+			t.aggregateStatus = se.AggregateStatus
+		}
+		if se.EventType == pollevent.ResourceUpdateEvent && se.Resource != nil {
+			id := se.Resource.Identifier
+			t.row(object.ObjMetadata{
+				Namespace: id.Namespace,
+				Name:      id.Name,
+				GroupKind: id.GroupKind,
+			}).resource = se.Resource
+		}
+	}
+}
+
+// Print keeps updating the table until the channel is closed. The
+// table is always printed one final time after that, so the output
+// reflects the last known state. If an error event is received, the
+// table so far is printed before the error is reported.
+func (t *TablePrinter) Print(ch <-chan event.Event) {
+	state := newTableState()
+	interactive := term.IsTerminal(t.IOStreams.Out)
+	ticker := time.NewTicker(tableRefreshRate)
+	defer ticker.Stop()
+
+	linesPrinted := 0
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.printTable(state, linesPrinted, interactive)
+				return
+			}
+			state.update(e)
+			if e.Type == event.ErrorType {
+				t.printTable(state, linesPrinted, interactive)
+				CheckErr(t.IOStreams.ErrOut, e.ErrorEvent.Err)
+				linesPrinted = 0
+			}
+		case <-ticker.C:
+			if interactive {
+				linesPrinted = t.printTable(state, linesPrinted, interactive)
+			}
+		}
+	}
+}
+
+// printTable writes the table with the latest state. If interactive,
+// the previously printed lines are overwritten. It returns the number
+// of lines printed so the next redraw knows how far to move the cursor.
+func (t *TablePrinter) printTable(state *tableState, previousLines int, interactive bool) int {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tRESOURCE\tACTION\tSTATUS\tMESSAGE")
+	for _, r := range state.rows {
+		printTableRow(w, r.identifier, r.action, r.resource, "")
+	}
+	_ = w.Flush()
+	if state.aggregateStatus != "" {
+		fmt.Fprintf(&buf, "aggregate status: %s\n", state.aggregateStatus)
+	}
+
+	if !interactive {
+		fmt.Fprint(t.IOStreams.Out, buf.String())
+		return 0
+	}
+	if previousLines > 0 {
+		fmt.Fprintf(t.IOStreams.Out, moveUp, previousLines)
+	}
+	count := 0
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		fmt.Fprint(t.IOStreams.Out, eraseLine+line)
+		count++
+	}
+	return count
+}
+
+// printTableRow prints a single row for the resource, followed by
+// rows for any generated resources which are indented below it.
+func printTableRow(w *tabwriter.Writer, id object.ObjMetadata, action string, r *pollevent.ObservedResource,
+	indent string) {
+	var s, message string
+	if r != nil {
+		s = r.Status.String()
+		message = r.Message
+		if r.Error != nil {
+			message = r.Error.Error()
+		}
+	}
+	fmt.Fprintf(w, "%s\t%s%s\t%s\t%s\t%s\n", id.Namespace, indent,
+		resourceIDToString(id.GroupKind, id.Name), action, s, message)
+	if r == nil {
+		return
+	}
+	for _, g := range r.GeneratedResources {
+		printTableRow(w, object.ObjMetadata{
+			Namespace: g.Identifier.Namespace,
+			Name:      g.Identifier.Name,
+			GroupKind: g.Identifier.GroupKind,
+		}, "", g, indent+"  ")
+	}
+}
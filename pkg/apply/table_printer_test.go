@@ -0,0 +1,67 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestTablePrinter(t *testing.T) {
+	deployment := func(name string) object.ObjMetadata {
+		return object.ObjMetadata{
+			Namespace: "default",
+			Name:      name,
+			GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		}
+	}
+	events := []event.Event{
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Created,
+				Identifier: deployment("foo"),
+			},
+		},
+		{
+			Type: event.ApplyType,
+			ApplyEvent: event.ApplyEvent{
+				Type:       event.ApplyEventResourceUpdate,
+				Operation:  event.Configured,
+				Identifier: deployment("bar"),
+			},
+		},
+		statusEvent("foo", status.InProgressStatus, "Replicas: 0/1"),
+		statusEvent("foo", status.CurrentStatus, "Deployment is available"),
+		{
+			Type: event.PruneType,
+			PruneEvent: event.PruneEvent{
+				Type:       event.PruneEventResourceUpdate,
+				Identifier: deployment("baz"),
+			},
+		},
+	}
+
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	printer := &TablePrinter{IOStreams: ioStreams}
+	ch := make(chan event.Event, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	close(ch)
+	printer.Print(ch)
+
+	assert.Equal(t, ""+
+		"NAMESPACE  RESOURCE             ACTION      STATUS   MESSAGE\n"+
+		"default    deployment.apps/foo  created     Current  Deployment is available\n"+
+		"default    deployment.apps/bar  configured           \n"+
+		"default    deployment.apps/baz  pruned               \n", out.String())
+}
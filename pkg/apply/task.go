@@ -0,0 +1,424 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-errors/errors"
+	"go.opentelemetry.io/otel/codes"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// Once the resources have been read and planned, a run of the Applier
+// executes a queue of tasks: the ApplyTask, the WaitTask, the
+// ConditionTask waiting for the conditions declared with the
+// WaitForAnnotation, and the PruneTask, in that order. If the Applier
+// keeps a history, the HistoryTask recording the revision comes last.
+// Custom tasks, like a database migration after the resources are
+// applied or a smoke test once they have reconciled, can be inserted
+// between them with the TaskQueue field of the Applier. The tasks are
+// run one after the other, and the run is aborted as soon as one of
+// them fails.
+
+// Names of the tasks in the default queue.
+const (
+	ApplyTaskName     = "apply"
+	WaitTaskName      = "wait"
+	ConditionTaskName = "wait-for-conditions"
+	PruneTaskName     = "prune"
+	// HistoryTaskName is only in the queue if the HistoryLimit
+	// is set, and the run is not a dry-run.
+	HistoryTaskName = "history"
+)
+
+// Task is a step of a run.
+type Task interface {
+	// Name identifies the task, for example to insert
+	// other tasks before or after it.
+	Name() string
+	// Run performs the task. The remaining tasks are not run if it
+	// returns an error, which is reported as an error event.
+	Run(ctx context.Context, tc *TaskContext) error
+}
+
+// TaskContext is shared by the tasks of a run.
+type TaskContext struct {
+	// Infos are the resources of the run, in the order
+	// they are applied.
+	Infos []*resource.Info
+	// Statuses are the last observed statuses of the resources, keyed
+	// by object.ObjMetadata.String(). They are set by the WaitTask.
+	Statuses map[string]status.Status
+	// AggregateStatus is the last observed aggregate status of the
+	// resources. It is set by the WaitTask.
+	AggregateStatus status.Status
+	// WaitTimedOut is set by the WaitTask if the resources didn't
+	// reconcile before the timeout. The run is reported as failed
+	// once all the tasks have run.
+	WaitTimedOut bool
+
+	ch chan<- event.Event
+}
+
+// SendEvent sends an event on the channel returned by Run.
+func (tc *TaskContext) SendEvent(e event.Event) {
+	tc.ch <- e
+}
+
+// TaskFunc is a Task that calls a function.
+type TaskFunc struct {
+	// TaskName is returned by Name.
+	TaskName string
+	// Fn is called by Run.
+	Fn func(ctx context.Context, tc *TaskContext) error
+}
+
+var _ Task = TaskFunc{}
+
+// Name returns the TaskName.
+func (t TaskFunc) Name() string {
+	return t.TaskName
+}
+
+// Run calls the function.
+func (t TaskFunc) Run(ctx context.Context, tc *TaskContext) error {
+	return t.Fn(ctx, tc)
+}
+
+// TaskQueueFunc returns the tasks of a run. It is passed the default
+// tasks, so it can add tasks between them, wrap them or leave them out.
+type TaskQueueFunc func(tasks []Task) []Task
+
+// InsertTaskBefore returns a TaskQueueFunc that inserts the task before
+// the task with the given name, or at the end if there is no such task.
+func InsertTaskBefore(name string, task Task) TaskQueueFunc {
+	return func(tasks []Task) []Task {
+		for i, t := range tasks {
+			if t.Name() == name {
+				return insertTask(tasks, i, task)
+			}
+		}
+		return append(tasks, task)
+	}
+}
+
+// InsertTaskAfter returns a TaskQueueFunc that inserts the task after
+// the task with the given name, or at the end if there is no such task.
+func InsertTaskAfter(name string, task Task) TaskQueueFunc {
+	return func(tasks []Task) []Task {
+		for i, t := range tasks {
+			if t.Name() == name {
+				return insertTask(tasks, i+1, task)
+			}
+		}
+		return append(tasks, task)
+	}
+}
+
+// ChainTaskQueues returns a TaskQueueFunc that applies the
+// functions in order, so several tasks can be inserted.
+func ChainTaskQueues(fns ...TaskQueueFunc) TaskQueueFunc {
+	return func(tasks []Task) []Task {
+		for _, fn := range fns {
+			tasks = fn(tasks)
+		}
+		return tasks
+	}
+}
+
+func insertTask(tasks []Task, i int, task Task) []Task {
+	result := make([]Task, 0, len(tasks)+1)
+	result = append(result, tasks[:i]...)
+	result = append(result, task)
+	return append(result, tasks[i:]...)
+}
+
+// runTasks runs the tasks of the queue. Errors of custom tasks are
+// reported as apply errors, while the default tasks set their own.
+func (a *Applier) runTasks(ctx context.Context, tc *TaskContext, defaults []Task) error {
+	tasks := defaults
+	if a.TaskQueue != nil {
+		tasks = a.TaskQueue(append([]Task{}, defaults...))
+	}
+	for _, t := range tasks {
+		if ctx.Err() != nil {
+			a.logger().Info("run cancelled, skipping the remaining tasks", "task", t.Name())
+			return withExitCode(fmt.Errorf("run %w before the %s task", ErrCancelled, t.Name()), ExitCancelled)
+		}
+		err := t.Run(ctx, tc)
+		if err == nil {
+			continue
+		}
+		if !isDefaultTask(t, defaults) {
+			a.logger().Error(err, "error running task", "task", t.Name())
+			err = withExitCode(errors.WrapPrefix(err, fmt.Sprintf("error running task %s", t.Name()), 1),
+				ExitApplyError)
+		}
+		return err
+	}
+	return nil
+}
+
+// isDefaultTask returns true if the task is one of the defaults. The
+// defaults are pointers, so comparing them with any task is safe.
+func isDefaultTask(t Task, defaults []Task) bool {
+	for _, d := range defaults {
+		if t == d {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyTask applies the resources with the Actuator, followed by the
+// resources whose kinds were not served when the run started. If the
+// context is cancelled, no more resources are applied, and the
+// inventory only records the ones that were.
+type ApplyTask struct {
+	applier  *Applier
+	adapter  *KubectlPrinterAdapter
+	deferred []*resource.Info
+}
+
+// Name returns ApplyTaskName.
+func (t *ApplyTask) Name() string {
+	return ApplyTaskName
+}
+
+// Run applies the resources.
+func (t *ApplyTask) Run(ctx context.Context, tc *TaskContext) error {
+	a := t.applier
+	a.logger().V(1).Info("applying resources", "count", len(tc.Infos), "dryRun", a.DryRunStrategy.String())
+	t.adapter.progress = newProgressCounter(event.ApplyPhase, len(tc.Infos))
+	applyCtx, span := startSpan(ctx, a.Tracer, spanApply)
+	t.adapter.ctx = applyCtx
+	applied, err := a.applyUntilCancelled(applyCtx, tc.Infos)
+	if err == nil && applied == len(tc.Infos) && len(t.deferred) > 0 {
+		err = a.applyDeferred(ctx, t.deferred)
+		if err == nil {
+			applied += len(t.deferred)
+		}
+		tc.Infos = append(tc.Infos, t.deferred...)
+	}
+	endSpan(span, err)
+	if applied < len(tc.Infos) && ctx.Err() != nil {
+		return t.cancel(ctx, tc, applied, err)
+	}
+	if err != nil {
+		a.logger().Error(err, "error applying resources")
+		// If we see an error here we just report it on the channel and then
+		// give up. Eventually we might be able to determine which errors
+		// are fatal and which might allow us to continue.
+		return withExitCode(errors.WrapPrefix(err, "error applying resources", 1), ExitApplyError)
+	}
+	// If we get there, then all resources have been successfully applied.
+	tc.SendEvent(event.Event{
+		Type:      event.ApplyType,
+		Timestamp: time.Now(),
+		Started:   t.adapter.progress.started,
+		ApplyEvent: event.ApplyEvent{
+			Type: event.ApplyEventCompleted,
+		},
+	})
+	return nil
+}
+
+// applyBatchSize is the number of resources applied by a single call
+// of the Actuator. Most packages are applied by a single call.
+var applyBatchSize = 100
+
+// applyUntilCancelled applies the resources in batches of applyBatchSize,
+// and checks the context between the batches, so no more resources are
+// applied once it is cancelled, while the batch being applied is
+// finished. It returns how many were applied. If a batch fails, all its
+// resources are counted as applied, since some of them may have been.
+func (a *Applier) applyUntilCancelled(ctx context.Context, infos []*resource.Info) (int, error) {
+	actuator := a.actuator()
+	for start := 0; start < len(infos); start += applyBatchSize {
+		if ctx.Err() != nil {
+			return start, nil
+		}
+		end := start + applyBatchSize
+		if end > len(infos) {
+			end = len(infos)
+		}
+		if err := actuator.Apply(ctx, infos[start:end]); err != nil {
+			return end, err
+		}
+	}
+	return len(infos), nil
+}
+
+// cancel removes the resources that were not applied from the
+// inventory of the cancelled run, so the next run neither expects
+// them to exist nor prunes them, and returns the error for the run.
+// Apply errors of the last batch are included in the error.
+func (t *ApplyTask) cancel(ctx context.Context, tc *TaskContext, applied int, applyErr error) error {
+	a := t.applier
+	total := len(tc.Infos)
+	tc.Infos = tc.Infos[:applied]
+	a.logger().Info("run cancelled, recording the applied resources in the inventory",
+		"applied", applied, "count", total)
+	// The grouping object is applied first, so if it is not among
+	// the applied resources, nothing was.
+	if _, found := prune.FindGroupingObject(tc.Infos); found && !a.DryRunStrategy.ClientOrServerDryRun() {
+		_, span := startSpan(ctx, a.Tracer, spanInventory)
+		err := a.inventoryClient().WriteApplied(tc.Infos)
+		endSpan(span, err)
+		if err != nil {
+			a.logger().Error(err, "error writing applied resources to inventory")
+			return errors.WrapPrefix(err, "error writing applied resources to inventory", 1)
+		}
+	}
+	err := fmt.Errorf("run %w after applying %d of %d resource(s), nothing was pruned", ErrCancelled, applied, total)
+	if applyErr != nil {
+		err = fmt.Errorf("%w: %v", err, applyErr)
+	}
+	return withExitCode(err, ExitCancelled)
+}
+
+// WaitTask waits for the resources to reconcile, and records their
+// statuses in the inventory. If the Applier doesn't wait, it records
+// that the statuses are unknown.
+type WaitTask struct {
+	applier *Applier
+}
+
+// Name returns WaitTaskName.
+func (t *WaitTask) Name() string {
+	return WaitTaskName
+}
+
+// Run waits for the resources.
+func (t *WaitTask) Run(ctx context.Context, tc *TaskContext) error {
+	a := t.applier
+	if a.StatusOptions.NoWait && !a.DryRunStrategy.ClientOrServerDryRun() {
+		// Nothing is known about the reconcile status, but the
+		// inventory still records that it hasn't been observed.
+		a.logger().V(1).Info("not waiting for resources, reconcile status is unknown")
+		_, span := startSpan(ctx, a.Tracer, spanInventory)
+		err := a.inventoryClient().WriteStatus(tc.Infos, nil, status.UnknownStatus)
+		endSpan(span, err)
+		if err != nil {
+			a.logger().Error(err, "error writing status to inventory")
+			return errors.WrapPrefix(err, "error writing status to inventory", 1)
+		}
+	}
+	if !a.StatusOptions.Wait {
+		return nil
+	}
+	a.logger().V(1).Info("waiting for resources to become current", "count", len(tc.Infos))
+	waitCtx, span := startSpan(ctx, a.Tracer, spanWait)
+	if a.StatusOptions.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(waitCtx, a.StatusOptions.Timeout)
+		defer cancel()
+	}
+	statusChannel := a.statusPoller.WaitForCurrent(waitCtx, infosToObjMetadata(tc.Infos))
+	// Keep track of the last observed status for every resource and
+	// the aggregate status, so they can be recorded in the inventory.
+	tc.Statuses = make(map[string]status.Status)
+	tc.AggregateStatus = status.UnknownStatus
+	waitStarted := time.Now()
+	reconciled := 0
+	// As long as the statusChannel remains open, we take every statusEvent,
+	// wrap it in an Event and send it on the channel.
+	// TODO: What should we do if waiting for status times out? We currently proceed with
+	// prune, but that doesn't seem right.
+	for statusEvent := range statusChannel {
+		if statusEvent.EventType == pollevent.ResourceUpdateEvent {
+			id := statusEvent.Resource.Identifier
+			objMeta := object.ObjMetadata{
+				Namespace: id.Namespace,
+				Name:      id.Name,
+				GroupKind: id.GroupKind,
+			}
+			tc.Statuses[objMeta.String()] = statusEvent.Resource.Status
+		}
+		if statusEvent.EventType != pollevent.ErrorEvent {
+			tc.AggregateStatus = statusEvent.AggregateStatus
+		}
+		if statusEvent.EventType == pollevent.AbortedEvent {
+			tc.WaitTimedOut = true
+		}
+		tc.SendEvent(event.Event{
+			Type:        event.StatusType,
+			Timestamp:   time.Now(),
+			Started:     waitStarted,
+			StatusEvent: statusEvent,
+		})
+		// Only report progress when the number of reconciled
+		// resources changes, since the status is polled.
+		if count := countReconciled(tc.Statuses); count != reconciled {
+			reconciled = count
+			tc.SendEvent(event.NewProgressEvent(event.WaitPhase, reconciled, len(tc.Infos), waitStarted))
+		}
+	}
+	if tc.WaitTimedOut {
+		span.SetStatus(codes.Error, "timed out")
+	}
+	span.End()
+	a.logger().V(1).Info("finished waiting for resources", "aggregateStatus", tc.AggregateStatus,
+		"timedOut", tc.WaitTimedOut)
+
+	if a.DryRunStrategy.ClientOrServerDryRun() {
+		return nil
+	}
+	_, span = startSpan(ctx, a.Tracer, spanInventory)
+	err := a.inventoryClient().WriteStatus(tc.Infos, tc.Statuses, tc.AggregateStatus)
+	endSpan(span, err)
+	if err != nil {
+		a.logger().Error(err, "error writing status to inventory")
+		return errors.WrapPrefix(err, "error writing status to inventory", 1)
+	}
+	return nil
+}
+
+// PruneTask deletes the resources that are no longer in the package
+// with the Pruner, unless pruning is disabled.
+type PruneTask struct {
+	applier *Applier
+}
+
+// Name returns PruneTaskName.
+func (t *PruneTask) Name() string {
+	return PruneTaskName
+}
+
+// Run prunes the resources.
+func (t *PruneTask) Run(ctx context.Context, tc *TaskContext) error {
+	a := t.applier
+	if a.NoPrune {
+		a.logger().V(1).Info("pruning is disabled, keeping previously applied resources")
+		return nil
+	}
+	pruneStarted := time.Now()
+	_, span := startSpan(ctx, a.Tracer, spanPrune)
+	err := a.pruner().Prune(tc.Infos, tc.ch)
+	endSpan(span, err)
+	if err != nil {
+		a.logger().Error(err, "error pruning resources")
+		// If we see an error here we just report it on the channel and then
+		// give up. Eventually we might be able to determine which errors
+		// are fatal and which might allow us to continue.
+		return withExitCode(errors.WrapPrefix(err, "error pruning resources", 1), ExitPruneError)
+	}
+	tc.SendEvent(event.Event{
+		Type:      event.PruneType,
+		Timestamp: time.Now(),
+		Started:   pruneStarted,
+		PruneEvent: event.PruneEvent{
+			Type: event.PruneEventCompleted,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,392 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func taskNames(tasks []Task) []string {
+	var names []string
+	for _, t := range tasks {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+func TestTaskQueueFuncs(t *testing.T) {
+	defaults := []Task{&ApplyTask{}, &WaitTask{}, &PruneTask{}}
+	migrate := TaskFunc{TaskName: "migrate"}
+	smoke := TaskFunc{TaskName: "smoke-test"}
+
+	testCases := map[string]struct {
+		queue    TaskQueueFunc
+		expected []string
+	}{
+		"insert after": {
+			queue:    InsertTaskAfter(ApplyTaskName, migrate),
+			expected: []string{"apply", "migrate", "wait", "prune"},
+		},
+		"insert before": {
+			queue:    InsertTaskBefore(PruneTaskName, smoke),
+			expected: []string{"apply", "wait", "smoke-test", "prune"},
+		},
+		"unknown task name": {
+			queue:    InsertTaskBefore("unknown", smoke),
+			expected: []string{"apply", "wait", "prune", "smoke-test"},
+		},
+		"chained": {
+			queue: ChainTaskQueues(
+				InsertTaskAfter(ApplyTaskName, migrate),
+				InsertTaskAfter(WaitTaskName, smoke),
+			),
+			expected: []string{"apply", "migrate", "wait", "smoke-test", "prune"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			tasks := append([]Task{}, defaults...)
+			assert.Equal(t, tc.expected, taskNames(tc.queue(tasks)))
+			// The passed tasks are not changed.
+			assert.Equal(t, []string{"apply", "wait", "prune"}, taskNames(tasks))
+		})
+	}
+}
+
+func TestApplierTaskQueue(t *testing.T) {
+	testCases := map[string]struct {
+		taskErr   error
+		expectErr bool
+	}{
+		"custom task succeeds": {},
+		"custom task fails": {
+			taskErr:   fmt.Errorf("migration failed"),
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithNoWait())
+			require.NoError(t, err)
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+			var appliedBeforeTask bool
+			applier.TaskQueue = InsertTaskAfter(ApplyTaskName, TaskFunc{
+				TaskName: "migrate",
+				Fn: func(_ context.Context, taskCtx *TaskContext) error {
+					appliedBeforeTask = cluster.Get(gvk, fakecluster.DefaultNamespace, "cm") != nil
+					assert.Len(t, taskCtx.Infos, 2)
+					return tc.taskErr
+				},
+			})
+
+			objs := []*unstructured.Unstructured{
+				configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+				configMap("cm", nil),
+			}
+			var errs []error
+			var pruneCompleted bool
+			for e := range applier.RunObjects(context.Background(), objs) {
+				switch e.Type {
+				case event.ErrorType:
+					errs = append(errs, e.ErrorEvent.Err)
+				case event.PruneType:
+					pruneCompleted = pruneCompleted || e.PruneEvent.Type == event.PruneEventCompleted
+				}
+			}
+			assert.True(t, appliedBeforeTask)
+			if tc.expectErr {
+				require.Len(t, errs, 1)
+				assert.Contains(t, errs[0].Error(), "error running task migrate")
+				assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+				// The tasks after the failed one are not run.
+				assert.False(t, pruneCompleted)
+				return
+			}
+			assert.Empty(t, errs)
+			assert.True(t, pruneCompleted)
+		})
+	}
+}
+
+// cancellingActuator cancels the run after the wrapped Actuator
+// has been called the given number of times.
+type cancellingActuator struct {
+	Actuator
+	cancel context.CancelFunc
+	after  int
+	calls  int
+}
+
+func (c *cancellingActuator) Apply(ctx context.Context, infos []*resource.Info) error {
+	err := c.Actuator.Apply(ctx, infos)
+	c.calls++
+	if c.calls == c.after {
+		c.cancel()
+	}
+	return err
+}
+
+func TestApplierCancelled(t *testing.T) {
+	testCases := map[string]struct {
+		cancelAfter int
+		applied     int
+	}{
+		"cancelled before anything is applied": {
+			cancelAfter: 0,
+		},
+		"cancelled after the grouping object": {
+			cancelAfter: 1,
+		},
+		"cancelled halfway": {
+			cancelAfter: 2,
+			applied:     1,
+		},
+	}
+	// Every resource is applied in its own batch, so
+	// the run can be cancelled after any of them.
+	defer func(size int) { applyBatchSize = size }(applyBatchSize)
+	applyBatchSize = 1
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithNoWait())
+			require.NoError(t, err)
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+			for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+				inventory.DeepCopy(), configMap("old", nil),
+			}) {
+				require.NotEqual(t, event.ErrorType, e.Type, "%v", e.ErrorEvent.Err)
+			}
+			previous := groupingObjectNames(cluster)
+			require.Len(t, previous, 1)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelAfter == 0 {
+				cancel()
+			}
+			applier.Actuator = &cancellingActuator{Actuator: applier.actuator(), cancel: cancel, after: tc.cancelAfter}
+			var errs []error
+			for e := range applier.RunObjects(ctx, []*unstructured.Unstructured{
+				inventory.DeepCopy(), configMap("a", nil), configMap("b", nil),
+			}) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+			require.Len(t, errs, 1)
+			assert.True(t, IsCancelledError(errs[0]))
+			assert.Equal(t, ExitCancelled, ExitCode(errs[0]))
+
+			gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+			var applied []string
+			for _, name := range []string{"a", "b"} {
+				if cluster.Get(gvk, fakecluster.DefaultNamespace, name) != nil {
+					applied = append(applied, name)
+				}
+			}
+			assert.Len(t, applied, tc.applied)
+			// Nothing is pruned.
+			assert.NotNil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "old"))
+
+			names := groupingObjectNames(cluster)
+			if tc.cancelAfter == 0 {
+				assert.Equal(t, previous, names)
+				return
+			}
+			require.Len(t, names, 2)
+			for _, name := range names {
+				if name == previous[0] {
+					continue
+				}
+				data, _, err := unstructured.NestedStringMap(
+					cluster.Get(gvk, fakecluster.DefaultNamespace, name).Object, "data")
+				require.NoError(t, err)
+				var recorded []string
+				for key := range data {
+					recorded = append(recorded, key)
+				}
+				var expected []string
+				// Only the applied resources are in the inventory.
+				for _, name := range applied {
+					id := object.ObjMetadata{
+						Namespace: fakecluster.DefaultNamespace,
+						Name:      name,
+						GroupKind: gvk.GroupKind(),
+					}
+					expected = append(expected, id.String())
+				}
+				assert.ElementsMatch(t, expected, recorded)
+			}
+		})
+	}
+}
+
+// recordingActuator records the sizes of the batches it is asked to
+// apply, and cancels the run after the given number of batches.
+type recordingActuator struct {
+	cancel  context.CancelFunc
+	after   int
+	batches []int
+}
+
+func (r *recordingActuator) Apply(_ context.Context, infos []*resource.Info) error {
+	r.batches = append(r.batches, len(infos))
+	if len(r.batches) == r.after {
+		r.cancel()
+	}
+	return nil
+}
+
+func TestApplyUntilCancelled(t *testing.T) {
+	testCases := map[string]struct {
+		count           int
+		cancelAfter     int
+		expectedBatches []int
+		expectedApplied int
+	}{
+		"single batch": {
+			count:           applyBatchSize,
+			expectedBatches: []int{applyBatchSize},
+			expectedApplied: applyBatchSize,
+		},
+		"several batches": {
+			count:           2*applyBatchSize + 1,
+			expectedBatches: []int{applyBatchSize, applyBatchSize, 1},
+			expectedApplied: 2*applyBatchSize + 1,
+		},
+		"cancelled between batches": {
+			count:           2*applyBatchSize + 1,
+			cancelAfter:     1,
+			expectedBatches: []int{applyBatchSize},
+			expectedApplied: applyBatchSize,
+		},
+		"cancelled during the last batch": {
+			count:           applyBatchSize,
+			cancelAfter:     1,
+			expectedBatches: []int{applyBatchSize},
+			expectedApplied: applyBatchSize,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			actuator := &recordingActuator{cancel: cancel, after: tc.cancelAfter}
+			applier := &Applier{Actuator: actuator}
+			infos := make([]*resource.Info, tc.count)
+			for i := range infos {
+				infos[i] = &resource.Info{Name: fmt.Sprintf("cm-%d", i)}
+			}
+
+			applied, err := applier.applyUntilCancelled(ctx, infos)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedApplied, applied)
+			assert.Equal(t, tc.expectedBatches, actuator.batches)
+		})
+	}
+}
+
+// groupingObjectNames returns the names of the
+// grouping objects in the cluster.
+func groupingObjectNames(cluster *fakecluster.Cluster) []string {
+	var names []string
+	for _, obj := range cluster.Objects() {
+		if prune.IsGroupingObject(obj) {
+			names = append(names, obj.GetName())
+		}
+	}
+	return names
+}
+
+// failingActuator fails to apply the resources with the error.
+type failingActuator struct {
+	err error
+}
+
+func (f *failingActuator) Apply(context.Context, []*resource.Info) error {
+	return f.err
+}
+
+func TestApplierRetriableErrors(t *testing.T) {
+	webhookErr := apierrors.NewBadRequest(`admission webhook "flaky.example.com" denied the request`)
+	testCases := map[string]struct {
+		matchers  []ErrorMatcher
+		retriable bool
+	}{
+		"built-in classification": {},
+		"matched": {
+			matchers:  []ErrorMatcher{MessageMatcher("flaky.example.com")},
+			retriable: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithNoWait(), WithRetriableErrors(tc.matchers...))
+			require.NoError(t, err)
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+			applier.Actuator = &failingActuator{err: webhookErr}
+
+			var errs []error
+			for e := range applier.RunObjects(context.Background(), []*unstructured.Unstructured{
+				configMap("inventory", map[string]string{prune.GroupingLabel: "test"}), configMap("a", nil),
+			}) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tc.retriable, IsRetriable(errs[0]))
+			assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NamespaceTerminatingError is returned before anything is applied if
+// the package uses namespaces that are being deleted, since the API
+// server rejects every resource created in them.
+type NamespaceTerminatingError struct {
+	Namespaces []string
+}
+
+func (e *NamespaceTerminatingError) Error() string {
+	return fmt.Sprintf("namespace(s) %s are being deleted; apply again once they are gone, or if the "+
+		"deletion is stuck, look for the finalizers blocking it with 'kubectl get namespace %s -o yaml'",
+		strings.Join(e.Namespaces, ", "), e.Namespaces[0])
+}
+
+// isTerminating returns true if the namespace is being deleted.
+func isTerminating(ns *corev1.Namespace) bool {
+	return ns.Status.Phase == corev1.NamespaceTerminating || ns.DeletionTimestamp != nil
+}
+
+// packageNamespaces returns the namespaces the resources are applied
+// to, including the namespaces that are part of the package, sorted.
+func packageNamespaces(infos []*resource.Info) []string {
+	namespaces := sets.NewString()
+	for _, info := range infos {
+		if info.Namespace != "" {
+			namespaces.Insert(info.Namespace)
+		}
+		if info.Object.GetObjectKind().GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Namespace"}) {
+			namespaces.Insert(info.Name)
+		}
+	}
+	return namespaces.List()
+}
+
+// checkTerminatingNamespaces returns a NamespaceTerminatingError if
+// any of the namespaces of the package is being deleted. Namespaces
+// that can't be read are left to the apply, since they may be created
+// by the package, or the user may not be allowed to read them.
+func (a *Applier) checkTerminatingNamespaces(infos []*resource.Info) error {
+	names := packageNamespaces(infos)
+	if len(names) == 0 {
+		return nil
+	}
+	client, err := clientset(a.clients, a.factory)
+	if err != nil {
+		return err
+	}
+	terminating := terminatingNamespaces(client, names)
+	if len(terminating) > 0 {
+		return &NamespaceTerminatingError{Namespaces: terminating}
+	}
+	return nil
+}
+
+// terminatingNamespaces returns the names of the namespaces that are
+// being deleted, in the order they are given.
+func terminatingNamespaces(client kubernetes.Interface, names []string) []string {
+	var terminating []string
+	for _, name := range names {
+		ns, err := client.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		if isTerminating(ns) {
+			terminating = append(terminating, name)
+		}
+	}
+	return terminating
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func TestPackageNamespaces(t *testing.T) {
+	infos := []*resource.Info{
+		validatorInfo("v1", "ConfigMap", "b", "cm", "cm.yaml"),
+		validatorInfo("v1", "ConfigMap", "a", "inventory", "inventory.yaml"),
+		validatorInfo("v1", "Namespace", "", "c", "ns.yaml"),
+		validatorInfo("rbac.authorization.k8s.io/v1", "ClusterRole", "", "role", "role.yaml"),
+		validatorInfo("v1", "Service", "b", "svc", "svc.yaml"),
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, packageNamespaces(infos))
+}
+
+func TestTerminatingNamespaces(t *testing.T) {
+	now := metav1.Now()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "active"}},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "terminating"},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "deleted", DeletionTimestamp: &now}},
+	)
+	assert.Equal(t, []string{"deleted", "terminating"},
+		terminatingNamespaces(client, []string{"active", "deleted", "missing", "terminating"}))
+}
+
+func TestRunTerminatingNamespace(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("terminating")
+	require.NoError(t, unstructured.SetNestedField(ns.Object, "Terminating", "status", "phase"))
+	require.NoError(t, cluster.Add(ns))
+
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+	cms := []*unstructured.Unstructured{configMap("a", nil), configMap("b", nil)}
+	for _, cm := range cms {
+		cm.SetNamespace("terminating")
+	}
+	var errs []error
+	for e := range applier.RunObjects(context.Background(), append([]*unstructured.Unstructured{inventory}, cms...)) {
+		if e.Type == event.ErrorType {
+			errs = append(errs, e.ErrorEvent.Err)
+		}
+	}
+
+	// The run fails once, before anything is applied.
+	require.Len(t, errs, 1)
+	var terminatingErr *NamespaceTerminatingError
+	require.True(t, errors.As(errs[0], &terminatingErr))
+	assert.Equal(t, []string{"terminating"}, terminatingErr.Namespaces)
+	assert.Contains(t, errs[0].Error(), "kubectl get namespace terminating -o yaml")
+	assert.True(t, IsTransientError(errs[0]))
+	assert.Equal(t, ExitApplyError, ExitCode(errs[0]))
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	assert.Nil(t, cluster.Get(gvk, fakecluster.DefaultNamespace, "inventory"))
+	assert.Empty(t, groupingObjectNames(cluster))
+}
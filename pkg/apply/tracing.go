@@ -0,0 +1,63 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// The names of the spans created for the phases of a run.
+const (
+	spanApplierRun     = "Applier.Run"
+	spanDestroyerRun   = "Destroyer.Run"
+	spanRead           = "read"
+	spanPlan           = "plan"
+	spanApply          = "apply"
+	spanApplyObject    = "apply-object"
+	spanWait           = "wait"
+	spanInventory      = "inventory-update"
+	spanPrune          = "prune"
+	spanDelete         = "delete"
+	attributeGroup     = "k8s.group"
+	attributeKind      = "k8s.kind"
+	attributeNamespace = "k8s.namespace"
+	attributeName      = "k8s.name"
+)
+
+// noopTracer is used if no tracer has been provided.
+var noopTracer = trace.NewNoopTracerProvider().Tracer("")
+
+// startSpan starts a new span as a child of the span in the context.
+// A nil tracer is treated the same as a tracer that does nothing.
+func startSpan(ctx context.Context, tracer trace.Tracer, name string,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if tracer == nil {
+		tracer = noopTracer
+	}
+	return tracer.Start(ctx, name, opts...)
+}
+
+// endSpan ends the span, and marks it as failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// objectAttributes returns the span attributes that identify a resource.
+func objectAttributes(id object.ObjMetadata) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(attributeGroup, id.GroupKind.Group),
+		attribute.String(attributeKind, id.GroupKind.Kind),
+		attribute.String(attributeNamespace, id.Namespace),
+		attribute.String(attributeName, id.Name),
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// fakeSpan records what is done to the span. All other
+// methods are handled by the embedded no-op span.
+type fakeSpan struct {
+	trace.Span
+	name       string
+	attributes []attribute.KeyValue
+	status     codes.Code
+	ended      bool
+}
+
+func (s *fakeSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func (s *fakeSpan) SetStatus(code codes.Code, _ string) {
+	s.status = code
+}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &fakeSpan{
+		Span:       trace.SpanFromContext(ctx),
+		name:       name,
+		attributes: cfg.Attributes(),
+	}
+	t.spans = append(t.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func TestEndSpan(t *testing.T) {
+	tracer := &fakeTracer{}
+
+	_, span := startSpan(context.Background(), tracer, spanRead)
+	endSpan(span, nil)
+	_, span = startSpan(context.Background(), tracer, spanPrune)
+	endSpan(span, fmt.Errorf("prune failed"))
+
+	if assert.Equal(t, 2, len(tracer.spans)) {
+		assert.Equal(t, spanRead, tracer.spans[0].name)
+		assert.True(t, tracer.spans[0].ended)
+		assert.Equal(t, codes.Unset, tracer.spans[0].status)
+		assert.Equal(t, spanPrune, tracer.spans[1].name)
+		assert.True(t, tracer.spans[1].ended)
+		assert.Equal(t, codes.Error, tracer.spans[1].status)
+	}
+
+	// A nil tracer doesn't create any spans.
+	_, span = startSpan(context.Background(), nil, spanRead)
+	endSpan(span, nil)
+	assert.False(t, span.IsRecording())
+}
+
+func TestKubectlPrinterAdapterSpans(t *testing.T) {
+	ch := make(chan event.Event, 2)
+	tracer := &fakeTracer{}
+	adapter := KubectlPrinterAdapter{
+		ch:       ch,
+		progress: newProgressCounter(event.ApplyPhase, 1),
+		ctx:      context.Background(),
+		tracer:   tracer,
+	}
+	resourcePrinter, err := adapter.toPrinterFunc()("created")
+	assert.NoError(t, err)
+
+	deployment := appsv1.Deployment{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	err = resourcePrinter.PrintObj(&deployment, &bytes.Buffer{})
+	assert.NoError(t, err)
+
+	if assert.Equal(t, 1, len(tracer.spans)) {
+		span := tracer.spans[0]
+		assert.Equal(t, spanApplyObject, span.name)
+		assert.True(t, span.ended)
+		assert.Contains(t, span.attributes, attribute.String(attributeKind, "Deployment"))
+		assert.Contains(t, span.attributes, attribute.String(attributeName, "name"))
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/go-errors/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// UndoPlan describes how undoing the last run changes the cluster. An
+// undo applies the revision before the last one again, so only the
+// resources changed by the last run are affected.
+type UndoPlan struct {
+	// Revision is the revision that is undone, and
+	// Target the revision that is applied again.
+	Revision int
+	Target   int
+	// Restore contains the resources changed by the last run,
+	// which get their previous manifest back.
+	Restore []*object.ObjMetadata
+	// Recreate contains the resources pruned by the last run.
+	Recreate []*object.ObjMetadata
+	// Delete contains the resources created by the last run.
+	Delete []*object.ObjMetadata
+}
+
+// Empty returns true if the last run didn't change any resources.
+func (p UndoPlan) Empty() bool {
+	return len(p.Restore) == 0 && len(p.Recreate) == 0 && len(p.Delete) == 0
+}
+
+// UndoPlan compares the last two revisions, and returns what undoing
+// the last one changes. Run with a zero Revision does the undo.
+func (r *Rollbacker) UndoPlan() (UndoPlan, error) {
+	revisions, err := r.Revisions()
+	if err != nil {
+		return UndoPlan{}, err
+	}
+	target, err := rollbackTarget(revisions, 0)
+	if err != nil {
+		return UndoPlan{}, err
+	}
+	plan, err := undoPlan(revisions[len(revisions)-1], target)
+	if err != nil {
+		return UndoPlan{}, errors.WrapPrefix(err, "error comparing revisions", 1)
+	}
+	return plan, nil
+}
+
+// undoPlan returns the changes that take the resources of the
+// last revision back to the ones of the target.
+func undoPlan(last, target Revision) (UndoPlan, error) {
+	plan := UndoPlan{Revision: last.Number, Target: target.Number}
+	lastObjs, err := objectsByID(last.Objects)
+	if err != nil {
+		return UndoPlan{}, err
+	}
+	targetObjs, err := objectsByID(target.Objects)
+	if err != nil {
+		return UndoPlan{}, err
+	}
+	for _, obj := range target.Objects {
+		if prune.IsGroupingObject(obj) {
+			continue
+		}
+		id, err := object.RuntimeToObjMeta(obj)
+		if err != nil {
+			return UndoPlan{}, err
+		}
+		current, found := lastObjs[id]
+		switch {
+		case !found:
+			plan.Recreate = append(plan.Recreate, &id)
+		case !reflect.DeepEqual(current.Object, obj.Object):
+			plan.Restore = append(plan.Restore, &id)
+		}
+	}
+	for _, obj := range last.Objects {
+		if prune.IsGroupingObject(obj) {
+			continue
+		}
+		id, err := object.RuntimeToObjMeta(obj)
+		if err != nil {
+			return UndoPlan{}, err
+		}
+		if _, found := targetObjs[id]; !found {
+			plan.Delete = append(plan.Delete, &id)
+		}
+	}
+	return plan, nil
+}
+
+// objectsByID returns the objects by their identifier.
+func objectsByID(objs []*unstructured.Unstructured) (map[object.ObjMetadata]*unstructured.Unstructured, error) {
+	byID := make(map[object.ObjMetadata]*unstructured.Unstructured, len(objs))
+	for _, obj := range objs {
+		id, err := object.RuntimeToObjMeta(obj)
+		if err != nil {
+			return nil, err
+		}
+		byID[id] = obj
+	}
+	return byID, nil
+}
+
+// PrintUndoPlan prints the resources changed by an undo,
+// so it can be reviewed before it is done.
+func PrintUndoPlan(w io.Writer, plan UndoPlan) {
+	fmt.Fprintf(w, "Undoing revision %d applies revision %d again.\n", plan.Revision, plan.Target)
+	if plan.Empty() {
+		fmt.Fprintln(w, "No resources were changed by the last run.")
+		return
+	}
+	for _, section := range []struct {
+		title string
+		ids   []*object.ObjMetadata
+	}{
+		{"restored to their previous manifest", plan.Restore},
+		{"recreated", plan.Recreate},
+		{"deleted", plan.Delete},
+	} {
+		if len(section.ids) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "The following %d resource(s) will be %s:\n", len(section.ids), section.title)
+		printIDs(w, section.ids)
+	}
+}
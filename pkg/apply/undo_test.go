@@ -0,0 +1,69 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestUndoPlan(t *testing.T) {
+	withData := func(obj *unstructured.Unstructured, value string) *unstructured.Unstructured {
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	inventory := configMap("inventory", map[string]string{prune.GroupingLabel: "test"})
+	target := Revision{Number: 1, Objects: []*unstructured.Unstructured{
+		inventory,
+		withData(configMap("unchanged", nil), "1"),
+		withData(configMap("changed", nil), "1"),
+		configMap("pruned", nil),
+	}}
+	last := Revision{Number: 2, Objects: []*unstructured.Unstructured{
+		inventory,
+		withData(configMap("unchanged", nil), "1"),
+		withData(configMap("changed", nil), "2"),
+		configMap("created", nil),
+	}}
+
+	plan, err := undoPlan(last, target)
+	require.NoError(t, err)
+	names := func(plan UndoPlan) [][]string {
+		var names [][]string
+		for _, ids := range [][]*object.ObjMetadata{plan.Restore, plan.Recreate, plan.Delete} {
+			var section []string
+			for _, id := range ids {
+				section = append(section, id.Name)
+			}
+			names = append(names, section)
+		}
+		return names
+	}
+	assert.Equal(t, [][]string{{"changed"}, {"pruned"}, {"created"}}, names(plan))
+	assert.False(t, plan.Empty())
+
+	var buf bytes.Buffer
+	PrintUndoPlan(&buf, plan)
+	assert.Equal(t, "Undoing revision 2 applies revision 1 again.\n"+
+		"The following 1 resource(s) will be restored to their previous manifest:\n"+
+		"  1. configmap/changed\n"+
+		"The following 1 resource(s) will be recreated:\n"+
+		"  1. configmap/pruned\n"+
+		"The following 1 resource(s) will be deleted:\n"+
+		"  1. configmap/created\n", buf.String())
+
+	plan, err = undoPlan(target, target)
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+	buf.Reset()
+	PrintUndoPlan(&buf, plan)
+	assert.Equal(t, "Undoing revision 1 applies revision 1 again.\n"+
+		"No resources were changed by the last run.\n", buf.String())
+}
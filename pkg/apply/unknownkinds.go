@@ -0,0 +1,181 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+)
+
+// deferUnknownKinds returns the resources read locally, with a mapping
+// for the ones whose kinds are served by the cluster. The resources of
+// the other kinds are returned without a mapping, so they can be applied
+// once the kinds appear. The error from reading the resources with the
+// builder is returned if all kinds are served, since it is about
+// something else.
+func (a *Applier) deferUnknownKinds(localInfos []*resource.Info, readErr error) ([]*resource.Info, error) {
+	mapper, err := restMapper(a.clients, a.factory, true)
+	if err != nil {
+		return nil, readErr
+	}
+	deferred := 0
+	for _, info := range localInfos {
+		gvk := info.Object.GetObjectKind().GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			deferred++
+			continue
+		}
+		if err != nil {
+			return nil, readErr
+		}
+		if err := a.setMapping(info, mapping); err != nil {
+			return nil, err
+		}
+	}
+	if deferred == 0 {
+		return nil, readErr
+	}
+	a.logger().Info("deferring resources whose kinds are not served yet", "count", deferred)
+	return localInfos, nil
+}
+
+// setMapping sets the mapping and the client of the resource read
+// locally, and defaults the namespace like the builder does.
+func (a *Applier) setMapping(info *resource.Info, mapping *meta.RESTMapping) error {
+	client, err := a.factory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := a.ApplyOptions.Namespace
+		if info.Namespace == "" {
+			info.Namespace = namespace
+			accessor, err := meta.Accessor(info.Object)
+			if err != nil {
+				return err
+			}
+			accessor.SetNamespace(namespace)
+		} else if a.ApplyOptions.EnforceNamespace && info.Namespace != namespace {
+			return fmt.Errorf("the namespace from the provided object %q does not match the namespace %q. "+
+				"You must pass '--namespace=%s' to perform this operation.", info.Namespace, namespace, info.Namespace)
+		}
+	}
+	info.Mapping = mapping
+	info.Client = client
+	return nil
+}
+
+// splitDeferred returns the resources that can be applied now, and the
+// ones whose kinds are not served yet.
+func splitDeferred(infos []*resource.Info) ([]*resource.Info, []*resource.Info) {
+	var ready, deferred []*resource.Info
+	for _, info := range infos {
+		if info.Mapping == nil {
+			deferred = append(deferred, info)
+		} else {
+			ready = append(ready, info)
+		}
+	}
+	return ready, deferred
+}
+
+// applyDeferred waits up to the UnknownKindTimeout for the kinds of the
+// deferred resources to be served, and applies them. The namespace of
+// the deferred resources is recorded in the inventory before their
+// scope is known, so it must be set in the manifests of namespaced
+// resources.
+func (a *Applier) applyDeferred(ctx context.Context, deferred []*resource.Info) error {
+	ctx, cancel := context.WithTimeout(ctx, a.UnknownKindTimeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(crdPollInterval, func() (bool, error) {
+		// A fresh mapper sees the kinds added since the last poll.
+		mapper, err := restMapper(a.clients, a.factory, true)
+		if err != nil {
+			return false, err
+		}
+		for _, info := range deferred {
+			if info.Mapping != nil {
+				continue
+			}
+			gvk := info.Object.GetObjectKind().GroupVersionKind()
+			mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			if meta.IsNoMatchError(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			if mapping.Scope.Name() == meta.RESTScopeNameNamespace && info.Namespace == "" {
+				objMeta := infoToObjMetadata(info)
+				return false, fmt.Errorf("%s: the namespace must be set in the manifest of resources "+
+					"whose kind is not served when the apply starts", objMeta.String())
+			}
+			if err := a.setMapping(info, mapping); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("%w waiting for the kinds %s to be served", ErrTimeout,
+			strings.Join(unservedKinds(deferred), ", "))
+	}
+	if err != nil {
+		return err
+	}
+	if err := a.actuator().Apply(ctx, deferred); err != nil {
+		return errors.WrapPrefix(err, "error applying resources whose kinds were not served", 1)
+	}
+	return nil
+}
+
+// unservedKinds returns the sorted kinds of the resources
+// that still don't have a mapping.
+func unservedKinds(infos []*resource.Info) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, info := range infos {
+		if info.Mapping != nil {
+			continue
+		}
+		kind := info.Object.GetObjectKind().GroupVersionKind().String()
+		if !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// deferResources returns the resources that can be applied now, and the
+// ones whose kinds are not served yet. If some are deferred, the
+// inventory is computed from all the resources, since it is not computed
+// again when the deferred resources are applied. In a dry-run, nothing
+// makes the kinds appear, so the deferred resources are skipped.
+func (a *Applier) deferResources(infos []*resource.Info) ([]*resource.Info, []*resource.Info, error) {
+	ready, deferred := splitDeferred(infos)
+	if len(deferred) == 0 {
+		return infos, nil, nil
+	}
+	if err := a.addInventory(infos); err != nil {
+		return nil, nil, err
+	}
+	prune.SortGroupingObject(ready)
+	if a.DryRunStrategy.ClientOrServerDryRun() {
+		fmt.Fprintf(a.ApplyOptions.ErrOut, "warning: skipping %d resource(s) of the kinds %s that are not served yet\n",
+			len(deferred), strings.Join(unservedKinds(deferred), ", "))
+		return ready, nil, nil
+	}
+	return ready, deferred, nil
+}
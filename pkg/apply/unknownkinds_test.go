@@ -0,0 +1,57 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func mappedInfo(info *resource.Info) *resource.Info {
+	info.Mapping = &meta.RESTMapping{Scope: meta.RESTScopeNamespace}
+	return info
+}
+
+func TestSplitDeferred(t *testing.T) {
+	configMap := mappedInfo(customResourceInfo("v1", "ConfigMap", "cm"))
+	known := mappedInfo(customResourceInfo("example.com/v1", "Known", "known"))
+	unknown := customResourceInfo("example.com/v1", "Unknown", "unknown")
+	other := customResourceInfo("other.com/v1beta1", "Other", "other")
+
+	testCases := map[string]struct {
+		infos            []*resource.Info
+		expectedReady    []*resource.Info
+		expectedDeferred []*resource.Info
+		expectedKinds    []string
+	}{
+		"all kinds are served": {
+			infos:         []*resource.Info{configMap, known},
+			expectedReady: []*resource.Info{configMap, known},
+		},
+		"the order of the resources is kept": {
+			infos:            []*resource.Info{unknown, configMap, other, known},
+			expectedReady:    []*resource.Info{configMap, known},
+			expectedDeferred: []*resource.Info{unknown, other},
+			expectedKinds:    []string{"example.com/v1, Kind=Unknown", "other.com/v1beta1, Kind=Other"},
+		},
+		"every kind is listed once": {
+			infos: []*resource.Info{unknown, customResourceInfo("example.com/v1", "Unknown", "unknown2")},
+			expectedDeferred: []*resource.Info{unknown,
+				customResourceInfo("example.com/v1", "Unknown", "unknown2")},
+			expectedKinds: []string{"example.com/v1, Kind=Unknown"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			ready, deferred := splitDeferred(tc.infos)
+			assert.Equal(t, tc.expectedReady, ready)
+			assert.Equal(t, tc.expectedDeferred, deferred)
+			assert.Equal(t, tc.expectedKinds, unservedKinds(tc.infos))
+		})
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// NewValidator returns a new Validator.
+func NewValidator(factory util.Factory, ioStreams genericclioptions.IOStreams) *Validator {
+	return &Validator{
+		Applier: NewApplier(factory, ioStreams),
+	}
+}
+
+// Validator checks a package without applying it. The manifests are
+// read locally, and the cluster is only asked which kinds it serves and
+// which namespaces exist. It reports the manifests that can't be read,
+// resources that are defined more than once, kinds that are neither
+// served by the cluster nor defined by a CustomResourceDefinition in the
+// package, namespaces that neither exist nor are in the package, names,
+// labels and annotations the API server would reject, and a missing or
+// invalid grouping object template.
+type Validator struct {
+	// Applier reads the manifests, so they are found the
+	// same way as for an apply.
+	Applier *Applier
+}
+
+// Initialize sets up the Validator for reading the package
+// given by the paths and the flags.
+func (v *Validator) Initialize(cmd *cobra.Command, paths []string) error {
+	v.Applier.DryRunStrategy = common.DryRunClient
+	return v.Applier.Initialize(cmd, paths)
+}
+
+// SetFlags configures the command line flags needed by the Validator.
+func (v *Validator) SetFlags(cmd *cobra.Command) error {
+	return v.Applier.SetFlags(cmd)
+}
+
+// Problem is something in the package that would make an apply fail
+// or behave differently than intended.
+type Problem struct {
+	// Source is the file the problem was found in. It is
+	// empty if the problem is not in a single file.
+	Source string
+	// Object is the resource the problem was found for. It
+	// is nil if the problem is not with a single resource.
+	Object *object.ObjMetadata
+	// Message describes the problem.
+	Message string
+}
+
+func (p Problem) String() string {
+	var prefix []string
+	if p.Source != "" {
+		prefix = append(prefix, p.Source)
+	}
+	if p.Object != nil {
+		prefix = append(prefix, p.Object.String())
+	}
+	if len(prefix) == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(prefix, ": "), p.Message)
+}
+
+// ValidationError is returned by Validate if problems were
+// found in the package.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("%d problem(s) found in the package:", len(e.Problems))}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the package. It returns a ValidationError with all
+// the problems found, or another error if the package could not be
+// checked, for example because the cluster can't be reached.
+func (v *Validator) Validate() error {
+	a := v.Applier
+	defer a.remote.cleanup()
+
+	var problems []Problem
+	infos, err := a.factory.NewBuilder().
+		Local().
+		Unstructured().
+		ContinueOnError().
+		FilenameParam(false, &a.ApplyOptions.DeleteOptions.FilenameOptions).
+		Flatten().
+		Do().
+		Infos()
+	if err != nil {
+		for _, e := range flattenErrors(err) {
+			problems = append(problems, Problem{Message: e.Error()})
+		}
+	}
+	for _, info := range infos {
+		if info.Namespace == "" {
+			info.Namespace = a.ApplyOptions.Namespace
+		}
+	}
+
+	for _, d := range findDuplicates(infos) {
+		d := d
+		problems = append(problems, Problem{
+			Object:  &d.Object,
+			Message: fmt.Sprintf("defined %d times, in %s", len(d.Sources), strings.Join(d.Sources, ", ")),
+		})
+	}
+	problems = append(problems, validateGroupingObject(infos, a.InventoryID)...)
+	problems = append(problems, validateMetadata(infos, a.ApplyOptions.ServerSideApply)...)
+
+	mapper, err := restMapper(a.clients, a.factory, false)
+	if err != nil {
+		return errors.WrapPrefix(err, "error getting RESTMapper", 1)
+	}
+	kindProblems, namespaces, err := validateKinds(infos, &refreshOnceMapper{RESTMapper: mapper})
+	if err != nil {
+		return err
+	}
+	problems = append(problems, kindProblems...)
+
+	if len(namespaces) > 0 {
+		client, err := clientset(a.clients, a.factory)
+		if err != nil {
+			return errors.WrapPrefix(err, "error creating client", 1)
+		}
+		nsProblems, err := validateNamespaces(client, namespaces)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, nsProblems...)
+	}
+
+	if len(problems) > 0 {
+		return withExitCode(&ValidationError{Problems: problems}, ExitValidationError)
+	}
+	return nil
+}
+
+// flattenErrors returns the errors in the aggregate,
+// or the error itself if it is not an aggregate.
+func flattenErrors(err error) []error {
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		return utilerrors.Flatten(agg).Errors()
+	}
+	return []error{err}
+}
+
+// validateGroupingObject checks that the package has exactly one
+// grouping object template with a valid inventory id. The id given
+// on the command line takes the place of the one in the template.
+func validateGroupingObject(infos []*resource.Info, inventoryID string) []Problem {
+	if err := prune.DetectGroupingObject(infos); err != nil {
+		return []Problem{{Message: err.Error()}}
+	}
+	info, _ := prune.FindGroupingObject(infos)
+	id := inventoryID
+	if id == "" {
+		if accessor, err := meta.Accessor(info.Object); err == nil {
+			id = accessor.GetLabels()[prune.GroupingLabel]
+		}
+	}
+	objMeta := infoToObjMetadata(info)
+	if id == "" {
+		return []Problem{{Source: info.Source, Object: &objMeta, Message: "the inventory id is empty"}}
+	}
+	if errs := validation.IsValidLabelValue(id); len(errs) > 0 {
+		return []Problem{{Source: info.Source, Object: &objMeta,
+			Message: fmt.Sprintf("invalid inventory id %q: %s", id, strings.Join(errs, "; "))}}
+	}
+	return nil
+}
+
+// validateKinds checks that every kind is served by the cluster or
+// defined by a CustomResourceDefinition in the package. It returns the
+// namespaces the namespaced resources are in, except for the ones that
+// are created by the package, mapped to the resources in them.
+func validateKinds(infos []*resource.Info, mapper meta.RESTMapper) ([]Problem, map[string][]*resource.Info, error) {
+	defined := definedKinds(infos)
+	created := make(map[string]bool)
+	for _, info := range infos {
+		if info.Object.GetObjectKind().GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Namespace"}) {
+			created[info.Name] = true
+		}
+	}
+
+	var problems []Problem
+	namespaces := make(map[string][]*resource.Info)
+	for _, info := range infos {
+		gvk := info.Object.GetObjectKind().GroupVersionKind()
+		namespaced := false
+		if crd, found := defined[gvk.GroupKind()]; found {
+			scope, _, _ := unstructured.NestedString(crd.Object.(*unstructured.Unstructured).Object, "spec", "scope")
+			namespaced = scope != "Cluster"
+		} else {
+			mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				if !meta.IsNoMatchError(err) {
+					return nil, nil, errors.WrapPrefix(err, "error getting the kinds served by the cluster", 1)
+				}
+				objMeta := infoToObjMetadata(info)
+				problems = append(problems, Problem{
+					Source: info.Source,
+					Object: &objMeta,
+					Message: fmt.Sprintf("kind %s is not served by the cluster and not defined by a "+
+						"CustomResourceDefinition in the package", gvk.String()),
+				})
+				continue
+			}
+			namespaced = mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		}
+		if p := scopeProblem(info, namespaced); p != nil {
+			problems = append(problems, *p)
+			continue
+		}
+		if namespaced && info.Namespace != "" && !created[info.Namespace] {
+			namespaces[info.Namespace] = append(namespaces[info.Namespace], info)
+		}
+	}
+	return problems, namespaces, nil
+}
+
+// validateScopes checks that the namespaces of the resources match the
+// scopes of their kinds. The resources whose kinds are not served yet
+// are skipped, since their scope is not known.
+func validateScopes(infos []*resource.Info) []Problem {
+	var problems []Problem
+	for _, info := range infos {
+		if info.Mapping == nil {
+			continue
+		}
+		if p := scopeProblem(info, info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace); p != nil {
+			problems = append(problems, *p)
+		}
+	}
+	return problems
+}
+
+// scopeProblem returns a problem if the manifest of a cluster-scoped
+// resource sets a namespace, or if a namespaced resource has no
+// namespace and there is no default.
+func scopeProblem(info *resource.Info, namespaced bool) *Problem {
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return nil
+	}
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	var message string
+	switch {
+	case !namespaced && accessor.GetNamespace() != "":
+		message = fmt.Sprintf("namespace %s is set, but kind %s is cluster-scoped", accessor.GetNamespace(),
+			gvk.Kind)
+	case namespaced && info.Namespace == "":
+		message = fmt.Sprintf("namespace is not set, but kind %s is namespaced; set it in the manifest "+
+			"or pass --namespace", gvk.Kind)
+	default:
+		return nil
+	}
+	objMeta := infoToObjMetadata(info)
+	return &Problem{Source: info.Source, Object: &objMeta, Message: message}
+}
+
+// validateNamespaces checks that the namespaces exist in the cluster,
+// and are not being deleted.
+func validateNamespaces(client kubernetes.Interface, namespaces map[string][]*resource.Info) ([]Problem, error) {
+	var names []string
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []Problem
+	for _, name := range names {
+		ns, err := client.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+		var message string
+		switch {
+		case err == nil && isTerminating(ns):
+			message = fmt.Sprintf("namespace %s is being deleted", name)
+		case err == nil:
+			continue
+		case apierrors.IsNotFound(err):
+			message = fmt.Sprintf("namespace %s does not exist and is not in the package", name)
+		default:
+			return nil, errors.WrapPrefix(err, fmt.Sprintf("error getting namespace %s", name), 1)
+		}
+		for _, info := range namespaces[name] {
+			objMeta := infoToObjMetadata(info)
+			problems = append(problems, Problem{
+				Source:  info.Source,
+				Object:  &objMeta,
+				Message: message,
+			})
+		}
+	}
+	return problems, nil
+}
+
+// annotationSizeLimit is the maximum total size of the
+// annotations of a resource accepted by the API server.
+const annotationSizeLimit = 256 * (1 << 10)
+
+// pathSegmentNameKinds are the kinds whose names only need to be
+// usable in the path of a request. The names of the other kinds must
+// be DNS subdomains, or DNS labels for the kinds in dnsLabelNameKinds.
+var pathSegmentNameKinds = map[schema.GroupKind]bool{
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        true,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: true,
+}
+
+var dnsLabelNameKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}: true,
+	{Kind: "Service"}:   true,
+}
+
+// validateMetadata checks the names, namespaces, labels and annotations
+// of the resources against the constraints of the API server, so all
+// the violations are reported together before anything is applied. With
+// client-side apply, kubectl records the whole resource in an annotation,
+// so its size is included in the size of the annotations.
+func validateMetadata(infos []*resource.Info, serverSideApply bool) []Problem {
+	var problems []Problem
+	for _, info := range infos {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			continue
+		}
+		objMeta := infoToObjMetadata(info)
+		report := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{
+				Source:  info.Source,
+				Object:  &objMeta,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+		name := accessor.GetName()
+		var errs []string
+		switch gk := objMeta.GroupKind; {
+		case pathSegmentNameKinds[gk]:
+			errs = path.IsValidPathSegmentName(name)
+			if len(name) > validation.DNS1123SubdomainMaxLength {
+				errs = append(errs, validation.MaxLenError(validation.DNS1123SubdomainMaxLength))
+			}
+		case dnsLabelNameKinds[gk]:
+			errs = validation.IsDNS1123Label(name)
+		default:
+			errs = validation.IsDNS1123Subdomain(name)
+		}
+		if name == "" {
+			errs = []string{"must not be empty"}
+		}
+		if len(errs) > 0 {
+			report("invalid name %q: %s", name, strings.Join(errs, "; "))
+		}
+		if ns := accessor.GetNamespace(); ns != "" {
+			if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+				report("invalid namespace %q: %s", ns, strings.Join(errs, "; "))
+			}
+		}
+		for _, e := range metav1validation.ValidateLabels(accessor.GetLabels(), field.NewPath("metadata", "labels")) {
+			report("%s", e.Error())
+		}
+		size := 0
+		for k, v := range accessor.GetAnnotations() {
+			if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+				report("invalid annotation key %q: %s", k, strings.Join(errs, "; "))
+			}
+			size += len(k) + len(v)
+		}
+		if !serverSideApply && !prune.IsGroupingObject(info.Object) {
+			if data, err := json.Marshal(info.Object); err == nil {
+				size += len(data)
+			}
+		}
+		if size > annotationSizeLimit {
+			message := fmt.Sprintf("the annotations are %d bytes, more than the limit of %d bytes", size,
+				annotationSizeLimit)
+			if !serverSideApply {
+				message += ", including the copy of the resource recorded by client-side apply; " +
+					"use server-side apply instead"
+			}
+			report("%s", message)
+		}
+	}
+	return problems
+}
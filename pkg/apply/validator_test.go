@@ -0,0 +1,376 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+)
+
+func validatorInfo(apiVersion, kind, namespace, name, source string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return &resource.Info{Namespace: namespace, Name: name, Source: source, Object: obj}
+}
+
+func groupingInfo(id string) *resource.Info {
+	info := validatorInfo("v1", "ConfigMap", "default", "inventory", "inventory.yaml")
+	info.Object.(*unstructured.Unstructured).SetLabels(map[string]string{prune.GroupingLabel: id})
+	return info
+}
+
+func problemStrings(problems []Problem) []string {
+	var s []string
+	for _, p := range problems {
+		s = append(s, p.String())
+	}
+	return s
+}
+
+func TestValidateGroupingObject(t *testing.T) {
+	testCases := map[string]struct {
+		infos       []*resource.Info
+		inventoryID string
+		expected    []string
+	}{
+		"valid": {
+			infos: []*resource.Info{groupingInfo("app")},
+		},
+		"missing": {
+			infos: []*resource.Info{validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml")},
+			expected: []string{"no grouping object found in the package; add a ConfigMap with the " +
+				prune.GroupingLabel + " label, for example with the init command"},
+		},
+		"invalid id": {
+			infos: []*resource.Info{groupingInfo("not/valid")},
+			expected: []string{"inventory.yaml: default_inventory__ConfigMap: invalid inventory id \"not/valid\": " +
+				"a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', " +
+				"and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or " +
+				"'12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"},
+		},
+		"valid override": {
+			infos:       []*resource.Info{groupingInfo("not/valid")},
+			inventoryID: "app",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			problems := validateGroupingObject(tc.infos, tc.inventoryID)
+			assert.Equal(t, tc.expected, problemStrings(problems))
+		})
+	}
+}
+
+func TestValidateKinds(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1",
+		Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1",
+		Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+
+	clusterCRD := crdInfo("clusterwidgets.example.com", "example.com", "ClusterWidget")
+	clusterCRD.Source = "crd.yaml"
+	_ = unstructured.SetNestedField(clusterCRD.Object.(*unstructured.Unstructured).Object, "Cluster", "spec", "scope")
+	namespacedCRD := crdInfo("widgets.example.com", "example.com", "Widget")
+	namespacedCRD.Source = "crd.yaml"
+
+	infos := []*resource.Info{
+		clusterCRD,
+		namespacedCRD,
+		validatorInfo("v1", "Namespace", "", "created", "ns.yaml"),
+		validatorInfo("v1", "ConfigMap", "created", "a", "a.yaml"),
+		validatorInfo("v1", "ConfigMap", "existing", "b", "b.yaml"),
+		validatorInfo("rbac.authorization.k8s.io/v1", "ClusterRole", "", "role", "role.yaml"),
+		validatorInfo("example.com/v1", "Widget", "missing", "w", "w.yaml"),
+		validatorInfo("example.com/v1", "ClusterWidget", "", "cw", "cw.yaml"),
+		validatorInfo("example.com/v1", "Gadget", "existing", "g", "g.yaml"),
+	}
+	problems, namespaces, err := validateKinds(infos, mapper)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"g.yaml: existing_g_example.com_Gadget: kind example.com/v1, Kind=Gadget is not served by the cluster " +
+			"and not defined by a CustomResourceDefinition in the package",
+	}, problemStrings(problems))
+	names := make(map[string][]string)
+	for ns, nsInfos := range namespaces {
+		for _, info := range nsInfos {
+			names[ns] = append(names[ns], info.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"existing": {"b"},
+		"missing":  {"w"},
+	}, names)
+}
+
+func TestValidateNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "existing"}},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "terminating"},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+	)
+	problems, err := validateNamespaces(client, map[string][]*resource.Info{
+		"existing":    {validatorInfo("v1", "ConfigMap", "existing", "a", "a.yaml")},
+		"terminating": {validatorInfo("v1", "ConfigMap", "terminating", "d", "d.yaml")},
+		"missing": {
+			validatorInfo("v1", "ConfigMap", "missing", "b", "b.yaml"),
+			validatorInfo("v1", "ConfigMap", "missing", "c", "c.yaml"),
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"b.yaml: missing_b__ConfigMap: namespace missing does not exist and is not in the package",
+		"c.yaml: missing_c__ConfigMap: namespace missing does not exist and is not in the package",
+		"d.yaml: terminating_d__ConfigMap: namespace terminating is being deleted",
+	}, problemStrings(problems))
+}
+
+func TestValidationError(t *testing.T) {
+	err := withExitCode(&ValidationError{Problems: []Problem{
+		{Message: "first"},
+		{Source: "a.yaml", Message: "second"},
+	}}, ExitValidationError)
+	assert.Equal(t, "2 problem(s) found in the package:\n  first\n  a.yaml: second", err.Error())
+	assert.Equal(t, ExitValidationError, ExitCode(err))
+}
+
+func TestValidateMetadata(t *testing.T) {
+	withLabels := func(info *resource.Info, labels map[string]string) *resource.Info {
+		info.Object.(*unstructured.Unstructured).SetLabels(labels)
+		return info
+	}
+	withAnnotations := func(info *resource.Info, annotations map[string]string) *resource.Info {
+		info.Object.(*unstructured.Unstructured).SetAnnotations(annotations)
+		return info
+	}
+	large := strings.Repeat("x", annotationSizeLimit-1000)
+
+	testCases := map[string]struct {
+		infos           []*resource.Info
+		serverSideApply bool
+		// expected are the prefixes of the problems.
+		expected []string
+	}{
+		"valid": {
+			infos: []*resource.Info{
+				validatorInfo("v1", "ConfigMap", "default", "app.config", "cm.yaml"),
+				validatorInfo("v1", "Namespace", "", "default", "ns.yaml"),
+				validatorInfo("rbac.authorization.k8s.io/v1", "ClusterRole", "", "system:viewer", "role.yaml"),
+				withLabels(validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml"),
+					map[string]string{"example.com/app": "my_app", "empty": ""}),
+			},
+		},
+		"invalid names": {
+			infos: []*resource.Info{
+				validatorInfo("v1", "ConfigMap", "default", "Upper", "a.yaml"),
+				validatorInfo("v1", "Service", "default", "has.dot", "b.yaml"),
+				validatorInfo("rbac.authorization.k8s.io/v1", "Role", "default", "a/b", "c.yaml"),
+				validatorInfo("v1", "ConfigMap", "Bad_Namespace", "cm", "d.yaml"),
+			},
+			expected: []string{
+				"a.yaml: default_Upper__ConfigMap: invalid name \"Upper\": ",
+				"b.yaml: default_has.dot__Service: invalid name \"has.dot\": ",
+				"c.yaml: .1_default_a.2Fb_rbac.2Eauthorization.2Ek8s.2Eio_Role: invalid name \"a/b\": ",
+				"d.yaml: .1_Bad.5FNamespace_cm__ConfigMap: invalid namespace \"Bad_Namespace\": ",
+			},
+		},
+		"all violations of a resource": {
+			infos: []*resource.Info{
+				withAnnotations(withLabels(validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml"),
+					map[string]string{"app": "not valid", "bad key": "x"}),
+					map[string]string{"not/valid/key": "x"}),
+			},
+			expected: []string{
+				"cm.yaml: default_cm__ConfigMap: metadata.labels: Invalid value: \"bad key\"",
+				"cm.yaml: default_cm__ConfigMap: metadata.labels: Invalid value: \"not valid\"",
+				"cm.yaml: default_cm__ConfigMap: invalid annotation key \"not/valid/key\": ",
+			},
+		},
+		"annotations too large with client-side apply": {
+			infos: []*resource.Info{
+				withAnnotations(validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml"),
+					map[string]string{"data": large}),
+			},
+			expected: []string{
+				"cm.yaml: default_cm__ConfigMap: the annotations are",
+			},
+		},
+		"annotations fit with server-side apply": {
+			infos: []*resource.Info{
+				withAnnotations(validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml"),
+					map[string]string{"data": large}),
+			},
+			serverSideApply: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			problems := problemStrings(validateMetadata(tc.infos, tc.serverSideApply))
+			sort.Strings(problems)
+			expected := append([]string{}, tc.expected...)
+			sort.Strings(expected)
+			if !assert.Len(t, problems, len(expected), "%v", problems) {
+				return
+			}
+			for i := range expected {
+				assert.True(t, strings.HasPrefix(problems[i], expected[i]), "%q does not start with %q",
+					problems[i], expected[i])
+			}
+		})
+	}
+}
+
+func TestRunReportsAllInvalidMetadata(t *testing.T) {
+	cluster, err := fakecluster.New()
+	require.NoError(t, err)
+	applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+		WithNoWait())
+	require.NoError(t, err)
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", "-"))
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	objs := []*unstructured.Unstructured{
+		configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+		configMap("valid", nil),
+		configMap("Invalid", nil),
+		configMap("labels", map[string]string{"app": "not valid"}),
+	}
+	var errs []error
+	for e := range applier.RunObjects(context.Background(), objs) {
+		switch e.Type {
+		case event.ErrorType:
+			errs = append(errs, e.ErrorEvent.Err)
+		case event.ApplyType:
+			t.Errorf("unexpected apply event %v", e.ApplyEvent)
+		}
+	}
+	require.Len(t, errs, 1)
+	assert.Equal(t, ExitValidationError, ExitCode(errs[0]))
+	var validationErr *ValidationError
+	require.True(t, errors.As(errs[0], &validationErr))
+	assert.Len(t, validationErr.Problems, 2)
+	// Nothing is applied, not even the valid resources.
+	assert.Empty(t, cluster.Objects())
+}
+
+func TestValidateScopes(t *testing.T) {
+	namespaced := &meta.RESTMapping{Scope: meta.RESTScopeNamespace}
+	cluster := &meta.RESTMapping{Scope: meta.RESTScopeRoot}
+	withMapping := func(info *resource.Info, mapping *meta.RESTMapping) *resource.Info {
+		info.Mapping = mapping
+		return info
+	}
+
+	problems := validateScopes([]*resource.Info{
+		withMapping(validatorInfo("v1", "ConfigMap", "default", "cm", "cm.yaml"), namespaced),
+		withMapping(validatorInfo("v1", "ConfigMap", "", "missing", "missing.yaml"), namespaced),
+		withMapping(validatorInfo("v1", "Namespace", "", "ns", "ns.yaml"), cluster),
+		withMapping(validatorInfo("v1", "Namespace", "default", "scoped", "scoped.yaml"), cluster),
+		// The scope of kinds that are not served yet is unknown.
+		validatorInfo("example.com/v1", "Widget", "", "w", "w.yaml"),
+	})
+	assert.Equal(t, []string{
+		"missing.yaml: _missing__ConfigMap: namespace is not set, but kind ConfigMap is namespaced; " +
+			"set it in the manifest or pass --namespace",
+		"scoped.yaml: default_scoped__Namespace: namespace default is set, but kind Namespace is cluster-scoped",
+	}, problemStrings(problems))
+}
+
+func TestRunRequireExplicitNamespace(t *testing.T) {
+	testCases := map[string]struct {
+		namespaceFlag    string
+		requireExplicit  bool
+		expectedProblems int
+		expectedNs       string
+	}{
+		"defaults to the namespace of the context": {
+			expectedNs: fakecluster.DefaultNamespace,
+		},
+		"no default": {
+			requireExplicit:  true,
+			expectedProblems: 2,
+		},
+		"defaults to the namespace flag": {
+			namespaceFlag:   "flag",
+			requireExplicit: true,
+			expectedNs:      "flag",
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			cluster, err := fakecluster.New()
+			require.NoError(t, err)
+			cluster.NamespaceFlag = tc.namespaceFlag
+			applier, err := NewApplierWithOptions(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard(),
+				WithNoWait())
+			require.NoError(t, err)
+			applier.RequireExplicitNamespace = tc.requireExplicit
+			cmd := &cobra.Command{}
+			require.NoError(t, applier.SetFlags(cmd))
+			cmdutil.AddValidateFlags(cmd)
+			cmdutil.AddServerSideApplyFlags(cmd)
+			require.NoError(t, cmd.Flags().Set("filename", "-"))
+			require.NoError(t, applier.Initialize(cmd, nil))
+
+			objs := []*unstructured.Unstructured{
+				configMap("inventory", map[string]string{prune.GroupingLabel: "test"}),
+				configMap("cm", nil),
+			}
+			var errs []error
+			for e := range applier.RunObjects(context.Background(), objs) {
+				if e.Type == event.ErrorType {
+					errs = append(errs, e.ErrorEvent.Err)
+				}
+			}
+			gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+			if tc.expectedProblems > 0 {
+				require.Len(t, errs, 1)
+				var validationErr *ValidationError
+				require.True(t, errors.As(errs[0], &validationErr))
+				assert.Len(t, validationErr.Problems, tc.expectedProblems)
+				assert.Empty(t, cluster.Objects())
+				return
+			}
+			assert.Empty(t, errs)
+			assert.NotNil(t, cluster.Get(gvk, tc.expectedNs, "cm"))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package common contains types that are shared between the
+// apply and the prune packages.
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DryRunStrategy determines if changes are sent to the cluster,
+// and if not, how they are evaluated.
+type DryRunStrategy int
+
+const (
+	// DryRunNone means the changes are made in the cluster.
+	DryRunNone DryRunStrategy = iota
+	// DryRunClient means no requests that change resources are sent
+	// to the cluster. The outcome is computed on the client.
+	DryRunClient
+	// DryRunServer means the requests are sent to the cluster with
+	// the dry-run option, so they go through validation, defaulting
+	// and admission without being persisted.
+	DryRunServer
+)
+
+// ClientDryRun returns true if changes are only evaluated on the client.
+func (s DryRunStrategy) ClientDryRun() bool {
+	return s == DryRunClient
+}
+
+// ServerDryRun returns true if changes are sent to the
+// cluster with the dry-run option.
+func (s DryRunStrategy) ServerDryRun() bool {
+	return s == DryRunServer
+}
+
+// ClientOrServerDryRun returns true if nothing is changed in the cluster.
+func (s DryRunStrategy) ClientOrServerDryRun() bool {
+	return s == DryRunClient || s == DryRunServer
+}
+
+func (s DryRunStrategy) String() string {
+	switch s {
+	case DryRunNone:
+		return "none"
+	case DryRunClient:
+		return "client"
+	case DryRunServer:
+		return "server"
+	default:
+		return fmt.Sprintf("DryRunStrategy(%d)", int(s))
+	}
+}
+
+// ParseDryRunStrategy returns the DryRunStrategy with the given name,
+// which must be one of none, client or server. For compatibility with
+// the boolean flag, true means client and false means none.
+func ParseDryRunStrategy(name string) (DryRunStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none", "false":
+		return DryRunNone, nil
+	case "client", "true":
+		return DryRunClient, nil
+	case "server":
+		return DryRunServer, nil
+	default:
+		return DryRunNone, fmt.Errorf("invalid dry-run value %q, must be one of none, client or server", name)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunStrategy(t *testing.T) {
+	testCases := map[string]struct {
+		strategy     DryRunStrategy
+		client       bool
+		server       bool
+		clientServer bool
+	}{
+		"none": {
+			strategy: DryRunNone,
+		},
+		"client": {
+			strategy:     DryRunClient,
+			client:       true,
+			clientServer: true,
+		},
+		"server": {
+			strategy:     DryRunServer,
+			server:       true,
+			clientServer: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.client, tc.strategy.ClientDryRun())
+			assert.Equal(t, tc.server, tc.strategy.ServerDryRun())
+			assert.Equal(t, tc.clientServer, tc.strategy.ClientOrServerDryRun())
+			assert.Equal(t, tn, tc.strategy.String())
+		})
+	}
+}
+
+func TestParseDryRunStrategy(t *testing.T) {
+	testCases := map[string]struct {
+		name        string
+		expected    DryRunStrategy
+		expectedErr bool
+	}{
+		"none":              {name: "none", expected: DryRunNone},
+		"client":            {name: "client", expected: DryRunClient},
+		"server":            {name: "Server", expected: DryRunServer},
+		"true means client": {name: "true", expected: DryRunClient},
+		"false means none":  {name: "false", expected: DryRunNone},
+		"invalid":           {name: "all", expectedErr: true},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			actual, err := ParseDryRunStrategy(tc.name)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
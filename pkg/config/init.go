@@ -0,0 +1,175 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package config contains the code for setting up a package
+// directory so it can be applied and pruned.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// GroupingTemplateFile is the name of the file the grouping
+	// object template is written to.
+	GroupingTemplateFile = "grouping-template.yaml"
+	// GroupingObjectName is the name of the generated grouping object.
+	// A hash of the inventory is added as a suffix on every apply.
+	GroupingObjectName = "inventory"
+)
+
+const groupingTemplateHeader = `# NOTE: auto-generated. Do NOT change the inventory-id label,
+# since it is used to find the previously applied resources.
+#
+# This ConfigMap is the template for the grouping object, which
+# keeps track of the applied resources so they can be pruned.
+`
+
+// InitOptions contains the fields needed to generate the grouping
+// object template for a package directory.
+type InitOptions struct {
+	ioStreams genericclioptions.IOStreams
+
+	// Dir is the package directory the template is written to.
+	Dir string
+	// Namespace is the namespace of the grouping object. It is
+	// left out of the template if it is empty, so the namespace
+	// is chosen when the package is applied.
+	Namespace string
+	// InventoryID is the value of the grouping label. A random
+	// id is generated if it is empty.
+	InventoryID string
+}
+
+// NewInitOptions returns a new InitOptions.
+func NewInitOptions(ioStreams genericclioptions.IOStreams) *InitOptions {
+	return &InitOptions{
+		ioStreams: ioStreams,
+	}
+}
+
+// Complete fills in the package directory from the arguments, and
+// generates the inventory id if none was provided.
+func (i *InitOptions) Complete(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("need one package directory argument; have %d", len(args))
+	}
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	i.Dir = dir
+	i.InventoryID = strings.TrimSpace(i.InventoryID)
+	if i.InventoryID == "" {
+		i.InventoryID = string(uuid.NewUUID())
+	}
+	return nil
+}
+
+// Run writes the grouping object template to the package directory.
+// It fails if the directory already contains a grouping object, since
+// a package must only have one.
+func (i *InitOptions) Run() error {
+	found, err := findGroupingObjectFile(i.Dir)
+	if err != nil {
+		return err
+	}
+	if found != "" {
+		return fmt.Errorf("grouping object already exists in %s", found)
+	}
+	content, err := i.groupingTemplate()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(i.Dir, GroupingTemplateFile)
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(i.ioStreams.Out, "initialized: %s\n", path)
+	return nil
+}
+
+// groupingTemplate returns the YAML for the grouping object template.
+func (i *InitOptions) groupingTemplate() ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName(GroupingObjectName)
+	obj.SetNamespace(i.Namespace)
+	obj.SetLabels(map[string]string{
+		prune.GroupingLabel: i.InventoryID,
+	})
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(groupingTemplateHeader), content...), nil
+}
+
+// findGroupingObjectFile returns the path of the first manifest in the
+// directory that contains a grouping object, or an empty string if
+// there is none. Subdirectories are not searched.
+func findGroupingObjectFile(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		found, err := containsGroupingObject(path)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// containsGroupingObject returns true if any of the objects in
+// the manifest is a grouping object.
+func containsGroupingObject(path string) (bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if prune.IsGroupingTemplate(obj) {
+			return true, nil
+		}
+	}
+}
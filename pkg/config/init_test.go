@@ -0,0 +1,97 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestInitOptions(t *testing.T) {
+	testCases := map[string]struct {
+		files       map[string]string
+		namespace   string
+		inventoryID string
+		expected    string
+		expectedErr bool
+	}{
+		"template with namespace and inventory id": {
+			files: map[string]string{
+				"deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: foo\n",
+			},
+			namespace:   "test",
+			inventoryID: "test-app",
+			expected: groupingTemplateHeader + `apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: test-app
+  name: inventory
+  namespace: test
+`,
+		},
+		"template without namespace": {
+			inventoryID: "test-app",
+			expected: groupingTemplateHeader + `apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: test-app
+  name: inventory
+`,
+		},
+		"grouping object already exists": {
+			files: map[string]string{
+				"grouping.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: inventory-map\n" +
+					"  labels:\n    cli-utils.sigs.k8s.io/inventory-id: hello-app\n",
+			},
+			inventoryID: "test-app",
+			expectedErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "init-test")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			out := &bytes.Buffer{}
+			initOptions := NewInitOptions(genericclioptions.IOStreams{Out: out})
+			initOptions.Namespace = tc.namespace
+			initOptions.InventoryID = tc.inventoryID
+			assert.NoError(t, initOptions.Complete([]string{dir}))
+			err = initOptions.Run()
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, GroupingTemplateFile))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(content))
+		})
+	}
+}
+
+func TestInitOptionsGeneratesInventoryID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	initOptions := NewInitOptions(genericclioptions.IOStreams{})
+	assert.NoError(t, initOptions.Complete([]string{dir}))
+	assert.NotEmpty(t, initOptions.InventoryID)
+}
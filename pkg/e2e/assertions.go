@@ -0,0 +1,160 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/prune"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// StatusPollInterval is how often WaitForStatus polls the resources.
+var StatusPollInterval = time.Second
+
+var configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// AssertNoErrors fails the test if there are error events.
+func (f *Framework) AssertNoErrors(events []event.Event) {
+	f.t.Helper()
+	for _, e := range events {
+		if e.Type == event.ErrorType {
+			f.t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+		}
+	}
+}
+
+// AssertExists reports an error if the resource doesn't exist.
+func (f *Framework) AssertExists(id object.ObjMetadata) {
+	f.t.Helper()
+	if f.Get(id) == nil {
+		f.t.Errorf("expected %s to exist", format(id))
+	}
+}
+
+// AssertNotExists reports an error if the resource exists.
+func (f *Framework) AssertNotExists(id object.ObjMetadata) {
+	f.t.Helper()
+	if f.Get(id) != nil {
+		f.t.Errorf("expected %s not to exist", format(id))
+	}
+}
+
+// Inventory returns the inventory of the package, read from its
+// grouping object in the namespace of the test. It is empty if
+// there is no grouping object.
+func (f *Framework) Inventory(p *Package) []object.ObjMetadata {
+	f.t.Helper()
+	list, err := f.DynamicClient.Resource(configMapGVR).Namespace(f.Namespace).List(metav1.ListOptions{
+		LabelSelector: prune.GroupingLabel + "=" + p.InventoryID,
+	})
+	if err != nil {
+		f.t.Fatalf("error listing grouping objects: %v", err)
+	}
+	if len(list.Items) > 1 {
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		f.t.Fatalf("expected one grouping object for inventory %s, found %s", p.InventoryID,
+			strings.Join(names, ", "))
+	}
+	if len(list.Items) == 0 {
+		return nil
+	}
+	data, _, err := unstructured.NestedStringMap(list.Items[0].Object, "data")
+	if err != nil {
+		f.t.Fatalf("error reading inventory: %v", err)
+	}
+	var inventory []object.ObjMetadata
+	for key := range data {
+		id, err := object.ParseObjMetadata(key)
+		if err != nil {
+			f.t.Fatalf("error reading inventory: %v", err)
+		}
+		inventory = append(inventory, *id)
+	}
+	sortIDs(inventory)
+	return inventory
+}
+
+// AssertInventory reports an error if the inventory of the package
+// doesn't contain exactly the given resources.
+func (f *Framework) AssertInventory(p *Package, expected ...object.ObjMetadata) {
+	f.t.Helper()
+	actual := f.Inventory(p)
+	expected = append([]object.ObjMetadata{}, expected...)
+	sortIDs(expected)
+	if idsString(actual) != idsString(expected) {
+		f.t.Errorf("unexpected inventory for %s:\nexpected: %s\nactual:   %s", p.InventoryID,
+			idsString(expected), idsString(actual))
+	}
+}
+
+// AssertStatus reports an error if the resource doesn't
+// currently have the status.
+func (f *Framework) AssertStatus(id object.ObjMetadata, expected status.Status) {
+	f.t.Helper()
+	actual, message := f.status(id)
+	if actual != expected {
+		f.t.Errorf("expected %s to be %s, but it is %s: %s", format(id), expected, actual, message)
+	}
+}
+
+// WaitForStatus waits up to the timeout for all the resources to
+// have the status, and fails the test if they don't. A resource
+// that doesn't exist has the NotFound status.
+func (f *Framework) WaitForStatus(timeout time.Duration, expected status.Status, ids ...object.ObjMetadata) {
+	f.t.Helper()
+	var pending []string
+	err := wait.PollImmediate(StatusPollInterval, timeout, func() (bool, error) {
+		pending = nil
+		for _, id := range ids {
+			if actual, message := f.status(id); actual != expected {
+				pending = append(pending, format(id)+" is "+actual.String()+": "+message)
+			}
+		}
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		f.t.Fatalf("timed out waiting for resources to be %s:\n%s", expected, strings.Join(pending, "\n"))
+	}
+}
+
+// status returns the status of the resource, and the message
+// that explains it.
+func (f *Framework) status(id object.ObjMetadata) (status.Status, string) {
+	f.t.Helper()
+	obj := f.Get(id)
+	if obj == nil {
+		return status.NotFoundStatus, "resource not found"
+	}
+	result, err := status.Compute(obj)
+	if err != nil {
+		return status.UnknownStatus, err.Error()
+	}
+	return result.Status, result.Message
+}
+
+func sortIDs(ids []object.ObjMetadata) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+}
+
+func idsString(ids []object.ObjMetadata) string {
+	var s []string
+	for _, id := range ids {
+		s = append(s, format(id))
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
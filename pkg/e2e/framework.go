@@ -0,0 +1,241 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package e2e helps with end-to-end tests of pipelines built on the
+// applier and destroyer. A Framework creates a namespace for every
+// test, writes packages of manifests, applies and destroys them, and
+// checks the inventory and the status of the resources.
+//
+// The tests run against the cluster in the kubeconfig, for example a
+// kind cluster, and are skipped if it can't be reached:
+//
+//	func TestPipeline(t *testing.T) {
+//		f := e2e.New(t, e2e.ClusterFromKubeconfig(t))
+//		defer f.Teardown()
+//
+//		pkg := f.NewPackage("pipeline")
+//		pkg.Set("cm.yaml", configMap)
+//		f.AssertNoErrors(f.Apply(pkg, nil))
+//		f.AssertInventory(pkg, id)
+//	}
+//
+// They can also run against the in-memory cluster from the
+// fakecluster package.
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// NamespacePrefix is the prefix of the names of the
+// namespaces created for the tests.
+const NamespacePrefix = "cli-utils-e2e-"
+
+var namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// Framework runs the tests in a namespace of their own. It must be
+// torn down at the end of the test, which deletes the namespace and
+// the packages.
+type Framework struct {
+	t      testing.TB
+	getter genericclioptions.RESTClientGetter
+	dirs   []string
+
+	// Namespace is the namespace created for the test. It is the
+	// default namespace of the resources that are applied.
+	Namespace string
+	// DynamicClient is a client for the cluster.
+	DynamicClient dynamic.Interface
+	// IOStreams are passed to the applier and the destroyer.
+	// The output is discarded by default.
+	IOStreams genericclioptions.IOStreams
+}
+
+// ClusterFromKubeconfig returns the getter for the current context
+// of the kubeconfig, which is read like kubectl does. The test is
+// skipped if the cluster can't be reached.
+func ClusterFromKubeconfig(t testing.TB) genericclioptions.RESTClientGetter {
+	t.Helper()
+	getter := genericclioptions.NewConfigFlags(true)
+	client, err := getter.ToDiscoveryClient()
+	if err == nil {
+		_, err = client.ServerVersion()
+	}
+	if err != nil {
+		t.Skipf("skipping end-to-end test, the cluster can't be reached: %v", err)
+	}
+	return getter
+}
+
+// New creates the namespace for a test in the cluster of the getter.
+func New(t testing.TB, getter genericclioptions.RESTClientGetter) *Framework {
+	t.Helper()
+	config, err := getter.ToRESTConfig()
+	if err != nil {
+		t.Fatalf("error getting the REST config: %v", err)
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("error creating the dynamic client: %v", err)
+	}
+	f := &Framework{
+		t:             t,
+		getter:        getter,
+		Namespace:     NamespacePrefix + rand.String(6),
+		DynamicClient: client,
+		IOStreams:     genericclioptions.NewTestIOStreamsDiscard(),
+	}
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(f.Namespace)
+	if _, err := client.Resource(namespaceGVR).Create(ns, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("error creating namespace %s: %v", f.Namespace, err)
+	}
+	return f
+}
+
+// Teardown deletes the namespace of the test and the packages. The
+// resources the test created outside the namespace must be destroyed
+// by the test.
+func (f *Framework) Teardown() {
+	f.t.Helper()
+	for _, dir := range f.dirs {
+		_ = os.RemoveAll(dir)
+	}
+	err := f.DynamicClient.Resource(namespaceGVR).Delete(f.Namespace, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		f.t.Errorf("error deleting namespace %s: %v", f.Namespace, err)
+	}
+}
+
+// Factory returns a factory for the cluster, with the namespace
+// of the test as the default namespace.
+func (f *Framework) Factory() util.Factory {
+	return util.NewFactory(&namespacedGetter{RESTClientGetter: f.getter, namespace: f.Namespace})
+}
+
+// ID returns the identifier of a resource, for the assertions.
+// The kind is given like KIND[.GROUP], for example Deployment.apps.
+func ID(kind, namespace, name string) object.ObjMetadata {
+	return object.ObjMetadata{
+		GroupKind: schema.ParseGroupKind(kind),
+		Namespace: namespace,
+		Name:      name,
+	}
+}
+
+// Get returns a resource from the cluster, or nil if it doesn't exist.
+func (f *Framework) Get(id object.ObjMetadata) *unstructured.Unstructured {
+	f.t.Helper()
+	obj, err := f.get(id)
+	if err != nil {
+		f.t.Fatalf("error getting %s: %v", format(id), err)
+	}
+	return obj
+}
+
+func (f *Framework) get(id object.ObjMetadata) (*unstructured.Unstructured, error) {
+	// A new mapper finds the kinds of CRDs created by the test.
+	mapper, err := f.getter.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapper.RESTMapping(id.GroupKind)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var client dynamic.ResourceInterface = f.DynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = f.DynamicClient.Resource(mapping.Resource).Namespace(id.Namespace)
+	}
+	obj, err := client.Get(id.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return obj, err
+}
+
+// NewPackage returns an empty package with the grouping object
+// template for the inventory id.
+func (f *Framework) NewPackage(inventoryID string) *Package {
+	f.t.Helper()
+	dir, err := ioutil.TempDir("", "cli-utils-e2e")
+	if err != nil {
+		f.t.Fatalf("error creating package directory: %v", err)
+	}
+	f.dirs = append(f.dirs, dir)
+	p := &Package{t: f.t, Dir: dir, InventoryID: inventoryID}
+	p.Set(groupingTemplateFile, fmt.Sprintf(groupingTemplate, inventoryID))
+	return p
+}
+
+// format returns the identifier of a resource in messages.
+func format(id object.ObjMetadata) string {
+	var sb strings.Builder
+	sb.WriteString(id.GroupKind.String())
+	sb.WriteString(" ")
+	if id.Namespace != "" {
+		sb.WriteString(id.Namespace + "/")
+	}
+	sb.WriteString(id.Name)
+	return sb.String()
+}
+
+// namespacedGetter makes the namespace of the test
+// the default namespace.
+type namespacedGetter struct {
+	genericclioptions.RESTClientGetter
+	namespace string
+}
+
+func (g *namespacedGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &namespacedClientConfig{
+		config:    g.RESTClientGetter.ToRawKubeConfigLoader(),
+		namespace: g.namespace,
+	}
+}
+
+type namespacedClientConfig struct {
+	config    clientcmd.ClientConfig
+	namespace string
+}
+
+func (c *namespacedClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return c.config.RawConfig()
+}
+
+func (c *namespacedClientConfig) ClientConfig() (*rest.Config, error) {
+	return c.config.ClientConfig()
+}
+
+func (c *namespacedClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return c.config.ConfigAccess()
+}
+
+// Namespace returns the namespace of the test. It is not reported as
+// overridden, so the resources can set a different namespace.
+func (c *namespacedClientConfig) Namespace() (string, bool, error) {
+	return c.namespace, false, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/cli-utils/pkg/fakecluster"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+const configMapA = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: a
+`
+
+const configMapB = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`
+
+func TestFramework(t *testing.T) {
+	cluster, err := fakecluster.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(t, cluster.RESTClientGetter())
+	defer f.Teardown()
+	if !strings.HasPrefix(f.Namespace, NamespacePrefix) {
+		t.Errorf("unexpected namespace %s", f.Namespace)
+	}
+	f.AssertExists(ID("Namespace", "", f.Namespace))
+
+	a := ID("ConfigMap", f.Namespace, "a")
+	b := ID("ConfigMap", f.Namespace, "b")
+	pkg := f.NewPackage("test")
+	pkg.Set("a.yaml", configMapA)
+	pkg.Set("b.yaml", configMapB)
+	f.AssertNoErrors(f.Apply(pkg, nil))
+	f.AssertExists(a)
+	f.AssertExists(b)
+	f.AssertInventory(pkg, b, a)
+	f.AssertStatus(a, status.CurrentStatus)
+	f.WaitForStatus(time.Second, status.CurrentStatus, a, b)
+
+	pkg.Remove("b.yaml")
+	f.AssertNoErrors(f.Apply(pkg, nil))
+	f.AssertNotExists(b)
+	f.AssertStatus(b, status.NotFoundStatus)
+	f.AssertInventory(pkg, a)
+
+	f.AssertNoErrors(f.Destroy(pkg, nil))
+	f.AssertNotExists(a)
+	f.AssertInventory(pkg)
+}
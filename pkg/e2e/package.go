@@ -0,0 +1,49 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const groupingTemplateFile = "inventory-template.yaml"
+
+const groupingTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: %s
+`
+
+// Package is a directory of manifests that is applied and
+// destroyed as a whole.
+type Package struct {
+	t testing.TB
+	// Dir is the directory of the manifests.
+	Dir string
+	// InventoryID is the inventory id in the grouping object template.
+	InventoryID string
+}
+
+// Set writes a manifest to the package, replacing the one with
+// the same name.
+func (p *Package) Set(name, manifest string) {
+	p.t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(p.Dir, name), []byte(manifest), 0600); err != nil {
+		p.t.Fatalf("error writing manifest %s: %v", name, err)
+	}
+}
+
+// Remove removes a manifest from the package, so the
+// resources in it are pruned by the next apply.
+func (p *Package) Remove(name string) {
+	p.t.Helper()
+	if err := os.Remove(filepath.Join(p.Dir, name)); err != nil {
+		p.t.Fatalf("error removing manifest %s: %v", name, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package e2e
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+// Apply applies the package with the command line flags of the apply
+// command, and returns the events. The resources are not waited for
+// unless the wait is enabled by the flags.
+func (f *Framework) Apply(p *Package, flags map[string]string) []event.Event {
+	f.t.Helper()
+	applier := apply.NewApplier(f.Factory(), f.IOStreams)
+	cmd := &cobra.Command{}
+	if err := applier.SetFlags(cmd); err != nil {
+		f.t.Fatalf("error setting up the applier: %v", err)
+	}
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	f.setFlags(cmd, p, flags)
+	if err := applier.Initialize(cmd, nil); err != nil {
+		f.t.Fatalf("error setting up the applier: %v", err)
+	}
+	defer applier.Close()
+	return collect(applier.Run(context.Background()))
+}
+
+// Destroy deletes the resources of the package with the command line
+// flags of the destroy command, and returns the events.
+func (f *Framework) Destroy(p *Package, flags map[string]string) []event.Event {
+	f.t.Helper()
+	destroyer := apply.NewDestroyer(f.Factory(), f.IOStreams)
+	cmd := &cobra.Command{}
+	if err := destroyer.SetFlags(cmd); err != nil {
+		f.t.Fatalf("error setting up the destroyer: %v", err)
+	}
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	f.setFlags(cmd, p, flags)
+	if err := destroyer.Initialize(cmd, nil); err != nil {
+		f.t.Fatalf("error setting up the destroyer: %v", err)
+	}
+	return collect(destroyer.Run())
+}
+
+// setFlags sets the package and the flags of the command. The wait
+// for the resources to reconcile is off unless the flags enable it.
+func (f *Framework) setFlags(cmd *cobra.Command, p *Package, flags map[string]string) {
+	f.t.Helper()
+	values := map[string]string{"filename": p.Dir}
+	if cmd.Flags().Lookup("wait") != nil {
+		values["wait"] = "false"
+	}
+	for name, value := range flags {
+		values[name] = value
+	}
+	for name, value := range values {
+		if err := cmd.Flags().Set(name, value); err != nil {
+			f.t.Fatalf("error setting flag --%s: %v", name, err)
+		}
+	}
+}
+
+func collect(ch <-chan event.Event) []event.Event {
+	var events []event.Event
+	for e := range ch {
+		events = append(events, e)
+	}
+	return events
+}
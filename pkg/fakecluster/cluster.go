@@ -0,0 +1,444 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fakecluster provides an in-memory cluster for unit testing
+// code that applies, prunes and waits for resources, without envtest
+// or a real cluster.
+//
+// The Cluster serves the Kubernetes API over an http.RoundTripper, so
+// the factory, dynamic client, clientset and controller-runtime client
+// built from its rest.Config all see the same resources. Discovery is
+// served from the kinds registered in the Cluster, and creating a
+// CustomResourceDefinition registers its kind, like a real cluster.
+//
+//	cluster := fakecluster.New()
+//	applier := apply.NewApplier(cluster.Factory(), ioStreams)
+//
+// Nothing reconciles the resources, so built-in workloads never
+// become Current unless their status is set with Update. Server-side
+// apply, server dry-runs and watches are not supported.
+package fakecluster
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/kubectl/pkg/cmd/util"
+)
+
+// DefaultNamespace is the namespace of the context in the
+// kubeconfig of a new Cluster.
+const DefaultNamespace = "default"
+
+// host is the address in the rest.Config. Nothing listens on it,
+// since the requests are served by the Cluster itself.
+const host = "http://fakecluster.local"
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// DefaultKinds are the kinds served by a new Cluster.
+var DefaultKinds = []Kind{
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}},
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Service"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1",
+		Kind: "ClusterRole"}},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1",
+		Kind: "ClusterRoleBinding"}},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1",
+		Kind: "Role"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1",
+		Kind: "RoleBinding"}, Namespaced: true},
+	{GroupVersionKind: schema.GroupVersionKind{Group: crdGroupKind.Group, Version: "v1beta1",
+		Kind: crdGroupKind.Kind}},
+}
+
+// Kind is a kind of resource served by the Cluster.
+type Kind struct {
+	schema.GroupVersionKind
+	// Resource is the plural name of the resource in the URLs. It is
+	// guessed from the kind if it is empty.
+	Resource string
+	// Namespaced is true if the resources live in a namespace.
+	Namespaced bool
+}
+
+func (k Kind) resource() schema.GroupVersionResource {
+	if k.Resource != "" {
+		return k.GroupVersion().WithResource(k.Resource)
+	}
+	plural, _ := meta.UnsafeGuessKindToResource(k.GroupVersionKind)
+	return plural
+}
+
+// Cluster is an in-memory cluster. It is safe for concurrent use.
+type Cluster struct {
+	// Namespace is the namespace of the context in the kubeconfig,
+	// used for the resources that don't set one.
+	Namespace string
+	// NamespaceFlag is the namespace that overrides the one of the
+	// context, like the --namespace flag of kubectl.
+	NamespaceFlag string
+
+	mu              sync.Mutex
+	kinds           []Kind
+	objects         map[schema.GroupVersionResource]map[types.NamespacedName]*unstructured.Unstructured
+	resourceVersion int
+}
+
+// New returns a Cluster serving the DefaultKinds, with the
+// given resources.
+func New(objs ...*unstructured.Unstructured) (*Cluster, error) {
+	c := &Cluster{
+		Namespace: DefaultNamespace,
+		kinds:     append([]Kind{}, DefaultKinds...),
+		objects:   make(map[schema.GroupVersionResource]map[types.NamespacedName]*unstructured.Unstructured),
+	}
+	for _, obj := range objs {
+		if err := c.Add(obj); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// AddKind makes the Cluster serve a kind. Adding a kind that is
+// already served has no effect.
+func (c *Cluster) AddKind(kind Kind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addKind(kind)
+}
+
+func (c *Cluster) addKind(kind Kind) {
+	if _, found := c.kindFor(kind.GroupVersionKind); found {
+		return
+	}
+	c.kinds = append(c.kinds, kind)
+}
+
+// RemoveKind stops serving a kind. The resources of the kind are kept,
+// and are served again if the kind is added back.
+func (c *Cluster) RemoveKind(gvk schema.GroupVersionKind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, kind := range c.kinds {
+		if kind.GroupVersionKind == gvk {
+			c.kinds = append(c.kinds[:i], c.kinds[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Cluster) kindFor(gvk schema.GroupVersionKind) (Kind, bool) {
+	for _, kind := range c.kinds {
+		if kind.GroupVersionKind == gvk {
+			return kind, true
+		}
+	}
+	return Kind{}, false
+}
+
+func (c *Cluster) kindForResource(gvr schema.GroupVersionResource) (Kind, bool) {
+	for _, kind := range c.kinds {
+		if kind.resource() == gvr {
+			return kind, true
+		}
+	}
+	return Kind{}, false
+}
+
+// Add creates a resource, like a POST to the API would. The namespace
+// of a namespaced resource defaults to the Namespace of the Cluster.
+func (c *Cluster) Add(obj *unstructured.Unstructured) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kind, found := c.kindFor(obj.GroupVersionKind())
+	if !found {
+		return fmt.Errorf("kind %s is not served", obj.GroupVersionKind())
+	}
+	obj = obj.DeepCopy()
+	if kind.Namespaced && obj.GetNamespace() == "" {
+		obj.SetNamespace(c.Namespace)
+	}
+	_, err := c.create(kind, obj, false)
+	return err
+}
+
+// Update replaces a resource, for example to set the status that a
+// controller would set. The resource must exist.
+func (c *Cluster) Update(obj *unstructured.Unstructured) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kind, found := c.kindFor(obj.GroupVersionKind())
+	if !found {
+		return fmt.Errorf("kind %s is not served", obj.GroupVersionKind())
+	}
+	_, err := c.update(kind, obj.DeepCopy(), false)
+	return err
+}
+
+// Get returns a copy of a resource, or nil if it doesn't exist.
+func (c *Cluster) Get(gvk schema.GroupVersionKind, namespace, name string) *unstructured.Unstructured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kind, found := c.kindFor(gvk)
+	if !found {
+		return nil
+	}
+	obj, found := c.objects[kind.resource()][types.NamespacedName{Namespace: namespace, Name: name}]
+	if !found {
+		return nil
+	}
+	return obj.DeepCopy()
+}
+
+// Objects returns copies of all the resources, sorted by group,
+// kind, namespace and name, so they can be compared in tests.
+func (c *Cluster) Objects() []*unstructured.Unstructured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var objs []*unstructured.Unstructured
+	for _, byName := range c.objects {
+		for _, obj := range byName {
+			objs = append(objs, obj.DeepCopy())
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		gki, gkj := objs[i].GroupVersionKind().GroupKind(), objs[j].GroupVersionKind().GroupKind()
+		if gki.Group != gkj.Group {
+			return gki.Group < gkj.Group
+		}
+		if gki.Kind != gkj.Kind {
+			return gki.Kind < gkj.Kind
+		}
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+	return objs
+}
+
+// RESTConfig returns a config for clients of the Cluster. The
+// requests are served in-process and are not rate limited.
+func (c *Cluster) RESTConfig() *rest.Config {
+	return &rest.Config{
+		Host:        host,
+		Transport:   c,
+		RateLimiter: flowcontrol.NewFakeAlwaysRateLimiter(),
+	}
+}
+
+// RESTClientGetter returns the getter the factories are built from.
+func (c *Cluster) RESTClientGetter() genericclioptions.RESTClientGetter {
+	return &restClientGetter{cluster: c}
+}
+
+// Factory returns a factory for the Cluster that can be passed to
+// the applier, destroyer and the commands.
+func (c *Cluster) Factory() util.Factory {
+	return util.NewFactory(c.RESTClientGetter())
+}
+
+// DynamicClient returns a dynamic client for the Cluster.
+func (c *Cluster) DynamicClient() dynamic.Interface {
+	return dynamic.NewForConfigOrDie(c.RESTConfig())
+}
+
+// create stores a new resource. It returns a conflict error
+// if it exists already.
+func (c *Cluster) create(kind Kind, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	gvr := kind.resource()
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + string(uuid.NewUUID())[:5])
+	}
+	if obj.GetName() == "" {
+		return nil, apierrors.NewBadRequest("name or generateName is required")
+	}
+	if !kind.Namespaced {
+		obj.SetNamespace("")
+	} else if obj.GetNamespace() == "" {
+		return nil, apierrors.NewBadRequest("the namespace of the provided object is empty")
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if _, found := c.objects[gvr][key]; found {
+		return nil, apierrors.NewAlreadyExists(gvr.GroupResource(), obj.GetName())
+	}
+	obj.SetUID(uuid.NewUUID())
+	obj.SetCreationTimestamp(metav1.NewTime(time.Now()))
+	obj.SetGeneration(1)
+	if kind.GroupKind() == crdGroupKind {
+		if err := c.establish(obj); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	}
+	if dryRun {
+		return obj, nil
+	}
+	c.store(gvr, key, obj)
+	return obj.DeepCopy(), nil
+}
+
+// update replaces a resource. The generation is increased if
+// anything but the metadata and status changed. Like the API server,
+// it returns a conflict error if the resource version is set and the
+// resource has been modified since.
+func (c *Cluster) update(kind Kind, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	gvr := kind.resource()
+	if !kind.Namespaced {
+		obj.SetNamespace("")
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	live, found := c.objects[gvr][key]
+	if !found {
+		return nil, apierrors.NewNotFound(gvr.GroupResource(), obj.GetName())
+	}
+	if rv := obj.GetResourceVersion(); rv != "" && rv != live.GetResourceVersion() {
+		return nil, apierrors.NewConflict(gvr.GroupResource(), obj.GetName(),
+			fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	obj.SetUID(live.GetUID())
+	obj.SetCreationTimestamp(live.GetCreationTimestamp())
+	obj.SetGeneration(live.GetGeneration())
+	if !reflect.DeepEqual(withoutMetadata(live), withoutMetadata(obj)) {
+		obj.SetGeneration(live.GetGeneration() + 1)
+	}
+	if dryRun {
+		return obj, nil
+	}
+	c.store(gvr, key, obj)
+	return obj.DeepCopy(), nil
+}
+
+// delete removes a resource. Deleting a CustomResourceDefinition
+// stops serving its kinds.
+func (c *Cluster) delete(kind Kind, namespace, name string, dryRun bool) error {
+	gvr := kind.resource()
+	if !kind.Namespaced {
+		namespace = ""
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	live, found := c.objects[gvr][key]
+	if !found {
+		return apierrors.NewNotFound(gvr.GroupResource(), name)
+	}
+	if dryRun {
+		return nil
+	}
+	delete(c.objects[gvr], key)
+	if kind.GroupKind() == crdGroupKind {
+		for _, crdKind := range crdKinds(live) {
+			for i, k := range c.kinds {
+				if k.GroupVersionKind == crdKind.GroupVersionKind {
+					c.kinds = append(c.kinds[:i], c.kinds[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) store(gvr schema.GroupVersionResource, key types.NamespacedName, obj *unstructured.Unstructured) {
+	c.resourceVersion++
+	obj.SetResourceVersion(strconv.Itoa(c.resourceVersion))
+	if c.objects[gvr] == nil {
+		c.objects[gvr] = make(map[types.NamespacedName]*unstructured.Unstructured)
+	}
+	c.objects[gvr][key] = obj.DeepCopy()
+}
+
+// establish registers the kinds of a CustomResourceDefinition and
+// marks it as established, like the apiextensions server does.
+func (c *Cluster) establish(crd *unstructured.Unstructured) error {
+	kinds := crdKinds(crd)
+	if len(kinds) == 0 {
+		return fmt.Errorf("CustomResourceDefinition %s has no group, kind or version", crd.GetName())
+	}
+	for _, kind := range kinds {
+		c.addKind(kind)
+	}
+	conditions := []interface{}{
+		map[string]interface{}{"type": "NamesAccepted", "status": "True", "reason": "NoConflicts"},
+		map[string]interface{}{"type": "Established", "status": "True", "reason": "InitialNamesAccepted"},
+	}
+	return unstructured.SetNestedSlice(crd.Object, conditions, "status", "conditions")
+}
+
+// crdKinds returns the kinds served for a CustomResourceDefinition.
+func crdKinds(crd *unstructured.Unstructured) []Kind {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+	if group == "" || kind == "" {
+		return nil
+	}
+	var versions []string
+	if version, _, _ := unstructured.NestedString(crd.Object, "spec", "version"); version != "" {
+		versions = append(versions, version)
+	}
+	list, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range list {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, found := version["served"].(bool); found && !served {
+			continue
+		}
+		if name, ok := version["name"].(string); ok {
+			versions = append(versions, name)
+		}
+	}
+	var kinds []Kind
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		kinds = append(kinds, Kind{
+			GroupVersionKind: schema.GroupVersionKind{Group: group, Version: version, Kind: kind},
+			Resource:         plural,
+			Namespaced:       scope != "Cluster",
+		})
+	}
+	return kinds
+}
+
+// withoutMetadata returns the content of the resource
+// that the generation is computed from.
+func withoutMetadata(obj *unstructured.Unstructured) map[string]interface{} {
+	content := make(map[string]interface{}, len(obj.Object))
+	for k, v := range obj.Object {
+		if k != "metadata" && k != "status" {
+			content[k] = v
+		}
+	}
+	return content
+}
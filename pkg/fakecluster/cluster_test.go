@@ -0,0 +1,255 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package fakecluster
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/apply/event/eventtest"
+)
+
+var (
+	configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	widgetGVK    = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+)
+
+const groupingObject = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: test
+`
+
+const configMapA = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: a
+`
+
+const configMapB = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+data:
+  key: b
+`
+
+const widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  version: v1
+  scope: Namespaced
+  names:
+    kind: Widget
+    plural: widgets
+`
+
+const widget = `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  size: 1
+`
+
+// applyManifests runs the applier for the manifests, without waiting
+// for the resources to reconcile, and returns the events.
+func applyManifests(t *testing.T, cluster *Cluster, manifests ...string) []event.Event {
+	return applyWithFlags(t, cluster, map[string]string{"wait": "false"}, manifests...)
+}
+
+// applyWithFlags runs the applier for the manifests with
+// the flags, and returns the events.
+func applyWithFlags(t *testing.T, cluster *Cluster, flags map[string]string, manifests ...string) []event.Event {
+	dir, err := ioutil.TempDir("", "fakecluster-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for i, m := range manifests {
+		name := filepath.Join(dir, string(rune('a'+i))+".yaml")
+		require.NoError(t, ioutil.WriteFile(name, []byte(m), 0600))
+	}
+
+	applier := apply.NewApplier(cluster.Factory(), genericclioptions.NewTestIOStreamsDiscard())
+	cmd := &cobra.Command{}
+	require.NoError(t, applier.SetFlags(cmd))
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	require.NoError(t, cmd.Flags().Set("filename", dir))
+	for name, value := range flags {
+		require.NoError(t, cmd.Flags().Set(name, value))
+	}
+	require.NoError(t, applier.Initialize(cmd, nil))
+
+	var events []event.Event
+	for e := range applier.Run(context.Background()) {
+		events = append(events, e)
+	}
+	return events
+}
+
+func assertNoErrors(t *testing.T, events []event.Event) {
+	for _, e := range events {
+		if e.Type == event.ErrorType {
+			t.Fatalf("unexpected error: %v", e.ErrorEvent.Err)
+		}
+	}
+}
+
+func names(objs []*unstructured.Unstructured) []string {
+	var result []string
+	for _, obj := range objs {
+		result = append(result, obj.GetKind()+"/"+obj.GetName())
+	}
+	return result
+}
+
+func TestApplyAndPrune(t *testing.T) {
+	cluster, err := New()
+	require.NoError(t, err)
+
+	assertNoErrors(t, applyManifests(t, cluster, groupingObject, configMapA, configMapB))
+	objs := cluster.Objects()
+	assert.Len(t, objs, 3)
+	a := cluster.Get(configMapGVK, DefaultNamespace, "a")
+	require.NotNil(t, a)
+	assert.Equal(t, "a", a.Object["data"].(map[string]interface{})["key"])
+
+	events := applyManifests(t, cluster, groupingObject, configMapA)
+	eventtest.AssertGolden(t, "testdata/apply-and-prune.golden", events)
+	assert.Nil(t, cluster.Get(configMapGVK, DefaultNamespace, "b"))
+	assert.NotNil(t, cluster.Get(configMapGVK, DefaultNamespace, "a"))
+	// The previous grouping object is pruned too.
+	assert.Len(t, cluster.Objects(), 2)
+}
+
+func TestApplyWaitsForStatus(t *testing.T) {
+	cluster, err := New()
+	require.NoError(t, err)
+
+	events := applyWithFlags(t, cluster, map[string]string{
+		"wait":                 "true",
+		"status-poll-interval": "10ms",
+		"reconcile-timeout":    "5s",
+	}, groupingObject, configMapA)
+	assertNoErrors(t, events)
+	var statusEvents int
+	for _, e := range events {
+		if e.Type == event.StatusType {
+			statusEvents++
+		}
+	}
+	assert.NotZero(t, statusEvents)
+}
+
+func TestApplyUpdatesResources(t *testing.T) {
+	cluster, err := New()
+	require.NoError(t, err)
+
+	assertNoErrors(t, applyManifests(t, cluster, groupingObject, configMapA))
+	assertNoErrors(t, applyManifests(t, cluster, groupingObject,
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: changed\n"))
+	a := cluster.Get(configMapGVK, DefaultNamespace, "a")
+	require.NotNil(t, a)
+	assert.Equal(t, "changed", a.Object["data"].(map[string]interface{})["key"])
+	assert.Equal(t, int64(2), a.GetGeneration())
+}
+
+func TestApplyCustomResources(t *testing.T) {
+	cluster, err := New()
+	require.NoError(t, err)
+
+	assertNoErrors(t, applyManifests(t, cluster, groupingObject, widgetCRD, widget))
+	w := cluster.Get(widgetGVK, DefaultNamespace, "w")
+	require.NotNil(t, w)
+	assert.Contains(t, names(cluster.Objects()), "CustomResourceDefinition/widgets.example.com")
+}
+
+func TestApplyWaitsForUnknownKinds(t *testing.T) {
+	cluster, err := New()
+	require.NoError(t, err)
+
+	// The kind is served after the apply starts, like when
+	// another system installs an operator.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(200 * time.Millisecond)
+		cluster.AddKind(Kind{GroupVersionKind: widgetGVK, Namespaced: true})
+	}()
+	// The scope of the kind is unknown when the apply starts,
+	// so the namespace must be set.
+	events := applyWithFlags(t, cluster, map[string]string{"wait": "false", "wait-for-kinds": "10s"},
+		groupingObject, configMapA, strings.Replace(widget, "name: w", "name: w\n  namespace: default", 1))
+	<-done
+	assertNoErrors(t, events)
+	assert.NotNil(t, cluster.Get(configMapGVK, DefaultNamespace, "a"))
+	assert.NotNil(t, cluster.Get(widgetGVK, DefaultNamespace, "w"))
+}
+
+func TestClusterObjects(t *testing.T) {
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("test")
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("cm")
+
+	cluster, err := New(ns, cm)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/cm", "Namespace/test"}, names(cluster.Objects()))
+	assert.Equal(t, DefaultNamespace, cluster.Get(configMapGVK, DefaultNamespace, "cm").GetNamespace())
+
+	unknown := &unstructured.Unstructured{}
+	unknown.SetAPIVersion("example.com/v1")
+	unknown.SetKind("Widget")
+	unknown.SetName("w")
+	assert.Error(t, cluster.Add(unknown))
+	cluster.AddKind(Kind{GroupVersionKind: unknown.GroupVersionKind(), Namespaced: true})
+	assert.NoError(t, cluster.Add(unknown))
+	assert.Error(t, cluster.Add(unknown))
+}
+
+func TestGeneratePackage(t *testing.T) {
+	p := GeneratePackage(10, 3)
+	assert.Len(t, p.Kinds, 3)
+	assert.Len(t, p.Objects, 10)
+
+	cluster, err := New()
+	require.NoError(t, err)
+	p.AddKinds(cluster)
+	for _, obj := range p.DeepCopyObjects() {
+		assert.NoError(t, cluster.Add(obj))
+	}
+	assert.Len(t, cluster.Objects(), 10)
+	// The original objects are not changed by the cluster.
+	assert.Empty(t, p.Objects[0].GetNamespace())
+}
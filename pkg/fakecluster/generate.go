@@ -0,0 +1,79 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package fakecluster
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GeneratedGroup is the group of the kinds of a generated Package.
+const GeneratedGroup = "bench.example.com"
+
+// Package is a synthetic package of resources, for benchmarks and
+// tests that need many resources of many kinds.
+type Package struct {
+	// Kinds are the kinds of the resources. They are not served
+	// by a Cluster until they are added with AddKinds.
+	Kinds []Kind
+	// Objects are the resources, spread evenly over the Kinds.
+	Objects []*unstructured.Unstructured
+}
+
+// GeneratePackage returns a package of objects resources, spread evenly
+// over kinds namespaced custom kinds. Every resource has a spec with
+// a few fields, so the resources are roughly the size of real ones.
+func GeneratePackage(objects, kinds int) Package {
+	if kinds < 1 {
+		kinds = 1
+	}
+	var p Package
+	for i := 0; i < kinds; i++ {
+		kind := fmt.Sprintf("Kind%d", i)
+		p.Kinds = append(p.Kinds, Kind{
+			GroupVersionKind: schema.GroupVersionKind{Group: GeneratedGroup, Version: "v1", Kind: kind},
+			Resource:         strings.ToLower(kind) + "s",
+			Namespaced:       true,
+		})
+	}
+	for i := 0; i < objects; i++ {
+		kind := p.Kinds[i%kinds]
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas": int64(i%5 + 1),
+				"image":    fmt.Sprintf("example.com/image:%d", i),
+				"config": map[string]interface{}{
+					"key1": "value1",
+					"key2": "value2",
+					"key3": "value3",
+				},
+			},
+		}}
+		u.SetGroupVersionKind(kind.GroupVersionKind)
+		u.SetName(fmt.Sprintf("object-%d", i))
+		u.SetLabels(map[string]string{"app": "bench"})
+		p.Objects = append(p.Objects, u)
+	}
+	return p
+}
+
+// AddKinds makes the Cluster serve the kinds of the package.
+func (p Package) AddKinds(c *Cluster) {
+	for _, kind := range p.Kinds {
+		c.AddKind(kind)
+	}
+}
+
+// DeepCopyObjects returns a copy of the resources, so they can be
+// used by code that changes them without affecting later uses.
+func (p Package) DeepCopyObjects() []*unstructured.Unstructured {
+	objs := make([]*unstructured.Unstructured, 0, len(p.Objects))
+	for _, obj := range p.Objects {
+		objs = append(objs, obj.DeepCopy())
+	}
+	return objs
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package fakecluster
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// restClientGetter provides the clients of the Cluster to the factory.
+// Every mapper it returns reads the discovery information again when
+// a kind is not found, so kinds added later are found.
+type restClientGetter struct {
+	cluster *Cluster
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.cluster.RESTConfig(), nil
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	client, err := discovery.NewDiscoveryClientForConfig(g.cluster.RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(client), nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	client, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(client), nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["fakecluster"] = &clientcmdapi.Cluster{Server: host}
+	config.AuthInfos["fakecluster"] = &clientcmdapi.AuthInfo{}
+	config.Contexts["fakecluster"] = &clientcmdapi.Context{
+		Cluster:   "fakecluster",
+		AuthInfo:  "fakecluster",
+		Namespace: g.cluster.Namespace,
+	}
+	config.CurrentContext = "fakecluster"
+	return clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{
+		Context: clientcmdapi.Context{Namespace: g.cluster.NamespaceFlag},
+	})
+}
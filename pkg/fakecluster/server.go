@@ -0,0 +1,354 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package fakecluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// RoundTrip serves a request to the API of the Cluster.
+func (c *Cluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+	c.mu.Lock()
+	code, resp := c.serve(req, body)
+	c.mu.Unlock()
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	var data []byte
+	switch resp := resp.(type) {
+	case error:
+		status := errorStatus(resp)
+		code = int(status.Code)
+		data, _ = json.Marshal(status)
+	case []byte:
+		header.Set("Content-Type", "application/octet-stream")
+		data = resp
+	default:
+		var err error
+		data, err = json.Marshal(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// errorStatus returns the status sent for an error.
+func errorStatus(err error) metav1.Status {
+	var status metav1.Status
+	if apiStatus, ok := err.(apierrors.APIStatus); ok {
+		status = apiStatus.Status()
+	} else {
+		status = apierrors.NewInternalError(err).Status()
+	}
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	return status
+}
+
+// serve returns the status code and the response for a request. The
+// response is an error, raw bytes, or a value encoded as JSON.
+func (c *Cluster) serve(req *http.Request, body []byte) (int, interface{}) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "version":
+		return http.StatusOK, version.Info{Major: "1", Minor: "17", GitVersion: "v1.17.0"}
+	case len(parts) == 2 && parts[0] == "openapi" && parts[1] == "v2":
+		// An empty document has no schemas, so the
+		// resources are not validated.
+		return http.StatusOK, []byte{}
+	case len(parts) == 1 && parts[0] == "api":
+		return http.StatusOK, metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		}
+	case len(parts) == 1 && parts[0] == "apis":
+		return http.StatusOK, c.groups()
+	}
+
+	var gv schema.GroupVersion
+	var rest []string
+	switch {
+	case parts[0] == "api" && len(parts) >= 2:
+		gv = schema.GroupVersion{Version: parts[1]}
+		rest = parts[2:]
+	case parts[0] == "apis" && len(parts) >= 3:
+		gv = schema.GroupVersion{Group: parts[1], Version: parts[2]}
+		rest = parts[3:]
+	default:
+		return 0, notFound()
+	}
+	if len(rest) == 0 {
+		return c.resources(gv)
+	}
+
+	namespace := ""
+	if len(rest) >= 3 && rest[0] == "namespaces" {
+		namespace = rest[1]
+		rest = rest[2:]
+	}
+	kind, found := c.kindForResource(gv.WithResource(rest[0]))
+	if !found {
+		return 0, notFound()
+	}
+	name := ""
+	if len(rest) >= 2 {
+		name = rest[1]
+	}
+	// Only the status subresource is served, as part of the resource.
+	if len(rest) > 3 || (len(rest) == 3 && rest[2] != "status") {
+		return 0, notFound()
+	}
+	dryRun := len(req.URL.Query()["dryRun"]) > 0
+
+	switch {
+	case req.Method == http.MethodGet && name == "":
+		if req.URL.Query().Get("watch") == "true" {
+			return 0, apierrors.NewMethodNotSupported(kind.resource().GroupResource(), "watch")
+		}
+		return c.list(kind, namespace, req.URL.Query().Get("labelSelector"))
+	case req.Method == http.MethodGet:
+		obj, found := c.objects[kind.resource()][types.NamespacedName{Namespace: namespace, Name: name}]
+		if !found {
+			return 0, apierrors.NewNotFound(kind.resource().GroupResource(), name)
+		}
+		return http.StatusOK, obj
+	case req.Method == http.MethodPost && name == "":
+		obj, err := decode(body)
+		if err != nil {
+			return 0, err
+		}
+		if kind.Namespaced {
+			obj.SetNamespace(namespace)
+		}
+		created, err := c.create(kind, obj, dryRun)
+		if err != nil {
+			return 0, err
+		}
+		return http.StatusCreated, created
+	case req.Method == http.MethodPut && name != "":
+		obj, err := decode(body)
+		if err != nil {
+			return 0, err
+		}
+		obj.SetNamespace(namespace)
+		if obj.GetName() != name {
+			return 0, apierrors.NewBadRequest("the name of the object does not match the name in the URL")
+		}
+		updated, err := c.update(kind, obj, dryRun)
+		if err != nil {
+			return 0, err
+		}
+		return http.StatusOK, updated
+	case req.Method == http.MethodPatch && name != "":
+		patched, err := c.patch(kind, namespace, name, types.PatchType(req.Header.Get("Content-Type")), body, dryRun)
+		if err != nil {
+			return 0, err
+		}
+		return http.StatusOK, patched
+	case req.Method == http.MethodDelete && name != "":
+		if err := c.delete(kind, namespace, name, dryRun); err != nil {
+			return 0, err
+		}
+		return http.StatusOK, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+		}
+	}
+	return 0, apierrors.NewMethodNotSupported(kind.resource().GroupResource(), req.Method)
+}
+
+// groups returns the discovery information for the API groups.
+func (c *Cluster) groups() metav1.APIGroupList {
+	versions := make(map[string][]string)
+	var names []string
+	for _, kind := range c.kinds {
+		if kind.Group == "" {
+			continue
+		}
+		if _, found := versions[kind.Group]; !found {
+			names = append(names, kind.Group)
+		}
+		if !containsString(versions[kind.Group], kind.Version) {
+			versions[kind.Group] = append(versions[kind.Group], kind.Version)
+		}
+	}
+	sort.Strings(names)
+	list := metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+	for _, name := range names {
+		group := metav1.APIGroup{Name: name}
+		for _, v := range versions[name] {
+			group.Versions = append(group.Versions, metav1.GroupVersionForDiscovery{
+				GroupVersion: schema.GroupVersion{Group: name, Version: v}.String(),
+				Version:      v,
+			})
+		}
+		group.PreferredVersion = group.Versions[0]
+		list.Groups = append(list.Groups, group)
+	}
+	return list
+}
+
+// resources returns the discovery information for the
+// resources of a group version.
+func (c *Cluster) resources(gv schema.GroupVersion) (int, interface{}) {
+	list := metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+	}
+	for _, kind := range c.kinds {
+		if kind.GroupVersion() != gv {
+			continue
+		}
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       kind.resource().Resource,
+			Namespaced: kind.Namespaced,
+			Kind:       kind.Kind,
+			Verbs:      metav1.Verbs{"create", "delete", "get", "list", "patch", "update"},
+		})
+	}
+	if len(list.APIResources) == 0 {
+		return 0, notFound()
+	}
+	return http.StatusOK, list
+}
+
+// list returns the resources of a kind in the namespace, or in
+// all namespaces if it is empty, that match the label selector.
+func (c *Cluster) list(kind Kind, namespace, selector string) (int, interface{}) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(err.Error())
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion(kind.GroupVersion().String())
+	list.SetKind(kind.Kind + "List")
+	list.SetResourceVersion(strconv.Itoa(c.resourceVersion))
+	var keys []types.NamespacedName
+	for key, obj := range c.objects[kind.resource()] {
+		if namespace != "" && key.Namespace != namespace {
+			continue
+		}
+		if !sel.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		list.Items = append(list.Items, *c.objects[kind.resource()][key])
+	}
+	return http.StatusOK, list
+}
+
+// patch applies a JSON, merge or strategic merge patch to a resource.
+// Strategic merge patches are only accepted for the built-in types.
+func (c *Cluster) patch(kind Kind, namespace, name string, patchType types.PatchType, patch []byte,
+	dryRun bool) (*unstructured.Unstructured, error) {
+	live, found := c.objects[kind.resource()][types.NamespacedName{Namespace: namespace, Name: name}]
+	if !found {
+		return nil, apierrors.NewNotFound(kind.resource().GroupResource(), name)
+	}
+	original, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	switch patchType {
+	case types.JSONPatchType:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		patched, err = p.Apply(original)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.StrategicMergePatchType:
+		typed, err := scheme.Scheme.New(kind.GroupVersionKind)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("strategic merge patch is not supported for %s",
+				kind.GroupVersionKind))
+		}
+		patched, err = strategicpatch.StrategicMergePatch(original, patch, typed)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("patch type %q is not supported", patchType))
+	}
+	obj, err := decode(patched)
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetName() != name || obj.GetNamespace() != namespace {
+		return nil, apierrors.NewBadRequest("the name and namespace of the object can't be patched")
+	}
+	return c.update(kind, obj, dryRun)
+}
+
+func decode(data []byte) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return obj, nil
+}
+
+func notFound() error {
+	return apierrors.NewGenericServerResponse(http.StatusNotFound, "get", schema.GroupResource{}, "",
+		"the server could not find the requested resource", 0, false)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
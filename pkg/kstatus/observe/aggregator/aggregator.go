@@ -75,3 +75,62 @@ func (d *BasicAggregator) AggregateStatus() status.Status {
 func (d *BasicAggregator) Completed() bool {
 	return d.AggregateStatus() == status.CurrentStatus
 }
+
+// AllNotFoundAggregator implements StatusAggregator.
+// Aggregate status will be NotFound when all observed
+// resources have been removed from the cluster. This is
+// used when waiting for deleted or pruned resources to
+// actually disappear.
+type AllNotFoundAggregator struct {
+	resourceCurrentStatus map[wait.ResourceIdentifier]status.Status
+}
+
+// NewAllNotFoundStatusAggregator returns an AllNotFoundAggregator that will
+// track resources identified by the argument.
+func NewAllNotFoundStatusAggregator(identifiers []wait.ResourceIdentifier) *AllNotFoundAggregator {
+	aggregator := &AllNotFoundAggregator{
+		resourceCurrentStatus: make(map[wait.ResourceIdentifier]status.Status),
+	}
+	for _, id := range identifiers {
+		aggregator.resourceCurrentStatus[id] = status.UnknownStatus
+	}
+	return aggregator
+}
+
+// ResourceObserved is called whenever we have an observation of a resource. We
+// keep the latest status for each resource.
+func (d *AllNotFoundAggregator) ResourceObserved(r *event.ObservedResource) {
+	d.resourceCurrentStatus[r.Identifier] = r.Status
+}
+
+// AggregateStatus computes the aggregate status for all the resources. Resources
+// that still exist, including those that are Terminating, means the aggregate
+// status is InProgress.
+func (d *AllNotFoundAggregator) AggregateStatus() status.Status {
+	if len(d.resourceCurrentStatus) == 0 {
+		return status.NotFoundStatus
+	}
+	anyUnknown := false
+	allNotFound := true
+	for _, s := range d.resourceCurrentStatus {
+		if s == status.UnknownStatus {
+			anyUnknown = true
+		}
+		if s != status.NotFoundStatus {
+			allNotFound = false
+		}
+	}
+	if anyUnknown {
+		return status.UnknownStatus
+	}
+	if allNotFound {
+		return status.NotFoundStatus
+	}
+	return status.InProgressStatus
+}
+
+// Completed returns true when none of the resources can be found
+// in the cluster.
+func (d *AllNotFoundAggregator) Completed() bool {
+	return d.AggregateStatus() == status.NotFoundStatus
+}
@@ -138,3 +138,86 @@ func TestAggregator(t *testing.T) {
 		})
 	}
 }
+
+func TestAllNotFoundAggregator(t *testing.T) {
+	testCases := map[string]struct {
+		identifiers     []wait.ResourceIdentifier
+		observations    []event.ObservedResource
+		aggregateStatus status.Status
+		completed       bool
+	}{
+		"no identifiers": {
+			identifiers:     []wait.ResourceIdentifier{},
+			observations:    []event.ObservedResource{},
+			aggregateStatus: status.NotFoundStatus,
+			completed:       true,
+		},
+		"resource not yet observed": {
+			identifiers:     []wait.ResourceIdentifier{resourceIdentifiers["deployment"]},
+			observations:    []event.ObservedResource{},
+			aggregateStatus: status.UnknownStatus,
+			completed:       false,
+		},
+		"one resource still terminating": {
+			identifiers: []wait.ResourceIdentifier{
+				resourceIdentifiers["deployment"],
+				resourceIdentifiers["statefulset"],
+			},
+			observations: []event.ObservedResource{
+				{
+					Identifier: resourceIdentifiers["deployment"],
+					Status:     status.NotFoundStatus,
+				},
+				{
+					Identifier: resourceIdentifiers["statefulset"],
+					Status:     status.TerminatingStatus,
+				},
+			},
+			aggregateStatus: status.InProgressStatus,
+			completed:       false,
+		},
+		"current resource is not deleted": {
+			identifiers: []wait.ResourceIdentifier{resourceIdentifiers["service"]},
+			observations: []event.ObservedResource{
+				{
+					Identifier: resourceIdentifiers["service"],
+					Status:     status.CurrentStatus,
+				},
+			},
+			aggregateStatus: status.InProgressStatus,
+			completed:       false,
+		},
+		"all resources not found": {
+			identifiers: []wait.ResourceIdentifier{
+				resourceIdentifiers["deployment"],
+				resourceIdentifiers["service"],
+			},
+			observations: []event.ObservedResource{
+				{
+					Identifier: resourceIdentifiers["deployment"],
+					Status:     status.NotFoundStatus,
+				},
+				{
+					Identifier: resourceIdentifiers["service"],
+					Status:     status.NotFoundStatus,
+				},
+			},
+			aggregateStatus: status.NotFoundStatus,
+			completed:       true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			aggregator := NewAllNotFoundStatusAggregator(tc.identifiers)
+
+			for _, o := range tc.observations {
+				observation := o
+				aggregator.ResourceObserved(&observation)
+			}
+
+			assert.Equal(t, tc.aggregateStatus, aggregator.AggregateStatus())
+			assert.Equal(t, tc.completed, aggregator.Completed())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package observe wires together the different parts of the observe
+// library, so clients that just want to poll the status of a set of
+// resources with the default configuration don't need to know about
+// the aggregators, cluster readers and resource observers.
+package observe
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/aggregator"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observers"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/reader"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewStatusObserver returns an Observer that uses the caching cluster
+// reader, the built-in resource observers and an aggregator that considers
+// the set of resources reconciled once all of them are Current or NotFound.
+func NewStatusObserver(reader client.Reader, mapper meta.RESTMapper) *observer.Observer {
+	return &observer.Observer{
+		Reader:                reader,
+		Mapper:                mapper,
+		AggregatorFactoryFunc: DefaultAggregatorFactoryFunc,
+		ReaderFactoryFunc:     DefaultReaderFactoryFunc,
+		ObserversFactoryFunc:  DefaultObserversFactoryFunc,
+	}
+}
+
+// DefaultAggregatorFactoryFunc creates an aggregator that computes the
+// aggregate status as Current when all resources are Current or NotFound.
+func DefaultAggregatorFactoryFunc(identifiers []wait.ResourceIdentifier) observer.StatusAggregator {
+	return aggregator.NewAllCurrentOrNotFoundStatusAggregator(identifiers)
+}
+
+// DefaultReaderFactoryFunc creates a ClusterReader that syncs all the
+// needed resources with LIST calls before each polling loop.
+func DefaultReaderFactoryFunc(r client.Reader, mapper meta.RESTMapper,
+	identifiers []wait.ResourceIdentifier) (observer.ClusterReader, error) {
+	return reader.NewCachingClusterReader(r, mapper, identifiers)
+}
+
+// DefaultObserversFactoryFunc creates the resource observers for the
+// built-in types that have generated resources, and the generic observer
+// used for everything else. The resources with the ReadyWhenAnnotation
+// or the FailedWhenAnnotation get their status from the CEL expressions
+// in those annotations instead.
+func DefaultObserversFactoryFunc(reader observer.ClusterReader, mapper meta.RESTMapper) (
+	map[schema.GroupKind]observer.ResourceObserver, observer.ResourceObserver) {
+	return NewCELObserversFactoryFunc(nil, BuiltinObserversFactoryFunc)(reader, mapper)
+}
+
+// NewCELObserversFactoryFunc returns a factory function that computes the
+// status of the resources with the CEL rules, either the ones passed in
+// for their GroupKind or the ones set with annotations. The observers
+// created by the factory function f are used for the other resources.
+// The observers of f that already use CEL rules are not wrapped again.
+func NewCELObserversFactoryFunc(rules map[schema.GroupKind]observers.CELRule,
+	f observer.ObserversFactoryFunc) observer.ObserversFactoryFunc {
+	return func(reader observer.ClusterReader, mapper meta.RESTMapper) (
+		map[schema.GroupKind]observer.ResourceObserver, observer.ResourceObserver) {
+		resourceObservers, defaultObserver := f(reader, mapper)
+		celObservers := make(map[schema.GroupKind]observer.ResourceObserver, len(resourceObservers))
+		for gk, o := range resourceObservers {
+			celObservers[gk] = observers.NewCELObserver(reader, mapper, rules, o)
+		}
+		return celObservers, observers.NewCELObserver(reader, mapper, rules, defaultObserver)
+	}
+}
+
+// BuiltinObserversFactoryFunc creates the resource observers that
+// compute the status with the status library, without the CEL
+// expressions of DefaultObserversFactoryFunc.
+func BuiltinObserversFactoryFunc(reader observer.ClusterReader, mapper meta.RESTMapper) (
+	map[schema.GroupKind]observer.ResourceObserver, observer.ResourceObserver) {
+	defaultObserver := observers.NewGenericObserver(reader, mapper)
+	replicaSetObserver := observers.NewReplicaSetObserver(reader, mapper, defaultObserver)
+	deploymentObserver := observers.NewDeploymentObserver(reader, mapper, replicaSetObserver)
+	statefulSetObserver := observers.NewStatefulSetObserver(reader, mapper, defaultObserver)
+
+	resourceObservers := map[schema.GroupKind]observer.ResourceObserver{
+		{Group: "apps", Kind: "Deployment"}:  deploymentObserver,
+		{Group: "apps", Kind: "ReplicaSet"}:  replicaSetObserver,
+		{Group: "apps", Kind: "StatefulSet"}: statefulSetObserver,
+	}
+	return resourceObservers, defaultObserver
+}
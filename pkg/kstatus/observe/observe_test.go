@@ -0,0 +1,38 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package observe
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/testutil"
+)
+
+func TestDefaultObserversFactoryFunc(t *testing.T) {
+	fakeMapper := testutil.NewFakeRESTMapper(
+		appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	)
+	resourceObservers, defaultObserver := DefaultObserversFactoryFunc(testutil.NewNoopObserverReader(), fakeMapper)
+
+	assert.Assert(t, defaultObserver != nil)
+	for _, gk := range []schema.GroupKind{
+		{Group: "apps", Kind: "Deployment"},
+		{Group: "apps", Kind: "ReplicaSet"},
+		{Group: "apps", Kind: "StatefulSet"},
+	} {
+		_, found := resourceObservers[gk]
+		assert.Assert(t, found, "expected observer for %s", gk.String())
+	}
+}
+
+func TestNewStatusObserver(t *testing.T) {
+	o := NewStatusObserver(nil, testutil.NewFakeRESTMapper())
+
+	assert.Assert(t, o.AggregatorFactoryFunc != nil)
+	assert.Assert(t, o.ReaderFactoryFunc != nil)
+	assert.Assert(t, o.ObserversFactoryFunc != nil)
+}
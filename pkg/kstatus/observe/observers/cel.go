@@ -0,0 +1,234 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package observers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/observer"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/wait"
+)
+
+const (
+	// ReadyWhenAnnotation holds a CEL expression that is true
+	// once the resource it is set on is reconciled.
+	ReadyWhenAnnotation = "cli-utils.sigs.k8s.io/ready-when"
+	// FailedWhenAnnotation holds a CEL expression that is true
+	// if the resource it is set on can't be reconciled.
+	FailedWhenAnnotation = "cli-utils.sigs.k8s.io/failed-when"
+)
+
+// CELRule computes the status of resources with CEL expressions, for
+// resources that don't follow the conventions of the status library.
+// The expressions are passed the resource as the variable object, for
+// example "object.status.phase == 'Ready'", and must return a bool.
+type CELRule struct {
+	// Ready is true once the resource is reconciled.
+	Ready string
+	// Failed is true if the resource can't be reconciled. It is
+	// checked before Ready, and can be left empty.
+	Failed string
+}
+
+// CELRuleFor returns the rule set with the annotations of the resource,
+// or the rule for its GroupKind. The annotations take precedence. The
+// second return value is false if there is no rule for the resource.
+func CELRuleFor(obj *unstructured.Unstructured, rules map[schema.GroupKind]CELRule) (CELRule, bool) {
+	annotations := obj.GetAnnotations()
+	ready, hasReady := annotations[ReadyWhenAnnotation]
+	failed, hasFailed := annotations[FailedWhenAnnotation]
+	if hasReady || hasFailed {
+		return CELRule{Ready: ready, Failed: failed}, true
+	}
+	rule, found := rules[obj.GroupVersionKind().GroupKind()]
+	return rule, found
+}
+
+// Validate returns an error if the expressions of the rule can't be compiled.
+func (r CELRule) Validate() error {
+	if r.Ready == "" {
+		return fmt.Errorf("a rule needs a ready expression")
+	}
+	for _, expr := range []string{r.Ready, r.Failed} {
+		if expr == "" {
+			continue
+		}
+		if _, err := compileCEL(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	celEnvOnce sync.Once
+	celEnv     *cel.Env
+	celEnvErr  error
+)
+
+// compileCEL compiles an expression that is passed the
+// resource as the variable object.
+func compileCEL(expr string) (cel.Program, error) {
+	celEnvOnce.Do(func() {
+		celEnv, celEnvErr = cel.NewEnv(cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)))
+	})
+	if celEnvErr != nil {
+		return nil, celEnvErr
+	}
+	ast, issues := celEnv.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("error compiling expression %q: %v", expr, issues.Err())
+	}
+	if t := ast.OutputType(); !t.IsAssignableType(cel.BoolType) && !t.IsAssignableType(cel.DynType) {
+		return nil, fmt.Errorf("expression %q returns %s, not a bool", expr, t)
+	}
+	return celEnv.Program(ast)
+}
+
+// NewCELObserver returns an observer that computes the status of the
+// resources with a CEL rule, set with annotations or passed in the
+// rules. The other resources are passed on to the delegate, which
+// is usually the generic observer. If the delegate is itself a CEL
+// observer, it is replaced, and its rules are kept unless the rules
+// passed in are for the same GroupKinds.
+func NewCELObserver(reader observer.ClusterReader, mapper meta.RESTMapper, rules map[schema.GroupKind]CELRule,
+	delegate observer.ResourceObserver) observer.ResourceObserver {
+	if c, ok := delegate.(*celObserver); ok {
+		merged := make(map[schema.GroupKind]CELRule, len(c.rules)+len(rules))
+		for gk, rule := range c.rules {
+			merged[gk] = rule
+		}
+		for gk, rule := range rules {
+			merged[gk] = rule
+		}
+		rules = merged
+		delegate = c.delegate
+	}
+	return &celObserver{
+		BaseObserver: BaseObserver{
+			Reader: reader,
+			Mapper: mapper,
+		},
+		rules:    rules,
+		delegate: delegate,
+		programs: make(map[string]cel.Program),
+	}
+}
+
+// celObserver computes the status of resources with CEL rules.
+type celObserver struct {
+	BaseObserver
+	rules    map[schema.GroupKind]CELRule
+	delegate observer.ResourceObserver
+
+	// programs caches the compiled expressions, since the same
+	// ones are evaluated in every polling loop.
+	mu       sync.Mutex
+	programs map[string]cel.Program
+}
+
+var _ observer.ResourceObserver = &celObserver{}
+
+func (c *celObserver) Observe(ctx context.Context, identifier wait.ResourceIdentifier) *event.ObservedResource {
+	u, err := c.LookupResource(ctx, identifier)
+	if err != nil {
+		return c.handleObservedResourceError(identifier, err)
+	}
+	return c.ObserveObject(ctx, u)
+}
+
+func (c *celObserver) ObserveObject(ctx context.Context, resource *unstructured.Unstructured) *event.ObservedResource {
+	rule, found := CELRuleFor(resource, c.rules)
+	if !found {
+		return c.delegate.ObserveObject(ctx, resource)
+	}
+	identifier := toIdentifier(resource)
+	res, err := c.evaluate(rule, resource)
+	if err != nil {
+		return &event.ObservedResource{
+			Identifier: identifier,
+			Status:     status.UnknownStatus,
+			Error:      err,
+		}
+	}
+	return &event.ObservedResource{
+		Identifier: identifier,
+		Status:     res.Status,
+		Resource:   resource,
+		Message:    res.Message,
+	}
+}
+
+// SetComputeStatusFunc sets the function of the delegate, since
+// the status of the resources with a rule is not computed by the
+// status library.
+func (c *celObserver) SetComputeStatusFunc(statusFunc observer.ComputeStatusFunc) {
+	c.delegate.SetComputeStatusFunc(statusFunc)
+}
+
+// evaluate computes the status of the resource with the rule. A
+// resource being deleted is Terminating whatever the rule says. The
+// expressions that fail to evaluate, for example because they read
+// fields that are not set yet, are taken as false.
+func (c *celObserver) evaluate(rule CELRule, resource *unstructured.Unstructured) (*status.Result, error) {
+	if resource.GetDeletionTimestamp() != nil {
+		return &status.Result{Status: status.TerminatingStatus, Message: status.TerminatingMessage(resource)}, nil
+	}
+	if rule.Failed != "" {
+		failed, err := c.eval(rule.Failed, resource)
+		if err != nil {
+			return nil, err
+		}
+		if failed {
+			return &status.Result{Status: status.FailedStatus, Message: fmt.Sprintf("%s is true", rule.Failed)}, nil
+		}
+	}
+	if rule.Ready == "" {
+		return nil, fmt.Errorf("a rule needs a ready expression")
+	}
+	ready, err := c.eval(rule.Ready, resource)
+	if err != nil {
+		return nil, err
+	}
+	if ready {
+		return &status.Result{Status: status.CurrentStatus, Message: fmt.Sprintf("%s is true", rule.Ready)}, nil
+	}
+	return &status.Result{Status: status.InProgressStatus, Message: fmt.Sprintf("%s is not true", rule.Ready)}, nil
+}
+
+// eval evaluates the expression. Only expressions that
+// can't be compiled, or don't return a bool, are errors.
+func (c *celObserver) eval(expr string, resource *unstructured.Unstructured) (bool, error) {
+	c.mu.Lock()
+	program, found := c.programs[expr]
+	c.mu.Unlock()
+	if !found {
+		var err error
+		program, err = compileCEL(expr)
+		if err != nil {
+			return false, err
+		}
+		c.mu.Lock()
+		c.programs[expr] = program
+		c.mu.Unlock()
+	}
+	val, _, err := program.Eval(map[string]interface{}{"object": resource.Object})
+	if err != nil {
+		return false, nil
+	}
+	b, ok := val.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q returned %v, not a bool", expr, val)
+	}
+	return bool(b), nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package observers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/observe/testutil"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func TestCELObserver(t *testing.T) {
+	rules := map[schema.GroupKind]CELRule{
+		customGVK.GroupKind(): {
+			Ready:  "object.status.phase == 'Ready'",
+			Failed: "has(object.status.error)",
+		},
+	}
+	testCases := map[string]struct {
+		gvk            schema.GroupVersionKind
+		annotations    map[string]string
+		status         map[string]interface{}
+		expectedStatus status.Status
+		expectError    bool
+	}{
+		"ready": {
+			gvk:            customGVK,
+			status:         map[string]interface{}{"phase": "Ready"},
+			expectedStatus: status.CurrentStatus,
+		},
+		"not ready": {
+			gvk:            customGVK,
+			status:         map[string]interface{}{"phase": "Pending"},
+			expectedStatus: status.InProgressStatus,
+		},
+		"no status yet": {
+			gvk:            customGVK,
+			expectedStatus: status.InProgressStatus,
+		},
+		"failed": {
+			gvk:            customGVK,
+			status:         map[string]interface{}{"phase": "Ready", "error": "quota exceeded"},
+			expectedStatus: status.FailedStatus,
+		},
+		"annotations take precedence": {
+			gvk:            customGVK,
+			annotations:    map[string]string{ReadyWhenAnnotation: "object.status.replicas >= 2"},
+			status:         map[string]interface{}{"phase": "Pending", "replicas": int64(3)},
+			expectedStatus: status.CurrentStatus,
+		},
+		"annotations on another kind": {
+			gvk:            schema.GroupVersionKind{Group: "other.io", Version: "v1", Kind: "Other"},
+			annotations:    map[string]string{ReadyWhenAnnotation: "object.status.ready"},
+			status:         map[string]interface{}{"ready": true},
+			expectedStatus: status.CurrentStatus,
+		},
+		"no rule": {
+			gvk:            schema.GroupVersionKind{Group: "other.io", Version: "v1", Kind: "Other"},
+			expectedStatus: status.UnknownStatus,
+		},
+		"invalid expression": {
+			gvk:            customGVK,
+			annotations:    map[string]string{ReadyWhenAnnotation: "object.status.phase =="},
+			expectedStatus: status.UnknownStatus,
+			expectError:    true,
+		},
+		"expression that doesn't return a bool": {
+			gvk:            customGVK,
+			annotations:    map[string]string{ReadyWhenAnnotation: "object.status.phase"},
+			status:         map[string]interface{}{"phase": "Ready"},
+			expectedStatus: status.UnknownStatus,
+			expectError:    true,
+		},
+		"missing ready expression": {
+			gvk:            customGVK,
+			annotations:    map[string]string{FailedWhenAnnotation: "has(object.status.error)"},
+			expectedStatus: status.UnknownStatus,
+			expectError:    true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			fakeReader := testutil.NewNoopObserverReader()
+			fakeMapper := testutil.NewFakeRESTMapper()
+			delegate := NewGenericObserver(fakeReader, fakeMapper)
+			observer := NewCELObserver(fakeReader, fakeMapper, rules, delegate)
+			observer.SetComputeStatusFunc(func(u *unstructured.Unstructured) (*status.Result, error) {
+				return &status.Result{Status: status.UnknownStatus}, nil
+			})
+
+			object := &unstructured.Unstructured{}
+			object.SetGroupVersionKind(tc.gvk)
+			object.SetName(name)
+			object.SetNamespace(namespace)
+			object.SetAnnotations(tc.annotations)
+			if tc.status != nil {
+				object.Object["status"] = tc.status
+			}
+
+			observedResource := observer.ObserveObject(context.Background(), object)
+
+			assert.Equal(t, tc.expectedStatus, observedResource.Status)
+			assert.Equal(t, tc.expectError, observedResource.Error != nil)
+		})
+	}
+}
+
+func TestCELObserverTerminating(t *testing.T) {
+	fakeReader := testutil.NewNoopObserverReader()
+	fakeMapper := testutil.NewFakeRESTMapper()
+	observer := NewCELObserver(fakeReader, fakeMapper, nil, NewGenericObserver(fakeReader, fakeMapper))
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(customGVK)
+	object.SetName(name)
+	object.SetNamespace(namespace)
+	object.SetAnnotations(map[string]string{ReadyWhenAnnotation: "true"})
+	object.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	object.SetFinalizers([]string{"example.com/cleanup"})
+
+	observedResource := observer.ObserveObject(context.Background(), object)
+
+	assert.Equal(t, status.TerminatingStatus, observedResource.Status)
+	assert.Equal(t, "Resource scheduled for deletion, waiting for finalizers: example.com/cleanup",
+		observedResource.Message)
+}
+
+func TestNewCELObserverWrapsOnce(t *testing.T) {
+	fakeReader := testutil.NewNoopObserverReader()
+	fakeMapper := testutil.NewFakeRESTMapper()
+	otherGK := schema.GroupKind{Group: "other.io", Kind: "Other"}
+	delegate := NewGenericObserver(fakeReader, fakeMapper)
+	inner := NewCELObserver(fakeReader, fakeMapper, map[schema.GroupKind]CELRule{
+		customGVK.GroupKind(): {Ready: "false"},
+		otherGK:               {Ready: "true"},
+	}, delegate)
+	outer := NewCELObserver(fakeReader, fakeMapper, map[schema.GroupKind]CELRule{
+		customGVK.GroupKind(): {Ready: "object.status.ready"},
+	}, inner)
+
+	c := outer.(*celObserver)
+	assert.Equal(t, delegate, c.delegate)
+	// The outer rules take precedence, and the inner ones are kept.
+	assert.DeepEqual(t, map[schema.GroupKind]CELRule{
+		customGVK.GroupKind(): {Ready: "object.status.ready"},
+		otherGK:               {Ready: "true"},
+	}, c.rules)
+}
+
+func TestCELRuleValidate(t *testing.T) {
+	testCases := map[string]struct {
+		rule        CELRule
+		expectError bool
+	}{
+		"ready and failed": {
+			rule: CELRule{Ready: "object.status.phase == 'Ready'", Failed: "object.status.phase == 'Failed'"},
+		},
+		"ready only": {
+			rule: CELRule{Ready: "has(object.status.ready) && object.status.ready"},
+		},
+		"no ready expression": {
+			rule:        CELRule{Failed: "object.status.phase == 'Failed'"},
+			expectError: true,
+		},
+		"syntax error": {
+			rule:        CELRule{Ready: "object.status.phase = 'Ready'"},
+			expectError: true,
+		},
+		"unknown variable": {
+			rule:        CELRule{Ready: "self.status.ready"},
+			expectError: true,
+		},
+		"not a bool": {
+			rule:        CELRule{Ready: "size(object.metadata.name)"},
+			expectError: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			err := tc.rule.Validate()
+			assert.Equal(t, tc.expectError, err != nil, "error: %v", err)
+		})
+	}
+}
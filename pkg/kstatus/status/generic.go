@@ -5,12 +5,25 @@ package status
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+// TerminatingMessage returns the message for a resource that is
+// scheduled for deletion. If the resource has finalizers, they are
+// included in the message, since they are what is keeping the
+// resource from being removed.
+func TerminatingMessage(u *unstructured.Unstructured) string {
+	message := "Resource scheduled for deletion"
+	if finalizers := u.GetFinalizers(); len(finalizers) > 0 {
+		message = fmt.Sprintf("%s, waiting for finalizers: %s", message, strings.Join(finalizers, ", "))
+	}
+	return message
+}
+
 // checkGenericProperties looks at the properties that are available on
 // all or most of the Kubernetes resources. If a decision can be made based
 // on this information, there is no need to look at the resource-specidic
@@ -31,7 +44,7 @@ func checkGenericProperties(u *unstructured.Unstructured) (*Result, error) {
 	if found && deletionTimestamp != "" {
 		return &Result{
 			Status:     TerminatingStatus,
-			Message:    "Resource scheduled for deletion",
+			Message:    TerminatingMessage(u),
 			Conditions: []Condition{},
 		}, nil
 	}
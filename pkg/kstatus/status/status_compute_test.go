@@ -1258,3 +1258,74 @@ func TestServiceStatus(t *testing.T) {
 		})
 	}
 }
+
+var podReadyTerminating = `
+apiVersion: v1
+kind: Pod
+metadata:
+   generation: 1
+   name: test
+   namespace: qual
+   deletionTimestamp: "2020-01-01T00:00:00Z"
+status:
+   conditions:
+    - type: Ready
+      status: "True"
+   phase: Running
+`
+
+var deploymentInProgressTerminating = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+   name: test
+   generation: 2
+   namespace: qual
+   deletionTimestamp: "2020-01-01T00:00:00Z"
+   finalizers:
+    - foregroundDeletion
+status:
+   observedGeneration: 1
+`
+
+var crdCurrentTerminating = `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+   name: test
+   generation: 1
+   namespace: qual
+   deletionTimestamp: "2020-01-01T00:00:00Z"
+status:
+   observedGeneration: 1
+`
+
+func TestTerminatingStatus(t *testing.T) {
+	testCases := map[string]struct {
+		spec            string
+		expectedMessage string
+	}{
+		"pod that is ready": {
+			spec:            podReadyTerminating,
+			expectedMessage: "Resource scheduled for deletion",
+		},
+		"deployment that is in progress": {
+			spec:            deploymentInProgressTerminating,
+			expectedMessage: "Resource scheduled for deletion, waiting for finalizers: foregroundDeletion",
+		},
+		"custom resource that is current": {
+			spec:            crdCurrentTerminating,
+			expectedMessage: "Resource scheduled for deletion",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			res, err := Compute(y2u(t, tc.spec))
+			assert.NoError(t, err)
+			assert.Equal(t, TerminatingStatus, res.Status)
+			assert.Equal(t, tc.expectedMessage, res.Message)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+//
+// ObjMetadata is the minimal set of information to
+// uniquely identify an object. The four fields are:
+//
+//   Group/Kind (NOTE: NOT version)
+//   Namespace
+//   Name
+//
+// We specifically do not use the "version", because
+// the APIServer does not recognize a version as a
+// different resource. This metadata is used to identify
+// resources for pruning and teardown.
+
+package object
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Separates inventory fields. This string is allowable as a
+// ConfigMap key, but it is not allowed as a character in
+// resource name.
+const fieldSeparator = "_"
+
+// ObjMetadata organizes and stores the indentifying information
+// for an object. This struct (as a string) is stored in a
+// grouping object to keep track of sets of applied objects.
+type ObjMetadata struct {
+	Namespace string
+	Name      string
+	GroupKind schema.GroupKind
+}
+
+// CreateObjMetadata returns a pointer to an ObjMetadata struct filled
+// with the passed values. This function normalizes and validates the
+// passed fields and returns an error for bad parameters.
+func CreateObjMetadata(namespace string, name string, gk schema.GroupKind) (*ObjMetadata, error) {
+	// Namespace can be empty, but name cannot.
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("empty name for inventory object")
+	}
+	if gk.Empty() {
+		return nil, fmt.Errorf("empty GroupKind for inventory object")
+	}
+
+	return &ObjMetadata{
+		Namespace: strings.TrimSpace(namespace),
+		Name:      name,
+		GroupKind: gk,
+	}, nil
+}
+
+// ParseObjMetadata takes an inventory string, as returned by
+// FormatObjMetadata, and returns a pointer to the ObjMetadata struct
+// it encodes. Both versions of the encoding are accepted. Example
+// inventory string:
+//
+//   test-namespace_test-name_apps_ReplicaSet
+//
+// Returns an error if unable to parse the string, or if the parsed
+// fields are not valid.
+func ParseObjMetadata(inv string) (*ObjMetadata, error) {
+	var fields []string
+	if strings.HasPrefix(inv, escapedPrefix) {
+		fields = strings.Split(strings.TrimPrefix(inv, escapedPrefix), fieldSeparator)
+		for i, f := range fields {
+			unescaped, err := unescapeField(f)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode inventory: %s: %v", inv, err)
+			}
+			fields[i] = unescaped
+		}
+	} else {
+		fields = strings.Split(inv, fieldSeparator)
+		for i, f := range fields {
+			fields[i] = strings.TrimSpace(f)
+		}
+	}
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unable to decode inventory: %s", inv)
+	}
+	o := ObjMetadata{
+		Namespace: fields[0],
+		Name:      fields[1],
+		GroupKind: schema.GroupKind{Group: fields[2], Kind: fields[3]},
+	}
+	if err := ValidateObjMetadata(o); err != nil {
+		return nil, fmt.Errorf("unable to decode inventory: %s: %v", inv, err)
+	}
+	return &o, nil
+}
+
+// FormatObjMetadata returns the inventory string of the ObjMetadata,
+// which is stored as a key in the grouping object. The GroupKind is
+// normalized first, and ParseObjMetadata returns the normalized
+// ObjMetadata for the string. Returns an error if the fields are not
+// valid, since the string could then not be parsed again.
+//
+// There are two versions of the encoding. Version 0 joins the fields
+// with underscores, and is used when every field only consists of
+// alphanumerics, '-' and '.'. Otherwise version 1 is used, which starts
+// with ".1_" and escapes every other character of the fields as '.'
+// followed by the hex code of each byte, so the string can still be
+// used as a ConfigMap key. Names like "system:auth-delegator" need it.
+func FormatObjMetadata(o ObjMetadata) (string, error) {
+	if err := ValidateObjMetadata(o); err != nil {
+		return "", err
+	}
+	return o.String(), nil
+}
+
+// ValidateObjMetadata returns an error if the ObjMetadata can't identify
+// a resource: the namespace must be empty or a DNS label, the name must
+// be usable in the path of a request, the group must be empty or a DNS
+// subdomain, and the kind must be valid for a CustomResourceDefinition.
+func ValidateObjMetadata(o ObjMetadata) error {
+	if o.Namespace != "" {
+		if errs := validation.IsDNS1123Label(o.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", o.Namespace, strings.Join(errs, "; "))
+		}
+	}
+	if o.Name == "" {
+		return fmt.Errorf("empty name for inventory object")
+	}
+	if len(o.Name) > validation.DNS1123SubdomainMaxLength {
+		return fmt.Errorf("invalid name %q: must be no more than %d characters",
+			o.Name, validation.DNS1123SubdomainMaxLength)
+	}
+	if errs := path.IsValidPathSegmentName(o.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", o.Name, strings.Join(errs, "; "))
+	}
+	if strings.TrimSpace(o.Name) != o.Name {
+		return fmt.Errorf("invalid name %q: may not start or end with whitespace", o.Name)
+	}
+	if o.GroupKind.Group != "" {
+		if errs := validation.IsDNS1123Subdomain(o.GroupKind.Group); len(errs) > 0 {
+			return fmt.Errorf("invalid group %q: %s", o.GroupKind.Group, strings.Join(errs, "; "))
+		}
+	}
+	if o.GroupKind.Kind == "" {
+		return fmt.Errorf("empty kind for inventory object")
+	}
+	if errs := validation.IsDNS1035Label(strings.ToLower(o.GroupKind.Kind)); len(errs) > 0 {
+		return fmt.Errorf("invalid kind %q: %s", o.GroupKind.Kind, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// escapedPrefix starts the strings of version 1 of the encoding. Strings
+// of version 0 never start with a '.', since namespaces can't.
+const escapedPrefix = ".1" + fieldSeparator
+
+// escapeChar starts the hex code of an escaped byte.
+const escapeChar = '.'
+
+// plainField returns true if the field can be used as-is
+// in version 0 of the encoding.
+func plainField(f string) bool {
+	for i := 0; i < len(f); i++ {
+		if !isAlphanumeric(f[i]) && f[i] != '-' && f[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// escapeField escapes every byte of the field other than
+// alphanumerics and '-' for version 1 of the encoding.
+func escapeField(f string) string {
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		if isAlphanumeric(f[i]) || f[i] == '-' {
+			b.WriteByte(f[i])
+			continue
+		}
+		fmt.Fprintf(&b, "%c%02X", escapeChar, f[i])
+	}
+	return b.String()
+}
+
+// unescapeField reverses escapeField.
+func unescapeField(f string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] != escapeChar {
+			if !isAlphanumeric(f[i]) && f[i] != '-' {
+				return "", fmt.Errorf("unexpected character %q", f[i])
+			}
+			b.WriteByte(f[i])
+			continue
+		}
+		if i+2 >= len(f) {
+			return "", fmt.Errorf("incomplete escape sequence")
+		}
+		c, err := strconv.ParseUint(f[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence %q", f[i:i+3])
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// Equals returns true if the ObjMetadata structs are identical;
+// false otherwise.
+func (o *ObjMetadata) Equals(other *ObjMetadata) bool {
+	if other == nil {
+		return false
+	}
+	return o.String() == other.String()
+}
+
+// GroupKinds that must be normalized from the "extensions" group.
+var normalizeGK = map[schema.GroupKind]schema.GroupKind{
+	{Group: "extensions", Kind: "Deployment"}:        {Group: "apps", Kind: "Deployment"},
+	{Group: "extensions", Kind: "DaemonSet"}:         {Group: "apps", Kind: "DaemonSet"},
+	{Group: "extensions", Kind: "ReplicaSet"}:        {Group: "apps", Kind: "ReplicaSet"},
+	{Group: "extensions", Kind: "Ingress"}:           {Group: "networking", Kind: "Ingress"},
+	{Group: "extensions", Kind: "NetworkPolicy"}:     {Group: "networking", Kind: "NetworkPolicy"},
+	{Group: "extensions", Kind: "PodSecurityPolicy"}: {Group: "policy", Kind: "PodSecurityPolicy"},
+}
+
+// String returns the inventory string of the ObjMetadata struct, like
+// FormatObjMetadata, but without validating the fields first.
+func (o *ObjMetadata) String() string {
+	gk := o.GroupKind
+	normalized, exists := normalizeGK[o.GroupKind]
+	if exists {
+		gk = normalized
+	}
+	fields := []string{o.Namespace, o.Name, gk.Group, gk.Kind}
+	plain := !strings.HasPrefix(o.Namespace, ".")
+	for _, f := range fields {
+		plain = plain && plainField(f)
+	}
+	if plain {
+		return strings.Join(fields, fieldSeparator)
+	}
+	for i, f := range fields {
+		fields[i] = escapeField(f)
+	}
+	return escapedPrefix + strings.Join(fields, fieldSeparator)
+}
+
+// RuntimeToObjMeta extracts the identifying information from the
+// passed runtime.Object. Returns an error if the object doesn't
+// have metadata.
+func RuntimeToObjMeta(obj runtime.Object) (ObjMetadata, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ObjMetadata{}, err
+	}
+	return ObjMetadata{
+		Namespace: accessor.GetNamespace(),
+		Name:      accessor.GetName(),
+		GroupKind: obj.GetObjectKind().GroupVersionKind().GroupKind(),
+	}, nil
+}
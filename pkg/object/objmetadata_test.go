@@ -0,0 +1,511 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCreateObjMetadata(t *testing.T) {
+	tests := []struct {
+		namespace string
+		name      string
+		gk        schema.GroupKind
+		expected  string
+		isError   bool
+	}{
+		{
+			namespace: "  \n",
+			name:      " test-name\t",
+			gk: schema.GroupKind{
+				Group: "apps",
+				Kind:  "ReplicaSet",
+			},
+			expected: "_test-name_apps_ReplicaSet",
+			isError:  false,
+		},
+		{
+			namespace: "test-namespace ",
+			name:      " test-name\t",
+			gk: schema.GroupKind{
+				Group: "apps",
+				Kind:  "ReplicaSet",
+			},
+			expected: "test-namespace_test-name_apps_ReplicaSet",
+			isError:  false,
+		},
+		// Error with empty name.
+		{
+			namespace: "test-namespace ",
+			name:      " \t",
+			gk: schema.GroupKind{
+				Group: "apps",
+				Kind:  "ReplicaSet",
+			},
+			expected: "",
+			isError:  true,
+		},
+		// Error with empty GroupKind.
+		{
+			namespace: "test-namespace",
+			name:      "test-name",
+			gk:        schema.GroupKind{},
+			expected:  "",
+			isError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		inv, err := CreateObjMetadata(test.namespace, test.name, test.gk)
+		if !test.isError {
+			if err != nil {
+				t.Errorf("Error creating inventory when it should have worked.")
+			} else if test.expected != inv.String() {
+				t.Errorf("Expected inventory (%s) != created inventory(%s)\n", test.expected, inv.String())
+			}
+		}
+		if test.isError && err == nil {
+			t.Errorf("Should have returned an error in CreateObjMetadata()")
+		}
+	}
+}
+
+func TestObjMetadataEqual(t *testing.T) {
+	tests := []struct {
+		inventory1 *ObjMetadata
+		inventory2 *ObjMetadata
+		isEqual    bool
+	}{
+		// "Other" inventory is nil, then not equal.
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: nil,
+			isEqual:    false,
+		},
+		// Two equal inventories without a namespace
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			isEqual: true,
+		},
+		// Two equal inventories with a namespace
+		{
+			inventory1: &ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			isEqual: true,
+		},
+		// One inventory with a namespace, one without -- not equal.
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			isEqual: false,
+		},
+		// One inventory with a Deployment, one with a ReplicaSet -- not equal.
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "ReplicaSet",
+				},
+			},
+			isEqual: false,
+		},
+		// Normalized Deployment is the same.
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "extensions",
+					Kind:  "Deployment",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			isEqual: true,
+		},
+		// Normalized NetworkPolicy is the same
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "extensions",
+					Kind:  "NetworkPolicy",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "networking",
+					Kind:  "NetworkPolicy",
+				},
+			},
+			isEqual: true,
+		},
+		// Normalized PodSecurityPolicy is the same
+		{
+			inventory1: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "extensions",
+					Kind:  "PodSecurityPolicy",
+				},
+			},
+			inventory2: &ObjMetadata{
+				Name: "test-inv",
+				GroupKind: schema.GroupKind{
+					Group: "policy",
+					Kind:  "PodSecurityPolicy",
+				},
+			},
+			isEqual: true,
+		},
+	}
+
+	for _, test := range tests {
+		actual := test.inventory1.Equals(test.inventory2)
+		if test.isEqual && !actual {
+			t.Errorf("Expected inventories equal, but actual is not: (%s)/(%s)\n", test.inventory1, test.inventory2)
+		}
+	}
+}
+
+func TestParseObjMetadata(t *testing.T) {
+	tests := []struct {
+		invStr    string
+		inventory *ObjMetadata
+		isError   bool
+	}{
+		{
+			invStr: "_test-name_apps_ReplicaSet\t",
+			inventory: &ObjMetadata{
+				Name: "test-name",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "ReplicaSet",
+				},
+			},
+			isError: false,
+		},
+		{
+			invStr: "test-namespace_test-name_apps_Deployment",
+			inventory: &ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+				GroupKind: schema.GroupKind{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+			isError: false,
+		},
+		// Not enough fields -- error
+		{
+			invStr:    "_test-name_apps",
+			inventory: &ObjMetadata{},
+			isError:   true,
+		},
+		{
+			invStr: ".1__system.3Aauth-delegator_rbac.2Eauthorization.2Ek8s.2Eio_ClusterRole",
+			inventory: &ObjMetadata{
+				Name: "system:auth-delegator",
+				GroupKind: schema.GroupKind{
+					Group: "rbac.authorization.k8s.io",
+					Kind:  "ClusterRole",
+				},
+			},
+			isError: false,
+		},
+		// Incomplete escape sequence -- error
+		{
+			invStr:    ".1__system.3_rbac.2Eauthorization.2Ek8s.2Eio_ClusterRole",
+			inventory: &ObjMetadata{},
+			isError:   true,
+		},
+		// Unescaped character in version 1 -- error
+		{
+			invStr:    ".1__a:b__ClusterRole",
+			inventory: &ObjMetadata{},
+			isError:   true,
+		},
+		// Invalid namespace -- error
+		{
+			invStr:    "Test_test-name_apps_Deployment",
+			inventory: &ObjMetadata{},
+			isError:   true,
+		},
+		// Invalid kind -- error
+		{
+			invStr:    "test-namespace_test-name_apps_1Deployment",
+			inventory: &ObjMetadata{},
+			isError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseObjMetadata(test.invStr)
+		if !test.isError {
+			if err != nil {
+				t.Errorf("Error parsing inventory when it should have worked.")
+			} else if !test.inventory.Equals(actual) {
+				t.Errorf("Expected inventory (%s) != parsed inventory (%s)\n", test.inventory, actual)
+			}
+		}
+		if test.isError && err == nil {
+			t.Errorf("Should have returned an error in ParseObjMetadata()")
+		}
+	}
+}
+
+func TestRuntimeToObjMeta(t *testing.T) {
+	u := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "foo",
+				"namespace": "bar",
+			},
+		},
+	}
+	objMeta, err := RuntimeToObjMeta(u)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := ObjMetadata{
+		Namespace: "bar",
+		Name:      "foo",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+	if !expected.Equals(&objMeta) {
+		t.Errorf("expected %s, got %s", expected.String(), objMeta.String())
+	}
+}
+
+func TestFormatObjMetadata(t *testing.T) {
+	tests := map[string]struct {
+		obj      ObjMetadata
+		expected string
+		isError  bool
+	}{
+		"plain fields are not escaped": {
+			obj: ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test.name",
+				GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+			},
+			expected: "test-namespace_test.name_apps_Deployment",
+		},
+		"group is normalized": {
+			obj: ObjMetadata{
+				Namespace: "test-namespace",
+				Name:      "test-name",
+				GroupKind: schema.GroupKind{Group: "extensions", Kind: "Deployment"},
+			},
+			expected: "test-namespace_test-name_apps_Deployment",
+		},
+		"names with other characters are escaped": {
+			obj: ObjMetadata{
+				Name:      "system:auth-delegator",
+				GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+			},
+			expected: ".1__system.3Aauth-delegator_rbac.2Eauthorization.2Ek8s.2Eio_ClusterRole",
+		},
+		"underscores are escaped": {
+			obj: ObjMetadata{
+				Name:      "a_b",
+				GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+			},
+			expected: ".1__a.5Fb_rbac.2Eauthorization.2Ek8s.2Eio_ClusterRole",
+		},
+		"empty name": {
+			obj: ObjMetadata{
+				GroupKind: schema.GroupKind{Kind: "Pod"},
+			},
+			isError: true,
+		},
+		"name with a slash": {
+			obj: ObjMetadata{
+				Name:      "a/b",
+				GroupKind: schema.GroupKind{Kind: "Pod"},
+			},
+			isError: true,
+		},
+		"invalid group": {
+			obj: ObjMetadata{
+				Name:      "test-name",
+				GroupKind: schema.GroupKind{Group: "Apps_", Kind: "Deployment"},
+			},
+			isError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := FormatObjMetadata(tc.obj)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("expected an error, got %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// fieldAlphabet contains the characters the random fields are made of,
+// weighted towards the ones with a meaning in the encoding.
+const fieldAlphabet = "abcz09AZ-._:/% \t.1_é"
+
+// randomObjMetadata is an ObjMetadata with random fields,
+// for checking the encoding with testing/quick.
+type randomObjMetadata ObjMetadata
+
+func randomField(r *rand.Rand) string {
+	runes := []rune(fieldAlphabet)
+	b := make([]rune, r.Intn(8))
+	for i := range b {
+		b[i] = runes[r.Intn(len(runes))]
+	}
+	return string(b)
+}
+
+func (randomObjMetadata) Generate(r *rand.Rand, _ int) reflect.Value {
+	o := randomObjMetadata{
+		Namespace: randomField(r),
+		Name:      randomField(r),
+		GroupKind: schema.GroupKind{Group: randomField(r), Kind: randomField(r)},
+	}
+	// Make valid values likely enough to be checked often.
+	if r.Intn(2) == 0 {
+		o.Namespace = strings.ToLower(strings.Trim(o.Namespace, "-._:/% \té"))
+	}
+	if r.Intn(2) == 0 {
+		o.GroupKind.Group = ""
+	}
+	if r.Intn(2) == 0 {
+		o.GroupKind.Kind = "K" + strings.Trim(o.GroupKind.Kind, "-._:/% \té1")
+	}
+	if r.Intn(2) == 0 {
+		o.Name = strings.Trim(o.Name, "/% \t") + "n"
+	}
+	return reflect.ValueOf(o)
+}
+
+func TestObjMetadataRoundTrip(t *testing.T) {
+	config := &quick.Config{MaxCount: 20000}
+	// Every valid ObjMetadata is parsed back from its string.
+	roundTrip := func(r randomObjMetadata) bool {
+		o := ObjMetadata(r)
+		s, err := FormatObjMetadata(o)
+		if err != nil {
+			return ValidateObjMetadata(o) != nil
+		}
+		parsed, err := ParseObjMetadata(s)
+		if err != nil {
+			t.Logf("%q: %v", s, err)
+			return false
+		}
+		return *parsed == o
+	}
+	if err := quick.Check(roundTrip, config); err != nil {
+		t.Error(err)
+	}
+	// Every string that is parsed is formatted to a string that is
+	// parsed to the same ObjMetadata.
+	reparse := func(s string) bool {
+		parsed, err := ParseObjMetadata(s)
+		if err != nil {
+			return true
+		}
+		formatted, err := FormatObjMetadata(*parsed)
+		if err != nil {
+			return false
+		}
+		again, err := ParseObjMetadata(formatted)
+		return err == nil && *again == *parsed
+	}
+	if err := quick.Check(reparse, config); err != nil {
+		t.Error(err)
+	}
+	// Strings made of formatted fields are checked too, since
+	// random strings are rarely valid.
+	reparseFormatted := func(r randomObjMetadata) bool {
+		o := ObjMetadata(r)
+		return reparse(o.String())
+	}
+	if err := quick.Check(reparseFormatted, config); err != nil {
+		t.Error(err)
+	}
+}
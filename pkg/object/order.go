@@ -0,0 +1,85 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"sort"
+)
+
+// An attempt to order things to help k8s, e.g.
+// a Service should come before things that refer to it.
+// Namespace should be first.
+// In some cases order just specified to provide determinism.
+var orderFirst = []string{
+	"Namespace",
+	"ResourceQuota",
+	"StorageClass",
+	"CustomResourceDefinition",
+	"MutatingWebhookConfiguration",
+	"ServiceAccount",
+	"PodSecurityPolicy",
+	"Role",
+	"ClusterRole",
+	"RoleBinding",
+	"ClusterRoleBinding",
+	"ConfigMap",
+	"Secret",
+	"Service",
+	"LimitRange",
+	"PriorityClass",
+	"Deployment",
+	"StatefulSet",
+	"CronJob",
+	"PodDisruptionBudget",
+}
+
+var orderLast = []string{
+	"ValidatingWebhookConfiguration",
+}
+
+// KindIndex returns the index of the kind respecting the order. Kinds
+// that must be applied first have a negative index, kinds that must be
+// applied last have a positive index and all other kinds have index 0.
+func KindIndex(kind string) int {
+	m := map[string]int{}
+	for i, n := range orderFirst {
+		m[n] = -len(orderFirst) + i
+	}
+	for i, n := range orderLast {
+		m[n] = 1 + i
+	}
+	return m[kind]
+}
+
+// SortObjMetadata sorts the resources in the order they should be
+// applied, so the resources that others depend on come first. Ties
+// are broken by group, namespace and name so the order is stable.
+func SortObjMetadata(objs []*ObjMetadata) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return less(objs[i], objs[j])
+	})
+}
+
+// ReverseSortObjMetadata sorts the resources in the order they should
+// be deleted, which is the reverse of the order they are applied.
+func ReverseSortObjMetadata(objs []*ObjMetadata) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return less(objs[j], objs[i])
+	})
+}
+
+func less(x, o *ObjMetadata) bool {
+	indexX := KindIndex(x.GroupKind.Kind)
+	indexO := KindIndex(o.GroupKind.Kind)
+	if indexX != indexO {
+		return indexX < indexO
+	}
+	if x.GroupKind != o.GroupKind {
+		return x.GroupKind.String() < o.GroupKind.String()
+	}
+	if x.Namespace != o.Namespace {
+		return x.Namespace < o.Namespace
+	}
+	return x.Name < o.Name
+}
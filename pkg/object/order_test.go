@@ -0,0 +1,70 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSortObjMetadata(t *testing.T) {
+	deployment := &ObjMetadata{
+		Namespace: "default",
+		Name:      "app",
+		GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"},
+	}
+	namespace := &ObjMetadata{
+		Name:      "default",
+		GroupKind: schema.GroupKind{Kind: "Namespace"},
+	}
+	configMapA := &ObjMetadata{
+		Namespace: "default",
+		Name:      "a",
+		GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+	}
+	configMapB := &ObjMetadata{
+		Namespace: "default",
+		Name:      "b",
+		GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+	}
+	webhook := &ObjMetadata{
+		Name:      "hook",
+		GroupKind: schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	}
+	custom := &ObjMetadata{
+		Namespace: "default",
+		Name:      "custom",
+		GroupKind: schema.GroupKind{Group: "example.com", Kind: "Custom"},
+	}
+
+	objs := []*ObjMetadata{webhook, configMapB, custom, deployment, namespace, configMapA}
+	SortObjMetadata(objs)
+	expected := []*ObjMetadata{namespace, configMapA, configMapB, deployment, custom, webhook}
+	for i := range expected {
+		if objs[i] != expected[i] {
+			t.Errorf("expected %s at index %d, got %s", expected[i], i, objs[i])
+		}
+	}
+
+	ReverseSortObjMetadata(objs)
+	for i := range expected {
+		if objs[len(objs)-1-i] != expected[i] {
+			t.Errorf("expected %s at index %d, got %s", expected[i], len(objs)-1-i, objs[len(objs)-1-i])
+		}
+	}
+}
+
+func TestSortObjMetadataByNamespaceThenName(t *testing.T) {
+	// The concatenation of namespace and name is the same
+	// for both, so it can't be used to order them.
+	x := &ObjMetadata{Namespace: "a", Name: "bc", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	o := &ObjMetadata{Namespace: "ab", Name: "c", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	for _, objs := range [][]*ObjMetadata{{x, o}, {o, x}} {
+		SortObjMetadata(objs)
+		if objs[0] != x || objs[1] != o {
+			t.Errorf("expected %s before %s, got %s before %s", x, o, objs[0], objs[1])
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// RedactedValue replaces the value of sensitive fields in output.
+	RedactedValue = "***"
+	// The values used in diffs when a sensitive field has changed,
+	// so the diff still shows that something is different.
+	redactedBefore = "*** (before)"
+	redactedAfter  = "*** (after)"
+)
+
+// SensitiveField identifies a field in resources of a GroupKind whose
+// value must never be included in output. If the field is a map, the
+// keys are kept and only the values are redacted.
+type SensitiveField struct {
+	GroupKind schema.GroupKind
+	Path      []string
+}
+
+// DefaultSensitiveFields are the fields that are always redacted.
+var DefaultSensitiveFields = []SensitiveField{
+	{GroupKind: schema.GroupKind{Kind: "Secret"}, Path: []string{"data"}},
+	{GroupKind: schema.GroupKind{Kind: "Secret"}, Path: []string{"stringData"}},
+}
+
+// lastAppliedPath is the path to the annotation in which kubectl keeps
+// the applied configuration, including the values of the sensitive fields.
+var lastAppliedPath = []string{"metadata", "annotations", corev1.LastAppliedConfigAnnotation}
+
+// ParseSensitiveField parses a field in the format KIND[.GROUP]:PATH, where
+// PATH is the dot-separated path to the field, for example
+// "ConfigMap:data.password" or "Certificate.cert-manager.io:spec.keystores".
+func ParseSensitiveField(s string) (SensitiveField, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return SensitiveField{}, fmt.Errorf("sensitive field %q must be in the format KIND[.GROUP]:PATH", s)
+	}
+	path := strings.Split(parts[1], ".")
+	for _, p := range path {
+		if p == "" {
+			return SensitiveField{}, fmt.Errorf("sensitive field %q has an empty path element", s)
+		}
+	}
+	return SensitiveField{
+		GroupKind: schema.ParseGroupKind(parts[0]),
+		Path:      path,
+	}, nil
+}
+
+// Redact returns a copy of the object with the value of all the
+// sensitive fields and of the last-applied-configuration annotation
+// replaced by RedactedValue. The object itself is
+// not modified. If the object has none of the fields, the object is
+// returned as-is. Only the maps on the paths of the fields are copied,
+// the rest of the content is shared with the object, so the returned
+// object must not be modified.
+func Redact(obj runtime.Object, fields []SensitiveField) runtime.Object {
+	if obj == nil {
+		return nil
+	}
+	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
+	if !hasSensitiveFields(gk, fields) {
+		return obj
+	}
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = copyPaths(gk, u.UnstructuredContent(), fields)
+	} else {
+		var err error
+		content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			// We can't tell which fields are sensitive, so
+			// don't return anything rather than too much.
+			return nil
+		}
+	}
+	RedactMap(gk, content, fields)
+	return &unstructured.Unstructured{Object: content}
+}
+
+// copyPaths returns a shallow copy of the content in which the maps on
+// the paths of the sensitive fields are copied as well, so the fields
+// can be redacted without changing the content. The paths of fields
+// the content doesn't have are not copied.
+func copyPaths(gk schema.GroupKind, content map[string]interface{}, fields []SensitiveField) map[string]interface{} {
+	copied := shallowCopy(content)
+	for _, f := range sensitiveFields(gk, fields) {
+		if _, found, _ := unstructured.NestedFieldNoCopy(content, f.Path...); !found {
+			continue
+		}
+		m := copied
+		for _, p := range f.Path {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				break
+			}
+			next = shallowCopy(next)
+			m[p] = next
+			m = next
+		}
+	}
+	return copied
+}
+
+func shallowCopy(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// RedactMap replaces the value of all the sensitive fields in the
+// content of a resource of the given GroupKind in place. If the
+// GroupKind has sensitive fields, the last-applied-configuration
+// annotation is replaced as well, since it has a copy of them.
+func RedactMap(gk schema.GroupKind, content map[string]interface{}, fields []SensitiveField) {
+	for _, f := range sensitiveFields(gk, fields) {
+		redactPath(content, f.Path, func(key string, m map[string]interface{}) {
+			m[key] = RedactedValue
+		})
+	}
+}
+
+// RedactDiff redacts the sensitive fields in both the live and the local
+// content of a resource so they can be diffed. Values that are equal
+// are replaced with the same placeholder, while values that differ get
+// different placeholders so the diff still shows that they changed.
+// Either map can be nil.
+func RedactDiff(gk schema.GroupKind, live, local map[string]interface{}, fields []SensitiveField) {
+	for _, f := range sensitiveFields(gk, fields) {
+		redactPath(live, f.Path, func(key string, m map[string]interface{}) {
+			localValue, found := lookupPath(local, f.Path, key)
+			if found && reflect.DeepEqual(m[key], localValue) {
+				m[key] = RedactedValue
+			} else {
+				m[key] = redactedBefore
+			}
+		})
+		redactPath(local, f.Path, func(key string, m map[string]interface{}) {
+			// Values in live have been redacted already, so the
+			// placeholder tells us if the values were equal.
+			liveValue, found := lookupPath(live, f.Path, key)
+			if found && liveValue == RedactedValue {
+				m[key] = RedactedValue
+			} else {
+				m[key] = redactedAfter
+			}
+		})
+	}
+}
+
+// redactPath calls redact for the field at the given path. If the field
+// is a map, redact is called for every key in the map instead.
+func redactPath(content map[string]interface{}, path []string, redact func(string, map[string]interface{})) {
+	m := content
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	last := path[len(path)-1]
+	value, found := m[last]
+	if !found {
+		return
+	}
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		for key := range valueMap {
+			redact(key, valueMap)
+		}
+		return
+	}
+	redact(last, m)
+}
+
+// lookupPath returns the value that redactPath would pass to redact
+// for the given key.
+func lookupPath(content map[string]interface{}, path []string, key string) (interface{}, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(content, path...)
+	if err != nil || !found {
+		return nil, false
+	}
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		v, found := valueMap[key]
+		return v, found
+	}
+	return value, true
+}
+
+// sensitiveFields returns the sensitive fields of the GroupKind. If
+// there are any, the last-applied-configuration annotation is added.
+func sensitiveFields(gk schema.GroupKind, fields []SensitiveField) []SensitiveField {
+	var result []SensitiveField
+	for _, f := range fields {
+		if f.GroupKind == gk {
+			result = append(result, f)
+		}
+	}
+	if len(result) > 0 {
+		result = append(result, SensitiveField{GroupKind: gk, Path: lastAppliedPath})
+	}
+	return result
+}
+
+func hasSensitiveFields(gk schema.GroupKind, fields []SensitiveField) bool {
+	for _, f := range fields {
+		if f.GroupKind == gk {
+			return true
+		}
+	}
+	return false
+}
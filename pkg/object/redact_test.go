@@ -0,0 +1,187 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var secretGK = schema.GroupKind{Kind: "Secret"}
+
+func TestParseSensitiveField(t *testing.T) {
+	tests := map[string]struct {
+		field    string
+		expected SensitiveField
+		isError  bool
+	}{
+		"core kind": {
+			field: "ConfigMap:data.password",
+			expected: SensitiveField{
+				GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+				Path:      []string{"data", "password"},
+			},
+		},
+		"kind with group": {
+			field: "Certificate.cert-manager.io:spec.keystores",
+			expected: SensitiveField{
+				GroupKind: schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"},
+				Path:      []string{"spec", "keystores"},
+			},
+		},
+		"missing path": {
+			field:   "ConfigMap",
+			isError: true,
+		},
+		"empty path element": {
+			field:   "ConfigMap:data..password",
+			isError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseSensitiveField(tc.field)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("expected error parsing %q", tc.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	secret := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name": "foo",
+			},
+			"data": map[string]interface{}{
+				"password": "c2VjcmV0",
+			},
+		},
+	}
+
+	redacted := Redact(secret, DefaultSensitiveFields).(*unstructured.Unstructured)
+	value, _, _ := unstructured.NestedString(redacted.Object, "data", "password")
+	if value != RedactedValue {
+		t.Errorf("expected redacted value, got %q", value)
+	}
+	// The original object must not be modified.
+	value, _, _ = unstructured.NestedString(secret.Object, "data", "password")
+	if value != "c2VjcmV0" {
+		t.Errorf("original object was modified, got %q", value)
+	}
+	// Only the sensitive fields are copied.
+	if reflect.ValueOf(redacted.Object["metadata"]).Pointer() != reflect.ValueOf(secret.Object["metadata"]).Pointer() {
+		t.Errorf("expected the metadata to be shared with the original object")
+	}
+
+	configMap := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+		},
+	}
+	if Redact(configMap, DefaultSensitiveFields) != configMap {
+		t.Errorf("expected object without sensitive fields to be returned as-is")
+	}
+}
+
+func TestRedactDiff(t *testing.T) {
+	live := map[string]interface{}{
+		"data": map[string]interface{}{
+			"same":    "YQ==",
+			"changed": "Yg==",
+			"removed": "Yw==",
+		},
+	}
+	local := map[string]interface{}{
+		"data": map[string]interface{}{
+			"same":    "YQ==",
+			"changed": "ZA==",
+			"added":   "ZQ==",
+		},
+	}
+
+	RedactDiff(secretGK, live, local, DefaultSensitiveFields)
+
+	expectedLive := map[string]interface{}{
+		"same":    RedactedValue,
+		"changed": redactedBefore,
+		"removed": redactedBefore,
+	}
+	expectedLocal := map[string]interface{}{
+		"same":    RedactedValue,
+		"changed": redactedAfter,
+		"added":   redactedAfter,
+	}
+	if !reflect.DeepEqual(expectedLive, live["data"]) {
+		t.Errorf("expected live %v, got %v", expectedLive, live["data"])
+	}
+	if !reflect.DeepEqual(expectedLocal, local["data"]) {
+		t.Errorf("expected local %v, got %v", expectedLocal, local["data"])
+	}
+
+	// The resource doesn't exist in the cluster yet.
+	local = map[string]interface{}{
+		"stringData": map[string]interface{}{
+			"password": "secret",
+		},
+	}
+	RedactDiff(secretGK, nil, local, DefaultSensitiveFields)
+	if value, _, _ := unstructured.NestedString(local, "stringData", "password"); value != redactedAfter {
+		t.Errorf("expected %q, got %q", redactedAfter, value)
+	}
+}
+
+func TestRedactLastAppliedConfiguration(t *testing.T) {
+	lastApplied := `{"apiVersion":"v1","data":{"password":"c2VjcmV0"},"kind":"Secret"}`
+	secret := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name": "foo",
+				"annotations": map[string]interface{}{
+					"kubectl.kubernetes.io/last-applied-configuration": lastApplied,
+					"owner": "team-a",
+				},
+			},
+		},
+	}
+
+	redacted := Redact(secret, DefaultSensitiveFields).(*unstructured.Unstructured)
+	expected := map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": RedactedValue,
+		"owner": "team-a",
+	}
+	if !reflect.DeepEqual(expected, redacted.GetAnnotations()) {
+		t.Errorf("expected annotations %v, got %v", expected, redacted.GetAnnotations())
+	}
+	// The original object must not be modified.
+	if value := secret.GetAnnotations()["kubectl.kubernetes.io/last-applied-configuration"]; value != lastApplied {
+		t.Errorf("original object was modified, got %q", value)
+	}
+
+	// Resources without sensitive fields keep the annotation.
+	configMap := secret.DeepCopy()
+	configMap.SetKind("ConfigMap")
+	if Redact(configMap, DefaultSensitiveFields) != configMap {
+		t.Errorf("expected object without sensitive fields to be returned as-is")
+	}
+}